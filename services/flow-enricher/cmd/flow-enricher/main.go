@@ -6,14 +6,18 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	"github.com/rhwendt/helios/services/flow-enricher/internal/enricher"
 	flowkafka "github.com/rhwendt/helios/services/flow-enricher/internal/kafka"
+	"github.com/rhwendt/helios/services/flow-enricher/internal/metrics"
 	flowpb "github.com/rhwendt/helios/services/flow-enricher/internal/proto"
 )
 
@@ -33,31 +37,111 @@ func main() {
 	consumerTopic := envOrDefault("KAFKA_CONSUMER_TOPIC", "helios-flows-raw")
 	consumerGroup := envOrDefault("KAFKA_CONSUMER_GROUP", "flow-enricher")
 	producerTopic := envOrDefault("KAFKA_PRODUCER_TOPIC", "helios-flows-enriched")
+	producerKeyField := envOrDefault("KAFKA_PRODUCER_KEY_FIELD", flowkafka.KeyExporterIP)
+	deadLetterTopic := envOrDefault("KAFKA_DLQ_TOPIC", "")
+	kafkaSecurity := flowkafka.SecurityConfig{
+		SecurityProtocol: envOrDefault("KAFKA_SECURITY_PROTOCOL", ""),
+		SASLMechanism:    envOrDefault("KAFKA_SASL_MECHANISM", ""),
+		SASLUsername:     envOrDefault("KAFKA_SASL_USERNAME", ""),
+		SASLPassword:     envOrDefault("KAFKA_SASL_PASSWORD", ""),
+		SSLCALocation:    envOrDefault("KAFKA_SSL_CA_LOCATION", ""),
+	}
 	netboxURL := envOrDefault("NETBOX_API_URL", "")
 	netboxToken := envOrDefault("NETBOX_API_TOKEN", "")
+	netboxDeviceFilter := envOrDefault("NETBOX_DEVICE_FILTER", enricher.DefaultDeviceFilter)
+	netboxDeviceStatus := envOrDefault("NETBOX_DEVICE_STATUS", enricher.DefaultDeviceStatus)
 	geoipCityDB := envOrDefault("GEOIP_CITY_DB", "/var/lib/geoip/GeoLite2-City.mmdb")
 	geoipASNDB := envOrDefault("GEOIP_ASN_DB", "/var/lib/geoip/GeoLite2-ASN.mmdb")
+	geoipLocales := strings.Split(envOrDefault("GEOIP_LOCALES", "en"), ",")
+	geoipReloadInterval, err := time.ParseDuration(envOrDefault("GEOIP_RELOAD_INTERVAL", "0"))
+	if err != nil {
+		logger.Error("invalid GEOIP_RELOAD_INTERVAL", "error", err)
+		os.Exit(1)
+	}
 	metricsAddr := envOrDefault("METRICS_ADDR", ":8080")
+	serviceNames, err := strconv.ParseBool(envOrDefault("ENRICH_SERVICE_NAMES", "false"))
+	if err != nil {
+		logger.Error("invalid ENRICH_SERVICE_NAMES", "error", err)
+		os.Exit(1)
+	}
+	netboxConcurrency, err := strconv.Atoi(envOrDefault("NETBOX_INTERFACE_CONCURRENCY", strconv.Itoa(enricher.DefaultInterfaceConcurrency)))
+	if err != nil {
+		logger.Error("invalid NETBOX_INTERFACE_CONCURRENCY", "error", err)
+		os.Exit(1)
+	}
+	netboxTimeout, err := time.ParseDuration(envOrDefault("NETBOX_TIMEOUT", enricher.DefaultNetBoxTimeout.String()))
+	if err != nil {
+		logger.Error("invalid NETBOX_TIMEOUT", "error", err)
+		os.Exit(1)
+	}
+	netboxMaxIdleConns, err := strconv.Atoi(envOrDefault("NETBOX_MAX_IDLE_CONNS_PER_HOST", strconv.Itoa(enricher.DefaultNetBoxMaxIdleConnsPerHost)))
+	if err != nil {
+		logger.Error("invalid NETBOX_MAX_IDLE_CONNS_PER_HOST", "error", err)
+		os.Exit(1)
+	}
+	netboxInsecure, err := strconv.ParseBool(envOrDefault("NETBOX_TLS_INSECURE_SKIP_VERIFY", "false"))
+	if err != nil {
+		logger.Error("invalid NETBOX_TLS_INSECURE_SKIP_VERIFY", "error", err)
+		os.Exit(1)
+	}
+	if netboxInsecure {
+		logger.Warn("NetBox TLS certificate verification is disabled")
+	}
+	netboxClient, err := enricher.NewNetBoxHTTPClient(enricher.NetBoxHTTPConfig{
+		Timeout:             netboxTimeout,
+		MaxIdleConnsPerHost: netboxMaxIdleConns,
+		CAFile:              envOrDefault("NETBOX_CA_CERT", ""),
+		InsecureSkipVerify:  netboxInsecure,
+	})
+	if err != nil {
+		logger.Error("failed to configure NetBox HTTP client", "error", err)
+		os.Exit(1)
+	}
+
+	enrichWorkers, err := strconv.Atoi(envOrDefault("ENRICH_WORKERS", "0"))
+	if err != nil {
+		logger.Error("invalid ENRICH_WORKERS", "error", err)
+		os.Exit(1)
+	}
+	maxInFlight, err := strconv.Atoi(envOrDefault("KAFKA_MAX_IN_FLIGHT_BATCHES", "1"))
+	if err != nil {
+		logger.Error("invalid KAFKA_MAX_IN_FLIGHT_BATCHES", "error", err)
+		os.Exit(1)
+	}
+	debugEndpoint, err := strconv.ParseBool(envOrDefault("ENABLE_DEBUG_ENDPOINT", "false"))
+	if err != nil {
+		logger.Error("invalid ENABLE_DEBUG_ENDPOINT", "error", err)
+		os.Exit(1)
+	}
+
+	metrics.Register(prometheus.DefaultRegisterer)
 
 	// Initialize NetBox cache
-	netboxCache := enricher.NewNetBoxCache(netboxURL, netboxToken, 5*time.Minute, logger)
+	netboxCache := enricher.NewNetBoxCache(netboxURL, netboxToken, 5*time.Minute, logger,
+		enricher.WithInterfaceConcurrency(netboxConcurrency),
+		enricher.WithDeviceFilter(netboxDeviceFilter),
+		enricher.WithDeviceStatus(netboxDeviceStatus),
+		enricher.WithHTTPClient(netboxClient))
 
 	// Initialize GeoIP reader
 	var geoipReader *enricher.GeoIPReader
-	var err error
-	geoipReader, err = enricher.NewGeoIPReader(geoipCityDB, geoipASNDB, logger)
+	geoipReader, err = enricher.NewGeoIPReader(geoipCityDB, geoipASNDB, logger, enricher.WithLocales(geoipLocales...))
 	if err != nil {
 		logger.Warn("GeoIP databases not available, continuing without GeoIP enrichment", "error", err)
 		geoipReader = nil
 	}
 
 	// Initialize enricher
-	e := enricher.New(netboxCache, geoipReader, logger)
+	e := enricher.New(netboxCache, geoipReader, logger,
+		enricher.WithServiceNames(serviceNames),
+		enricher.WithWorkers(enrichWorkers))
 
 	// Initialize Kafka producer
 	producer, err := flowkafka.NewProducer(flowkafka.ProducerConfig{
-		Brokers: kafkaBrokers,
-		Topic:   producerTopic,
+		Brokers:        kafkaBrokers,
+		Topic:          producerTopic,
+		KeyField:       producerKeyField,
+		SecurityConfig: kafkaSecurity,
 	}, logger)
 	if err != nil {
 		logger.Error("failed to create Kafka producer", "error", err)
@@ -67,19 +151,34 @@ func main() {
 
 	// Message handler: enrich and produce
 	handler := func(ctx context.Context, flows []*flowpb.EnrichedFlow) error {
-		for _, flow := range flows {
-			e.Enrich(flow)
-		}
+		e.EnrichBatch(flows)
 		return producer.ProduceBatch(ctx, flows)
 	}
 
+	// Initialize optional dead-letter producer for unparseable messages
+	consumerOpts := []flowkafka.ConsumerOption{flowkafka.WithMaxInFlight(maxInFlight)}
+	if deadLetterTopic != "" {
+		deadLetter, err := flowkafka.NewDeadLetterProducer(flowkafka.ProducerConfig{
+			Brokers:        kafkaBrokers,
+			Topic:          deadLetterTopic,
+			SecurityConfig: kafkaSecurity,
+		}, logger)
+		if err != nil {
+			logger.Error("failed to create Kafka dead-letter producer", "error", err)
+			os.Exit(1)
+		}
+		defer deadLetter.Close()
+		consumerOpts = append(consumerOpts, flowkafka.WithDeadLetter(deadLetter))
+	}
+
 	// Initialize Kafka consumer
 	consumer, err := flowkafka.NewConsumer(flowkafka.ConsumerConfig{
-		Brokers:   kafkaBrokers,
-		GroupID:   consumerGroup,
-		Topic:     consumerTopic,
-		BatchSize: 100,
-	}, handler, logger)
+		Brokers:        kafkaBrokers,
+		GroupID:        consumerGroup,
+		Topic:          consumerTopic,
+		BatchSize:      100,
+		SecurityConfig: kafkaSecurity,
+	}, handler, logger, consumerOpts...)
 	if err != nil {
 		logger.Error("failed to create Kafka consumer", "error", err)
 		os.Exit(1)
@@ -92,6 +191,15 @@ func main() {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("ok"))
 	})
+	// Readiness waits for the first NetBox refresh, unless NetBox is not configured.
+	var readyCache *enricher.NetBoxCache
+	if netboxURL != "" {
+		readyCache = netboxCache
+	}
+	mux.Handle("/readyz", enricher.ReadyHandler(readyCache))
+	if debugEndpoint {
+		mux.Handle("/debug/enrich", e.DebugHandler())
+	}
 	server := &http.Server{Addr: metricsAddr, Handler: mux}
 
 	var wg sync.WaitGroup
@@ -106,6 +214,15 @@ func main() {
 		}
 	}()
 
+	// Reload GeoIP databases on SIGHUP and, if configured, periodically
+	if geoipReader != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reloadGeoIP(ctx, geoipReader, geoipReloadInterval, logger)
+		}()
+	}
+
 	// Start NetBox cache refresh
 	wg.Add(1)
 	go func() {
@@ -121,6 +238,8 @@ func main() {
 		defer wg.Done()
 		if err := consumer.Start(ctx); err != nil && err != context.Canceled {
 			logger.Error("Kafka consumer error", "error", err)
+			// Shut down so the pod is restarted rather than idling without a consumer.
+			cancel()
 		}
 	}()
 
@@ -133,14 +252,41 @@ func main() {
 	defer shutdownCancel()
 	server.Shutdown(shutdownCtx)
 
+	wg.Wait()
+
 	if geoipReader != nil {
 		geoipReader.Close()
 	}
-
-	wg.Wait()
 	logger.Info("shutdown complete")
 }
 
+// reloadGeoIP reloads the GeoIP databases whenever the process receives
+// SIGHUP, and every interval if it is positive, until ctx is cancelled.
+func reloadGeoIP(ctx context.Context, r *enricher.GeoIPReader, interval time.Duration, logger *slog.Logger) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+		case <-tick:
+		}
+		if err := r.Reload(); err != nil {
+			logger.Error("GeoIP reload failed, keeping current databases", "error", err)
+		}
+	}
+}
+
 func envOrDefault(key, defaultValue string) string {
 	if v := os.Getenv(key); v != "" {
 		return v
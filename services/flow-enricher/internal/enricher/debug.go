@@ -0,0 +1,40 @@
+package enricher
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+)
+
+// debugResponse is the body returned by DebugHandler.
+type debugResponse struct {
+	IP     string          `json:"ip"`
+	Device *DeviceMetadata `json:"device,omitempty"`
+	GeoIP  GeoIPResult     `json:"geoip"`
+}
+
+// DebugHandler serves GET /debug/enrich?ip=..., reporting the NetBox device
+// and GeoIP data the Enricher holds for an address as JSON.
+func (e *Enricher) DebugHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		ip := net.ParseIP(r.URL.Query().Get("ip"))
+		if ip == nil {
+			http.Error(w, "ip query parameter must be an IPv4 or IPv6 address", http.StatusBadRequest)
+			return
+		}
+
+		device, geo, ok := e.LookupIP(ip)
+		resp := debugResponse{IP: ip.String(), GeoIP: geo}
+		if ok {
+			resp.Device = &device
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			e.logger.Debug("failed to write debug response", "error", err)
+		}
+	})
+}
@@ -0,0 +1,65 @@
+package enricher
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDebugHandler(t *testing.T) {
+	cache := newPopulatedCache(map[string]DeviceMetadata{
+		"10.0.0.1": {Name: "router1", Site: "dc1", Role: "core", Interfaces: map[uint32]InterfaceMetadata{
+			1: {Name: "Ethernet1", Speed: 10000000000},
+		}},
+	})
+	e := New(cache, nil, newTestLogger())
+	e.geoip = &mockGeoIPReader{results: map[string]GeoIPResult{
+		"8.8.8.8": {Country: "US", ASNum: 15169, ASName: "GOOGLE"},
+	}}
+	handler := e.DebugHandler()
+
+	t.Run("known device", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/enrich?ip=10.0.0.1", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+		}
+
+		var resp debugResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if resp.IP != "10.0.0.1" || resp.Device == nil || resp.Device.Name != "router1" {
+			t.Fatalf("response = %+v, want router1", resp)
+		}
+		if resp.Device.Interfaces[1].Name != "Ethernet1" {
+			t.Errorf("interfaces = %+v, want Ethernet1 at index 1", resp.Device.Interfaces)
+		}
+	})
+
+	t.Run("external address", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/enrich?ip=8.8.8.8", nil))
+
+		var body map[string]interface{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if _, ok := body["device"]; ok {
+			t.Errorf("device = %v, want it omitted", body["device"])
+		}
+		geo, _ := body["geoip"].(map[string]interface{})
+		if geo["country"] != "US" || geo["as_name"] != "GOOGLE" {
+			t.Errorf("geoip = %v, want US GOOGLE", geo)
+		}
+	})
+
+	for _, target := range []string{"/debug/enrich", "/debug/enrich?ip=router1"} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", target, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package enricher
+
+import "strconv"
+
+// dscpClasses maps each 6-bit DSCP value to its per-hop behaviour name as
+// defined in RFC 2474, RFC 2597 and RFC 3246. Values without a standard
+// name map to their decimal string.
+var dscpClasses = func() [64]string {
+	var classes [64]string
+	for i := range classes {
+		classes[i] = strconv.Itoa(i)
+	}
+	for cs := 0; cs < 8; cs++ {
+		classes[cs<<3] = "CS" + strconv.Itoa(cs)
+	}
+	// AFxy: class x (1-4), drop precedence y (1-3), DSCP = 8x + 2y.
+	for x := 1; x <= 4; x++ {
+		for y := 1; y <= 3; y++ {
+			classes[8*x+2*y] = "AF" + strconv.Itoa(x) + strconv.Itoa(y)
+		}
+	}
+	classes[0] = "default"
+	classes[44] = "VOICE-ADMIT"
+	classes[46] = "EF"
+	return classes
+}()
+
+// dscpClass returns the QoS class name for the DSCP bits of a ToS byte.
+func dscpClass(tos uint32) string {
+	return dscpClasses[(tos>>2)&0x3f]
+}
@@ -0,0 +1,62 @@
+package enricher
+
+import (
+	"testing"
+
+	flowpb "github.com/rhwendt/helios/services/flow-enricher/internal/proto"
+)
+
+func TestDSCPClass(t *testing.T) {
+	tests := []struct {
+		dscp uint32
+		want string
+	}{
+		{0, "default"},
+		{8, "CS1"},
+		{10, "AF11"},
+		{12, "AF12"},
+		{14, "AF13"},
+		{18, "AF21"},
+		{26, "AF31"},
+		{34, "AF41"},
+		{38, "AF43"},
+		{40, "CS5"},
+		{44, "VOICE-ADMIT"},
+		{46, "EF"},
+		{48, "CS6"},
+		{56, "CS7"},
+		{1, "1"},
+		{63, "63"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.want, func(t *testing.T) {
+			// DSCP occupies the upper six bits of the ToS byte; the ECN bits
+			// must not affect the class.
+			tos := tc.dscp<<2 | 0x3
+			if got := dscpClass(tos); got != tc.want {
+				t.Errorf("dscpClass(%#x) = %q, want %q", tos, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDSCPClass_NoAllocation(t *testing.T) {
+	allocs := testing.AllocsPerRun(100, func() {
+		_ = dscpClass(46 << 2)
+		_ = dscpClass(0xff)
+	})
+	if allocs != 0 {
+		t.Errorf("dscpClass allocated %v times per run, want 0", allocs)
+	}
+}
+
+func TestEnrichFlow_DSCPClass(t *testing.T) {
+	e := New(newPopulatedCache(map[string]DeviceMetadata{}), nil, newTestLogger())
+
+	result := e.Enrich(&flowpb.EnrichedFlow{Tos: 0xb8}) // DSCP 46
+
+	if result.DscpClass != "EF" {
+		t.Errorf("DscpClass = %q, want EF", result.DscpClass)
+	}
+}
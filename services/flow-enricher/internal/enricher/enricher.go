@@ -2,37 +2,128 @@ package enricher
 
 import (
 	"log/slog"
+	"math"
+	"math/bits"
 	"net"
+	"runtime"
+	"sync"
 
 	flowpb "github.com/rhwendt/helios/services/flow-enricher/internal/proto"
 )
 
-// Enricher applies NetBox metadata and GeoIP data to raw flow records.
+// geoIPLookup is the subset of GeoIPReader used by the Enricher.
+type geoIPLookup interface {
+	Lookup(ip net.IP) GeoIPResult
+}
+
+// Enricher applies NetBox metadata and GeoIP data to raw flow records. It is
+// safe for concurrent use: the NetBox cache and GeoIP reader are only read
+// under their own locks, and each call mutates only the flow it is given.
 type Enricher struct {
-	netbox *NetBoxCache
-	geoip  *GeoIPReader
-	logger *slog.Logger
+	netbox       *NetBoxCache
+	geoip        geoIPLookup
+	serviceNames bool
+	workers      int
+	logger       *slog.Logger
+}
+
+// Option configures optional Enricher behaviour.
+type Option func(*Enricher)
+
+// WithServiceNames enables labelling flows with the well-known service name
+// of their server-side port. It is off by default as it costs two map
+// lookups per flow.
+func WithServiceNames(enabled bool) Option {
+	return func(e *Enricher) {
+		e.serviceNames = enabled
+	}
+}
+
+// WithWorkers sets how many goroutines EnrichBatch uses. Values below one are
+// ignored; the default is GOMAXPROCS.
+func WithWorkers(n int) Option {
+	return func(e *Enricher) {
+		if n > 0 {
+			e.workers = n
+		}
+	}
 }
 
 // New creates a new Enricher with the given dependencies.
-func New(netbox *NetBoxCache, geoip *GeoIPReader, logger *slog.Logger) *Enricher {
-	return &Enricher{
-		netbox: netbox,
-		geoip:  geoip,
-		logger: logger,
+func New(netbox *NetBoxCache, geoip *GeoIPReader, logger *slog.Logger, opts ...Option) *Enricher {
+	e := &Enricher{
+		netbox:  netbox,
+		workers: runtime.GOMAXPROCS(0),
+		logger:  logger,
+	}
+	// Avoid storing a typed nil, which would defeat the nil check in applyGeoIP.
+	if geoip != nil {
+		e.geoip = geoip
 	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
 }
 
 // Enrich takes a raw flow protobuf and applies NetBox metadata and GeoIP enrichment.
 func (e *Enricher) Enrich(flow *flowpb.EnrichedFlow) *flowpb.EnrichedFlow {
 	e.applyNetBoxMetadata(flow)
+	e.applyEndpointMetadata(flow)
 	e.applyGeoIP(flow)
+	flow.ProtoName = protocolName(flow.Protocol)
+	flow.DscpClass = dscpClass(flow.Tos)
+	flow.IcmpTypeName = icmpTypeName(flow.Protocol, flow.IcmpType)
+	applySampling(flow)
+	if e.serviceNames {
+		e.applyServiceNames(flow)
+	}
 	return flow
 }
 
+// LookupIP returns everything the Enricher knows about ip: the NetBox device
+// it belongs to and its GeoIP data. The bool reports whether a device was
+// found; the GeoIP result is empty when no GeoIP databases are loaded. It is
+// meant for ad-hoc troubleshooting rather than the flow path.
+func (e *Enricher) LookupIP(ip net.IP) (DeviceMetadata, GeoIPResult, bool) {
+	device, ok := e.netbox.LookupByIP(ip)
+	var geo GeoIPResult
+	if e.geoip != nil {
+		geo = e.geoip.Lookup(ip)
+	}
+	return device, geo, ok
+}
+
+// EnrichBatch enriches flows in place, spreading them across the configured
+// number of workers.
+func (e *Enricher) EnrichBatch(flows []*flowpb.EnrichedFlow) {
+	workers := min(e.workers, len(flows))
+	if workers <= 1 {
+		for _, flow := range flows {
+			e.Enrich(flow)
+		}
+		return
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(start int) {
+			defer wg.Done()
+			for i := start; i < len(flows); i += workers {
+				e.Enrich(flows[i])
+			}
+		}(w)
+	}
+	wg.Wait()
+}
+
 // applyNetBoxMetadata enriches the flow with device and interface metadata from NetBox.
 func (e *Enricher) applyNetBoxMetadata(flow *flowpb.EnrichedFlow) {
-	exporterIP := uint32ToIP(flow.ExporterIp)
+	exporterIP := exporterAddr(flow)
+	if exporterIP == nil {
+		return
+	}
 	device, ok := e.netbox.LookupByIP(exporterIP)
 	if !ok {
 		e.logger.Debug("no NetBox metadata for exporter", "ip", exporterIP)
@@ -54,28 +145,73 @@ func (e *Enricher) applyNetBoxMetadata(flow *flowpb.EnrichedFlow) {
 	}
 }
 
+// applyEndpointMetadata labels the flow's source and destination with the
+// NetBox device they belong to, or failing that with the site of the most
+// specific NetBox prefix containing them. Unknown addresses are left as is.
+func (e *Enricher) applyEndpointMetadata(flow *flowpb.EnrichedFlow) {
+	if srcIP := flowIP(flow.SrcIp); srcIP != nil {
+		if device, ok := e.netbox.LookupByIP(srcIP); ok {
+			flow.SrcDeviceName = device.Name
+			flow.SrcSite = device.Site
+		} else if prefix, ok := e.netbox.LookupByPrefix(srcIP); ok {
+			flow.SrcSite = prefix.Site
+		}
+	}
+	if dstIP := flowIP(flow.DstIp); dstIP != nil {
+		if device, ok := e.netbox.LookupByIP(dstIP); ok {
+			flow.DstDeviceName = device.Name
+			flow.DstSite = device.Site
+		} else if prefix, ok := e.netbox.LookupByPrefix(dstIP); ok {
+			flow.DstSite = prefix.Site
+		}
+	}
+}
+
+// applySampling sets the scaled counters to the raw counters multiplied by
+// the sampling rate. A rate of 0 means unknown and leaves them unscaled. The
+// raw counters are not modified.
+func applySampling(flow *flowpb.EnrichedFlow) {
+	rate := uint64(flow.SamplingRate)
+	if rate == 0 {
+		rate = 1
+	}
+	flow.BytesScaled = scaleCounter(flow.Bytes, rate)
+	flow.PacketsScaled = scaleCounter(flow.Packets, rate)
+}
+
+// scaleCounter returns value*rate, saturating at the maximum uint64.
+func scaleCounter(value, rate uint64) uint64 {
+	hi, lo := bits.Mul64(value, rate)
+	if hi != 0 {
+		return math.MaxUint64
+	}
+	return lo
+}
+
 // applyGeoIP enriches the flow with GeoIP country/city/ASN data.
 func (e *Enricher) applyGeoIP(flow *flowpb.EnrichedFlow) {
 	if e.geoip == nil {
 		return
 	}
 
-	if len(flow.SrcIp) > 0 {
-		srcIP := net.IP(flow.SrcIp)
+	if srcIP := flowIP(flow.SrcIp); srcIP != nil {
 		srcResult := e.geoip.Lookup(srcIP)
 		flow.SrcCountry = srcResult.Country
 		flow.SrcCity = srcResult.City
+		flow.SrcSubdivision = srcResult.Subdivision
+		flow.SrcContinent = srcResult.Continent
 		flow.SrcAsName = srcResult.ASName
 		if flow.SrcAs == 0 {
 			flow.SrcAs = srcResult.ASNum
 		}
 	}
 
-	if len(flow.DstIp) > 0 {
-		dstIP := net.IP(flow.DstIp)
+	if dstIP := flowIP(flow.DstIp); dstIP != nil {
 		dstResult := e.geoip.Lookup(dstIP)
 		flow.DstCountry = dstResult.Country
 		flow.DstCity = dstResult.City
+		flow.DstSubdivision = dstResult.Subdivision
+		flow.DstContinent = dstResult.Continent
 		flow.DstAsName = dstResult.ASName
 		if flow.DstAs == 0 {
 			flow.DstAs = dstResult.ASNum
@@ -83,6 +219,28 @@ func (e *Enricher) applyGeoIP(flow *flowpb.EnrichedFlow) {
 	}
 }
 
+// flowIP converts a 4-byte IPv4 or 16-byte IPv6 address field to net.IP.
+// It returns nil for any other length.
+func flowIP(b []byte) net.IP {
+	if len(b) != net.IPv4len && len(b) != net.IPv6len {
+		return nil
+	}
+	return net.IP(b)
+}
+
+// exporterAddr returns the exporter address of the flow, preferring the IPv4
+// field and falling back to the IPv6 one when it is zero. It returns nil if
+// neither is set.
+func exporterAddr(flow *flowpb.EnrichedFlow) net.IP {
+	if flow.ExporterIp != 0 {
+		return uint32ToIP(flow.ExporterIp)
+	}
+	if len(flow.ExporterIpv6) == net.IPv6len {
+		return net.IP(flow.ExporterIpv6)
+	}
+	return nil
+}
+
 // uint32ToIP converts a fixed32 IP to net.IP.
 func uint32ToIP(ip uint32) net.IP {
 	return net.IPv4(
@@ -1,9 +1,13 @@
 package enricher
 
 import (
+	"fmt"
 	"log/slog"
+	"math"
 	"net"
 	"os"
+	"path/filepath"
+	"sync"
 	"testing"
 
 	flowpb "github.com/rhwendt/helios/services/flow-enricher/internal/proto"
@@ -195,6 +199,271 @@ func TestEnrichFlow_GeoIPLookup(t *testing.T) {
 	})
 }
 
+func TestEnricher_LookupIP(t *testing.T) {
+	cache := newPopulatedCache(map[string]DeviceMetadata{
+		"10.0.0.1": {Name: "router1", Site: "dc1", Region: "us-east", Role: "core"},
+	})
+	e := New(cache, nil, newTestLogger())
+	e.geoip = &mockGeoIPReader{results: map[string]GeoIPResult{
+		"8.8.8.8": {Country: "US", Continent: "NA", ASNum: 15169, ASName: "GOOGLE"},
+	}}
+
+	t.Run("known device", func(t *testing.T) {
+		device, geo, ok := e.LookupIP(net.ParseIP("10.0.0.1"))
+		if !ok {
+			t.Fatal("expected device to be found")
+		}
+		if device.Name != "router1" || device.Site != "dc1" {
+			t.Errorf("device = %+v, want router1 in dc1", device)
+		}
+		if geo != (GeoIPResult{}) {
+			t.Errorf("geo = %+v, want empty for a private address", geo)
+		}
+	})
+
+	t.Run("external address", func(t *testing.T) {
+		device, geo, ok := e.LookupIP(net.ParseIP("8.8.8.8"))
+		if ok || device.Name != "" {
+			t.Errorf("device = %+v, found %v, want none", device, ok)
+		}
+		if geo.Country != "US" || geo.ASNum != 15169 {
+			t.Errorf("geo = %+v, want US AS15169", geo)
+		}
+	})
+
+	t.Run("without GeoIP", func(t *testing.T) {
+		_, geo, _ := New(cache, nil, newTestLogger()).LookupIP(net.ParseIP("8.8.8.8"))
+		if geo != (GeoIPResult{}) {
+			t.Errorf("geo = %+v, want empty", geo)
+		}
+	})
+}
+
+func TestEnrichFlow_IPv6Exporter(t *testing.T) {
+	cache := newPopulatedCache(map[string]DeviceMetadata{
+		"2001:db8::1": {
+			Name: "router-v6",
+			Site: "dc1",
+			Role: "edge-router",
+			Interfaces: map[uint32]InterfaceMetadata{
+				1: {Name: "Ethernet1", Speed: 100000},
+			},
+		},
+	})
+	e := New(cache, nil, newTestLogger())
+
+	t.Run("uses IPv6 exporter when IPv4 field is zero", func(t *testing.T) {
+		flow := &flowpb.EnrichedFlow{
+			ExporterIpv6: net.ParseIP("2001:db8::1"),
+			InIf:         1,
+		}
+
+		result := e.Enrich(flow)
+
+		if result.ExporterName != "router-v6" {
+			t.Errorf("ExporterName = %q, want router-v6", result.ExporterName)
+		}
+		if result.InIfName != "Ethernet1" {
+			t.Errorf("InIfName = %q, want Ethernet1", result.InIfName)
+		}
+	})
+
+	t.Run("unknown IPv6 exporter passes through", func(t *testing.T) {
+		flow := &flowpb.EnrichedFlow{
+			ExporterIpv6: net.ParseIP("2001:db8::99"),
+		}
+
+		result := e.Enrich(flow)
+
+		if result.ExporterName != "" {
+			t.Errorf("expected empty ExporterName on cache miss, got %q", result.ExporterName)
+		}
+	})
+
+	t.Run("no exporter address skips lookup", func(t *testing.T) {
+		flow := &flowpb.EnrichedFlow{}
+
+		result := e.Enrich(flow)
+
+		if result.ExporterName != "" {
+			t.Errorf("expected empty ExporterName without exporter address, got %q", result.ExporterName)
+		}
+	})
+}
+
+func TestEnrichFlow_EndpointMetadata(t *testing.T) {
+	cache := newPopulatedCache(map[string]DeviceMetadata{
+		"10.0.0.1":    {Name: "router-1", Site: "dc1"},
+		"10.1.0.5":    {Name: "server-5", Site: "dc2"},
+		"2001:db8::5": {Name: "server-v6", Site: "dc3"},
+	})
+	e := New(cache, nil, newTestLogger())
+
+	t.Run("src matches cached device, dst does not", func(t *testing.T) {
+		flow := &flowpb.EnrichedFlow{
+			ExporterIp: ipToUint32(net.ParseIP("10.0.0.1")),
+			SrcIp:      net.ParseIP("10.1.0.5").To4(),
+			DstIp:      net.ParseIP("8.8.8.8").To4(),
+		}
+
+		result := e.Enrich(flow)
+
+		if result.SrcDeviceName != "server-5" {
+			t.Errorf("SrcDeviceName = %q, want server-5", result.SrcDeviceName)
+		}
+		if result.SrcSite != "dc2" {
+			t.Errorf("SrcSite = %q, want dc2", result.SrcSite)
+		}
+		if result.DstDeviceName != "" {
+			t.Errorf("expected empty DstDeviceName for unknown dst, got %q", result.DstDeviceName)
+		}
+		if result.DstSite != "" {
+			t.Errorf("expected empty DstSite for unknown dst, got %q", result.DstSite)
+		}
+		if result.ExporterName != "router-1" {
+			t.Errorf("ExporterName = %q, want router-1", result.ExporterName)
+		}
+	})
+
+	t.Run("IPv6 dst matches cached device", func(t *testing.T) {
+		flow := &flowpb.EnrichedFlow{
+			SrcIp: net.ParseIP("2001:db8::99"),
+			DstIp: net.ParseIP("2001:db8::5"),
+		}
+
+		result := e.Enrich(flow)
+
+		if result.SrcDeviceName != "" {
+			t.Errorf("expected empty SrcDeviceName for unknown src, got %q", result.SrcDeviceName)
+		}
+		if result.DstDeviceName != "server-v6" {
+			t.Errorf("DstDeviceName = %q, want server-v6", result.DstDeviceName)
+		}
+		if result.DstSite != "dc3" {
+			t.Errorf("DstSite = %q, want dc3", result.DstSite)
+		}
+	})
+}
+
+func TestEnrichFlow_EndpointSiteFromPrefix(t *testing.T) {
+	cache := newPopulatedCache(map[string]DeviceMetadata{})
+	cache.prefixes = newPrefixIndex()
+	cache.prefixes.add(mustParseCIDR("10.1.0.0/16"), PrefixMetadata{Site: "dc1"})
+	cache.prefixes.add(mustParseCIDR("10.1.2.0/24"), PrefixMetadata{Site: "dc1-pod2"})
+	e := New(cache, nil, newTestLogger())
+
+	flow := &flowpb.EnrichedFlow{
+		SrcIp: net.ParseIP("10.1.2.3").To4(),
+		DstIp: net.ParseIP("10.1.9.9").To4(),
+	}
+
+	result := e.Enrich(flow)
+
+	if result.SrcSite != "dc1-pod2" {
+		t.Errorf("SrcSite = %q, want dc1-pod2", result.SrcSite)
+	}
+	if result.DstSite != "dc1" {
+		t.Errorf("DstSite = %q, want dc1", result.DstSite)
+	}
+	if result.SrcDeviceName != "" || result.DstDeviceName != "" {
+		t.Errorf("expected no device names from prefix match, got %q/%q", result.SrcDeviceName, result.DstDeviceName)
+	}
+}
+
+func TestEnrichFlow_GeoIPLookupIPv6(t *testing.T) {
+	e := New(newPopulatedCache(map[string]DeviceMetadata{}), nil, newTestLogger())
+	e.geoip = &mockGeoIPReader{results: map[string]GeoIPResult{
+		"2001:4860:4860::8888": {Country: "US", City: "Mountain View", Subdivision: "CA", Continent: "NA", ASNum: 15169, ASName: "GOOGLE"},
+		"2606:4700:4700::1111": {Country: "AU", City: "Sydney", Subdivision: "NSW", Continent: "OC", ASNum: 13335, ASName: "CLOUDFLARENET"},
+	}}
+
+	flow := &flowpb.EnrichedFlow{
+		SrcIp:     net.ParseIP("2001:4860:4860::8888"),
+		DstIp:     net.ParseIP("2606:4700:4700::1111"),
+		IpVersion: 6,
+	}
+
+	result := e.Enrich(flow)
+
+	if result.SrcCountry != "US" || result.SrcCity != "Mountain View" {
+		t.Errorf("src geo = %q/%q, want US/Mountain View", result.SrcCountry, result.SrcCity)
+	}
+	if result.SrcAs != 15169 || result.SrcAsName != "GOOGLE" {
+		t.Errorf("src AS = %d/%q, want 15169/GOOGLE", result.SrcAs, result.SrcAsName)
+	}
+	if result.DstCountry != "AU" || result.DstCity != "Sydney" {
+		t.Errorf("dst geo = %q/%q, want AU/Sydney", result.DstCountry, result.DstCity)
+	}
+	if result.DstAs != 13335 || result.DstAsName != "CLOUDFLARENET" {
+		t.Errorf("dst AS = %d/%q, want 13335/CLOUDFLARENET", result.DstAs, result.DstAsName)
+	}
+	if result.SrcSubdivision != "CA" || result.SrcContinent != "NA" {
+		t.Errorf("src subdivision/continent = %q/%q, want CA/NA", result.SrcSubdivision, result.SrcContinent)
+	}
+	if result.DstSubdivision != "NSW" || result.DstContinent != "OC" {
+		t.Errorf("dst subdivision/continent = %q/%q, want NSW/OC", result.DstSubdivision, result.DstContinent)
+	}
+}
+
+func TestEnrichFlow_SamplingRate(t *testing.T) {
+	tests := []struct {
+		name        string
+		rate        uint32
+		bytes       uint64
+		packets     uint64
+		wantBytes   uint64
+		wantPackets uint64
+	}{
+		{"rate 0 leaves counters unscaled", 0, 1500, 3, 1500, 3},
+		{"rate 1", 1, 1500, 3, 1500, 3},
+		{"rate 1000", 1000, 1500, 3, 1500000, 3000},
+		{"overflow saturates", 4096, math.MaxUint64 / 2, 1, math.MaxUint64, 4096},
+	}
+
+	e := New(newPopulatedCache(map[string]DeviceMetadata{}), nil, newTestLogger())
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			result := e.Enrich(&flowpb.EnrichedFlow{
+				SamplingRate: tc.rate,
+				Bytes:        tc.bytes,
+				Packets:      tc.packets,
+			})
+
+			if result.BytesScaled != tc.wantBytes {
+				t.Errorf("BytesScaled = %d, want %d", result.BytesScaled, tc.wantBytes)
+			}
+			if result.PacketsScaled != tc.wantPackets {
+				t.Errorf("PacketsScaled = %d, want %d", result.PacketsScaled, tc.wantPackets)
+			}
+			if result.Bytes != tc.bytes || result.Packets != tc.packets {
+				t.Errorf("raw counters modified: bytes=%d packets=%d", result.Bytes, result.Packets)
+			}
+		})
+	}
+}
+
+func TestFlowIP(t *testing.T) {
+	tests := []struct {
+		name string
+		b    []byte
+		want string
+	}{
+		{"IPv4", net.ParseIP("10.0.0.1").To4(), "10.0.0.1"},
+		{"IPv6", net.ParseIP("2001:db8::1"), "2001:db8::1"},
+		{"empty", nil, "<nil>"},
+		{"truncated", []byte{10, 0, 0}, "<nil>"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := flowIP(tc.b).String(); got != tc.want {
+				t.Errorf("flowIP(%v) = %s, want %s", tc.b, got, tc.want)
+			}
+		})
+	}
+}
+
 func TestUint32ToIP(t *testing.T) {
 	tests := []struct {
 		name string
@@ -234,6 +503,16 @@ func TestNetBoxCache_LookupByIP(t *testing.T) {
 		}
 	})
 
+	t.Run("returns device for IPv4-mapped IPv6 address", func(t *testing.T) {
+		device, ok := cache.LookupByIP(net.ParseIP("::ffff:10.0.0.2"))
+		if !ok {
+			t.Fatal("expected to find device")
+		}
+		if device.Name != "switch-1" {
+			t.Errorf("Name = %q, want switch-1", device.Name)
+		}
+	})
+
 	t.Run("returns false for unknown IP", func(t *testing.T) {
 		_, ok := cache.LookupByIP(net.ParseIP("10.99.99.99"))
 		if ok {
@@ -247,3 +526,95 @@ func TestNetBoxCache_LookupByIP(t *testing.T) {
 		}
 	})
 }
+
+func TestEnrichBatch_Concurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "geo.mmdb")
+	writeTestMMDB(t, path, geoRecord("US", "Ashburn", 64500, "Example Transit"))
+	geoip, err := NewGeoIPReader(path, path, newTestLogger())
+	if err != nil {
+		t.Fatalf("NewGeoIPReader() error = %v", err)
+	}
+	defer geoip.Close()
+
+	devices := map[string]DeviceMetadata{
+		"10.0.0.1": {Name: "router-1", Site: "dc1"},
+	}
+	cache := newPopulatedCache(devices)
+	e := New(cache, geoip, newTestLogger(), WithWorkers(8), WithServiceNames(true))
+
+	flows := make([]*flowpb.EnrichedFlow, 1000)
+	for i := range flows {
+		flows[i] = &flowpb.EnrichedFlow{
+			ExporterIp: ipToUint32(net.ParseIP("10.0.0.1")),
+			SrcIp:      net.ParseIP("203.0.113.9").To4(),
+			DstIp:      net.ParseIP("198.51.100.7").To4(),
+			DstPort:    443,
+			Protocol:   6,
+		}
+	}
+
+	// Swap the caches underneath the workers, as a refresh or reload would.
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			cache.mu.Lock()
+			cache.devices = devices
+			cache.mu.Unlock()
+			if err := geoip.Reload(); err != nil {
+				t.Errorf("Reload() error = %v", err)
+				return
+			}
+		}
+	}()
+
+	e.EnrichBatch(flows)
+	close(stop)
+	wg.Wait()
+
+	for i, flow := range flows {
+		if flow.ExporterName != "router-1" || flow.SrcCountry != "US" || flow.DstService != "https" {
+			t.Fatalf("flow %d not enriched: exporter=%q country=%q service=%q",
+				i, flow.ExporterName, flow.SrcCountry, flow.DstService)
+		}
+	}
+}
+
+func TestEnrichBatch_Empty(t *testing.T) {
+	e := New(newPopulatedCache(map[string]DeviceMetadata{}), nil, newTestLogger(), WithWorkers(4))
+	e.EnrichBatch(nil)
+}
+
+func BenchmarkEnrichBatch(b *testing.B) {
+	cache := newPopulatedCache(map[string]DeviceMetadata{
+		"10.0.0.1": {Name: "router-1", Site: "dc1"},
+	})
+	geoip := &mockGeoIPReader{results: map[string]GeoIPResult{
+		"203.0.113.9": {Country: "US", City: "Ashburn", ASNum: 64500},
+	}}
+
+	for _, workers := range []int{1, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			e := &Enricher{netbox: cache, geoip: geoip, workers: workers, logger: newTestLogger()}
+			flows := make([]*flowpb.EnrichedFlow, 500)
+			for i := range flows {
+				flows[i] = &flowpb.EnrichedFlow{
+					ExporterIp: ipToUint32(net.ParseIP("10.0.0.1")),
+					SrcIp:      net.ParseIP("203.0.113.9").To4(),
+					DstIp:      net.ParseIP("198.51.100.7").To4(),
+				}
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				e.EnrichBatch(flows)
+			}
+		})
+	}
+}
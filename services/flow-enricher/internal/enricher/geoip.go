@@ -1,33 +1,67 @@
 package enricher
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
 	"net"
+	"sync"
 
 	"github.com/oschwald/maxminddb-golang"
 )
 
 // GeoIPResult holds the result of a GeoIP lookup.
 type GeoIPResult struct {
-	Country string
-	City    string
-	ASNum   uint32
-	ASName  string
+	Country     string `json:"country,omitempty"`
+	City        string `json:"city,omitempty"`        // in the first configured locale the database has
+	Subdivision string `json:"subdivision,omitempty"` // ISO code of the most general subdivision
+	Continent   string `json:"continent,omitempty"`   // two-letter continent code
+	ASNum       uint32 `json:"as_num,omitempty"`
+	ASName      string `json:"as_name,omitempty"`
 }
 
-// GeoIPReader provides IP-to-location and IP-to-ASN lookups.
+// GeoIPReader provides IP-to-location and IP-to-ASN lookups. The databases
+// can be swapped at runtime with Reload. Either database may be missing, in
+// which case lookups only fill the fields of the one that is loaded.
 type GeoIPReader struct {
+	mu     sync.RWMutex
 	cityDB *maxminddb.Reader
 	asnDB  *maxminddb.Reader
-	logger *slog.Logger
+
+	cityDBPath string
+	asnDBPath  string
+	locales    []string
+	logger     *slog.Logger
+}
+
+// GeoIPOption configures optional GeoIPReader behaviour.
+type GeoIPOption func(*GeoIPReader)
+
+// WithLocales sets the preferred locales for city names, in order. English
+// is always tried last.
+func WithLocales(locales ...string) GeoIPOption {
+	return func(r *GeoIPReader) {
+		r.locales = r.locales[:0]
+		for _, l := range locales {
+			if l != "" && l != "en" {
+				r.locales = append(r.locales, l)
+			}
+		}
+		r.locales = append(r.locales, "en")
+	}
 }
 
 // cityRecord mirrors the MaxMind GeoLite2-City database record structure.
 type cityRecord struct {
+	Continent struct {
+		Code string `maxminddb:"code"`
+	} `maxminddb:"continent"`
 	Country struct {
 		ISOCode string `maxminddb:"iso_code"`
 	} `maxminddb:"country"`
+	Subdivisions []struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"subdivisions"`
 	City struct {
 		Names map[string]string `maxminddb:"names"`
 	} `maxminddb:"city"`
@@ -39,46 +73,125 @@ type asnRecord struct {
 	AutonomousSystemOrganization string `maxminddb:"autonomous_system_organization"`
 }
 
-// NewGeoIPReader opens the MaxMind GeoLite2 databases.
-func NewGeoIPReader(cityDBPath, asnDBPath string, logger *slog.Logger) (*GeoIPReader, error) {
-	cityDB, err := maxminddb.Open(cityDBPath)
+// NewGeoIPReader opens the MaxMind GeoLite2 databases. It succeeds as long
+// as at least one of them opens; an empty path skips that database.
+func NewGeoIPReader(cityDBPath, asnDBPath string, logger *slog.Logger, opts ...GeoIPOption) (*GeoIPReader, error) {
+	cityDB, asnDB, err := openGeoIPDatabases(cityDBPath, asnDBPath)
+	if cityDB == nil && asnDB == nil {
+		if err == nil {
+			err = errors.New("no GeoIP database configured")
+		}
+		return nil, err
+	}
 	if err != nil {
-		return nil, fmt.Errorf("opening city database: %w", err)
+		logger.Warn("GeoIP database unavailable, continuing with partial enrichment", "error", err)
 	}
+	logger.Info("GeoIP databases loaded", "city_db", cityDB != nil, "asn_db", asnDB != nil)
 
-	asnDB, err := maxminddb.Open(asnDBPath)
-	if err != nil {
-		cityDB.Close()
-		return nil, fmt.Errorf("opening ASN database: %w", err)
+	r := &GeoIPReader{
+		cityDB:     cityDB,
+		asnDB:      asnDB,
+		cityDBPath: cityDBPath,
+		asnDBPath:  asnDBPath,
+		locales:    []string{"en"},
+		logger:     logger,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r, nil
+}
+
+// openGeoIPDatabases opens the databases at the non-empty paths. It returns
+// whichever opened along with the errors of those that did not.
+func openGeoIPDatabases(cityDBPath, asnDBPath string) (*maxminddb.Reader, *maxminddb.Reader, error) {
+	var cityDB, asnDB *maxminddb.Reader
+	var errs []error
+	if cityDBPath != "" {
+		db, err := maxminddb.Open(cityDBPath)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("opening city database: %w", err))
+		} else {
+			cityDB = db
+		}
 	}
+	if asnDBPath != "" {
+		db, err := maxminddb.Open(asnDBPath)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("opening ASN database: %w", err))
+		} else {
+			asnDB = db
+		}
+	}
+	return cityDB, asnDB, errors.Join(errs...)
+}
+
+// Reload reopens the databases from their original paths and swaps them in.
+// Lookups in progress finish against the old databases, which are closed
+// once no lookup is using them. If a loaded database fails to reopen, the
+// current ones are kept; a database that was missing is picked up once it
+// opens.
+func (r *GeoIPReader) Reload() error {
+	cityDB, asnDB, err := openGeoIPDatabases(r.cityDBPath, r.asnDBPath)
 
-	return &GeoIPReader{
-		cityDB: cityDB,
-		asnDB:  asnDB,
-		logger: logger,
-	}, nil
+	r.mu.RLock()
+	lostCity := cityDB == nil && r.cityDB != nil
+	lostASN := asnDB == nil && r.asnDB != nil
+	r.mu.RUnlock()
+	if lostCity || lostASN {
+		closeGeoIPDatabases(cityDB, asnDB)
+		return fmt.Errorf("reloading GeoIP databases: %w", err)
+	}
+
+	r.mu.Lock()
+	oldCity, oldASN := r.cityDB, r.asnDB
+	r.cityDB, r.asnDB = cityDB, asnDB
+	r.mu.Unlock()
+
+	// Holding the write lock above waited out all in-flight lookups, so
+	// nothing can still be reading the old databases.
+	if err := closeGeoIPDatabases(oldCity, oldASN); err != nil {
+		r.logger.Warn("failed to close previous GeoIP databases", "error", err)
+	}
+	r.logger.Info("GeoIP databases reloaded", "city_db", cityDB != nil, "asn_db", asnDB != nil)
+	return nil
 }
 
-// Lookup performs a GeoIP lookup for the given IP address.
+// Lookup performs a GeoIP lookup for the given IP address. Fields backed by
+// a database that is not loaded are left empty.
 func (r *GeoIPReader) Lookup(ip net.IP) GeoIPResult {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
 	var result GeoIPResult
 
-	var city cityRecord
-	if err := r.cityDB.Lookup(ip, &city); err != nil {
-		r.logger.Debug("city lookup failed", "ip", ip, "error", err)
-	} else {
-		result.Country = city.Country.ISOCode
-		if name, ok := city.City.Names["en"]; ok {
-			result.City = name
+	if r.cityDB != nil {
+		var city cityRecord
+		if err := r.cityDB.Lookup(ip, &city); err != nil {
+			r.logger.Debug("city lookup failed", "ip", ip, "error", err)
+		} else {
+			result.Country = city.Country.ISOCode
+			result.Continent = city.Continent.Code
+			if len(city.Subdivisions) > 0 {
+				result.Subdivision = city.Subdivisions[0].ISOCode
+			}
+			for _, locale := range r.locales {
+				if name, ok := city.City.Names[locale]; ok {
+					result.City = name
+					break
+				}
+			}
 		}
 	}
 
-	var asn asnRecord
-	if err := r.asnDB.Lookup(ip, &asn); err != nil {
-		r.logger.Debug("ASN lookup failed", "ip", ip, "error", err)
-	} else {
-		result.ASNum = asn.AutonomousSystemNumber
-		result.ASName = asn.AutonomousSystemOrganization
+	if r.asnDB != nil {
+		var asn asnRecord
+		if err := r.asnDB.Lookup(ip, &asn); err != nil {
+			r.logger.Debug("ASN lookup failed", "ip", ip, "error", err)
+		} else {
+			result.ASNum = asn.AutonomousSystemNumber
+			result.ASName = asn.AutonomousSystemOrganization
+		}
 	}
 
 	return result
@@ -86,12 +199,20 @@ func (r *GeoIPReader) Lookup(ip net.IP) GeoIPResult {
 
 // Close releases the database resources.
 func (r *GeoIPReader) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return closeGeoIPDatabases(r.cityDB, r.asnDB)
+}
+
+func closeGeoIPDatabases(cityDB, asnDB *maxminddb.Reader) error {
 	var errs []error
-	if err := r.cityDB.Close(); err != nil {
-		errs = append(errs, err)
-	}
-	if err := r.asnDB.Close(); err != nil {
-		errs = append(errs, err)
+	for _, db := range []*maxminddb.Reader{cityDB, asnDB} {
+		if db == nil {
+			continue
+		}
+		if err := db.Close(); err != nil {
+			errs = append(errs, err)
+		}
 	}
 	if len(errs) > 0 {
 		return fmt.Errorf("closing GeoIP databases: %v", errs)
@@ -0,0 +1,379 @@
+package enricher
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+)
+
+// writeTestMMDB writes a minimal IPv4 MaxMind DB to path in which every
+// address maps to record. It writes to a temporary file and renames it into
+// place, as a database update would.
+func writeTestMMDB(t *testing.T, path string, record map[string]any) {
+	t.Helper()
+
+	const nodeCount = 1
+	// A single search tree node whose records both point at the first (and
+	// only) data section entry: data pointers are offset by the node count
+	// and the 16-byte data section separator.
+	var buf bytes.Buffer
+	ptr := nodeCount + 16
+	for i := 0; i < 2; i++ {
+		buf.Write([]byte{byte(ptr >> 16), byte(ptr >> 8), byte(ptr)})
+	}
+	buf.Write(make([]byte, 16))
+	buf.Write(mmdbEncode(record))
+
+	buf.WriteString("\xab\xcd\xefMaxMind.com")
+	buf.Write(mmdbEncode(map[string]any{
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"build_epoch":                 uint64(1700000000),
+		"database_type":               "Helios-Test",
+		"description":                 map[string]any{"en": "test database"},
+		"ip_version":                  uint16(4),
+		"languages":                   []any{"en"},
+		"node_count":                  uint32(nodeCount),
+		"record_size":                 uint16(24),
+	}))
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o644); err != nil {
+		t.Fatalf("writing test database: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatalf("renaming test database: %v", err)
+	}
+}
+
+// mmdbEncode encodes v in the MaxMind DB data section format. Only the types
+// needed by the test databases are supported.
+func mmdbEncode(v any) []byte {
+	var buf bytes.Buffer
+	control := func(typ, size int) {
+		var extra []byte
+		if size >= 29 {
+			// Sizes from 29 to 284 are stored in one extra byte.
+			extra = []byte{byte(size - 29)}
+			size = 29
+		}
+		if typ <= 7 {
+			buf.WriteByte(byte(typ<<5 | size))
+		} else {
+			buf.WriteByte(byte(size))
+			buf.WriteByte(byte(typ - 7))
+		}
+		buf.Write(extra)
+	}
+	uint := func(typ int, n uint64, width int) {
+		b := make([]byte, 8)
+		binary.BigEndian.PutUint64(b, n)
+		b = bytes.TrimLeft(b[8-width:], "\x00")
+		control(typ, len(b))
+		buf.Write(b)
+	}
+
+	switch v := v.(type) {
+	case string:
+		control(2, len(v))
+		buf.WriteString(v)
+	case uint16:
+		uint(5, uint64(v), 2)
+	case uint32:
+		uint(6, uint64(v), 4)
+	case uint64:
+		uint(9, v, 8)
+	case []any:
+		control(11, len(v))
+		for _, item := range v {
+			buf.Write(mmdbEncode(item))
+		}
+	case map[string]any:
+		control(7, len(v))
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			buf.Write(mmdbEncode(k))
+			buf.Write(mmdbEncode(v[k]))
+		}
+	default:
+		panic("unsupported MaxMind DB type")
+	}
+	return buf.Bytes()
+}
+
+// geoRecord returns a record carrying both the city and ASN fields, so the
+// same file can serve as either database.
+func geoRecord(country, city string, asn uint32, org string) map[string]any {
+	return map[string]any{
+		"country":                        map[string]any{"iso_code": country},
+		"city":                           map[string]any{"names": map[string]any{"en": city}},
+		"autonomous_system_number":       asn,
+		"autonomous_system_organization": org,
+	}
+}
+
+func TestGeoIPReader_Lookup(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "geo.mmdb")
+	writeTestMMDB(t, path, geoRecord("US", "Ashburn", 64500, "Example Transit"))
+
+	r, err := NewGeoIPReader(path, path, newTestLogger())
+	if err != nil {
+		t.Fatalf("NewGeoIPReader() error = %v", err)
+	}
+	defer r.Close()
+
+	got := r.Lookup(net.ParseIP("198.51.100.7"))
+	want := GeoIPResult{Country: "US", City: "Ashburn", ASNum: 64500, ASName: "Example Transit"}
+	if got != want {
+		t.Errorf("Lookup() = %+v, want %+v", got, want)
+	}
+}
+
+func TestGeoIPReader_PartialDatabases(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "geo.mmdb")
+	missing := filepath.Join(dir, "missing.mmdb")
+	writeTestMMDB(t, path, geoRecord("US", "Ashburn", 64500, "Example Transit"))
+
+	tests := []struct {
+		name      string
+		cityDB    string
+		asnDB     string
+		want      GeoIPResult
+		wantError bool
+	}{
+		{
+			name:   "city only",
+			cityDB: path,
+			asnDB:  missing,
+			want:   GeoIPResult{Country: "US", City: "Ashburn"},
+		},
+		{
+			name:   "asn only",
+			cityDB: missing,
+			asnDB:  path,
+			want:   GeoIPResult{ASNum: 64500, ASName: "Example Transit"},
+		},
+		{
+			name:   "unconfigured asn",
+			cityDB: path,
+			want:   GeoIPResult{Country: "US", City: "Ashburn"},
+		},
+		{
+			name:      "neither",
+			cityDB:    missing,
+			asnDB:     missing,
+			wantError: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := NewGeoIPReader(tc.cityDB, tc.asnDB, newTestLogger())
+			if tc.wantError {
+				if err == nil {
+					r.Close()
+					t.Fatal("expected error when no database opens")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewGeoIPReader() error = %v", err)
+			}
+			defer r.Close()
+
+			if got := r.Lookup(net.ParseIP("198.51.100.7")); got != tc.want {
+				t.Errorf("Lookup() = %+v, want %+v", got, tc.want)
+			}
+			if err := r.Reload(); err != nil {
+				t.Errorf("Reload() error = %v, want nil while the missing database stays missing", err)
+			}
+		})
+	}
+}
+
+func TestGeoIPReader_ReloadPicksUpMissingDatabase(t *testing.T) {
+	dir := t.TempDir()
+	cityPath := filepath.Join(dir, "city.mmdb")
+	asnPath := filepath.Join(dir, "asn.mmdb")
+	writeTestMMDB(t, cityPath, geoRecord("US", "Ashburn", 64500, "Example Transit"))
+
+	r, err := NewGeoIPReader(cityPath, asnPath, newTestLogger())
+	if err != nil {
+		t.Fatalf("NewGeoIPReader() error = %v", err)
+	}
+	defer r.Close()
+
+	ip := net.ParseIP("203.0.113.9")
+	if got := r.Lookup(ip).ASNum; got != 0 {
+		t.Fatalf("ASNum before the ASN database exists = %d, want 0", got)
+	}
+
+	writeTestMMDB(t, asnPath, geoRecord("US", "Ashburn", 64500, "Example Transit"))
+	if err := r.Reload(); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if got := r.Lookup(ip).ASNum; got != 64500 {
+		t.Errorf("ASNum after reload = %d, want 64500", got)
+	}
+}
+
+func TestGeoIPReader_Reload(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "geo.mmdb")
+	writeTestMMDB(t, path, geoRecord("US", "Ashburn", 64500, "Example Transit"))
+
+	r, err := NewGeoIPReader(path, path, newTestLogger())
+	if err != nil {
+		t.Fatalf("NewGeoIPReader() error = %v", err)
+	}
+	defer r.Close()
+
+	ip := net.ParseIP("203.0.113.9")
+	if got := r.Lookup(ip).Country; got != "US" {
+		t.Fatalf("Country before reload = %q, want US", got)
+	}
+
+	writeTestMMDB(t, path, geoRecord("DE", "Frankfurt", 64501, "Example Carrier"))
+	if err := r.Reload(); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+
+	got := r.Lookup(ip)
+	want := GeoIPResult{Country: "DE", City: "Frankfurt", ASNum: 64501, ASName: "Example Carrier"}
+	if got != want {
+		t.Errorf("Lookup() after reload = %+v, want %+v", got, want)
+	}
+}
+
+func TestGeoIPReader_ReloadFailureKeepsDatabases(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "geo.mmdb")
+	writeTestMMDB(t, path, geoRecord("US", "Ashburn", 64500, "Example Transit"))
+
+	r, err := NewGeoIPReader(path, path, newTestLogger())
+	if err != nil {
+		t.Fatalf("NewGeoIPReader() error = %v", err)
+	}
+	defer r.Close()
+
+	// Replace rather than overwrite the file: the open database is mmapped.
+	if err := os.WriteFile(path+".tmp", []byte("not a database"), 0o644); err != nil {
+		t.Fatalf("writing corrupt database: %v", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		t.Fatalf("replacing database: %v", err)
+	}
+	if err := r.Reload(); err == nil {
+		t.Fatal("expected Reload() to fail for a corrupt database")
+	}
+
+	if got := r.Lookup(net.ParseIP("203.0.113.9")).Country; got != "US" {
+		t.Errorf("Country after failed reload = %q, want US", got)
+	}
+}
+
+func TestGeoIPReader_ConcurrentLookupDuringReload(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "geo.mmdb")
+	writeTestMMDB(t, path, geoRecord("US", "Ashburn", 64500, "Example Transit"))
+
+	r, err := NewGeoIPReader(path, path, newTestLogger())
+	if err != nil {
+		t.Fatalf("NewGeoIPReader() error = %v", err)
+	}
+	defer r.Close()
+
+	ip := net.ParseIP("192.0.2.1")
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if got := r.Lookup(ip).Country; got != "US" && got != "DE" {
+					t.Errorf("Lookup() country = %q during reload", got)
+					return
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 20; i++ {
+		country := "US"
+		if i%2 == 0 {
+			country = "DE"
+		}
+		writeTestMMDB(t, path, geoRecord(country, "City", 64500, "Org"))
+		if err := r.Reload(); err != nil {
+			t.Errorf("Reload() error = %v", err)
+		}
+	}
+	close(stop)
+	wg.Wait()
+}
+
+func TestGeoIPReader_Locales(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "city.mmdb")
+	writeTestMMDB(t, path, map[string]any{
+		"continent":    map[string]any{"code": "EU"},
+		"country":      map[string]any{"iso_code": "DE"},
+		"subdivisions": []any{map[string]any{"iso_code": "BY"}, map[string]any{"iso_code": "09"}},
+		"city": map[string]any{"names": map[string]any{
+			"en": "Munich",
+			"de": "München",
+			"ja": "ミュンヘン",
+		}},
+	})
+
+	tests := []struct {
+		name     string
+		opts     []GeoIPOption
+		wantCity string
+	}{
+		{"default is English", nil, "Munich"},
+		{"preferred locale", []GeoIPOption{WithLocales("de")}, "München"},
+		{"first available locale wins", []GeoIPOption{WithLocales("fr", "ja", "de")}, "ミュンヘン"},
+		{"falls back to English", []GeoIPOption{WithLocales("pt-BR")}, "Munich"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := NewGeoIPReader(path, path, newTestLogger(), tc.opts...)
+			if err != nil {
+				t.Fatalf("NewGeoIPReader() error = %v", err)
+			}
+			defer r.Close()
+
+			got := r.Lookup(net.ParseIP("192.0.2.10"))
+			if got.City != tc.wantCity {
+				t.Errorf("City = %q, want %q", got.City, tc.wantCity)
+			}
+			if got.Country != "DE" {
+				t.Errorf("Country = %q, want DE", got.Country)
+			}
+			if got.Subdivision != "BY" {
+				t.Errorf("Subdivision = %q, want BY", got.Subdivision)
+			}
+			if got.Continent != "EU" {
+				t.Errorf("Continent = %q, want EU", got.Continent)
+			}
+		})
+	}
+}
@@ -0,0 +1,18 @@
+package enricher
+
+import "net/http"
+
+// ReadyHandler serves /readyz. It reports 503 until cache has completed its
+// first successful refresh, so the pod does not receive traffic while it
+// would only produce unenriched flows. A nil cache, for when NetBox is not
+// configured, is always ready.
+func ReadyHandler(cache *NetBoxCache) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cache != nil && !cache.Loaded() {
+			http.Error(w, "NetBox cache not loaded", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+	})
+}
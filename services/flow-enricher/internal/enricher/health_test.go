@@ -0,0 +1,54 @@
+package enricher
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestReadyHandler(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/dcim/devices/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(mockNetBoxDevicesResponse([]json.RawMessage{
+			mustMarshal(map[string]any{"id": 1, "name": "router-1", "primary_ip": map[string]any{"address": "10.0.0.1/32"}}),
+		}, nil))
+	})
+	mux.HandleFunc("/api/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(mockNetBoxDevicesResponse(nil, nil))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	cache := NewNetBoxCache(srv.URL, "test-token", time.Minute, newTestLogger())
+	cache.retry = testRetryPolicy
+	handler := ReadyHandler(cache)
+
+	status := func() int {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		return rec.Code
+	}
+
+	if got := status(); got != http.StatusServiceUnavailable {
+		t.Fatalf("status before refresh = %d, want 503", got)
+	}
+	if err := cache.refresh(context.Background()); err != nil {
+		t.Fatalf("refresh() error = %v", err)
+	}
+	if got := status(); got != http.StatusOK {
+		t.Errorf("status after refresh = %d, want 200", got)
+	}
+}
+
+func TestReadyHandler_NetBoxNotConfigured(t *testing.T) {
+	rec := httptest.NewRecorder()
+	ReadyHandler(nil).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want 200", rec.Code)
+	}
+}
@@ -0,0 +1,93 @@
+package enricher
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+// DefaultNetBoxTimeout bounds each NetBox API request, including reading the
+// response body.
+const DefaultNetBoxTimeout = 30 * time.Second
+
+// DefaultNetBoxMaxIdleConnsPerHost is how many idle keep-alive connections to
+// NetBox are kept for reuse. It exceeds DefaultInterfaceConcurrency so that
+// parallel interface fetches do not open a new connection per request.
+const DefaultNetBoxMaxIdleConnsPerHost = 16
+
+// NetBoxHTTPConfig tunes the HTTP client used to reach NetBox. Zero values
+// select the defaults.
+type NetBoxHTTPConfig struct {
+	Timeout             time.Duration
+	MaxIdleConnsPerHost int
+	// RootCAs replaces the system roots as the pool NetBox's certificate is
+	// verified against.
+	RootCAs *x509.CertPool
+	// CAFile is a PEM bundle trusted in addition to RootCAs or the system
+	// roots, for a NetBox served with a self-signed or private CA
+	// certificate.
+	CAFile string
+	// InsecureSkipVerify disables verification of NetBox's certificate.
+	InsecureSkipVerify bool
+}
+
+// NewNetBoxHTTPClient returns an *http.Client configured by cfg. Its
+// transport is meant to be shared by every request to NetBox.
+func NewNetBoxHTTPClient(cfg NetBoxHTTPConfig) (*http.Client, error) {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = DefaultNetBoxTimeout
+	}
+	idle := cfg.MaxIdleConnsPerHost
+	if idle <= 0 {
+		idle = DefaultNetBoxMaxIdleConnsPerHost
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = idle
+	tlsConfig, err := cfg.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	return &http.Client{Timeout: timeout, Transport: transport}, nil
+}
+
+// tlsConfig returns the TLS configuration for cfg, or nil when the transport
+// defaults apply.
+func (cfg NetBoxHTTPConfig) tlsConfig() (*tls.Config, error) {
+	if cfg.RootCAs == nil && cfg.CAFile == "" && !cfg.InsecureSkipVerify {
+		return nil, nil
+	}
+
+	pool := cfg.RootCAs
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading NetBox CA file: %w", err)
+		}
+		if pool == nil {
+			pool, err = x509.SystemCertPool()
+			if err != nil {
+				pool = x509.NewCertPool()
+			}
+		} else {
+			// Leave the caller's pool untouched.
+			pool = pool.Clone()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in NetBox CA file %s", cfg.CAFile)
+		}
+	}
+	return &tls.Config{
+		RootCAs:            pool,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}, nil
+}
@@ -0,0 +1,133 @@
+package enricher
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNewNetBoxHTTPClient(t *testing.T) {
+	client, err := NewNetBoxHTTPClient(NetBoxHTTPConfig{Timeout: 5 * time.Second, MaxIdleConnsPerHost: 3})
+	if err != nil {
+		t.Fatalf("NewNetBoxHTTPClient() error = %v", err)
+	}
+	if client.Timeout != 5*time.Second {
+		t.Errorf("Timeout = %v, want 5s", client.Timeout)
+	}
+	if got := client.Transport.(*http.Transport).MaxIdleConnsPerHost; got != 3 {
+		t.Errorf("MaxIdleConnsPerHost = %d, want 3", got)
+	}
+
+	client, err = NewNetBoxHTTPClient(NetBoxHTTPConfig{})
+	if err != nil {
+		t.Fatalf("NewNetBoxHTTPClient() error = %v", err)
+	}
+	if client.Timeout != DefaultNetBoxTimeout {
+		t.Errorf("default Timeout = %v, want %v", client.Timeout, DefaultNetBoxTimeout)
+	}
+	if got := client.Transport.(*http.Transport).MaxIdleConnsPerHost; got != DefaultNetBoxMaxIdleConnsPerHost {
+		t.Errorf("default MaxIdleConnsPerHost = %d, want %d", got, DefaultNetBoxMaxIdleConnsPerHost)
+	}
+}
+
+func TestNetBoxCache_HTTPClientTimeoutApplied(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	client, err := NewNetBoxHTTPClient(NetBoxHTTPConfig{Timeout: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("NewNetBoxHTTPClient() error = %v", err)
+	}
+	cache := NewNetBoxCache(srv.URL, "test-token", time.Minute, newTestLogger(), WithHTTPClient(client))
+	cache.retry = testRetryPolicy
+
+	if cache.httpClient() != client {
+		t.Fatal("cache does not use the configured client")
+	}
+	start := time.Now()
+	if _, _, err := cache.fetchPage(context.Background(), cache.httpClient(), srv.URL); err == nil {
+		t.Fatal("expected a timeout error from a stalled NetBox")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("fetchPage took %v, want it bounded by the client timeout", elapsed)
+	}
+}
+
+func TestNewNetBoxHTTPClient_CAFile(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"results": []}`))
+	}))
+	defer srv.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(caFile, certPEM, 0o600); err != nil {
+		t.Fatalf("writing CA file: %v", err)
+	}
+
+	client, err := NewNetBoxHTTPClient(NetBoxHTTPConfig{CAFile: caFile})
+	if err != nil {
+		t.Fatalf("NewNetBoxHTTPClient() error = %v", err)
+	}
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("GET with the NetBox CA trusted: %v", err)
+	}
+	resp.Body.Close()
+
+	if _, err := NewNetBoxHTTPClient(NetBoxHTTPConfig{CAFile: filepath.Join(t.TempDir(), "missing.pem")}); err == nil {
+		t.Error("expected error for a missing CA file")
+	}
+	if err := os.WriteFile(caFile, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatalf("writing CA file: %v", err)
+	}
+	if _, err := NewNetBoxHTTPClient(NetBoxHTTPConfig{CAFile: caFile}); err == nil {
+		t.Error("expected error for a CA file without certificates")
+	}
+}
+
+func TestNetBoxCache_CustomRootCAs(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"results": []}`))
+	}))
+	defer srv.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+
+	tests := []struct {
+		name    string
+		cfg     NetBoxHTTPConfig
+		wantErr bool
+	}{
+		{name: "system roots reject the NetBox CA", cfg: NetBoxHTTPConfig{}, wantErr: true},
+		{name: "custom root pool", cfg: NetBoxHTTPConfig{RootCAs: pool}},
+		{name: "insecure skip verify", cfg: NetBoxHTTPConfig{InsecureSkipVerify: true}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := NewNetBoxHTTPClient(tc.cfg)
+			if err != nil {
+				t.Fatalf("NewNetBoxHTTPClient() error = %v", err)
+			}
+			cache := NewNetBoxCache(srv.URL, "test-token", time.Minute, newTestLogger(), WithHTTPClient(client))
+			cache.retry = testRetryPolicy
+
+			_, _, err = cache.fetchPage(context.Background(), cache.httpClient(), srv.URL)
+			if tc.wantErr != (err != nil) {
+				t.Errorf("fetchPage() error = %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package enricher
+
+import "strconv"
+
+// icmpTypeNames and icmpv6TypeNames map ICMP (RFC 792, RFC 950) and ICMPv6
+// (RFC 4443, RFC 4861, RFC 3810) message types to their common names.
+var (
+	icmpTypeNames = map[uint32]string{
+		0:  "echo-reply",
+		3:  "dest-unreachable",
+		4:  "source-quench",
+		5:  "redirect",
+		8:  "echo-request",
+		9:  "router-advertisement",
+		10: "router-solicitation",
+		11: "time-exceeded",
+		12: "parameter-problem",
+		13: "timestamp-request",
+		14: "timestamp-reply",
+		17: "address-mask-request",
+		18: "address-mask-reply",
+	}
+	icmpv6TypeNames = map[uint32]string{
+		1:   "dest-unreachable",
+		2:   "packet-too-big",
+		3:   "time-exceeded",
+		4:   "parameter-problem",
+		128: "echo-request",
+		129: "echo-reply",
+		130: "mld-query",
+		131: "mld-report",
+		132: "mld-done",
+		133: "router-solicitation",
+		134: "router-advertisement",
+		135: "neighbor-solicitation",
+		136: "neighbor-advertisement",
+		137: "redirect",
+		143: "mldv2-report",
+	}
+)
+
+// icmpTypeName returns the name of the ICMP type of an ICMP or ICMPv6 flow,
+// or the type number as a string when it has no common name. It returns ""
+// for any other protocol.
+func icmpTypeName(proto, icmpType uint32) string {
+	var names map[uint32]string
+	switch proto {
+	case 1:
+		names = icmpTypeNames
+	case 58:
+		names = icmpv6TypeNames
+	default:
+		return ""
+	}
+	if name, ok := names[icmpType]; ok {
+		return name
+	}
+	return strconv.FormatUint(uint64(icmpType), 10)
+}
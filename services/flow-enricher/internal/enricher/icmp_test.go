@@ -0,0 +1,40 @@
+package enricher
+
+import (
+	"testing"
+
+	flowpb "github.com/rhwendt/helios/services/flow-enricher/internal/proto"
+)
+
+func TestICMPTypeName(t *testing.T) {
+	tests := []struct {
+		name     string
+		proto    uint32
+		icmpType uint32
+		icmpCode uint32
+		want     string
+	}{
+		{"echo request", 1, 8, 0, "echo-request"},
+		{"echo reply", 1, 0, 0, "echo-reply"},
+		{"port unreachable", 1, 3, 3, "dest-unreachable"},
+		{"fragmentation needed", 1, 3, 4, "dest-unreachable"},
+		{"ttl exceeded", 1, 11, 0, "time-exceeded"},
+		{"unknown icmp type", 1, 42, 0, "42"},
+		{"icmpv6 echo request", 58, 128, 0, "echo-request"},
+		{"icmpv6 packet too big", 58, 2, 0, "packet-too-big"},
+		{"icmpv6 neighbor solicitation", 58, 135, 0, "neighbor-solicitation"},
+		{"icmpv6 type 8 is not echo", 58, 8, 0, "8"},
+		{"tcp", 6, 8, 0, ""},
+		{"udp", 17, 0, 0, ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			e := New(newPopulatedCache(map[string]DeviceMetadata{}), nil, newTestLogger())
+			flow := e.Enrich(&flowpb.EnrichedFlow{Protocol: tc.proto, IcmpType: tc.icmpType, IcmpCode: tc.icmpCode})
+			if flow.IcmpTypeName != tc.want {
+				t.Errorf("IcmpTypeName = %q, want %q", flow.IcmpTypeName, tc.want)
+			}
+		})
+	}
+}
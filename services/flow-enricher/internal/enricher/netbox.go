@@ -11,44 +11,115 @@ import (
 	"net/url"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/rhwendt/helios/services/flow-enricher/internal/metrics"
 )
 
 // DeviceMetadata holds enrichment data for a network device.
 type DeviceMetadata struct {
-	Name       string
-	Site       string
-	Region     string
-	Role       string
-	Interfaces map[uint32]InterfaceMetadata // keyed by SNMP index
+	Name       string                       `json:"name"`
+	Site       string                       `json:"site,omitempty"`
+	Region     string                       `json:"region,omitempty"`
+	Role       string                       `json:"role,omitempty"`
+	Interfaces map[uint32]InterfaceMetadata `json:"interfaces,omitempty"` // keyed by SNMP index
 }
 
 // InterfaceMetadata holds enrichment data for a device interface.
 type InterfaceMetadata struct {
-	Name  string
-	Speed uint64
+	Name  string `json:"name"`
+	Speed uint64 `json:"speed,omitempty"`
 }
 
 // NetBoxCache provides device metadata lookup by IP address.
 type NetBoxCache struct {
-	mu      sync.RWMutex
-	devices map[string]DeviceMetadata // keyed by management IP
+	mu       sync.RWMutex
+	devices  map[string]DeviceMetadata // keyed by management IP
+	prefixes *prefixIndex
+	pages    *pageCache
+	loaded   atomic.Bool // set by the first successful refresh
+
+	apiURL               string
+	apiToken             string
+	client               *http.Client
+	interval             time.Duration
+	interfaceConcurrency int
+	deviceFilter         string
+	deviceStatus         string
+	retry                retryPolicy
+	logger               *slog.Logger
+}
+
+// DefaultInterfaceConcurrency is the number of devices whose interfaces are
+// fetched from NetBox in parallel during a refresh.
+const DefaultInterfaceConcurrency = 8
+
+// DefaultDeviceFilter selects the devices Helios monitors.
+const DefaultDeviceFilter = "cf_helios_monitor=true"
+
+// DefaultDeviceStatus is the NetBox device status loaded into the cache.
+const DefaultDeviceStatus = "active"
+
+// NetBoxCacheOption configures optional NetBoxCache behaviour.
+type NetBoxCacheOption func(*NetBoxCache)
+
+// WithInterfaceConcurrency sets how many devices have their interfaces
+// fetched in parallel. Values below 1 are ignored.
+func WithInterfaceConcurrency(n int) NetBoxCacheOption {
+	return func(c *NetBoxCache) {
+		if n > 0 {
+			c.interfaceConcurrency = n
+		}
+	}
+}
 
-	apiURL   string
-	apiToken string
-	interval time.Duration
-	logger   *slog.Logger
+// WithDeviceFilter replaces the query string fragment that selects monitored
+// devices, e.g. "tag=helios". An empty filter loads every device.
+func WithDeviceFilter(filter string) NetBoxCacheOption {
+	return func(c *NetBoxCache) {
+		c.deviceFilter = filter
+	}
+}
+
+// WithDeviceStatus sets the NetBox status devices must have to be cached. An
+// empty status disables the status filter.
+func WithDeviceStatus(status string) NetBoxCacheOption {
+	return func(c *NetBoxCache) {
+		c.deviceStatus = status
+	}
+}
+
+// WithHTTPClient sets the client used for NetBox API requests, typically one
+// built by NewNetBoxHTTPClient. A nil client is ignored.
+func WithHTTPClient(client *http.Client) NetBoxCacheOption {
+	return func(c *NetBoxCache) {
+		if client != nil {
+			c.client = client
+		}
+	}
 }
 
 // NewNetBoxCache creates a new NetBox cache with the given configuration.
-func NewNetBoxCache(apiURL, apiToken string, refreshInterval time.Duration, logger *slog.Logger) *NetBoxCache {
-	return &NetBoxCache{
-		devices:  make(map[string]DeviceMetadata),
-		apiURL:   apiURL,
-		apiToken: apiToken,
-		interval: refreshInterval,
-		logger:   logger,
+func NewNetBoxCache(apiURL, apiToken string, refreshInterval time.Duration, logger *slog.Logger, opts ...NetBoxCacheOption) *NetBoxCache {
+	c := &NetBoxCache{
+		devices:              make(map[string]DeviceMetadata),
+		prefixes:             newPrefixIndex(),
+		pages:                newPageCache(),
+		apiURL:               apiURL,
+		apiToken:             apiToken,
+		client:               &http.Client{Timeout: DefaultNetBoxTimeout},
+		interval:             refreshInterval,
+		interfaceConcurrency: DefaultInterfaceConcurrency,
+		deviceFilter:         DefaultDeviceFilter,
+		deviceStatus:         DefaultDeviceStatus,
+		retry:                defaultRetryPolicy,
+		logger:               logger,
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
 }
 
 // Start begins periodic cache refresh. It blocks until the context is cancelled.
@@ -77,10 +148,25 @@ func (c *NetBoxCache) Start(ctx context.Context) error {
 func (c *NetBoxCache) LookupByIP(ip net.IP) (DeviceMetadata, bool) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
-	meta, ok := c.devices[ip.String()]
+	meta, ok := c.devices[normalizeIP(ip.String())]
 	return meta, ok
 }
 
+// LookupByPrefix returns the site and region for the given IP address. An
+// exact match on a device's management IP is tried first; otherwise the most
+// specific NetBox prefix containing the address is used.
+func (c *NetBoxCache) LookupByPrefix(ip net.IP) (PrefixMetadata, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if meta, ok := c.devices[normalizeIP(ip.String())]; ok {
+		return PrefixMetadata{Site: meta.Site, Region: meta.Region}, true
+	}
+	if c.prefixes == nil {
+		return PrefixMetadata{}, false
+	}
+	return c.prefixes.lookup(ip)
+}
+
 // DeviceCount returns the number of devices in the cache.
 func (c *NetBoxCache) DeviceCount() int {
 	c.mu.RLock()
@@ -88,22 +174,63 @@ func (c *NetBoxCache) DeviceCount() int {
 	return len(c.devices)
 }
 
-// refresh fetches all devices from NetBox and rebuilds the cache.
+// Loaded reports whether the cache has completed a successful refresh. A
+// NetBox with no matching devices still counts as loaded.
+func (c *NetBoxCache) Loaded() bool {
+	return c.loaded.Load()
+}
+
+func (c *NetBoxCache) prefixCount() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.prefixes == nil {
+		return 0
+	}
+	return c.prefixes.len()
+}
+
+// refresh fetches all devices from NetBox and rebuilds the cache. The new
+// data is built without holding the lock and swapped in only once complete,
+// so lookups are never blocked by NetBox requests and a failed refresh keeps
+// serving the previous data.
 func (c *NetBoxCache) refresh(ctx context.Context) error {
 	c.logger.Info("refreshing NetBox device cache")
 	start := time.Now()
+	defer c.pages.sweep()
 
 	devices, err := c.fetchDevices(ctx)
 	if err != nil {
+		metrics.CacheRefreshErrorsTotal.Inc()
 		return fmt.Errorf("fetching devices from NetBox: %w", err)
 	}
 
+	siteRegions := make(map[string]string)
+	for _, d := range devices {
+		if d.Site != "" && d.Region != "" {
+			siteRegions[d.Site] = d.Region
+		}
+	}
+	prefixes, err := c.fetchPrefixes(ctx, c.httpClient(), siteRegions)
+	if err != nil {
+		// Exact device lookups still work; keep the previous prefix index.
+		metrics.CacheRefreshErrorsTotal.Inc()
+		c.logger.Warn("failed to fetch prefixes from NetBox", "error", err)
+	}
+
 	c.mu.Lock()
 	c.devices = devices
+	if prefixes != nil {
+		c.prefixes = prefixes
+	}
 	c.mu.Unlock()
+	c.loaded.Store(true)
+
+	metrics.CacheDevices.Set(float64(len(devices)))
+	metrics.CacheLastRefreshTimestamp.SetToCurrentTime()
 
 	c.logger.Info("NetBox cache refreshed",
 		"devices", len(devices),
+		"prefixes", c.prefixCount(),
 		"duration", time.Since(start),
 	)
 	return nil
@@ -146,19 +273,26 @@ type netboxInterface struct {
 	Label string `json:"label"`
 }
 
-// httpClient returns an *http.Client with a reasonable timeout.
+// httpClient returns the client used for NetBox API requests.
 func (c *NetBoxCache) httpClient() *http.Client {
-	return &http.Client{Timeout: 30 * time.Second}
+	return c.client
+}
+
+// pendingDevice is a device whose interfaces have yet to be fetched.
+type pendingDevice struct {
+	id     int
+	mgmtIP string
+	meta   DeviceMetadata
 }
 
 // fetchDevices queries the NetBox API for all devices with helios_monitor=true.
 // Returns a map keyed by management IP.
 func (c *NetBoxCache) fetchDevices(ctx context.Context) (map[string]DeviceMetadata, error) {
 	client := c.httpClient()
-	devices := make(map[string]DeviceMetadata)
+	var pending []pendingDevice
 
 	// Fetch all monitored devices with pagination.
-	nextURL := fmt.Sprintf("%s/api/dcim/devices/?cf_helios_monitor=true&status=active&limit=100", strings.TrimRight(c.apiURL, "/"))
+	nextURL := fmt.Sprintf("%s/api/dcim/devices/?%s", strings.TrimRight(c.apiURL, "/"), c.deviceQuery())
 
 	for nextURL != "" {
 		rawDevices, next, err := c.fetchPage(ctx, client, nextURL)
@@ -179,7 +313,7 @@ func (c *NetBoxCache) fetchDevices(ctx context.Context) (map[string]DeviceMetada
 			}
 
 			// Strip CIDR notation (e.g. "10.0.0.1/32" -> "10.0.0.1").
-			mgmtIP := stripCIDR(d.PrimaryIP.Address)
+			mgmtIP := normalizeIP(stripCIDR(d.PrimaryIP.Address))
 
 			meta := DeviceMetadata{
 				Name:       d.Name,
@@ -195,16 +329,7 @@ func (c *NetBoxCache) fetchDevices(ctx context.Context) (map[string]DeviceMetada
 				meta.Role = d.Role.Name
 			}
 
-			// Fetch interfaces for this device.
-			ifaces, err := c.fetchInterfaces(ctx, client, d.ID)
-			if err != nil {
-				c.logger.Warn("failed to fetch interfaces for device", "device", d.Name, "id", d.ID, "error", err)
-				// Continue with empty interfaces rather than failing the entire refresh.
-			} else {
-				meta.Interfaces = ifaces
-			}
-
-			devices[mgmtIP] = meta
+			pending = append(pending, pendingDevice{id: d.ID, mgmtIP: mgmtIP, meta: meta})
 		}
 
 		if next != nil {
@@ -214,9 +339,45 @@ func (c *NetBoxCache) fetchDevices(ctx context.Context) (map[string]DeviceMetada
 		}
 	}
 
+	c.fetchAllInterfaces(ctx, client, pending)
+
+	devices := make(map[string]DeviceMetadata, len(pending))
+	for _, p := range pending {
+		devices[p.mgmtIP] = p.meta
+	}
 	return devices, nil
 }
 
+// fetchAllInterfaces fills in the interfaces of each pending device, running
+// up to interfaceConcurrency fetches at a time. A device whose interfaces
+// cannot be fetched keeps an empty interface map.
+func (c *NetBoxCache) fetchAllInterfaces(ctx context.Context, client *http.Client, pending []pendingDevice) {
+	workers := c.interfaceConcurrency
+	if workers < 1 {
+		workers = 1
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, workers)
+	for i := range pending {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(p *pendingDevice) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			ifaces, err := c.fetchInterfaces(ctx, client, p.id)
+			if err != nil {
+				c.logger.Warn("failed to fetch interfaces for device", "device", p.meta.Name, "id", p.id, "error", err)
+				// Continue with empty interfaces rather than failing the entire refresh.
+				return
+			}
+			p.meta.Interfaces = ifaces
+		}(&pending[i])
+	}
+	wg.Wait()
+}
+
 // fetchInterfaces retrieves all interfaces for a given device ID from NetBox.
 func (c *NetBoxCache) fetchInterfaces(ctx context.Context, client *http.Client, deviceID int) (map[uint32]InterfaceMetadata, error) {
 	interfaces := make(map[uint32]InterfaceMetadata)
@@ -273,26 +434,54 @@ func (c *NetBoxCache) fetchInterfaces(ctx context.Context, client *http.Client,
 	return interfaces, nil
 }
 
-// fetchPage fetches a single page from the NetBox paginated API.
+// deviceQuery builds the device list query from the configured filter and
+// status.
+func (c *NetBoxCache) deviceQuery() string {
+	var parts []string
+	if c.deviceFilter != "" {
+		parts = append(parts, strings.TrimPrefix(c.deviceFilter, "&"))
+	}
+	if c.deviceStatus != "" {
+		parts = append(parts, "status="+url.QueryEscape(c.deviceStatus))
+	}
+	return strings.Join(append(parts, "limit=100"), "&")
+}
+
+// fetchPage fetches a single page from the NetBox paginated API. Pages seen
+// before are requested conditionally and reused on 304 Not Modified.
 func (c *NetBoxCache) fetchPage(ctx context.Context, client *http.Client, rawURL string) ([]json.RawMessage, *string, error) {
 	parsedURL, err := url.Parse(rawURL)
 	if err != nil {
 		return nil, nil, fmt.Errorf("parsing URL: %w", err)
 	}
+	cached, haveCached := c.pages.get(rawURL)
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, parsedURL.String(), nil)
-	if err != nil {
-		return nil, nil, fmt.Errorf("creating request: %w", err)
-	}
-	req.Header.Set("Authorization", fmt.Sprintf("Token %s", c.apiToken))
-	req.Header.Set("Accept", "application/json")
-
-	resp, err := client.Do(req)
+	resp, err := doWithRetry(ctx, client, c.retry, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, parsedURL.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", fmt.Sprintf("Token %s", c.apiToken))
+		req.Header.Set("Accept", "application/json")
+		if haveCached {
+			if cached.etag != "" {
+				req.Header.Set("If-None-Match", cached.etag)
+			}
+			if cached.lastModified != "" {
+				req.Header.Set("If-Modified-Since", cached.lastModified)
+			}
+		}
+		return req, nil
+	})
 	if err != nil {
-		return nil, nil, fmt.Errorf("executing request: %w", err)
+		return nil, nil, err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotModified && haveCached {
+		metrics.NetBoxPageCacheHitsTotal.Inc()
+		return cached.results, cached.next, nil
+	}
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 		return nil, nil, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(body))
@@ -302,10 +491,22 @@ func (c *NetBoxCache) fetchPage(ctx context.Context, client *http.Client, rawURL
 	if err := json.NewDecoder(resp.Body).Decode(&paginated); err != nil {
 		return nil, nil, fmt.Errorf("decoding response: %w", err)
 	}
+	c.pages.put(rawURL, resp.Header, paginated.Results, paginated.Next)
 
 	return paginated.Results, paginated.Next, nil
 }
 
+// normalizeIP returns the canonical string form of an IP address so that
+// IPv4, IPv4-mapped IPv6 and differently written IPv6 addresses produce the
+// same cache key. Unparseable addresses are returned unchanged.
+func normalizeIP(addr string) string {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return addr
+	}
+	return ip.String()
+}
+
 // stripCIDR removes CIDR notation from an IP address string.
 // e.g. "10.0.0.1/32" → "10.0.0.1", "10.0.0.1" → "10.0.0.1"
 func stripCIDR(addr string) string {
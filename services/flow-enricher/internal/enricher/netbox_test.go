@@ -4,11 +4,17 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/rhwendt/helios/services/flow-enricher/internal/metrics"
 )
 
 // mockNetBoxDevicesResponse builds a NetBox paginated response for devices.
@@ -464,6 +470,7 @@ func TestFetchDevices_InterfaceAPIError(t *testing.T) {
 	defer srv.Close()
 
 	cache := NewNetBoxCache(srv.URL, "test-token", time.Minute, newTestLogger())
+	cache.retry = testRetryPolicy
 	devices, err := cache.fetchDevices(context.Background())
 	if err != nil {
 		t.Fatalf("fetchDevices() error = %v (should handle interface errors gracefully)", err)
@@ -504,6 +511,109 @@ func TestFetchDevices_ContextCancellation(t *testing.T) {
 	}
 }
 
+func TestFetchDevices_ConcurrentInterfaces(t *testing.T) {
+	const (
+		numDevices  = 50
+		concurrency = 4
+	)
+
+	var inFlight, maxInFlight atomic.Int32
+	mux := http.NewServeMux()
+
+	devices := make([]json.RawMessage, 0, numDevices)
+	for i := 1; i <= numDevices; i++ {
+		devices = append(devices, mustMarshal(map[string]any{
+			"id":   i,
+			"name": fmt.Sprintf("device-%d", i),
+			"primary_ip": map[string]any{
+				"address": fmt.Sprintf("10.0.%d.%d/32", i/256, i%256),
+			},
+		}))
+	}
+
+	mux.HandleFunc("/api/dcim/devices/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(mockNetBoxDevicesResponse(devices, nil))
+	})
+	mux.HandleFunc("/api/dcim/interfaces/", func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			max := maxInFlight.Load()
+			if n <= max || maxInFlight.CompareAndSwap(max, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+
+		deviceID := r.URL.Query().Get("device_id")
+		ifaces := []json.RawMessage{
+			mustMarshal(map[string]any{
+				"id":            1,
+				"name":          "eth-" + deviceID,
+				"speed":         1000000,
+				"custom_fields": map[string]any{"snmp_index": 1},
+			}),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(mockNetBoxDevicesResponse(ifaces, nil))
+	})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	cache := NewNetBoxCache(srv.URL, "test-token", time.Minute, newTestLogger(), WithInterfaceConcurrency(concurrency))
+	result, err := cache.fetchDevices(context.Background())
+	if err != nil {
+		t.Fatalf("fetchDevices() error = %v", err)
+	}
+
+	if len(result) != numDevices {
+		t.Fatalf("expected %d devices, got %d", numDevices, len(result))
+	}
+	for i := 1; i <= numDevices; i++ {
+		ip := fmt.Sprintf("10.0.%d.%d", i/256, i%256)
+		dev, ok := result[ip]
+		if !ok {
+			t.Errorf("missing device %s", ip)
+			continue
+		}
+		want := fmt.Sprintf("eth-%d", i)
+		if got := dev.Interfaces[1].Name; got != want {
+			t.Errorf("device %s interface 1 = %q, want %q", ip, got, want)
+		}
+	}
+
+	if got := maxInFlight.Load(); got > concurrency {
+		t.Errorf("max concurrent interface fetches = %d, want <= %d", got, concurrency)
+	}
+}
+
+func TestWithInterfaceConcurrency(t *testing.T) {
+	tests := []struct {
+		name string
+		n    int
+		want int
+	}{
+		{"default", 0, DefaultInterfaceConcurrency},
+		{"custom", 16, 16},
+		{"negative ignored", -1, DefaultInterfaceConcurrency},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var opts []NetBoxCacheOption
+			if tc.n != 0 {
+				opts = append(opts, WithInterfaceConcurrency(tc.n))
+			}
+			cache := NewNetBoxCache("http://netbox", "token", time.Minute, newTestLogger(), opts...)
+			if cache.interfaceConcurrency != tc.want {
+				t.Errorf("interfaceConcurrency = %d, want %d", cache.interfaceConcurrency, tc.want)
+			}
+		})
+	}
+}
+
 func TestFetchDevices_AuthorizationHeader(t *testing.T) {
 	var receivedAuth string
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -706,3 +816,283 @@ func TestFetchDevices_IPWithoutCIDR(t *testing.T) {
 		t.Error("expected device keyed by '172.16.0.1' (no CIDR to strip)")
 	}
 }
+
+func TestFetchDevices_IPv6PrimaryIP(t *testing.T) {
+	mux := http.NewServeMux()
+
+	device := mustMarshal(map[string]any{
+		"id":   1,
+		"name": "v6-device",
+		"primary_ip": map[string]any{
+			"address": "2001:DB8:0:0::1/128",
+		},
+	})
+
+	mux.HandleFunc("/api/dcim/devices/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(mockNetBoxDevicesResponse([]json.RawMessage{device}, nil))
+	})
+	mux.HandleFunc("/api/dcim/interfaces/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(mockNetBoxDevicesResponse(nil, nil))
+	})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	cache := NewNetBoxCache(srv.URL, "test-token", time.Minute, newTestLogger())
+	devices, err := cache.fetchDevices(context.Background())
+	if err != nil {
+		t.Fatalf("fetchDevices() error = %v", err)
+	}
+
+	if _, ok := devices["2001:db8::1"]; !ok {
+		t.Errorf("expected device keyed by normalized '2001:db8::1', got keys %v", devices)
+	}
+}
+
+func TestNormalizeIP(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"10.0.0.1", "10.0.0.1"},
+		{"::ffff:10.0.0.1", "10.0.0.1"},
+		{"2001:DB8:0:0:0:0:0:1", "2001:db8::1"},
+		{"fe80::1", "fe80::1"},
+		{"not-an-ip", "not-an-ip"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.input, func(t *testing.T) {
+			if got := normalizeIP(tc.input); got != tc.want {
+				t.Errorf("normalizeIP(%q) = %q, want %q", tc.input, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRefresh_ErrorKeepsExistingCache(t *testing.T) {
+	var failing atomic.Bool
+	mux := http.NewServeMux()
+
+	devices := []json.RawMessage{
+		mustMarshal(map[string]any{
+			"id": 1, "name": "router-1",
+			"primary_ip": map[string]any{"address": "10.0.0.1/32"},
+		}),
+		mustMarshal(map[string]any{
+			"id": 2, "name": "router-2",
+			"primary_ip": map[string]any{"address": "10.0.0.2/32"},
+		}),
+	}
+
+	mux.HandleFunc("/api/dcim/devices/", func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("netbox down"))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(mockNetBoxDevicesResponse(devices, nil))
+	})
+	mux.HandleFunc("/api/dcim/interfaces/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(mockNetBoxDevicesResponse(nil, nil))
+	})
+	mux.HandleFunc("/api/ipam/prefixes/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(mockNetBoxDevicesResponse(nil, nil))
+	})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	cache := NewNetBoxCache(srv.URL, "test-token", time.Minute, newTestLogger())
+	cache.retry = testRetryPolicy
+	if err := cache.refresh(context.Background()); err != nil {
+		t.Fatalf("initial refresh() error = %v", err)
+	}
+	if cache.DeviceCount() != 2 {
+		t.Fatalf("DeviceCount() = %d, want 2", cache.DeviceCount())
+	}
+	if got := gaugeValue(t, metrics.CacheDevices); got != 2 {
+		t.Errorf("cache devices gauge = %v, want 2", got)
+	}
+	lastRefresh := gaugeValue(t, metrics.CacheLastRefreshTimestamp)
+	if lastRefresh == 0 {
+		t.Error("expected last refresh timestamp to be set")
+	}
+	errorsBefore := counterValue(t, metrics.CacheRefreshErrorsTotal)
+
+	failing.Store(true)
+	if err := cache.refresh(context.Background()); err == nil {
+		t.Fatal("expected refresh() error while NetBox is unavailable")
+	}
+
+	if cache.DeviceCount() != 2 {
+		t.Errorf("DeviceCount() after failed refresh = %d, want 2", cache.DeviceCount())
+	}
+	if _, ok := cache.LookupByIP(net.ParseIP("10.0.0.1")); !ok {
+		t.Error("expected cached device to remain after failed refresh")
+	}
+	if got := counterValue(t, metrics.CacheRefreshErrorsTotal); got != errorsBefore+1 {
+		t.Errorf("refresh errors = %v, want %v", got, errorsBefore+1)
+	}
+	if got := gaugeValue(t, metrics.CacheDevices); got != 2 {
+		t.Errorf("cache devices gauge after failed refresh = %v, want 2", got)
+	}
+	if got := gaugeValue(t, metrics.CacheLastRefreshTimestamp); got != lastRefresh {
+		t.Errorf("last refresh timestamp changed on failed refresh: %v -> %v", lastRefresh, got)
+	}
+}
+
+func gaugeValue(t *testing.T, g interface{ Write(*dto.Metric) error }) float64 {
+	t.Helper()
+	var m dto.Metric
+	if err := g.Write(&m); err != nil {
+		t.Fatalf("reading gauge: %v", err)
+	}
+	return m.GetGauge().GetValue()
+}
+
+func counterValue(t *testing.T, c interface{ Write(*dto.Metric) error }) float64 {
+	t.Helper()
+	var m dto.Metric
+	if err := c.Write(&m); err != nil {
+		t.Fatalf("reading counter: %v", err)
+	}
+	return m.GetCounter().GetValue()
+}
+
+// testRetryPolicy retries like the default policy but without the waits.
+var testRetryPolicy = retryPolicy{maxAttempts: 3, baseDelay: time.Millisecond, maxDelay: 5 * time.Millisecond}
+
+func TestFetchPage_RetriesTransientErrors(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(mockNetBoxDevicesResponse([]json.RawMessage{mustMarshal(map[string]any{"id": 1})}, nil))
+	}))
+	defer srv.Close()
+
+	cache := NewNetBoxCache(srv.URL, "test-token", time.Minute, newTestLogger())
+	cache.retry = testRetryPolicy
+	results, _, err := cache.fetchPage(context.Background(), cache.httpClient(), srv.URL+"/api/dcim/devices/")
+	if err != nil {
+		t.Fatalf("fetchPage() error = %v", err)
+	}
+	if len(results) != 1 {
+		t.Errorf("got %d results, want 1", len(results))
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("server saw %d requests, want 3", got)
+	}
+}
+
+func TestFetchPage_ClientErrorNotRetried(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		http.Error(w, "forbidden", http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	cache := NewNetBoxCache(srv.URL, "test-token", time.Minute, newTestLogger())
+	cache.retry = testRetryPolicy
+	if _, _, err := cache.fetchPage(context.Background(), cache.httpClient(), srv.URL); err == nil {
+		t.Fatal("expected error for 403 response")
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("server saw %d requests, want 1", got)
+	}
+}
+
+func TestFetchPage_HonoursRetryAfter(t *testing.T) {
+	var calls atomic.Int32
+	var first time.Time
+	var retried time.Duration
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			first = time.Now()
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		retried = time.Since(first)
+		w.Write(mockNetBoxDevicesResponse(nil, nil))
+	}))
+	defer srv.Close()
+
+	cache := NewNetBoxCache(srv.URL, "test-token", time.Minute, newTestLogger())
+	cache.retry = retryPolicy{maxAttempts: 2, baseDelay: time.Millisecond, maxDelay: 2 * time.Second}
+	if _, _, err := cache.fetchPage(context.Background(), cache.httpClient(), srv.URL); err != nil {
+		t.Fatalf("fetchPage() error = %v", err)
+	}
+	if retried < 900*time.Millisecond {
+		t.Errorf("retried after %v, want at least the 1s Retry-After", retried)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	if d, ok := retryAfter("3"); !ok || d != 3*time.Second {
+		t.Errorf("retryAfter(\"3\") = %v, %v", d, ok)
+	}
+	date := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	if d, ok := retryAfter(date); !ok || d <= 0 || d > time.Minute {
+		t.Errorf("retryAfter(%q) = %v, %v", date, d, ok)
+	}
+	for _, v := range []string{"", "soon", "-1"} {
+		if _, ok := retryAfter(v); ok {
+			t.Errorf("retryAfter(%q) should not parse", v)
+		}
+	}
+}
+
+func TestFetchDevices_CustomFilter(t *testing.T) {
+	var receivedQuery string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/api/dcim/devices/") {
+			receivedQuery = r.URL.RawQuery
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(mockNetBoxDevicesResponse(nil, nil))
+	}))
+	defer srv.Close()
+
+	cache := NewNetBoxCache(srv.URL, "test-token", time.Minute, newTestLogger(),
+		WithDeviceFilter("tag=helios"), WithDeviceStatus("planned"))
+	if _, err := cache.fetchDevices(context.Background()); err != nil {
+		t.Fatalf("fetchDevices() error = %v", err)
+	}
+
+	if receivedQuery != "tag=helios&status=planned&limit=100" {
+		t.Errorf("query = %q, want tag=helios&status=planned&limit=100", receivedQuery)
+	}
+}
+
+func TestDeviceQuery(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []NetBoxCacheOption
+		want string
+	}{
+		{"defaults", nil, "cf_helios_monitor=true&status=active&limit=100"},
+		{"no status filter", []NetBoxCacheOption{WithDeviceStatus("")}, "cf_helios_monitor=true&limit=100"},
+		{"no device filter", []NetBoxCacheOption{WithDeviceFilter("")}, "status=active&limit=100"},
+		{"multiple terms", []NetBoxCacheOption{WithDeviceFilter("tag=helios&role=core")}, "tag=helios&role=core&status=active&limit=100"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cache := NewNetBoxCache("http://netbox", "token", time.Minute, newTestLogger(), tc.opts...)
+			if got := cache.deviceQuery(); got != tc.want {
+				t.Errorf("deviceQuery() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
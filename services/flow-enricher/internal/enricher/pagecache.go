@@ -0,0 +1,92 @@
+package enricher
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+)
+
+// cachedPage is a NetBox API page kept for conditional re-fetching.
+type cachedPage struct {
+	etag         string
+	lastModified string
+	results      []json.RawMessage
+	next         *string
+	gen          uint64
+}
+
+// pageCache remembers validated NetBox pages by URL so an unchanged page can
+// be revalidated with a 304 instead of downloaded again. Pages not requested
+// during a refresh are dropped by sweep, so the cache tracks the inventory
+// rather than growing without bound. A nil *pageCache caches nothing.
+type pageCache struct {
+	mu    sync.Mutex
+	gen   uint64
+	pages map[string]cachedPage
+}
+
+func newPageCache() *pageCache {
+	return &pageCache{pages: make(map[string]cachedPage)}
+}
+
+// get returns the cached page for url and marks it as used.
+func (pc *pageCache) get(url string) (cachedPage, bool) {
+	if pc == nil {
+		return cachedPage{}, false
+	}
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	p, ok := pc.pages[url]
+	if ok {
+		p.gen = pc.gen
+		pc.pages[url] = p
+	}
+	return p, ok
+}
+
+// put stores a page if the response carried a validator. Pages without one
+// cannot be revalidated, so any stale copy is dropped instead.
+func (pc *pageCache) put(url string, header http.Header, results []json.RawMessage, next *string) {
+	if pc == nil {
+		return
+	}
+	etag, lastModified := header.Get("ETag"), header.Get("Last-Modified")
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	if etag == "" && lastModified == "" {
+		delete(pc.pages, url)
+		return
+	}
+	pc.pages[url] = cachedPage{
+		etag:         etag,
+		lastModified: lastModified,
+		results:      results,
+		next:         next,
+		gen:          pc.gen,
+	}
+}
+
+// sweep drops pages not used since the previous sweep.
+func (pc *pageCache) sweep() {
+	if pc == nil {
+		return
+	}
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	for url, p := range pc.pages {
+		if p.gen != pc.gen {
+			delete(pc.pages, url)
+		}
+	}
+	pc.gen++
+}
+
+// len returns the number of cached pages.
+func (pc *pageCache) len() int {
+	if pc == nil {
+		return 0
+	}
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	return len(pc.pages)
+}
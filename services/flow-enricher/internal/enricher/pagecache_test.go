@@ -0,0 +1,120 @@
+package enricher
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rhwendt/helios/services/flow-enricher/internal/metrics"
+)
+
+func TestFetchPage_NotModifiedReusesCachedPage(t *testing.T) {
+	var full, conditional atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			conditional.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		full.Add(1)
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(mockNetBoxDevicesResponse([]json.RawMessage{mustMarshal(map[string]any{"id": 7})}, nil))
+	}))
+	defer srv.Close()
+
+	cache := NewNetBoxCache(srv.URL, "test-token", time.Minute, newTestLogger())
+	hitsBefore := counterValue(t, metrics.NetBoxPageCacheHitsTotal)
+
+	first, _, err := cache.fetchPage(context.Background(), cache.httpClient(), srv.URL+"/api/dcim/devices/")
+	if err != nil {
+		t.Fatalf("first fetchPage() error = %v", err)
+	}
+	second, _, err := cache.fetchPage(context.Background(), cache.httpClient(), srv.URL+"/api/dcim/devices/")
+	if err != nil {
+		t.Fatalf("second fetchPage() error = %v", err)
+	}
+
+	if full.Load() != 1 || conditional.Load() != 1 {
+		t.Errorf("full fetches = %d, conditional = %d; want 1 and 1", full.Load(), conditional.Load())
+	}
+	if len(second) != 1 || string(second[0]) != string(first[0]) {
+		t.Errorf("cached results = %s, want %s", second, first)
+	}
+	if got := counterValue(t, metrics.NetBoxPageCacheHitsTotal) - hitsBefore; got != 1 {
+		t.Errorf("cache hits increased by %v, want 1", got)
+	}
+}
+
+func TestFetchPage_LastModified(t *testing.T) {
+	modified := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC).Format(http.TimeFormat)
+	var sawConditional atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-Modified-Since") == modified {
+			sawConditional.Store(true)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Last-Modified", modified)
+		w.Write(mockNetBoxDevicesResponse(nil, nil))
+	}))
+	defer srv.Close()
+
+	cache := NewNetBoxCache(srv.URL, "test-token", time.Minute, newTestLogger())
+	for i := 0; i < 2; i++ {
+		if _, _, err := cache.fetchPage(context.Background(), cache.httpClient(), srv.URL); err != nil {
+			t.Fatalf("fetchPage() #%d error = %v", i+1, err)
+		}
+	}
+	if !sawConditional.Load() {
+		t.Error("expected If-Modified-Since on the second request")
+	}
+}
+
+func TestFetchPage_NoValidatorsFullFetch(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.Header.Get("If-None-Match") != "" || r.Header.Get("If-Modified-Since") != "" {
+			t.Error("unexpected conditional request to a server without validators")
+		}
+		w.Write(mockNetBoxDevicesResponse(nil, nil))
+	}))
+	defer srv.Close()
+
+	cache := NewNetBoxCache(srv.URL, "test-token", time.Minute, newTestLogger())
+	for i := 0; i < 2; i++ {
+		if _, _, err := cache.fetchPage(context.Background(), cache.httpClient(), srv.URL); err != nil {
+			t.Fatalf("fetchPage() error = %v", err)
+		}
+	}
+	if calls.Load() != 2 {
+		t.Errorf("server saw %d requests, want 2", calls.Load())
+	}
+	if n := cache.pages.len(); n != 0 {
+		t.Errorf("cached %d pages without validators, want 0", n)
+	}
+}
+
+func TestPageCache_SweepDropsUnusedPages(t *testing.T) {
+	pc := newPageCache()
+	header := http.Header{"Etag": []string{`"v1"`}}
+	pc.put("a", header, nil, nil)
+	pc.put("b", header, nil, nil)
+	pc.sweep()
+
+	// Only "a" is used during the next refresh.
+	pc.get("a")
+	pc.sweep()
+
+	if _, ok := pc.get("a"); !ok {
+		t.Error("expected used page to be kept")
+	}
+	if _, ok := pc.get("b"); ok {
+		t.Error("expected unused page to be dropped")
+	}
+}
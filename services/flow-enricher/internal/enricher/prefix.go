@@ -0,0 +1,150 @@
+package enricher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// PrefixMetadata holds the location a NetBox prefix is assigned to.
+type PrefixMetadata struct {
+	Prefix string
+	Site   string
+	Region string
+}
+
+// prefixIndex supports longest-prefix-match lookups. Prefixes are grouped by
+// family and length so a lookup masks the address once per distinct length,
+// longest first, instead of scanning every prefix.
+type prefixIndex struct {
+	v4, v6 prefixTable
+}
+
+type prefixTable struct {
+	lengths []int                     // distinct prefix lengths, longest first
+	nets    map[string]PrefixMetadata // keyed by network address and length
+}
+
+func newPrefixIndex() *prefixIndex {
+	return &prefixIndex{
+		v4: prefixTable{nets: make(map[string]PrefixMetadata)},
+		v6: prefixTable{nets: make(map[string]PrefixMetadata)},
+	}
+}
+
+// add inserts a prefix into the index, replacing any existing entry for the
+// same network.
+func (idx *prefixIndex) add(network *net.IPNet, meta PrefixMetadata) {
+	ones, _ := network.Mask.Size()
+	table := &idx.v6
+	if network.IP.To4() != nil {
+		table = &idx.v4
+	}
+
+	key := prefixKey(network.IP, ones)
+	if _, ok := table.nets[key]; !ok {
+		i := sort.Search(len(table.lengths), func(i int) bool { return table.lengths[i] <= ones })
+		if i == len(table.lengths) || table.lengths[i] != ones {
+			table.lengths = append(table.lengths, 0)
+			copy(table.lengths[i+1:], table.lengths[i:])
+			table.lengths[i] = ones
+		}
+	}
+	table.nets[key] = meta
+}
+
+// lookup returns the most specific prefix containing ip.
+func (idx *prefixIndex) lookup(ip net.IP) (PrefixMetadata, bool) {
+	table, bits := &idx.v6, 8*net.IPv6len
+	if v4 := ip.To4(); v4 != nil {
+		table, bits, ip = &idx.v4, 8*net.IPv4len, v4
+	}
+
+	for _, ones := range table.lengths {
+		masked := ip.Mask(net.CIDRMask(ones, bits))
+		if meta, ok := table.nets[prefixKey(masked, ones)]; ok {
+			return meta, true
+		}
+	}
+	return PrefixMetadata{}, false
+}
+
+func (idx *prefixIndex) len() int {
+	return len(idx.v4.nets) + len(idx.v6.nets)
+}
+
+func prefixKey(network net.IP, ones int) string {
+	return fmt.Sprintf("%s/%d", network, ones)
+}
+
+// netboxPrefix represents the relevant fields from a NetBox prefix API
+// response. NetBox 4.2 replaced the site field with a generic scope.
+type netboxPrefix struct {
+	Prefix string `json:"prefix"`
+	Site   *struct {
+		Name string `json:"name"`
+	} `json:"site"`
+	ScopeType string `json:"scope_type"`
+	Scope     *struct {
+		Name string `json:"name"`
+	} `json:"scope"`
+}
+
+// fetchPrefixes queries the NetBox API for all active prefixes assigned to a
+// site or region. siteRegions maps site names to their region, since nested
+// sites in prefix responses do not include it.
+func (c *NetBoxCache) fetchPrefixes(ctx context.Context, client *http.Client, siteRegions map[string]string) (*prefixIndex, error) {
+	idx := newPrefixIndex()
+
+	nextURL := fmt.Sprintf("%s/api/ipam/prefixes/?status=active&limit=100", strings.TrimRight(c.apiURL, "/"))
+
+	for nextURL != "" {
+		rawPrefixes, next, err := c.fetchPage(ctx, client, nextURL)
+		if err != nil {
+			return nil, fmt.Errorf("fetching prefixes page: %w", err)
+		}
+
+		for _, raw := range rawPrefixes {
+			var p netboxPrefix
+			if err := json.Unmarshal(raw, &p); err != nil {
+				c.logger.Warn("skipping prefix with unparseable data", "error", err)
+				continue
+			}
+
+			_, network, err := net.ParseCIDR(p.Prefix)
+			if err != nil {
+				c.logger.Warn("skipping prefix with invalid CIDR", "prefix", p.Prefix, "error", err)
+				continue
+			}
+
+			meta := PrefixMetadata{Prefix: network.String()}
+			switch {
+			case p.Site != nil:
+				meta.Site = p.Site.Name
+			case p.Scope != nil && p.ScopeType == "dcim.site":
+				meta.Site = p.Scope.Name
+			case p.Scope != nil && p.ScopeType == "dcim.region":
+				meta.Region = p.Scope.Name
+			default:
+				continue // Not tied to a location — nothing to enrich with.
+			}
+			if meta.Region == "" {
+				meta.Region = siteRegions[meta.Site]
+			}
+
+			idx.add(network, meta)
+		}
+
+		if next != nil {
+			nextURL = *next
+		} else {
+			nextURL = ""
+		}
+	}
+
+	return idx, nil
+}
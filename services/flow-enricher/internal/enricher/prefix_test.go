@@ -0,0 +1,202 @@
+package enricher
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func mustParseCIDR(s string) *net.IPNet {
+	_, network, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	return network
+}
+
+func TestPrefixIndex_LongestMatchWins(t *testing.T) {
+	idx := newPrefixIndex()
+	idx.add(mustParseCIDR("10.0.0.0/8"), PrefixMetadata{Prefix: "10.0.0.0/8", Site: "global", Region: "all"})
+	idx.add(mustParseCIDR("10.1.0.0/16"), PrefixMetadata{Prefix: "10.1.0.0/16", Site: "dc1", Region: "us-east"})
+	idx.add(mustParseCIDR("10.1.2.0/24"), PrefixMetadata{Prefix: "10.1.2.0/24", Site: "dc1-pod2", Region: "us-east"})
+	idx.add(mustParseCIDR("2001:db8::/32"), PrefixMetadata{Prefix: "2001:db8::/32", Site: "v6-global"})
+	idx.add(mustParseCIDR("2001:db8:1::/48"), PrefixMetadata{Prefix: "2001:db8:1::/48", Site: "v6-dc1"})
+
+	tests := []struct {
+		ip         string
+		wantPrefix string
+		wantSite   string
+		wantFound  bool
+	}{
+		{"10.1.2.3", "10.1.2.0/24", "dc1-pod2", true},
+		{"10.1.3.3", "10.1.0.0/16", "dc1", true},
+		{"10.200.0.1", "10.0.0.0/8", "global", true},
+		{"::ffff:10.1.2.3", "10.1.2.0/24", "dc1-pod2", true},
+		{"192.168.1.1", "", "", false},
+		{"2001:db8:1::10", "2001:db8:1::/48", "v6-dc1", true},
+		{"2001:db8:2::10", "2001:db8::/32", "v6-global", true},
+		{"2001:dead::1", "", "", false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.ip, func(t *testing.T) {
+			meta, ok := idx.lookup(net.ParseIP(tc.ip))
+			if ok != tc.wantFound {
+				t.Fatalf("lookup(%s) found = %v, want %v", tc.ip, ok, tc.wantFound)
+			}
+			if meta.Prefix != tc.wantPrefix {
+				t.Errorf("lookup(%s) prefix = %q, want %q", tc.ip, meta.Prefix, tc.wantPrefix)
+			}
+			if meta.Site != tc.wantSite {
+				t.Errorf("lookup(%s) site = %q, want %q", tc.ip, meta.Site, tc.wantSite)
+			}
+		})
+	}
+}
+
+func TestPrefixIndex_InsertionOrderIndependent(t *testing.T) {
+	idx := newPrefixIndex()
+	idx.add(mustParseCIDR("10.1.2.0/24"), PrefixMetadata{Site: "specific"})
+	idx.add(mustParseCIDR("10.0.0.0/8"), PrefixMetadata{Site: "broad"})
+	idx.add(mustParseCIDR("10.1.0.0/16"), PrefixMetadata{Site: "middle"})
+
+	meta, ok := idx.lookup(net.ParseIP("10.1.2.50"))
+	if !ok || meta.Site != "specific" {
+		t.Errorf("lookup = %+v, %v; want site specific", meta, ok)
+	}
+	if idx.len() != 3 {
+		t.Errorf("len() = %d, want 3", idx.len())
+	}
+}
+
+func TestNetBoxCache_LookupByPrefix(t *testing.T) {
+	cache := newPopulatedCache(map[string]DeviceMetadata{
+		"10.1.2.1": {Name: "router-1", Site: "dc1-core", Region: "us-east"},
+	})
+	cache.prefixes = newPrefixIndex()
+	cache.prefixes.add(mustParseCIDR("10.1.0.0/16"), PrefixMetadata{Prefix: "10.1.0.0/16", Site: "dc1", Region: "us-east"})
+	cache.prefixes.add(mustParseCIDR("10.1.2.0/24"), PrefixMetadata{Prefix: "10.1.2.0/24", Site: "dc1-pod2", Region: "us-east"})
+
+	t.Run("exact device match takes precedence", func(t *testing.T) {
+		meta, ok := cache.LookupByPrefix(net.ParseIP("10.1.2.1"))
+		if !ok {
+			t.Fatal("expected match")
+		}
+		if meta.Site != "dc1-core" {
+			t.Errorf("Site = %q, want dc1-core", meta.Site)
+		}
+	})
+
+	t.Run("falls back to most specific prefix", func(t *testing.T) {
+		meta, ok := cache.LookupByPrefix(net.ParseIP("10.1.2.77"))
+		if !ok {
+			t.Fatal("expected match")
+		}
+		if meta.Site != "dc1-pod2" || meta.Region != "us-east" {
+			t.Errorf("got %+v, want site dc1-pod2 region us-east", meta)
+		}
+	})
+
+	t.Run("no match outside known prefixes", func(t *testing.T) {
+		if _, ok := cache.LookupByPrefix(net.ParseIP("172.16.0.1")); ok {
+			t.Error("expected no match")
+		}
+	})
+
+	t.Run("cache without prefix index", func(t *testing.T) {
+		bare := newPopulatedCache(map[string]DeviceMetadata{})
+		if _, ok := bare.LookupByPrefix(net.ParseIP("10.1.2.77")); ok {
+			t.Error("expected no match")
+		}
+	})
+}
+
+func TestFetchPrefixes(t *testing.T) {
+	mux := http.NewServeMux()
+
+	prefixes := []json.RawMessage{
+		mustMarshal(map[string]any{
+			"prefix": "10.1.0.0/16",
+			"site":   map[string]any{"name": "dc1"},
+		}),
+		mustMarshal(map[string]any{
+			"prefix":     "10.2.0.0/16",
+			"scope_type": "dcim.site",
+			"scope":      map[string]any{"name": "dc2"},
+		}),
+		mustMarshal(map[string]any{
+			"prefix":     "10.3.0.0/16",
+			"scope_type": "dcim.region",
+			"scope":      map[string]any{"name": "eu-west"},
+		}),
+		mustMarshal(map[string]any{
+			"prefix": "10.4.0.0/16", // no location
+		}),
+		mustMarshal(map[string]any{
+			"prefix": "not-a-cidr",
+			"site":   map[string]any{"name": "dc1"},
+		}),
+	}
+
+	mux.HandleFunc("/api/ipam/prefixes/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("status") != "active" {
+			t.Errorf("expected status=active filter, got %q", r.URL.RawQuery)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(mockNetBoxDevicesResponse(prefixes, nil))
+	})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	cache := NewNetBoxCache(srv.URL, "test-token", time.Minute, newTestLogger())
+	idx, err := cache.fetchPrefixes(context.Background(), cache.httpClient(), map[string]string{"dc1": "us-east"})
+	if err != nil {
+		t.Fatalf("fetchPrefixes() error = %v", err)
+	}
+
+	if idx.len() != 3 {
+		t.Fatalf("expected 3 indexed prefixes, got %d", idx.len())
+	}
+
+	tests := []struct {
+		ip         string
+		wantSite   string
+		wantRegion string
+	}{
+		{"10.1.0.1", "dc1", "us-east"},
+		{"10.2.0.1", "dc2", ""},
+		{"10.3.0.1", "", "eu-west"},
+	}
+	for _, tc := range tests {
+		meta, ok := idx.lookup(net.ParseIP(tc.ip))
+		if !ok {
+			t.Errorf("expected prefix match for %s", tc.ip)
+			continue
+		}
+		if meta.Site != tc.wantSite || meta.Region != tc.wantRegion {
+			t.Errorf("lookup(%s) = %+v, want site %q region %q", tc.ip, meta, tc.wantSite, tc.wantRegion)
+		}
+	}
+	if _, ok := idx.lookup(net.ParseIP("10.4.0.1")); ok {
+		t.Error("expected prefix without location to be skipped")
+	}
+}
+
+func TestFetchPrefixes_APIError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("internal error"))
+	}))
+	defer srv.Close()
+
+	cache := NewNetBoxCache(srv.URL, "test-token", time.Minute, newTestLogger())
+	cache.retry = testRetryPolicy
+	if _, err := cache.fetchPrefixes(context.Background(), cache.httpClient(), nil); err == nil {
+		t.Fatal("expected error for 500 response")
+	}
+}
@@ -0,0 +1,50 @@
+package enricher
+
+import "strconv"
+
+// protocolNames maps IANA protocol numbers to their keyword. Numbers without
+// a well-known name map to their decimal string, so that lookups for any
+// 8-bit protocol number never allocate.
+var protocolNames = func() [256]string {
+	var names [256]string
+	for i := range names {
+		names[i] = strconv.Itoa(i)
+	}
+	for num, name := range map[int]string{
+		1:   "ICMP",
+		2:   "IGMP",
+		4:   "IPv4",
+		6:   "TCP",
+		17:  "UDP",
+		41:  "IPv6",
+		43:  "IPv6-Route",
+		44:  "IPv6-Frag",
+		46:  "RSVP",
+		47:  "GRE",
+		50:  "ESP",
+		51:  "AH",
+		58:  "ICMPv6",
+		59:  "IPv6-NoNxt",
+		60:  "IPv6-Opts",
+		88:  "EIGRP",
+		89:  "OSPF",
+		94:  "IPIP",
+		103: "PIM",
+		112: "VRRP",
+		115: "L2TP",
+		132: "SCTP",
+		137: "MPLS-in-IP",
+	} {
+		names[num] = name
+	}
+	return names
+}()
+
+// protocolName returns the name of an IP protocol number, or the number as a
+// string when it has no well-known name.
+func protocolName(proto uint32) string {
+	if proto < uint32(len(protocolNames)) {
+		return protocolNames[proto]
+	}
+	return strconv.FormatUint(uint64(proto), 10)
+}
@@ -0,0 +1,54 @@
+package enricher
+
+import (
+	"testing"
+
+	flowpb "github.com/rhwendt/helios/services/flow-enricher/internal/proto"
+)
+
+func TestProtocolName(t *testing.T) {
+	tests := []struct {
+		proto uint32
+		want  string
+	}{
+		{1, "ICMP"},
+		{6, "TCP"},
+		{17, "UDP"},
+		{47, "GRE"},
+		{50, "ESP"},
+		{58, "ICMPv6"},
+		{89, "OSPF"},
+		{132, "SCTP"},
+		{0, "0"},
+		{253, "253"},
+		{300, "300"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.want, func(t *testing.T) {
+			if got := protocolName(tc.proto); got != tc.want {
+				t.Errorf("protocolName(%d) = %q, want %q", tc.proto, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestProtocolName_NoAllocation(t *testing.T) {
+	allocs := testing.AllocsPerRun(100, func() {
+		_ = protocolName(6)
+		_ = protocolName(253)
+	})
+	if allocs != 0 {
+		t.Errorf("protocolName allocated %v times per run, want 0", allocs)
+	}
+}
+
+func TestEnrichFlow_ProtoName(t *testing.T) {
+	e := New(newPopulatedCache(map[string]DeviceMetadata{}), nil, newTestLogger())
+
+	result := e.Enrich(&flowpb.EnrichedFlow{Protocol: 17})
+
+	if result.ProtoName != "UDP" {
+		t.Errorf("ProtoName = %q, want UDP", result.ProtoName)
+	}
+}
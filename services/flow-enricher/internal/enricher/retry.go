@@ -0,0 +1,96 @@
+package enricher
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// retryPolicy bounds how NetBox requests are retried after transient
+// failures.
+type retryPolicy struct {
+	maxAttempts int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+}
+
+// defaultRetryPolicy tolerates a NetBox restart or a brief overload without
+// stretching a refresh by more than about half a minute per request.
+var defaultRetryPolicy = retryPolicy{
+	maxAttempts: 4,
+	baseDelay:   500 * time.Millisecond,
+	maxDelay:    10 * time.Second,
+}
+
+// doWithRetry sends the request built by newReq, retrying network errors,
+// 429 and 5xx responses with jittered exponential backoff. A Retry-After
+// header overrides the computed delay, up to maxDelay. Any other response is
+// returned to the caller as is, as is the last response once attempts run
+// out.
+func doWithRetry(ctx context.Context, client *http.Client, policy retryPolicy, newReq func() (*http.Request, error)) (*http.Response, error) {
+	attempts := max(policy.maxAttempts, 1)
+	delay := policy.baseDelay
+
+	for attempt := 1; ; attempt++ {
+		req, err := newReq()
+		if err != nil {
+			return nil, fmt.Errorf("creating request: %w", err)
+		}
+
+		resp, err := client.Do(req)
+		if err == nil && !retryableStatus(resp.StatusCode) {
+			return resp, nil
+		}
+		if attempt >= attempts || ctx.Err() != nil {
+			if err != nil {
+				return nil, fmt.Errorf("executing request: %w", err)
+			}
+			return resp, nil
+		}
+
+		wait := jitter(min(delay, policy.maxDelay))
+		if err == nil {
+			if after, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+				wait = min(after, policy.maxDelay)
+			}
+			resp.Body.Close()
+		}
+		delay *= 2
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// retryableStatus reports whether a response status is worth retrying.
+func retryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+}
+
+// retryAfter parses a Retry-After header given in seconds or as an HTTP date.
+func retryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0), true
+	}
+	return 0, false
+}
+
+// jitter returns a random duration in [d/2, d].
+func jitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + rand.N(d/2+1)
+}
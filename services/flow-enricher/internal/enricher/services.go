@@ -0,0 +1,82 @@
+package enricher
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"strconv"
+	"strings"
+
+	flowpb "github.com/rhwendt/helios/services/flow-enricher/internal/proto"
+)
+
+//go:embed services.txt
+var servicesTable string
+
+// serviceKey identifies a service by transport port and IP protocol number.
+type serviceKey struct {
+	port  uint32
+	proto uint32
+}
+
+// serviceNames maps well-known (port, protocol) pairs to service names.
+var serviceNames = mustParseServices(servicesTable)
+
+// mustParseServices parses an /etc/services style table of
+// "name port/protocol" lines. Blank lines and # comments are ignored.
+func mustParseServices(table string) map[serviceKey]string {
+	names := make(map[serviceKey]string)
+	scanner := bufio.NewScanner(strings.NewReader(table))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			panic(fmt.Sprintf("invalid services entry %q", line))
+		}
+
+		portStr, protoStr, ok := strings.Cut(fields[1], "/")
+		port, err := strconv.ParseUint(portStr, 10, 16)
+		if !ok || err != nil {
+			panic(fmt.Sprintf("invalid services entry %q", line))
+		}
+		var proto uint32
+		switch protoStr {
+		case "tcp":
+			proto = 6
+		case "udp":
+			proto = 17
+		default:
+			panic(fmt.Sprintf("unsupported protocol in services entry %q", line))
+		}
+		names[serviceKey{port: uint32(port), proto: proto}] = fields[0]
+	}
+	return names
+}
+
+// applyServiceNames labels the server side of the flow with its well-known
+// service name. When both ports are well-known, the lower one is taken as
+// the server port.
+func (e *Enricher) applyServiceNames(flow *flowpb.EnrichedFlow) {
+	src, srcOK := serviceNames[serviceKey{port: flow.SrcPort, proto: flow.Protocol}]
+	dst, dstOK := serviceNames[serviceKey{port: flow.DstPort, proto: flow.Protocol}]
+	if srcOK && dstOK {
+		switch {
+		case flow.SrcPort < flow.DstPort:
+			dstOK = false
+		case flow.DstPort < flow.SrcPort:
+			srcOK = false
+		}
+	}
+	if srcOK {
+		flow.SrcService = src
+	}
+	if dstOK {
+		flow.DstService = dst
+	}
+}
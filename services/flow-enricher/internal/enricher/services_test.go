@@ -0,0 +1,79 @@
+package enricher
+
+import (
+	"testing"
+
+	flowpb "github.com/rhwendt/helios/services/flow-enricher/internal/proto"
+)
+
+func TestEnrichFlow_ServiceNames(t *testing.T) {
+	tests := []struct {
+		name       string
+		protocol   uint32
+		srcPort    uint32
+		dstPort    uint32
+		wantSrcSvc string
+		wantDstSvc string
+	}{
+		{"client to https", 6, 51514, 443, "", "https"},
+		{"https response", 6, 443, 51514, "https", ""},
+		{"dns query over udp", 17, 40000, 53, "", "dns"},
+		{"udp 443 is quic", 17, 50000, 443, "", "https"},
+		{"ssh", 6, 60000, 22, "", "ssh"},
+		{"both well-known prefers lower port", 6, 8080, 22, "", "ssh"},
+		{"ephemeral ports yield empty", 6, 49152, 61000, "", ""},
+		{"port known for other protocol only", 17, 50000, 22, "", ""},
+	}
+
+	e := New(newPopulatedCache(map[string]DeviceMetadata{}), nil, newTestLogger(), WithServiceNames(true))
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			result := e.Enrich(&flowpb.EnrichedFlow{
+				Protocol: tc.protocol,
+				SrcPort:  tc.srcPort,
+				DstPort:  tc.dstPort,
+			})
+			if result.SrcService != tc.wantSrcSvc {
+				t.Errorf("SrcService = %q, want %q", result.SrcService, tc.wantSrcSvc)
+			}
+			if result.DstService != tc.wantDstSvc {
+				t.Errorf("DstService = %q, want %q", result.DstService, tc.wantDstSvc)
+			}
+		})
+	}
+}
+
+func TestEnrichFlow_ServiceNamesDisabled(t *testing.T) {
+	e := New(newPopulatedCache(map[string]DeviceMetadata{}), nil, newTestLogger())
+
+	result := e.Enrich(&flowpb.EnrichedFlow{Protocol: 6, SrcPort: 51514, DstPort: 443})
+
+	if result.SrcService != "" || result.DstService != "" {
+		t.Errorf("expected no service names when disabled, got %q/%q", result.SrcService, result.DstService)
+	}
+}
+
+func TestMustParseServices(t *testing.T) {
+	names := mustParseServices("# comment\n\nhttps 443/tcp # trailing\ndns 53/udp\n")
+	if len(names) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(names))
+	}
+	if names[serviceKey{port: 443, proto: 6}] != "https" {
+		t.Error("expected 443/tcp to be https")
+	}
+	if names[serviceKey{port: 53, proto: 17}] != "dns" {
+		t.Error("expected 53/udp to be dns")
+	}
+
+	for _, bad := range []string{"http\n", "http 80\n", "http 80/sctp\n", "http 99999/tcp\n"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected panic for %q", bad)
+				}
+			}()
+			mustParseServices(bad)
+		}()
+	}
+}
@@ -2,26 +2,105 @@ package kafka
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"strconv"
 	"time"
 
 	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
 	"google.golang.org/protobuf/proto"
 
+	"github.com/rhwendt/helios/services/flow-enricher/internal/metrics"
 	flowpb "github.com/rhwendt/helios/services/flow-enricher/internal/proto"
 )
 
 // MessageHandler processes a batch of flow messages.
 type MessageHandler func(ctx context.Context, flows []*flowpb.EnrichedFlow) error
 
+// consumerClient is the subset of *kafka.Consumer used by Consumer.
+type consumerClient interface {
+	Subscribe(topic string, rebalanceCb kafka.RebalanceCb) error
+	Poll(timeoutMs int) kafka.Event
+	StoreMessage(m *kafka.Message) ([]kafka.TopicPartition, error)
+	Seek(partition kafka.TopicPartition, ignoredTimeoutMs int) error
+	Assignment() ([]kafka.TopicPartition, error)
+	Position(partitions []kafka.TopicPartition) ([]kafka.TopicPartition, error)
+	GetWatermarkOffsets(topic string, partition int32) (low, high int64, err error)
+	Close() error
+}
+
+const (
+	// retryBackoff is how long the consumer waits before re-reading a batch
+	// whose processing failed.
+	retryBackoff = time.Second
+
+	// DefaultMaxReconnects is how many times the consumer tries to recreate
+	// its client after all brokers went down before giving up.
+	DefaultMaxReconnects = 5
+
+	// DefaultReconnectBackoff is the delay before the first reconnect
+	// attempt; it doubles with each further attempt.
+	DefaultReconnectBackoff = 2 * time.Second
+
+	// lagInterval is how often the consumer lag metric is refreshed.
+	lagInterval = 10 * time.Second
+)
+
+// errAllBrokersDown is returned by pollBatch when librdkafka reports that no
+// broker is reachable.
+var errAllBrokersDown = errors.New("all Kafka brokers down")
+
 // Consumer reads raw flow protobuf messages from a Kafka topic.
 type Consumer struct {
-	consumer  *kafka.Consumer
-	topic     string
-	batchSize int
-	handler   MessageHandler
-	logger    *slog.Logger
+	client     consumerClient
+	newClient  func() (consumerClient, error)
+	topic      string
+	batchSize  int
+	handler    MessageHandler
+	deadLetter DeadLetterWriter
+	logger     *slog.Logger
+
+	maxReconnects    int
+	reconnectBackoff time.Duration
+
+	// slots bounds the batches being handled concurrently when more than
+	// one may be in flight; pending holds them in the order they were read.
+	slots   chan struct{}
+	pending []*inFlightBatch
+
+	lagUpdated time.Time
+}
+
+// inFlightBatch is a batch being handled while the consumer keeps polling.
+type inFlightBatch struct {
+	msgs []*kafka.Message
+	done chan struct{}
+	err  error
+}
+
+// ConsumerOption configures optional Consumer behaviour.
+type ConsumerOption func(*Consumer)
+
+// WithDeadLetter routes messages that cannot be unmarshalled to w instead of
+// only dropping them.
+func WithDeadLetter(w DeadLetterWriter) ConsumerOption {
+	return func(c *Consumer) {
+		c.deadLetter = w
+	}
+}
+
+// WithMaxInFlight lets up to n batches be handled concurrently, so polling
+// the next batch overlaps with producing the previous ones. Once n batches
+// are in flight the consumer stops polling until one completes. Offsets are
+// still stored in the order batches were read. Values below two keep the
+// default of handling one batch at a time.
+func WithMaxInFlight(n int) ConsumerOption {
+	return func(c *Consumer) {
+		if n > 1 {
+			c.slots = make(chan struct{}, n)
+		}
+	}
 }
 
 // ConsumerConfig holds configuration for the Kafka consumer.
@@ -30,20 +109,22 @@ type ConsumerConfig struct {
 	GroupID   string
 	Topic     string
 	BatchSize int
+	SecurityConfig
 }
 
 // NewConsumer creates a new Kafka consumer.
-func NewConsumer(cfg ConsumerConfig, handler MessageHandler, logger *slog.Logger) (*Consumer, error) {
-	c, err := kafka.NewConsumer(&kafka.ConfigMap{
-		"bootstrap.servers":        cfg.Brokers,
-		"group.id":                 cfg.GroupID,
-		"auto.offset.reset":        "latest",
-		"enable.auto.offset.store": false,
-		"session.timeout.ms":       30000,
-		"max.poll.interval.ms":     300000,
-	})
+func NewConsumer(cfg ConsumerConfig, handler MessageHandler, logger *slog.Logger, opts ...ConsumerOption) (*Consumer, error) {
+	cm := consumerConfigMap(cfg)
+	newClient := func() (consumerClient, error) {
+		c, err := kafka.NewConsumer(&cm)
+		if err != nil {
+			return nil, fmt.Errorf("creating Kafka consumer: %w", err)
+		}
+		return c, nil
+	}
+	c, err := newClient()
 	if err != nil {
-		return nil, fmt.Errorf("creating Kafka consumer: %w", err)
+		return nil, err
 	}
 
 	batchSize := cfg.BatchSize
@@ -51,18 +132,40 @@ func NewConsumer(cfg ConsumerConfig, handler MessageHandler, logger *slog.Logger
 		batchSize = 100
 	}
 
-	return &Consumer{
-		consumer:  c,
-		topic:     cfg.Topic,
-		batchSize: batchSize,
-		handler:   handler,
-		logger:    logger,
-	}, nil
+	consumer := &Consumer{
+		client:           c,
+		newClient:        newClient,
+		topic:            cfg.Topic,
+		batchSize:        batchSize,
+		handler:          handler,
+		logger:           logger,
+		maxReconnects:    DefaultMaxReconnects,
+		reconnectBackoff: DefaultReconnectBackoff,
+	}
+	for _, opt := range opts {
+		opt(consumer)
+	}
+	return consumer, nil
+}
+
+// consumerConfigMap builds the librdkafka configuration for cfg.
+func consumerConfigMap(cfg ConsumerConfig) kafka.ConfigMap {
+	cm := kafka.ConfigMap{
+		"bootstrap.servers":        cfg.Brokers,
+		"group.id":                 cfg.GroupID,
+		"auto.offset.reset":        "latest",
+		"enable.auto.offset.store": false,
+		"session.timeout.ms":       30000,
+		"max.poll.interval.ms":     300000,
+	}
+	cfg.SecurityConfig.apply(cm)
+	return cm
 }
 
-// Start begins consuming messages. It blocks until the context is cancelled.
+// Start begins consuming messages. It blocks until the context is cancelled
+// or the consumer cannot reconnect after all brokers went down.
 func (c *Consumer) Start(ctx context.Context) error {
-	if err := c.consumer.Subscribe(c.topic, nil); err != nil {
+	if err := c.client.Subscribe(c.topic, nil); err != nil {
 		return fmt.Errorf("subscribing to topic %s: %w", c.topic, err)
 	}
 
@@ -72,61 +175,285 @@ func (c *Consumer) Start(ctx context.Context) error {
 		select {
 		case <-ctx.Done():
 			c.logger.Info("shutting down Kafka consumer")
-			c.consumer.Close()
+			c.drain()
+			c.client.Close()
 			return ctx.Err()
 		default:
-			batch, err := c.pollBatch(ctx)
+			batch, msgs, err := c.pollBatch(ctx)
+			if errors.Is(err, errAllBrokersDown) {
+				// Uncommitted messages are re-read by the new client.
+				c.drain()
+				if err := c.reconnect(ctx); err != nil {
+					return err
+				}
+				continue
+			}
 			if err != nil {
 				c.logger.Error("error polling batch", "error", err)
+				c.rewind(msgs)
 				continue
 			}
-			if len(batch) == 0 {
-				continue
+			if len(msgs) > 0 {
+				metrics.BatchSize.Observe(float64(len(msgs)))
+			}
+			if time.Since(c.lagUpdated) >= lagInterval {
+				c.updateLag()
+			}
+			switch {
+			case len(msgs) == 0:
+				err = c.settle(false)
+			case c.slots != nil:
+				err = c.dispatch(ctx, batch, msgs)
+			default:
+				err = c.processBatch(ctx, batch, msgs)
+			}
+			if err != nil {
+				c.logger.Error("error processing batch", "error", err)
+				select {
+				case <-ctx.Done():
+				case <-time.After(retryBackoff):
+				}
+			}
+		}
+	}
+}
+
+// reconnect replaces the client with a newly created and subscribed one,
+// backing off exponentially between attempts. It gives up after
+// maxReconnects attempts.
+func (c *Consumer) reconnect(ctx context.Context) error {
+	if err := c.client.Close(); err != nil {
+		c.logger.Warn("failed to close Kafka consumer", "error", err)
+	}
+
+	backoff := c.reconnectBackoff
+	for attempt := 1; attempt <= c.maxReconnects; attempt++ {
+		metrics.ConsumerReconnectsTotal.Inc()
+		c.logger.Warn("all Kafka brokers down, reconnecting", "attempt", attempt, "backoff", backoff)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+
+		client, err := c.newClient()
+		if err != nil {
+			c.logger.Error("failed to recreate Kafka consumer", "attempt", attempt, "error", err)
+			continue
+		}
+		if err := client.Subscribe(c.topic, nil); err != nil {
+			c.logger.Error("failed to resubscribe", "topic", c.topic, "attempt", attempt, "error", err)
+			client.Close()
+			continue
+		}
+
+		c.client = client
+		c.logger.Info("Kafka consumer reconnected", "topic", c.topic, "attempt", attempt)
+		return nil
+	}
+	return fmt.Errorf("%w: gave up after %d reconnect attempts", errAllBrokersDown, c.maxReconnects)
+}
+
+// processBatch runs the handler on batch and, only if it succeeds, stores the
+// offsets of msgs so they are committed. On failure the consumer is rewound
+// to the start of the batch so the messages are read again.
+func (c *Consumer) processBatch(ctx context.Context, batch []*flowpb.EnrichedFlow, msgs []*kafka.Message) error {
+	if len(batch) > 0 {
+		if err := c.handler(ctx, batch); err != nil {
+			c.rewind(msgs)
+			return err
+		}
+	}
+
+	for _, m := range msgs {
+		if _, err := c.client.StoreMessage(m); err != nil {
+			c.logger.Warn("failed to store offset", "error", err)
+		}
+	}
+	return nil
+}
+
+// dispatch hands batch to the handler in the background, first waiting for
+// a free slot if the maximum number of batches are already in flight. It
+// then settles any batches that have completed, returning the error of a
+// failed one.
+func (c *Consumer) dispatch(ctx context.Context, batch []*flowpb.EnrichedFlow, msgs []*kafka.Message) error {
+	select {
+	case c.slots <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	b := &inFlightBatch{msgs: msgs, done: make(chan struct{})}
+	c.pending = append(c.pending, b)
+	metrics.InFlightBatches.Inc()
+	go func() {
+		if len(batch) > 0 {
+			b.err = c.handler(ctx, batch)
+		}
+		metrics.InFlightBatches.Dec()
+		close(b.done)
+		<-c.slots
+	}()
+	return c.settle(false)
+}
+
+// settle stores the offsets of completed in-flight batches in the order they
+// were read, stopping at the first batch still running unless wait is set.
+// If a batch failed, every later batch is waited for and the consumer is
+// rewound to the start of the failed one, so the failed batch and any read
+// after it are handled again.
+func (c *Consumer) settle(wait bool) error {
+	for len(c.pending) > 0 {
+		head := c.pending[0]
+		if wait {
+			<-head.done
+		} else {
+			select {
+			case <-head.done:
+			default:
+				return nil
+			}
+		}
+
+		if head.err != nil {
+			var msgs []*kafka.Message
+			for _, b := range c.pending {
+				<-b.done
+				msgs = append(msgs, b.msgs...)
 			}
+			c.pending = nil
+			c.rewind(msgs)
+			return head.err
+		}
 
-			if err := c.handler(ctx, batch); err != nil {
-				c.logger.Error("error processing batch", "error", err, "batch_size", len(batch))
+		for _, m := range head.msgs {
+			if _, err := c.client.StoreMessage(m); err != nil {
+				c.logger.Warn("failed to store offset", "error", err)
 			}
 		}
+		c.pending = c.pending[1:]
+	}
+	return nil
+}
+
+// drain waits for every in-flight batch and stores the offsets of those that
+// succeeded.
+func (c *Consumer) drain() {
+	if err := c.settle(true); err != nil {
+		c.logger.Warn("in-flight batch failed", "error", err)
 	}
 }
 
-// pollBatch reads up to batchSize messages from Kafka.
-func (c *Consumer) pollBatch(ctx context.Context) ([]*flowpb.EnrichedFlow, error) {
+// rewind seeks each partition in msgs back to its earliest offset in msgs.
+func (c *Consumer) rewind(msgs []*kafka.Message) {
+	earliest := make(map[int32]kafka.TopicPartition)
+	for _, m := range msgs {
+		tp, ok := earliest[m.TopicPartition.Partition]
+		if !ok || m.TopicPartition.Offset < tp.Offset {
+			earliest[m.TopicPartition.Partition] = kafka.TopicPartition{
+				Topic:     m.TopicPartition.Topic,
+				Partition: m.TopicPartition.Partition,
+				Offset:    m.TopicPartition.Offset,
+			}
+		}
+	}
+	for _, tp := range earliest {
+		if err := c.client.Seek(tp, 0); err != nil {
+			c.logger.Warn("failed to rewind partition", "partition", tp.Partition, "offset", tp.Offset, "error", err)
+		}
+	}
+}
+
+// updateLag sets the consumer lag metric of each assigned partition to the
+// distance between the consumer position and the high watermark last seen by
+// the client. Partitions whose position or watermark is not yet known are
+// left out.
+func (c *Consumer) updateLag() {
+	c.lagUpdated = time.Now()
+	assigned, err := c.client.Assignment()
+	if err != nil {
+		c.logger.Warn("failed to read partition assignment", "error", err)
+		return
+	}
+	positions, err := c.client.Position(assigned)
+	if err != nil {
+		c.logger.Warn("failed to read consumer position", "error", err)
+		return
+	}
+
+	metrics.ConsumerLag.Reset()
+	for _, tp := range positions {
+		if tp.Topic == nil || tp.Offset < 0 {
+			continue
+		}
+		_, high, err := c.client.GetWatermarkOffsets(*tp.Topic, tp.Partition)
+		if err != nil || high < 0 {
+			continue
+		}
+		lag := high - int64(tp.Offset)
+		if lag < 0 {
+			lag = 0
+		}
+		metrics.ConsumerLag.WithLabelValues(strconv.Itoa(int(tp.Partition))).Set(float64(lag))
+	}
+}
+
+// pollBatch reads up to batchSize messages from Kafka. It returns the
+// decoded flows along with every message read, including ones that failed
+// to decode, so their offsets can be stored once the batch is processed.
+func (c *Consumer) pollBatch(ctx context.Context) ([]*flowpb.EnrichedFlow, []*kafka.Message, error) {
 	var batch []*flowpb.EnrichedFlow
+	var msgs []*kafka.Message
 	timeout := 100 * time.Millisecond
 
 	for i := 0; i < c.batchSize; i++ {
 		select {
 		case <-ctx.Done():
-			return batch, ctx.Err()
+			return batch, msgs, ctx.Err()
 		default:
 		}
 
-		ev := c.consumer.Poll(int(timeout.Milliseconds()))
+		ev := c.client.Poll(int(timeout.Milliseconds()))
 		if ev == nil {
 			break
 		}
 
 		switch e := ev.(type) {
 		case *kafka.Message:
-			flow := &flowpb.EnrichedFlow{}
-			if err := proto.Unmarshal(e.Value, flow); err != nil {
-				c.logger.Warn("failed to unmarshal flow", "error", err)
-				continue
-			}
-			batch = append(batch, flow)
-
-			if _, err := c.consumer.StoreMessage(e); err != nil {
-				c.logger.Warn("failed to store offset", "error", err)
+			metrics.MessagesConsumedTotal.Inc()
+			msgs = append(msgs, e)
+			if flow, ok := c.decode(ctx, e); ok {
+				batch = append(batch, flow)
 			}
 		case kafka.Error:
 			c.logger.Error("Kafka consumer error", "error", e)
 			if e.Code() == kafka.ErrAllBrokersDown {
-				return batch, fmt.Errorf("all Kafka brokers down: %w", e)
+				return batch, msgs, fmt.Errorf("%w: %w", errAllBrokersDown, e)
 			}
 		}
 	}
 
-	return batch, nil
+	return batch, msgs, nil
+}
+
+// decode unmarshals a flow message. Messages that fail to unmarshal are
+// counted, sent to the dead-letter writer if one is configured, and dropped.
+func (c *Consumer) decode(ctx context.Context, msg *kafka.Message) (*flowpb.EnrichedFlow, bool) {
+	flow := &flowpb.EnrichedFlow{}
+	err := proto.Unmarshal(msg.Value, flow)
+	if err == nil {
+		return flow, true
+	}
+
+	metrics.DroppedMessagesTotal.Inc()
+	c.logger.Warn("failed to unmarshal flow", "error", err, "partition", msg.TopicPartition.Partition, "offset", msg.TopicPartition.Offset)
+	if c.deadLetter != nil {
+		if dlqErr := c.deadLetter.WriteDeadLetter(ctx, msg, fmt.Errorf("unmarshalling flow: %w", err)); dlqErr != nil {
+			c.logger.Error("failed to write message to dead-letter topic", "error", dlqErr)
+		}
+	}
+	return nil, false
 }
@@ -0,0 +1,101 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// Dead-letter message headers describing why and where a message failed.
+const (
+	HeaderError           = "helios-error"
+	HeaderSourceTopic     = "helios-source-topic"
+	HeaderSourcePartition = "helios-source-partition"
+	HeaderSourceOffset    = "helios-source-offset"
+)
+
+// DeadLetterWriter receives messages the consumer could not process.
+type DeadLetterWriter interface {
+	WriteDeadLetter(ctx context.Context, msg *kafka.Message, cause error) error
+}
+
+// DeadLetterProducer writes unprocessable messages to a dead-letter topic,
+// keeping the original key and value and annotating them with headers.
+type DeadLetterProducer struct {
+	producer *kafka.Producer
+	topic    string
+	logger   *slog.Logger
+}
+
+// NewDeadLetterProducer creates a producer for the dead-letter topic.
+func NewDeadLetterProducer(cfg ProducerConfig, logger *slog.Logger) (*DeadLetterProducer, error) {
+	cm := kafka.ConfigMap{
+		"bootstrap.servers":   cfg.Brokers,
+		"acks":                "all",
+		"retries":             3,
+		"retry.backoff.ms":    100,
+		"delivery.timeout.ms": 30000,
+	}
+	cfg.SecurityConfig.apply(cm)
+	p, err := kafka.NewProducer(&cm)
+	if err != nil {
+		return nil, fmt.Errorf("creating Kafka dead-letter producer: %w", err)
+	}
+
+	return &DeadLetterProducer{
+		producer: p,
+		topic:    cfg.Topic,
+		logger:   logger,
+	}, nil
+}
+
+// WriteDeadLetter produces msg to the dead-letter topic and waits for delivery.
+func (p *DeadLetterProducer) WriteDeadLetter(ctx context.Context, msg *kafka.Message, cause error) error {
+	deliveryChan := make(chan kafka.Event, 1)
+	if err := p.producer.Produce(deadLetterMessage(p.topic, msg, cause), deliveryChan); err != nil {
+		return fmt.Errorf("producing dead-letter message: %w", err)
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case e := <-deliveryChan:
+		if m, ok := e.(*kafka.Message); ok && m.TopicPartition.Error != nil {
+			return fmt.Errorf("delivering dead-letter message: %w", m.TopicPartition.Error)
+		}
+	}
+	return nil
+}
+
+// Close shuts down the producer.
+func (p *DeadLetterProducer) Close() {
+	p.producer.Flush(5000)
+	p.producer.Close()
+}
+
+// deadLetterMessage builds the message written to the dead-letter topic for
+// msg, which failed with cause.
+func deadLetterMessage(topic string, msg *kafka.Message, cause error) *kafka.Message {
+	headers := append([]kafka.Header(nil), msg.Headers...)
+	headers = append(headers,
+		kafka.Header{Key: HeaderError, Value: []byte(cause.Error())},
+		kafka.Header{Key: HeaderSourcePartition, Value: []byte(strconv.Itoa(int(msg.TopicPartition.Partition)))},
+		kafka.Header{Key: HeaderSourceOffset, Value: []byte(strconv.FormatInt(int64(msg.TopicPartition.Offset), 10))},
+	)
+	if msg.TopicPartition.Topic != nil {
+		headers = append(headers, kafka.Header{Key: HeaderSourceTopic, Value: []byte(*msg.TopicPartition.Topic)})
+	}
+
+	return &kafka.Message{
+		TopicPartition: kafka.TopicPartition{
+			Topic:     &topic,
+			Partition: kafka.PartitionAny,
+		},
+		Key:     msg.Key,
+		Value:   msg.Value,
+		Headers: headers,
+	}
+}
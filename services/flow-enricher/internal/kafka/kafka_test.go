@@ -1,13 +1,21 @@
 package kafka
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"log/slog"
+	"net"
 	"os"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	dto "github.com/prometheus/client_model/go"
 	"google.golang.org/protobuf/proto"
 
+	"github.com/rhwendt/helios/services/flow-enricher/internal/metrics"
 	flowpb "github.com/rhwendt/helios/services/flow-enricher/internal/proto"
 )
 
@@ -73,6 +81,69 @@ func TestProducerConfig_Fields(t *testing.T) {
 	}
 }
 
+func TestEnrichedMessage_Key(t *testing.T) {
+	tests := []struct {
+		name     string
+		keyField string
+		flow     *flowpb.EnrichedFlow
+		wantKey  []byte
+	}{
+		{
+			name:     "exporter ipv4",
+			keyField: KeyExporterIP,
+			flow:     &flowpb.EnrichedFlow{ExporterIp: 0xC0000201, ExporterName: "router-1"},
+			wantKey:  []byte("192.0.2.1"),
+		},
+		{
+			name:     "exporter ipv6",
+			keyField: KeyExporterIP,
+			flow:     &flowpb.EnrichedFlow{ExporterIpv6: net.ParseIP("2001:db8::1")},
+			wantKey:  []byte("2001:db8::1"),
+		},
+		{
+			name:     "exporter name",
+			keyField: KeyExporterName,
+			flow:     &flowpb.EnrichedFlow{ExporterIp: 0xC0000201, ExporterName: "router-1"},
+			wantKey:  []byte("router-1"),
+		},
+		{
+			name:     "missing field leaves message unkeyed",
+			keyField: KeyExporterName,
+			flow:     &flowpb.EnrichedFlow{ExporterIp: 0xC0000201},
+		},
+		{
+			name:     "none",
+			keyField: KeyNone,
+			flow:     &flowpb.EnrichedFlow{ExporterIp: 0xC0000201, ExporterName: "router-1"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			msg := enrichedMessage("enriched-flows", tc.keyField, tc.flow, []byte("data"))
+			if !bytes.Equal(msg.Key, tc.wantKey) {
+				t.Errorf("Key = %q, want %q", msg.Key, tc.wantKey)
+			}
+			if *msg.TopicPartition.Topic != "enriched-flows" {
+				t.Errorf("Topic = %q, want %q", *msg.TopicPartition.Topic, "enriched-flows")
+			}
+			if msg.TopicPartition.Partition != kafka.PartitionAny {
+				t.Errorf("Partition = %d, want PartitionAny", msg.TopicPartition.Partition)
+			}
+			if string(msg.Value) != "data" {
+				t.Errorf("Value = %q, want %q", msg.Value, "data")
+			}
+		})
+	}
+}
+
+func TestNewProducer_RejectsUnknownKeyField(t *testing.T) {
+	_, err := NewProducer(ProducerConfig{Brokers: "localhost:9092", Topic: "t", KeyField: "src_addr"}, testLogger())
+	if err == nil {
+		t.Fatal("expected error for unsupported key field")
+	}
+}
+
 func TestProtobuf_RoundTrip(t *testing.T) {
 	tests := []struct {
 		name string
@@ -214,3 +285,664 @@ func TestMessageHandler_Type(t *testing.T) {
 		t.Errorf("handler returned unexpected error: %v", err)
 	}
 }
+
+// fakeDeadLetter records messages written to it.
+type fakeDeadLetter struct {
+	msgs   []*kafka.Message
+	causes []error
+	err    error
+}
+
+func (f *fakeDeadLetter) WriteDeadLetter(ctx context.Context, msg *kafka.Message, cause error) error {
+	f.msgs = append(f.msgs, msg)
+	f.causes = append(f.causes, cause)
+	return f.err
+}
+
+func droppedMessages(t *testing.T) float64 {
+	t.Helper()
+	var m dto.Metric
+	if err := metrics.DroppedMessagesTotal.Write(&m); err != nil {
+		t.Fatalf("reading dropped messages counter: %v", err)
+	}
+	return m.GetCounter().GetValue()
+}
+
+func TestConsumer_MalformedMessageRoutedToDeadLetter(t *testing.T) {
+	dlq := &fakeDeadLetter{}
+	c := &Consumer{logger: testLogger()}
+	WithDeadLetter(dlq)(c)
+
+	topic := "helios-flows-raw"
+	msg := &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: 3, Offset: 42},
+		Key:            []byte("exporter-1"),
+		Value:          []byte{0xff, 0xfe, 0xfd, 0xfc, 0xfb},
+	}
+	before := droppedMessages(t)
+
+	flow, ok := c.decode(context.Background(), msg)
+
+	if ok || flow != nil {
+		t.Fatalf("decode() = %v, %v; want nil, false for malformed message", flow, ok)
+	}
+	if len(dlq.msgs) != 1 {
+		t.Fatalf("expected 1 dead-letter message, got %d", len(dlq.msgs))
+	}
+	if dlq.msgs[0] != msg {
+		t.Error("expected the original message to be dead-lettered")
+	}
+	if dlq.causes[0] == nil {
+		t.Error("expected a dead-letter cause")
+	}
+	if got := droppedMessages(t); got != before+1 {
+		t.Errorf("dropped messages = %v, want %v", got, before+1)
+	}
+}
+
+func TestConsumer_ValidMessageNotDeadLettered(t *testing.T) {
+	dlq := &fakeDeadLetter{}
+	c := &Consumer{logger: testLogger(), deadLetter: dlq}
+
+	data, err := proto.Marshal(&flowpb.EnrichedFlow{SrcPort: 443})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	flow, ok := c.decode(context.Background(), &kafka.Message{Value: data})
+
+	if !ok || flow.SrcPort != 443 {
+		t.Fatalf("decode() = %v, %v; want decoded flow", flow, ok)
+	}
+	if len(dlq.msgs) != 0 {
+		t.Errorf("expected no dead-letter messages, got %d", len(dlq.msgs))
+	}
+}
+
+func TestConsumer_DeadLetterFailureStillDrops(t *testing.T) {
+	dlq := &fakeDeadLetter{err: errors.New("broker unavailable")}
+	c := &Consumer{logger: testLogger(), deadLetter: dlq}
+
+	if _, ok := c.decode(context.Background(), &kafka.Message{Value: []byte{0xff}}); ok {
+		t.Fatal("expected malformed message to be dropped")
+	}
+	if len(dlq.msgs) != 1 {
+		t.Errorf("expected dead-letter write attempt, got %d", len(dlq.msgs))
+	}
+}
+
+func TestConsumer_MalformedMessageWithoutDeadLetter(t *testing.T) {
+	c := &Consumer{logger: testLogger()}
+
+	if _, ok := c.decode(context.Background(), &kafka.Message{Value: []byte{0xff}}); ok {
+		t.Fatal("expected malformed message to be dropped")
+	}
+}
+
+func TestDeadLetterMessage(t *testing.T) {
+	source := "helios-flows-raw"
+	msg := &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &source, Partition: 2, Offset: 1234},
+		Key:            []byte("k"),
+		Value:          []byte{0xff, 0x00},
+		Headers:        []kafka.Header{{Key: "trace-id", Value: []byte("abc")}},
+	}
+
+	out := deadLetterMessage("helios-flows-dlq", msg, errors.New("bad wire type"))
+
+	if *out.TopicPartition.Topic != "helios-flows-dlq" {
+		t.Errorf("topic = %q, want helios-flows-dlq", *out.TopicPartition.Topic)
+	}
+	if out.TopicPartition.Partition != kafka.PartitionAny {
+		t.Errorf("partition = %d, want PartitionAny", out.TopicPartition.Partition)
+	}
+	if string(out.Key) != "k" || string(out.Value) != string(msg.Value) {
+		t.Error("expected original key and value to be preserved")
+	}
+
+	headers := make(map[string]string)
+	for _, h := range out.Headers {
+		headers[h.Key] = string(h.Value)
+	}
+	want := map[string]string{
+		"trace-id":            "abc",
+		HeaderError:           "bad wire type",
+		HeaderSourceTopic:     "helios-flows-raw",
+		HeaderSourcePartition: "2",
+		HeaderSourceOffset:    "1234",
+	}
+	for k, v := range want {
+		if headers[k] != v {
+			t.Errorf("header %s = %q, want %q", k, headers[k], v)
+		}
+	}
+	if len(msg.Headers) != 1 {
+		t.Error("expected source message headers to be left unmodified")
+	}
+}
+
+// fakeClient is a consumerClient that replays events and records stored and
+// rewound offsets.
+type fakeClient struct {
+	events     []kafka.Event
+	subscribed []string
+	closed     bool
+	stored     []kafka.TopicPartition
+	seeks      []kafka.TopicPartition
+
+	positions  []kafka.TopicPartition
+	watermarks map[int32]int64
+}
+
+func (f *fakeClient) Subscribe(topic string, rebalanceCb kafka.RebalanceCb) error {
+	f.subscribed = append(f.subscribed, topic)
+	return nil
+}
+
+func (f *fakeClient) Poll(timeoutMs int) kafka.Event {
+	if len(f.events) == 0 {
+		return nil
+	}
+	ev := f.events[0]
+	f.events = f.events[1:]
+	return ev
+}
+
+func (f *fakeClient) StoreMessage(m *kafka.Message) ([]kafka.TopicPartition, error) {
+	f.stored = append(f.stored, m.TopicPartition)
+	return nil, nil
+}
+
+func (f *fakeClient) Seek(partition kafka.TopicPartition, ignoredTimeoutMs int) error {
+	f.seeks = append(f.seeks, partition)
+	return nil
+}
+
+func (f *fakeClient) Assignment() ([]kafka.TopicPartition, error) {
+	var assigned []kafka.TopicPartition
+	for _, tp := range f.positions {
+		assigned = append(assigned, kafka.TopicPartition{Topic: tp.Topic, Partition: tp.Partition})
+	}
+	return assigned, nil
+}
+
+func (f *fakeClient) Position(partitions []kafka.TopicPartition) ([]kafka.TopicPartition, error) {
+	return f.positions, nil
+}
+
+func (f *fakeClient) GetWatermarkOffsets(topic string, partition int32) (int64, int64, error) {
+	high, ok := f.watermarks[partition]
+	if !ok {
+		return int64(kafka.OffsetInvalid), int64(kafka.OffsetInvalid), nil
+	}
+	return 0, high, nil
+}
+
+func (f *fakeClient) Close() error {
+	f.closed = true
+	return nil
+}
+
+func testBatch(t *testing.T) ([]*flowpb.EnrichedFlow, []*kafka.Message) {
+	t.Helper()
+	topic := "helios-flows-raw"
+	var flows []*flowpb.EnrichedFlow
+	var msgs []*kafka.Message
+	for i, tp := range []struct {
+		partition int32
+		offset    kafka.Offset
+	}{{0, 10}, {1, 7}, {0, 11}, {1, 8}} {
+		flow := &flowpb.EnrichedFlow{SrcPort: uint32(i)}
+		data, err := proto.Marshal(flow)
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+		flows = append(flows, flow)
+		msgs = append(msgs, &kafka.Message{
+			TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: tp.partition, Offset: tp.offset},
+			Value:          data,
+		})
+	}
+	return flows, msgs
+}
+
+func TestConsumer_HandlerErrorDoesNotStoreOffsets(t *testing.T) {
+	client := &fakeClient{}
+	c := &Consumer{
+		client: client,
+		handler: func(ctx context.Context, flows []*flowpb.EnrichedFlow) error {
+			return errors.New("produce failed")
+		},
+		logger: testLogger(),
+	}
+	flows, msgs := testBatch(t)
+
+	if err := c.processBatch(context.Background(), flows, msgs); err == nil {
+		t.Fatal("expected handler error to be returned")
+	}
+
+	if len(client.stored) != 0 {
+		t.Errorf("expected no offsets stored after handler error, got %v", client.stored)
+	}
+
+	// Each partition is rewound to the first offset of the failed batch.
+	rewound := make(map[int32]kafka.Offset)
+	for _, tp := range client.seeks {
+		rewound[tp.Partition] = tp.Offset
+	}
+	want := map[int32]kafka.Offset{0: 10, 1: 7}
+	if len(rewound) != len(want) {
+		t.Fatalf("rewound partitions = %v, want %v", rewound, want)
+	}
+	for p, off := range want {
+		if rewound[p] != off {
+			t.Errorf("partition %d rewound to %d, want %d", p, rewound[p], off)
+		}
+	}
+}
+
+func TestConsumer_HandlerSuccessStoresOffsets(t *testing.T) {
+	client := &fakeClient{}
+	var handled int
+	c := &Consumer{
+		client: client,
+		handler: func(ctx context.Context, flows []*flowpb.EnrichedFlow) error {
+			handled = len(flows)
+			return nil
+		},
+		logger: testLogger(),
+	}
+	flows, msgs := testBatch(t)
+
+	if err := c.processBatch(context.Background(), flows, msgs); err != nil {
+		t.Fatalf("processBatch() error = %v", err)
+	}
+
+	if handled != len(flows) {
+		t.Errorf("handler saw %d flows, want %d", handled, len(flows))
+	}
+	if len(client.stored) != len(msgs) {
+		t.Errorf("stored %d offsets, want %d", len(client.stored), len(msgs))
+	}
+	if len(client.seeks) != 0 {
+		t.Errorf("expected no rewind on success, got %v", client.seeks)
+	}
+}
+
+func TestConsumer_DroppedOnlyBatchStoresOffsets(t *testing.T) {
+	client := &fakeClient{}
+	called := false
+	c := &Consumer{
+		client: client,
+		handler: func(ctx context.Context, flows []*flowpb.EnrichedFlow) error {
+			called = true
+			return nil
+		},
+		logger: testLogger(),
+	}
+	msgs := []*kafka.Message{{Value: []byte{0xff}}}
+
+	if err := c.processBatch(context.Background(), nil, msgs); err != nil {
+		t.Fatalf("processBatch() error = %v", err)
+	}
+
+	if called {
+		t.Error("expected handler not to be called for an empty batch")
+	}
+	if len(client.stored) != 1 {
+		t.Errorf("expected offset of dropped message to be stored, got %d", len(client.stored))
+	}
+}
+
+// singleMessageEvents returns n valid flow messages on partition 0 with
+// consecutive offsets from 100.
+func singleMessageEvents(t *testing.T, n int) []kafka.Event {
+	t.Helper()
+	topic := "helios-flows-raw"
+	var events []kafka.Event
+	for i := 0; i < n; i++ {
+		data, err := proto.Marshal(&flowpb.EnrichedFlow{SrcPort: uint32(i)})
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+		events = append(events, &kafka.Message{
+			TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: 0, Offset: kafka.Offset(100 + i)},
+			Value:          data,
+		})
+	}
+	return events
+}
+
+func inFlightBatches(t *testing.T) float64 {
+	t.Helper()
+	var m dto.Metric
+	if err := metrics.InFlightBatches.Write(&m); err != nil {
+		t.Fatalf("reading in-flight gauge: %v", err)
+	}
+	return m.GetGauge().GetValue()
+}
+
+func TestConsumer_MaxInFlightCapsConcurrentHandlers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	const batches, maxInFlight = 6, 2
+	client := &fakeClient{events: singleMessageEvents(t, batches)}
+	var active, peak, handled atomic.Int32
+	c := &Consumer{
+		client:    client,
+		topic:     "helios-flows-raw",
+		batchSize: 1,
+		handler: func(ctx context.Context, flows []*flowpb.EnrichedFlow) error {
+			n := active.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+			active.Add(-1)
+			if handled.Add(1) == batches {
+				cancel()
+			}
+			return nil
+		},
+		logger: testLogger(),
+	}
+	WithMaxInFlight(maxInFlight)(c)
+
+	if err := c.Start(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Start() error = %v, want context.Canceled", err)
+	}
+
+	if got := peak.Load(); got != maxInFlight {
+		t.Errorf("peak concurrent handlers = %d, want %d", got, maxInFlight)
+	}
+	if len(client.stored) != batches {
+		t.Fatalf("stored %d offsets, want %d", len(client.stored), batches)
+	}
+	for i, tp := range client.stored {
+		if tp.Offset != kafka.Offset(100+i) {
+			t.Errorf("stored offset %d = %d, want %d (in read order)", i, tp.Offset, 100+i)
+		}
+	}
+	if got := inFlightBatches(t); got != 0 {
+		t.Errorf("in-flight gauge = %v after shutdown, want 0", got)
+	}
+}
+
+func TestConsumer_MaxInFlightFailureRewinds(t *testing.T) {
+	client := &fakeClient{}
+	release := make(chan struct{})
+	c := &Consumer{
+		client: client,
+		handler: func(ctx context.Context, flows []*flowpb.EnrichedFlow) error {
+			switch flows[0].SrcPort {
+			case 0:
+				return nil
+			case 1:
+				<-release
+				return errors.New("produce failed")
+			}
+			return nil
+		},
+		logger: testLogger(),
+	}
+	WithMaxInFlight(3)(c)
+
+	events := singleMessageEvents(t, 3)
+	for _, ev := range events {
+		msg := ev.(*kafka.Message)
+		flow := &flowpb.EnrichedFlow{}
+		if err := proto.Unmarshal(msg.Value, flow); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		if err := c.dispatch(context.Background(), []*flowpb.EnrichedFlow{flow}, []*kafka.Message{msg}); err != nil {
+			t.Fatalf("dispatch() error = %v", err)
+		}
+	}
+	close(release)
+
+	if err := c.settle(true); err == nil {
+		t.Fatal("expected the failed batch's error")
+	}
+	// The first batch is stored; the third succeeded but is read again with
+	// the failed second one, so its offset must not be stored.
+	if len(client.stored) != 1 || client.stored[0].Offset != 100 {
+		t.Errorf("stored = %v, want only offset 100", client.stored)
+	}
+	if len(client.seeks) != 1 || client.seeks[0].Offset != 101 {
+		t.Errorf("seeks = %v, want a rewind to offset 101", client.seeks)
+	}
+	if len(c.pending) != 0 {
+		t.Errorf("pending = %d batches after settling, want 0", len(c.pending))
+	}
+}
+
+func reconnectsTotal(t *testing.T) float64 {
+	t.Helper()
+	var m dto.Metric
+	if err := metrics.ConsumerReconnectsTotal.Write(&m); err != nil {
+		t.Fatalf("reading reconnect counter: %v", err)
+	}
+	return m.GetCounter().GetValue()
+}
+
+func counterValue(t *testing.T, c interface{ Write(*dto.Metric) error }) float64 {
+	t.Helper()
+	var m dto.Metric
+	if err := c.Write(&m); err != nil {
+		t.Fatalf("reading counter: %v", err)
+	}
+	return m.GetCounter().GetValue()
+}
+
+func TestConsumer_CountsConsumedMessages(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	consumedBefore := counterValue(t, metrics.MessagesConsumedTotal)
+	var batches dto.Metric
+	if err := metrics.BatchSize.Write(&batches); err != nil {
+		t.Fatalf("reading batch size histogram: %v", err)
+	}
+	batchesBefore := batches.GetHistogram().GetSampleCount()
+
+	c := &Consumer{
+		client:    &fakeClient{events: singleMessageEvents(t, 5)},
+		topic:     "helios-flows-raw",
+		batchSize: 3,
+		logger:    testLogger(),
+	}
+	handled := 0
+	c.handler = func(ctx context.Context, flows []*flowpb.EnrichedFlow) error {
+		handled += len(flows)
+		if handled == 5 {
+			cancel()
+		}
+		return nil
+	}
+
+	if err := c.Start(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Start() error = %v, want context.Canceled", err)
+	}
+
+	if got := counterValue(t, metrics.MessagesConsumedTotal) - consumedBefore; got != 5 {
+		t.Errorf("messages consumed = %v, want 5", got)
+	}
+	if err := metrics.BatchSize.Write(&batches); err != nil {
+		t.Fatalf("reading batch size histogram: %v", err)
+	}
+	if got := batches.GetHistogram().GetSampleCount() - batchesBefore; got != 2 {
+		t.Errorf("batches observed = %d, want 2 (3 + 2 messages)", got)
+	}
+}
+
+func TestConsumer_UpdateLag(t *testing.T) {
+	topic := "helios-flows-raw"
+	client := &fakeClient{
+		positions: []kafka.TopicPartition{
+			{Topic: &topic, Partition: 0, Offset: 90},
+			{Topic: &topic, Partition: 1, Offset: 40},
+			{Topic: &topic, Partition: 2, Offset: kafka.OffsetInvalid},
+		},
+		// Partition 1's watermark has not been fetched yet.
+		watermarks: map[int32]int64{0: 100, 2: 10},
+	}
+	c := &Consumer{client: client, logger: testLogger()}
+
+	metrics.ConsumerLag.WithLabelValues("7").Set(3)
+	c.updateLag()
+
+	lag := func(partition string) float64 {
+		var m dto.Metric
+		if err := metrics.ConsumerLag.WithLabelValues(partition).Write(&m); err != nil {
+			t.Fatalf("reading lag: %v", err)
+		}
+		return m.GetGauge().GetValue()
+	}
+	if got := lag("0"); got != 10 {
+		t.Errorf("partition 0 lag = %v, want 10", got)
+	}
+	for _, p := range []string{"1", "2", "7"} {
+		if got := lag(p); got != 0 {
+			t.Errorf("partition %s lag = %v, want unset", p, got)
+		}
+	}
+	if c.lagUpdated.IsZero() {
+		t.Error("lagUpdated not recorded")
+	}
+}
+
+func TestConsumer_ReconnectsAfterAllBrokersDown(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	first := &fakeClient{events: []kafka.Event{kafka.NewError(kafka.ErrAllBrokersDown, "all brokers down", false)}}
+	second := &fakeClient{}
+	var created int
+	c := &Consumer{
+		client: first,
+		newClient: func() (consumerClient, error) {
+			created++
+			// Stop once the replacement client has been subscribed.
+			cancel()
+			return second, nil
+		},
+		topic:            "helios-flows-raw",
+		batchSize:        10,
+		handler:          func(ctx context.Context, flows []*flowpb.EnrichedFlow) error { return nil },
+		logger:           testLogger(),
+		maxReconnects:    3,
+		reconnectBackoff: time.Millisecond,
+	}
+	before := reconnectsTotal(t)
+
+	if err := c.Start(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Start() error = %v, want context.Canceled", err)
+	}
+
+	if created != 1 {
+		t.Errorf("factory called %d times, want 1", created)
+	}
+	if !first.closed {
+		t.Error("expected the dead client to be closed")
+	}
+	if len(second.subscribed) != 1 || second.subscribed[0] != "helios-flows-raw" {
+		t.Errorf("new client subscriptions = %v, want [helios-flows-raw]", second.subscribed)
+	}
+	if c.client != second {
+		t.Error("expected the consumer to use the new client")
+	}
+	if got := reconnectsTotal(t) - before; got != 1 {
+		t.Errorf("reconnect counter increased by %v, want 1", got)
+	}
+}
+
+func TestConsumer_ReconnectGivesUp(t *testing.T) {
+	var created int
+	c := &Consumer{
+		client: &fakeClient{},
+		newClient: func() (consumerClient, error) {
+			created++
+			return nil, errors.New("broker unreachable")
+		},
+		topic:            "helios-flows-raw",
+		logger:           testLogger(),
+		maxReconnects:    3,
+		reconnectBackoff: time.Millisecond,
+	}
+
+	err := c.reconnect(context.Background())
+	if !errors.Is(err, errAllBrokersDown) {
+		t.Fatalf("reconnect() error = %v, want errAllBrokersDown", err)
+	}
+	if created != 3 {
+		t.Errorf("factory called %d times, want 3", created)
+	}
+}
+
+func TestConfigMap_SASL(t *testing.T) {
+	security := SecurityConfig{
+		SecurityProtocol: "SASL_SSL",
+		SASLMechanism:    "SCRAM-SHA-512",
+		SASLUsername:     "flow-enricher",
+		SASLPassword:     "s3cret",
+		SSLCALocation:    "/etc/kafka/ca.crt",
+	}
+	want := map[string]string{
+		"security.protocol": "SASL_SSL",
+		"sasl.mechanism":    "SCRAM-SHA-512",
+		"sasl.username":     "flow-enricher",
+		"sasl.password":     "s3cret",
+		"ssl.ca.location":   "/etc/kafka/ca.crt",
+	}
+
+	maps := map[string]kafka.ConfigMap{
+		"consumer": consumerConfigMap(ConsumerConfig{Brokers: "b:9092", GroupID: "g", SecurityConfig: security}),
+		"producer": producerConfigMap(ProducerConfig{Brokers: "b:9092", SecurityConfig: security}),
+	}
+	for name, cm := range maps {
+		t.Run(name, func(t *testing.T) {
+			for key, value := range want {
+				if cm[key] != value {
+					t.Errorf("%s = %v, want %q", key, cm[key], value)
+				}
+			}
+			if cm["bootstrap.servers"] != "b:9092" {
+				t.Errorf("bootstrap.servers = %v, want b:9092", cm["bootstrap.servers"])
+			}
+		})
+	}
+}
+
+func TestConfigMap_PlaintextOmitsSecurityKeys(t *testing.T) {
+	maps := map[string]kafka.ConfigMap{
+		"consumer": consumerConfigMap(ConsumerConfig{Brokers: "b:9092", GroupID: "g"}),
+		"producer": producerConfigMap(ProducerConfig{Brokers: "b:9092"}),
+	}
+	for name, cm := range maps {
+		t.Run(name, func(t *testing.T) {
+			for _, key := range []string{"security.protocol", "sasl.mechanism", "sasl.username", "sasl.password", "ssl.ca.location"} {
+				if _, ok := cm[key]; ok {
+					t.Errorf("expected %s to be unset for plaintext config", key)
+				}
+			}
+		})
+	}
+}
+
+func TestConfigMap_TLSOnly(t *testing.T) {
+	cm := producerConfigMap(ProducerConfig{
+		Brokers:        "b:9093",
+		SecurityConfig: SecurityConfig{SecurityProtocol: "SSL", SSLCALocation: "/ca.pem"},
+	})
+
+	if cm["security.protocol"] != "SSL" || cm["ssl.ca.location"] != "/ca.pem" {
+		t.Errorf("unexpected TLS settings: %v", cm)
+	}
+	if _, ok := cm["sasl.mechanism"]; ok {
+		t.Error("expected sasl.mechanism to be unset without SASL")
+	}
+}
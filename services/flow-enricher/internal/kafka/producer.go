@@ -4,17 +4,28 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"net"
 
 	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
 	"google.golang.org/protobuf/proto"
 
+	"github.com/rhwendt/helios/services/flow-enricher/internal/metrics"
 	flowpb "github.com/rhwendt/helios/services/flow-enricher/internal/proto"
 )
 
+// Flow fields that can be used as the message key, so that flows sharing a
+// value land on the same partition.
+const (
+	KeyExporterIP   = "exporter_ip"
+	KeyExporterName = "exporter_name"
+	KeyNone         = "none"
+)
+
 // Producer writes enriched flow protobuf messages to a Kafka topic.
 type Producer struct {
 	producer *kafka.Producer
 	topic    string
+	keyField string
 	logger   *slog.Logger
 }
 
@@ -22,20 +33,25 @@ type Producer struct {
 type ProducerConfig struct {
 	Brokers string
 	Topic   string
+	// KeyField selects the flow field used as the message key. It defaults
+	// to KeyExporterIP; KeyNone produces unkeyed messages.
+	KeyField string
+	SecurityConfig
 }
 
 // NewProducer creates a new Kafka producer.
 func NewProducer(cfg ProducerConfig, logger *slog.Logger) (*Producer, error) {
-	p, err := kafka.NewProducer(&kafka.ConfigMap{
-		"bootstrap.servers":   cfg.Brokers,
-		"linger.ms":           10,
-		"batch.num.messages":  1000,
-		"compression.type":    "lz4",
-		"acks":                "all",
-		"retries":             3,
-		"retry.backoff.ms":    100,
-		"delivery.timeout.ms": 30000,
-	})
+	keyField := cfg.KeyField
+	switch keyField {
+	case "":
+		keyField = KeyExporterIP
+	case KeyExporterIP, KeyExporterName, KeyNone:
+	default:
+		return nil, fmt.Errorf("unsupported message key field %q", cfg.KeyField)
+	}
+
+	cm := producerConfigMap(cfg)
+	p, err := kafka.NewProducer(&cm)
 	if err != nil {
 		return nil, fmt.Errorf("creating Kafka producer: %w", err)
 	}
@@ -43,10 +59,27 @@ func NewProducer(cfg ProducerConfig, logger *slog.Logger) (*Producer, error) {
 	return &Producer{
 		producer: p,
 		topic:    cfg.Topic,
+		keyField: keyField,
 		logger:   logger,
 	}, nil
 }
 
+// producerConfigMap builds the librdkafka configuration for cfg.
+func producerConfigMap(cfg ProducerConfig) kafka.ConfigMap {
+	cm := kafka.ConfigMap{
+		"bootstrap.servers":   cfg.Brokers,
+		"linger.ms":           10,
+		"batch.num.messages":  1000,
+		"compression.type":    "lz4",
+		"acks":                "all",
+		"retries":             3,
+		"retry.backoff.ms":    100,
+		"delivery.timeout.ms": 30000,
+	}
+	cfg.SecurityConfig.apply(cm)
+	return cm
+}
+
 // ProduceBatch sends a batch of enriched flows to Kafka.
 func (p *Producer) ProduceBatch(ctx context.Context, flows []*flowpb.EnrichedFlow) error {
 	deliveryChan := make(chan kafka.Event, len(flows))
@@ -58,13 +91,7 @@ func (p *Producer) ProduceBatch(ctx context.Context, flows []*flowpb.EnrichedFlo
 			continue
 		}
 
-		err = p.producer.Produce(&kafka.Message{
-			TopicPartition: kafka.TopicPartition{
-				Topic:     &p.topic,
-				Partition: kafka.PartitionAny,
-			},
-			Value: data,
-		}, deliveryChan)
+		err = p.producer.Produce(enrichedMessage(p.topic, p.keyField, flow, data), deliveryChan)
 		if err != nil {
 			p.logger.Error("failed to produce message", "error", err)
 		}
@@ -85,12 +112,46 @@ func (p *Producer) ProduceBatch(ctx context.Context, flows []*flowpb.EnrichedFlo
 		}
 	}
 
+	metrics.MessagesProducedTotal.Add(float64(len(flows) - errs))
 	if errs > 0 {
 		return fmt.Errorf("failed to deliver %d/%d messages", errs, len(flows))
 	}
 	return nil
 }
 
+// enrichedMessage builds the message carrying the marshaled flow data, keyed
+// by the flow's keyField value.
+func enrichedMessage(topic, keyField string, flow *flowpb.EnrichedFlow, data []byte) *kafka.Message {
+	return &kafka.Message{
+		TopicPartition: kafka.TopicPartition{
+			Topic:     &topic,
+			Partition: kafka.PartitionAny,
+		},
+		Key:   messageKey(keyField, flow),
+		Value: data,
+	}
+}
+
+// messageKey returns the key for flow, or nil when the key field is unset on
+// the flow so the partitioner spreads it like an unkeyed message.
+func messageKey(keyField string, flow *flowpb.EnrichedFlow) []byte {
+	switch keyField {
+	case KeyExporterIP:
+		if flow.ExporterIp != 0 {
+			ip := flow.ExporterIp
+			return []byte(net.IPv4(byte(ip>>24), byte(ip>>16), byte(ip>>8), byte(ip)).String())
+		}
+		if len(flow.ExporterIpv6) == net.IPv6len {
+			return []byte(net.IP(flow.ExporterIpv6).String())
+		}
+	case KeyExporterName:
+		if flow.ExporterName != "" {
+			return []byte(flow.ExporterName)
+		}
+	}
+	return nil
+}
+
 // Flush waits for all outstanding messages to be delivered.
 func (p *Producer) Flush(timeoutMs int) {
 	p.producer.Flush(timeoutMs)
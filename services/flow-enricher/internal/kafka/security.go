@@ -0,0 +1,29 @@
+package kafka
+
+import "github.com/confluentinc/confluent-kafka-go/v2/kafka"
+
+// SecurityConfig holds the settings for connecting to a secured Kafka
+// cluster. Empty fields are left at their librdkafka defaults, which is a
+// plaintext connection.
+type SecurityConfig struct {
+	SecurityProtocol string // e.g. SASL_SSL, SSL
+	SASLMechanism    string // e.g. PLAIN, SCRAM-SHA-512
+	SASLUsername     string
+	SASLPassword     string
+	SSLCALocation    string // path to a CA bundle for verifying the brokers
+}
+
+// apply sets the configured security options on cm.
+func (s SecurityConfig) apply(cm kafka.ConfigMap) {
+	for key, value := range map[string]string{
+		"security.protocol": s.SecurityProtocol,
+		"sasl.mechanism":    s.SASLMechanism,
+		"sasl.username":     s.SASLUsername,
+		"sasl.password":     s.SASLPassword,
+		"ssl.ca.location":   s.SSLCALocation,
+	} {
+		if value != "" {
+			cm[key] = value
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// CacheDevices reports the number of devices in the NetBox cache.
+	CacheDevices = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "helios_flow_enricher_cache_devices",
+		Help: "Number of devices in the NetBox cache",
+	})
+
+	// CacheLastRefreshTimestamp records when the NetBox cache was last
+	// refreshed successfully.
+	CacheLastRefreshTimestamp = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "helios_flow_enricher_cache_last_refresh_timestamp_seconds",
+		Help: "Unix time of the last successful NetBox cache refresh",
+	})
+
+	// CacheRefreshErrorsTotal counts failed NetBox cache refreshes.
+	CacheRefreshErrorsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "helios_flow_enricher_cache_refresh_errors_total",
+		Help: "Total failed NetBox cache refreshes",
+	})
+
+	// DroppedMessagesTotal counts consumed messages that could not be
+	// unmarshalled.
+	DroppedMessagesTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "helios_flow_enricher_dropped_messages_total",
+		Help: "Total consumed flow messages dropped because they could not be unmarshalled",
+	})
+
+	// NetBoxPageCacheHitsTotal counts NetBox pages reused after a 304 Not
+	// Modified response.
+	NetBoxPageCacheHitsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "helios_flow_enricher_netbox_page_cache_hits_total",
+		Help: "Total NetBox API pages served from cache after a 304 Not Modified response",
+	})
+
+	// ConsumerReconnectsTotal counts attempts to recreate the Kafka consumer
+	// after all brokers went down.
+	ConsumerReconnectsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "helios_flow_enricher_consumer_reconnects_total",
+		Help: "Total Kafka consumer reconnect attempts after all brokers went down",
+	})
+
+	// InFlightBatches reports the consumed batches currently being enriched
+	// and produced.
+	InFlightBatches = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "helios_flow_enricher_in_flight_batches",
+		Help: "Number of consumed batches currently being enriched and produced",
+	})
+
+	// MessagesConsumedTotal counts flow messages read from Kafka, including
+	// ones re-read after a failed batch.
+	MessagesConsumedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "helios_flow_enricher_messages_consumed_total",
+		Help: "Total flow messages read from the raw flow topic",
+	})
+
+	// MessagesProducedTotal counts enriched flows delivered to Kafka.
+	MessagesProducedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "helios_flow_enricher_messages_produced_total",
+		Help: "Total enriched flow messages delivered to the enriched flow topic",
+	})
+
+	// BatchSize records the number of messages in each consumed batch.
+	BatchSize = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "helios_flow_enricher_batch_size",
+		Help:    "Number of messages in each batch read from Kafka",
+		Buckets: prometheus.ExponentialBuckets(1, 2, 10),
+	})
+
+	// ConsumerLag reports, per assigned partition, how many messages the
+	// consumer position is behind the partition's high watermark.
+	ConsumerLag = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "helios_flow_enricher_consumer_lag",
+		Help: "Messages between the consumer position and the high watermark of each assigned partition",
+	}, []string{"partition"})
+)
+
+// Register adds the flow-enricher metrics to reg.
+func Register(reg prometheus.Registerer) {
+	reg.MustRegister(
+		CacheDevices,
+		CacheLastRefreshTimestamp,
+		CacheRefreshErrorsTotal,
+		DroppedMessagesTotal,
+		NetBoxPageCacheHitsTotal,
+		ConsumerReconnectsTotal,
+		InFlightBatches,
+		MessagesConsumedTotal,
+		MessagesProducedTotal,
+		BatchSize,
+		ConsumerLag,
+	)
+}
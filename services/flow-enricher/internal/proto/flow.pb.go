@@ -182,11 +182,27 @@ type EnrichedFlow struct {
 	SrcAsName string `protobuf:"bytes,70,opt,name=src_as_name,json=srcAsName,proto3" json:"src_as_name,omitempty"`
 	DstAsName string `protobuf:"bytes,71,opt,name=dst_as_name,json=dstAsName,proto3" json:"dst_as_name,omitempty"`
 	// VLAN
-	SrcVlan       uint32                 `protobuf:"varint,80,opt,name=src_vlan,json=srcVlan,proto3" json:"src_vlan,omitempty"`
-	DstVlan       uint32                 `protobuf:"varint,81,opt,name=dst_vlan,json=dstVlan,proto3" json:"dst_vlan,omitempty"`
-	Direction     EnrichedFlow_Direction `protobuf:"varint,82,opt,name=direction,proto3,enum=helios.flows.EnrichedFlow_Direction" json:"direction,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	SrcVlan        uint32                 `protobuf:"varint,80,opt,name=src_vlan,json=srcVlan,proto3" json:"src_vlan,omitempty"`
+	DstVlan        uint32                 `protobuf:"varint,81,opt,name=dst_vlan,json=dstVlan,proto3" json:"dst_vlan,omitempty"`
+	Direction      EnrichedFlow_Direction `protobuf:"varint,82,opt,name=direction,proto3,enum=helios.flows.EnrichedFlow_Direction" json:"direction,omitempty"`
+	ExporterIpv6   []byte                 `protobuf:"bytes,8,opt,name=exporter_ipv6,json=exporterIpv6,proto3" json:"exporter_ipv6,omitempty"`
+	SrcDeviceName  string                 `protobuf:"bytes,90,opt,name=src_device_name,json=srcDeviceName,proto3" json:"src_device_name,omitempty"`
+	DstDeviceName  string                 `protobuf:"bytes,91,opt,name=dst_device_name,json=dstDeviceName,proto3" json:"dst_device_name,omitempty"`
+	SrcSite        string                 `protobuf:"bytes,92,opt,name=src_site,json=srcSite,proto3" json:"src_site,omitempty"`
+	DstSite        string                 `protobuf:"bytes,93,opt,name=dst_site,json=dstSite,proto3" json:"dst_site,omitempty"`
+	ProtoName      string                 `protobuf:"bytes,26,opt,name=proto_name,json=protoName,proto3" json:"proto_name,omitempty"`
+	SrcService     string                 `protobuf:"bytes,35,opt,name=src_service,json=srcService,proto3" json:"src_service,omitempty"`
+	DstService     string                 `protobuf:"bytes,36,opt,name=dst_service,json=dstService,proto3" json:"dst_service,omitempty"`
+	SrcSubdivision string                 `protobuf:"bytes,64,opt,name=src_subdivision,json=srcSubdivision,proto3" json:"src_subdivision,omitempty"`
+	DstSubdivision string                 `protobuf:"bytes,65,opt,name=dst_subdivision,json=dstSubdivision,proto3" json:"dst_subdivision,omitempty"`
+	SrcContinent   string                 `protobuf:"bytes,66,opt,name=src_continent,json=srcContinent,proto3" json:"src_continent,omitempty"`
+	DstContinent   string                 `protobuf:"bytes,67,opt,name=dst_continent,json=dstContinent,proto3" json:"dst_continent,omitempty"`
+	BytesScaled    uint64                 `protobuf:"varint,45,opt,name=bytes_scaled,json=bytesScaled,proto3" json:"bytes_scaled,omitempty"`
+	PacketsScaled  uint64                 `protobuf:"varint,46,opt,name=packets_scaled,json=packetsScaled,proto3" json:"packets_scaled,omitempty"`
+	DscpClass      string                 `protobuf:"bytes,27,opt,name=dscp_class,json=dscpClass,proto3" json:"dscp_class,omitempty"`
+	IcmpTypeName   string                 `protobuf:"bytes,28,opt,name=icmp_type_name,json=icmpTypeName,proto3" json:"icmp_type_name,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
 }
 
 func (x *EnrichedFlow) Reset() {
@@ -520,11 +536,123 @@ func (x *EnrichedFlow) GetDirection() EnrichedFlow_Direction {
 	return EnrichedFlow_UNKNOWN_DIR
 }
 
+func (x *EnrichedFlow) GetExporterIpv6() []byte {
+	if x != nil {
+		return x.ExporterIpv6
+	}
+	return nil
+}
+
+func (x *EnrichedFlow) GetSrcDeviceName() string {
+	if x != nil {
+		return x.SrcDeviceName
+	}
+	return ""
+}
+
+func (x *EnrichedFlow) GetDstDeviceName() string {
+	if x != nil {
+		return x.DstDeviceName
+	}
+	return ""
+}
+
+func (x *EnrichedFlow) GetSrcSite() string {
+	if x != nil {
+		return x.SrcSite
+	}
+	return ""
+}
+
+func (x *EnrichedFlow) GetDstSite() string {
+	if x != nil {
+		return x.DstSite
+	}
+	return ""
+}
+
+func (x *EnrichedFlow) GetProtoName() string {
+	if x != nil {
+		return x.ProtoName
+	}
+	return ""
+}
+
+func (x *EnrichedFlow) GetSrcService() string {
+	if x != nil {
+		return x.SrcService
+	}
+	return ""
+}
+
+func (x *EnrichedFlow) GetDstService() string {
+	if x != nil {
+		return x.DstService
+	}
+	return ""
+}
+
+func (x *EnrichedFlow) GetSrcSubdivision() string {
+	if x != nil {
+		return x.SrcSubdivision
+	}
+	return ""
+}
+
+func (x *EnrichedFlow) GetDstSubdivision() string {
+	if x != nil {
+		return x.DstSubdivision
+	}
+	return ""
+}
+
+func (x *EnrichedFlow) GetSrcContinent() string {
+	if x != nil {
+		return x.SrcContinent
+	}
+	return ""
+}
+
+func (x *EnrichedFlow) GetDstContinent() string {
+	if x != nil {
+		return x.DstContinent
+	}
+	return ""
+}
+
+func (x *EnrichedFlow) GetBytesScaled() uint64 {
+	if x != nil {
+		return x.BytesScaled
+	}
+	return 0
+}
+
+func (x *EnrichedFlow) GetPacketsScaled() uint64 {
+	if x != nil {
+		return x.PacketsScaled
+	}
+	return 0
+}
+
+func (x *EnrichedFlow) GetDscpClass() string {
+	if x != nil {
+		return x.DscpClass
+	}
+	return ""
+}
+
+func (x *EnrichedFlow) GetIcmpTypeName() string {
+	if x != nil {
+		return x.IcmpTypeName
+	}
+	return ""
+}
+
 var File_proto_flow_proto protoreflect.FileDescriptor
 
 const file_proto_flow_proto_rawDesc = "" +
 	"\n" +
-	"\x10proto/flow.proto\x12\fhelios.flows\"\xc2\v\n" +
+	"\x10proto/flow.proto\x12\fhelios.flows\"\xf9\x0f\n" +
 	"\fEnrichedFlow\x12!\n" +
 	"\ftimestamp_ms\x18\x01 \x01(\x03R\vtimestampMs\x12@\n" +
 	"\tflow_type\x18\x02 \x01(\x0e2#.helios.flows.EnrichedFlow.FlowTypeR\bflowType\x12\x1f\n" +
@@ -575,7 +703,27 @@ const file_proto_flow_proto_rawDesc = "" +
 	"\vdst_as_name\x18G \x01(\tR\tdstAsName\x12\x19\n" +
 	"\bsrc_vlan\x18P \x01(\rR\asrcVlan\x12\x19\n" +
 	"\bdst_vlan\x18Q \x01(\rR\adstVlan\x12B\n" +
-	"\tdirection\x18R \x01(\x0e2$.helios.flows.EnrichedFlow.DirectionR\tdirection\"M\n" +
+	"\tdirection\x18R \x01(\x0e2$.helios.flows.EnrichedFlow.DirectionR\tdirection\x12#\n" +
+	"\rexporter_ipv6\x18\b \x01(\fR\fexporterIpv6\x12&\n" +
+	"\x0fsrc_device_name\x18Z \x01(\tR\rsrcDeviceName\x12&\n" +
+	"\x0fdst_device_name\x18[ \x01(\tR\rdstDeviceName\x12\x19\n" +
+	"\bsrc_site\x18\\ \x01(\tR\asrcSite\x12\x19\n" +
+	"\bdst_site\x18] \x01(\tR\adstSite\x12\x1d\n" +
+	"\n" +
+	"proto_name\x18\x1a \x01(\tR\tprotoName\x12\x1f\n" +
+	"\vsrc_service\x18# \x01(\tR\n" +
+	"srcService\x12\x1f\n" +
+	"\vdst_service\x18$ \x01(\tR\n" +
+	"dstService\x12'\n" +
+	"\x0fsrc_subdivision\x18@ \x01(\tR\x0esrcSubdivision\x12'\n" +
+	"\x0fdst_subdivision\x18A \x01(\tR\x0edstSubdivision\x12#\n" +
+	"\rsrc_continent\x18B \x01(\tR\fsrcContinent\x12#\n" +
+	"\rdst_continent\x18C \x01(\tR\fdstContinent\x12!\n" +
+	"\fbytes_scaled\x18- \x01(\x04R\vbytesScaled\x12%\n" +
+	"\x0epackets_scaled\x18. \x01(\x04R\rpacketsScaled\x12\x1d\n" +
+	"\n" +
+	"dscp_class\x18\x1b \x01(\tR\tdscpClass\x12$\n" +
+	"\x0eicmp_type_name\x18\x1c \x01(\tR\ficmpTypeName\"M\n" +
 	"\bFlowType\x12\v\n" +
 	"\aUNKNOWN\x10\x00\x12\x0e\n" +
 	"\n" +
@@ -30,29 +30,35 @@ const (
 type StepAction string
 
 const (
-	ActionGNMISet       StepAction = "gnmi_set"
-	ActionGNMIGet       StepAction = "gnmi_get"
-	ActionGNMISubscribe StepAction = "gnmi_subscribe"
-	ActionWait          StepAction = "wait"
-	ActionNotify        StepAction = "notify"
-	ActionCondition     StepAction = "condition"
-	ActionScript        StepAction = "script"
+	ActionGNMISet          StepAction = "gnmi_set"
+	ActionGNMIGet          StepAction = "gnmi_get"
+	ActionGNMISubscribe    StepAction = "gnmi_subscribe"
+	ActionGNMICapabilities StepAction = "gnmi_capabilities"
+	ActionGNMIWait         StepAction = "gnmi_wait"
+	ActionWait             StepAction = "wait"
+	ActionNotify           StepAction = "notify"
+	ActionCondition        StepAction = "condition"
+	ActionScript           StepAction = "script"
 )
 
 // RunbookSpec defines the desired state of Runbook.
 type RunbookSpec struct {
-	Name             string            `json:"name"`
-	Description      string            `json:"description,omitempty"`
-	Category         RunbookCategory   `json:"category"`
-	RiskLevel        RiskLevel         `json:"riskLevel"`
-	RequiresApproval bool              `json:"requiresApproval,omitempty"`
-	Approvers        []Approver        `json:"approvers,omitempty"`
-	ApprovalTimeout  string            `json:"approvalTimeout,omitempty"`
-	AllowedRoles     []string          `json:"allowedRoles,omitempty"`
-	Cooldown         string            `json:"cooldown,omitempty"`
-	Parameters       []Parameter       `json:"parameters,omitempty"`
-	Steps            []RunbookStep     `json:"steps"`
-	Rollback         []RunbookStep     `json:"rollback,omitempty"`
+	Name             string          `json:"name"`
+	Description      string          `json:"description,omitempty"`
+	Category         RunbookCategory `json:"category"`
+	RiskLevel        RiskLevel       `json:"riskLevel"`
+	RequiresApproval bool            `json:"requiresApproval,omitempty"`
+	Approvers        []Approver      `json:"approvers,omitempty"`
+	ApprovalTimeout  string          `json:"approvalTimeout,omitempty"`
+	AllowedRoles     []string        `json:"allowedRoles,omitempty"`
+	Cooldown         string          `json:"cooldown,omitempty"`
+	ExecutionTimeout string          `json:"executionTimeout,omitempty"`
+	MaxConcurrent    int32           `json:"maxConcurrent,omitempty"`
+	Schedule         string          `json:"schedule,omitempty"`
+	StrictTemplates  bool            `json:"strictTemplates,omitempty"`
+	Parameters       []Parameter     `json:"parameters,omitempty"`
+	Steps            []RunbookStep   `json:"steps"`
+	Rollback         []RunbookStep   `json:"rollback,omitempty"`
 }
 
 // Approver defines an approver for a runbook.
@@ -84,7 +90,8 @@ type RunbookStep struct {
 
 // RunbookStatus defines the observed state of Runbook.
 type RunbookStatus struct {
-	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	Conditions       []metav1.Condition `json:"conditions,omitempty"`
+	LastScheduleTime *metav1.Time       `json:"lastScheduleTime,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -41,6 +41,13 @@ const (
 	StepSkipped   StepStatus = "Skipped"
 )
 
+// ApprovalDecision records an explicit decision on a pending approval.
+type ApprovalDecision string
+
+const (
+	ApprovalDenied ApprovalDecision = "denied"
+)
+
 // RunbookExecutionSpec defines the desired state of RunbookExecution.
 type RunbookExecutionSpec struct {
 	RunbookRef    RunbookRef             `json:"runbookRef"`
@@ -49,6 +56,7 @@ type RunbookExecutionSpec struct {
 	TriggerSource TriggerSource          `json:"triggerSource,omitempty"`
 	AlertRef      string                 `json:"alertRef,omitempty"`
 	DryRun        bool                   `json:"dryRun,omitempty"`
+	Cancel        bool                   `json:"cancel,omitempty"`
 }
 
 // RunbookRef references a Runbook.
@@ -59,26 +67,34 @@ type RunbookRef struct {
 
 // RunbookExecutionStatus defines the observed state of RunbookExecution.
 type RunbookExecutionStatus struct {
-	Phase          ExecutionPhase       `json:"phase,omitempty"`
-	StartTime      *metav1.Time         `json:"startTime,omitempty"`
-	CompletionTime *metav1.Time         `json:"completionTime,omitempty"`
-	Duration       string               `json:"duration,omitempty"`
-	ApprovedBy     string               `json:"approvedBy,omitempty"`
-	ApprovedAt     *metav1.Time         `json:"approvedAt,omitempty"`
-	Message        string               `json:"message,omitempty"`
-	Steps          []ExecutionStepStatus `json:"steps,omitempty"`
-	JobName        string               `json:"jobName,omitempty"`
-	Conditions     []metav1.Condition   `json:"conditions,omitempty"`
+	Phase            ExecutionPhase        `json:"phase,omitempty"`
+	StartTime        *metav1.Time          `json:"startTime,omitempty"`
+	CompletionTime   *metav1.Time          `json:"completionTime,omitempty"`
+	Duration         string                `json:"duration,omitempty"`
+	ApprovedBy       string                `json:"approvedBy,omitempty"`
+	ApprovedAt       *metav1.Time          `json:"approvedAt,omitempty"`
+	ApprovalDecision ApprovalDecision      `json:"approvalDecision,omitempty"`
+	DeniedBy         string                `json:"deniedBy,omitempty"`
+	DenialReason     string                `json:"denialReason,omitempty"`
+	Message          string                `json:"message,omitempty"`
+	Steps            []ExecutionStepStatus `json:"steps,omitempty"`
+	JobName          string                `json:"jobName,omitempty"`
+	Conditions       []metav1.Condition    `json:"conditions,omitempty"`
 }
 
 // ExecutionStepStatus defines the status of a single execution step.
+// Outputs too large to keep in the status are truncated and marked
+// OutputTruncated; when outputs are offloaded, OutputRef names the ConfigMap
+// holding the full output under its "output" key.
 type ExecutionStepStatus struct {
-	Name           string     `json:"name"`
-	Status         StepStatus `json:"status"`
-	StartTime      *metav1.Time `json:"startTime,omitempty"`
-	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
-	Output         string     `json:"output,omitempty"`
-	Error          string     `json:"error,omitempty"`
+	Name            string       `json:"name"`
+	Status          StepStatus   `json:"status"`
+	StartTime       *metav1.Time `json:"startTime,omitempty"`
+	CompletionTime  *metav1.Time `json:"completionTime,omitempty"`
+	Output          string       `json:"output,omitempty"`
+	OutputTruncated bool         `json:"outputTruncated,omitempty"`
+	OutputRef       string       `json:"outputRef,omitempty"`
+	Error           string       `json:"error,omitempty"`
 }
 
 // +kubebuilder:object:root=true
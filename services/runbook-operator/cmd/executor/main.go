@@ -2,20 +2,31 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
+	"unicode/utf8"
 
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/rest"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	heliosv1alpha1 "github.com/rhwendt/helios/services/runbook-operator/api/v1alpha1"
+	"github.com/rhwendt/helios/services/runbook-operator/controllers"
+	"github.com/rhwendt/helios/services/runbook-operator/pkg/approval"
 	"github.com/rhwendt/helios/services/runbook-operator/pkg/audit"
 	gnmiclient "github.com/rhwendt/helios/services/runbook-operator/pkg/gnmic"
 	"github.com/rhwendt/helios/services/runbook-operator/pkg/template"
@@ -66,132 +77,648 @@ func main() {
 		os.Exit(1)
 	}
 
-	auditLogger := audit.NewLogger(log)
-	tmplEngine := template.NewEngine()
+	auditSinks, err := audit.NewSinksFromEnv()
+	if err != nil {
+		log.Error("failed to configure audit sinks", "error", err)
+		os.Exit(1)
+	}
+	auditLogger := audit.NewLogger(log, auditSinks...)
+	var tmplOpts []template.EngineOption
+	if runbook.Spec.StrictTemplates {
+		tmplOpts = append(tmplOpts, template.WithStrict())
+	}
+	tmplEngine := template.NewEngine(tmplOpts...)
+
+	defaultTimeout, err := time.ParseDuration(getEnv("STEP_DEFAULT_TIMEOUT", "30s"))
+	if err != nil {
+		log.Error("invalid STEP_DEFAULT_TIMEOUT", "error", err)
+		os.Exit(1)
+	}
+	ex := newExecutor(log, tmplEngine, execution.Spec.DryRun, defaultTimeout)
+	if ex.maxParallel, err = strconv.Atoi(getEnv("MAX_PARALLEL_STEPS", "4")); err != nil || ex.maxParallel < 1 {
+		log.Error("MAX_PARALLEL_STEPS must be a positive integer", "value", os.Getenv("MAX_PARALLEL_STEPS"))
+		os.Exit(1)
+	}
+	if ex.maxOutputBytes, err = strconv.Atoi(getEnv("MAX_STEP_OUTPUT_BYTES", "32768")); err != nil || ex.maxOutputBytes < 0 {
+		log.Error("MAX_STEP_OUTPUT_BYTES must be a non-negative integer", "value", os.Getenv("MAX_STEP_OUTPUT_BYTES"))
+		os.Exit(1)
+	}
+	if os.Getenv("OFFLOAD_STEP_OUTPUTS") == "true" {
+		ex.outputSink = &configMapOutputSink{client: k8sClient, execution: &execution}
+	}
 
-	// Build parameters map
-	params := make(map[string]interface{})
-	if execution.Spec.Parameters != nil {
-		params = execution.Spec.Parameters
+	// Build parameters map. The operator has already filled in defaults; the
+	// map is copied so registered step outputs do not leak into the
+	// execution spec.
+	params := make(map[string]interface{}, len(execution.Spec.Parameters))
+	for k, v := range execution.Spec.Parameters {
+		params[k] = v
 	}
 
-	// Execute steps sequentially
-	steps := runbook.Spec.Steps
+	// Execute steps in order, running parallel groups concurrently. Rollback
+	// jobs run the runbook's rollback steps and record them after the
+	// forward steps' statuses.
+	rollback := os.Getenv("ROLLBACK") == "true"
+	steps := selectSteps(&runbook, rollback)
+	var priorStatuses []heliosv1alpha1.ExecutionStepStatus
+	if rollback {
+		log.Info("running rollback steps", "count", len(steps))
+		priorStatuses = execution.Status.Steps
+	}
 	stepStatuses := make([]heliosv1alpha1.ExecutionStepStatus, len(steps))
 
 	for i, step := range steps {
+		name := step.Name
+		if rollback {
+			name = "rollback/" + name
+		}
 		stepStatuses[i] = heliosv1alpha1.ExecutionStepStatus{
-			Name:   step.Name,
+			Name:   name,
 			Status: heliosv1alpha1.StepPending,
 		}
 	}
 
+	events := stepAudit{
+		logger:      auditLogger,
+		execution:   executionName,
+		namespace:   executionNamespace,
+		runbook:     runbook.Spec.Name,
+		triggeredBy: execution.Spec.TriggeredBy,
+	}
 	exitCode := 0
+	ok := ex.runSteps(ctx, steps, stepStatuses, params, events, func() {
+		// Update execution status with step progress
+		execution.Status.Steps = concatStatuses(priorStatuses, stepStatuses)
+		if updateErr := k8sClient.Status().Update(ctx, &execution); updateErr != nil {
+			log.Error("failed to update execution status", "error", updateErr)
+		}
+	})
+	if !ok {
+		exitCode = 1
+	}
+
+	// Mark remaining steps as skipped if we exited early
+	for i := range stepStatuses {
+		if stepStatuses[i].Status == heliosv1alpha1.StepPending {
+			stepStatuses[i].Status = heliosv1alpha1.StepSkipped
+		}
+	}
+
+	execution.Status.Steps = concatStatuses(priorStatuses, stepStatuses)
+	if err := k8sClient.Status().Update(ctx, &execution); err != nil {
+		log.Error("failed to update final execution status", "error", err)
+	}
+
+	ex.closeClients()
+	if pushURL := os.Getenv("METRICS_PUSHGATEWAY_URL"); pushURL != "" {
+		if err := pushMetrics(pushURL, executionNamespace, execution.Spec.RunbookRef.Name); err != nil {
+			log.Warn("failed to push gNMI metrics", "error", err)
+		}
+	}
+	if err := auditLogger.Close(); err != nil {
+		log.Error("failed to close audit sinks", "error", err)
+	}
+	os.Exit(exitCode)
+}
+
+// stepAudit records step events against the execution being run.
+type stepAudit struct {
+	logger      *audit.Logger
+	execution   string
+	namespace   string
+	runbook     string
+	triggeredBy string
+}
+
+// stepGroups splits steps into the groups runSteps executes one after
+// another. Consecutive steps sharing a config.parallel_group form one group;
+// every other step is a group of its own.
+func stepGroups(steps []heliosv1alpha1.RunbookStep) [][]int {
+	var groups [][]int
+	prev := ""
 	for i, step := range steps {
-		now := metav1.Now()
-		stepStatuses[i].Status = heliosv1alpha1.StepRunning
-		stepStatuses[i].StartTime = &now
+		group, _ := step.Config["parallel_group"].(string)
+		if group != "" && group == prev {
+			groups[len(groups)-1] = append(groups[len(groups)-1], i)
+		} else {
+			groups = append(groups, []int{i})
+		}
+		prev = group
+	}
+	return groups
+}
 
-		auditLogger.LogStepStart(ctx, executionName, executionNamespace, runbook.Spec.Name, step.Name, execution.Spec.TriggeredBy)
+// runSteps executes steps in order, recording each result in the matching
+// entry of statuses, and calls progress after each group of steps. Steps in
+// a parallel group run concurrently, at most maxParallel at a time, and the
+// group is joined before the next step starts. Their outputs are registered
+// in step order once the group completes, so steps in a group cannot use
+// each other's outputs. It returns false when a step without
+// ContinueOnError failed; no later steps are started, leaving them pending.
+func (e *executor) runSteps(ctx context.Context, steps []heliosv1alpha1.RunbookStep, statuses []heliosv1alpha1.ExecutionStepStatus, params map[string]interface{}, a stepAudit, progress func()) bool {
+	limit := e.maxParallel
+	if limit < 1 {
+		limit = 1
+	}
 
-		// Check condition
-		if step.Condition != "" {
-			result, err := tmplEngine.Render(step.Condition, params)
-			if err != nil {
-				log.Warn("condition evaluation failed", "step", step.Name, "error", err)
+	for _, group := range stepGroups(steps) {
+		outputs := make([]string, len(steps))
+		sem := make(chan struct{}, limit)
+		var (
+			wg      sync.WaitGroup
+			mu      sync.Mutex
+			stopped bool
+		)
+		for _, i := range group {
+			sem <- struct{}{}
+			mu.Lock()
+			stop := stopped
+			mu.Unlock()
+			if stop {
+				<-sem
+				break
 			}
-			if result == "false" || result == "" {
-				completionTime := metav1.Now()
-				stepStatuses[i].Status = heliosv1alpha1.StepSkipped
-				stepStatuses[i].CompletionTime = &completionTime
-				stepStatuses[i].Output = "Condition not met, skipped"
+
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				output, err := e.runStep(ctx, steps[i], &statuses[i], params, a)
+				if err != nil && !steps[i].ContinueOnError {
+					mu.Lock()
+					stopped = true
+					mu.Unlock()
+				}
+				outputs[i] = output
+			}(i)
+		}
+		wg.Wait()
+
+		for _, i := range group {
+			if statuses[i].Status != heliosv1alpha1.StepCompleted {
 				continue
 			}
+			if err := registerOutput(params, steps[i], outputs[i]); err != nil {
+				e.log.Warn("failed to register step output", "step", steps[i].Name, "error", err)
+			}
+		}
+		progress()
+		if stopped {
+			return false
 		}
+	}
+	return true
+}
 
-		// Execute step
-		output, err := executeStep(ctx, log, step, params, tmplEngine, execution.Spec.DryRun)
+// runStep evaluates the step's condition and executes it, recording the
+// result in status.
+func (e *executor) runStep(ctx context.Context, step heliosv1alpha1.RunbookStep, status *heliosv1alpha1.ExecutionStepStatus, params map[string]interface{}, a stepAudit) (string, error) {
+	now := metav1.Now()
+	status.Status = heliosv1alpha1.StepRunning
+	status.StartTime = &now
 
-		completionTime := metav1.Now()
-		stepStatuses[i].CompletionTime = &completionTime
+	a.logger.LogStepStart(ctx, a.execution, a.namespace, a.runbook, step.Name, a.triggeredBy)
 
+	// Check condition
+	if step.Condition != "" {
+		result, err := e.tmplEngine.Render(step.Condition, params)
 		if err != nil {
-			stepStatuses[i].Status = heliosv1alpha1.StepFailed
-			stepStatuses[i].Error = err.Error()
-			auditLogger.LogStepFailed(ctx, executionName, executionNamespace, runbook.Spec.Name, step.Name, execution.Spec.TriggeredBy, err.Error())
+			e.log.Warn("condition evaluation failed", "step", step.Name, "error", err)
+		}
+		if isFalsey(result) {
+			completionTime := metav1.Now()
+			status.Status = heliosv1alpha1.StepSkipped
+			status.CompletionTime = &completionTime
+			status.Output = "Condition not met, skipped"
+			return "", nil
+		}
+	}
 
-			if !step.ContinueOnError {
-				exitCode = 1
-				break
+	// Execute step
+	output, err := e.executeStep(ctx, step, params)
+
+	completionTime := metav1.Now()
+	status.CompletionTime = &completionTime
+
+	if err != nil {
+		status.Status = heliosv1alpha1.StepFailed
+		status.Error = err.Error()
+		a.logger.LogStepFailed(ctx, a.execution, a.namespace, a.runbook, step.Name, a.triggeredBy, err.Error())
+		return "", err
+	}
+	status.Status = heliosv1alpha1.StepCompleted
+	e.recordOutput(ctx, status, output)
+	a.logger.LogStepComplete(ctx, a.execution, a.namespace, a.runbook, step.Name, a.triggeredBy, output)
+	return output, nil
+}
+
+// outputSink stores the full output of a step whose output was truncated in
+// the execution status, returning a reference to the stored copy.
+type outputSink interface {
+	Store(ctx context.Context, step, output string) (string, error)
+}
+
+// recordOutput sets the status output, truncating it to maxOutputBytes and
+// offloading the full output to the output sink when it is too large.
+func (e *executor) recordOutput(ctx context.Context, status *heliosv1alpha1.ExecutionStepStatus, output string) {
+	if e.maxOutputBytes <= 0 || len(output) <= e.maxOutputBytes {
+		status.Output = output
+		return
+	}
+	status.Output = truncateUTF8(output, e.maxOutputBytes)
+	status.OutputTruncated = true
+	if e.outputSink == nil {
+		return
+	}
+	ref, err := e.outputSink.Store(ctx, status.Name, output)
+	if err != nil {
+		e.log.Warn("failed to store full step output", "step", status.Name, "bytes", len(output), "error", err)
+		return
+	}
+	status.OutputRef = ref
+}
+
+// truncateUTF8 shortens s to at most n bytes without splitting a rune.
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// pushMetrics sends the gNMI client metrics to a Prometheus Pushgateway, as
+// executor Jobs are too short-lived to be scraped. Metrics are grouped by
+// runbook so the number of groups stays bounded; each push replaces the
+// previous execution's values for that runbook.
+func pushMetrics(url, namespace, runbook string) error {
+	reg := prometheus.NewRegistry()
+	gnmiclient.RegisterMetrics(reg)
+	return push.New(url, "helios_runbook_executor").
+		Gatherer(reg).
+		Grouping("namespace", namespace).
+		Grouping("runbook", runbook).
+		Push()
+}
+
+// selectSteps returns the steps an executor job should run: the rollback
+// steps for rollback jobs, otherwise the forward steps.
+func selectSteps(runbook *heliosv1alpha1.Runbook, rollback bool) []heliosv1alpha1.RunbookStep {
+	if rollback {
+		return runbook.Spec.Rollback
+	}
+	return runbook.Spec.Steps
+}
+
+// concatStatuses joins step statuses into a new slice.
+func concatStatuses(prior, current []heliosv1alpha1.ExecutionStepStatus) []heliosv1alpha1.ExecutionStepStatus {
+	out := make([]heliosv1alpha1.ExecutionStepStatus, 0, len(prior)+len(current))
+	out = append(out, prior...)
+	return append(out, current...)
+}
+
+// gnmiClient is the subset of the gNMI client used by step actions.
+type gnmiClient interface {
+	Connect(ctx context.Context) error
+	Close() error
+	Get(ctx context.Context, paths []string, opts ...gnmiclient.GetOption) (*gnmipb.GetResponse, error)
+	Set(ctx context.Context, requests []gnmiclient.SetRequest) (*gnmipb.SetResponse, error)
+	Capabilities(ctx context.Context) (*gnmipb.CapabilityResponse, error)
+	SubscribeTo(ctx context.Context, subs []gnmiclient.Subscription, mode gnmipb.SubscriptionList_Mode, handler gnmiclient.SubscribeHandler) error
+}
+
+// executor carries the state shared by every step of an execution.
+type executor struct {
+	log            *slog.Logger
+	tmplEngine     *template.Engine
+	dryRun         bool
+	defaultTimeout time.Duration
+	// maxParallel bounds how many steps of a parallel group run at once.
+	maxParallel int
+	// maxOutputBytes caps the step output kept in the execution status; a
+	// larger output is truncated and, with an outputSink, stored in full
+	// there. Zero keeps every output whole.
+	maxOutputBytes int
+	outputSink     outputSink
+	newClient      func(target string, config map[string]interface{}) gnmiClient
+
+	// clients holds the connection for each target so that steps against
+	// the same device share one connection for the execution. mu guards
+	// only the map; connections are dialled outside it.
+	mu      sync.Mutex
+	clients map[clientKey]*pendingClient
+}
+
+// pendingClient is a cached connection. done is closed once the dial has
+// finished, after which client or err is set.
+type pendingClient struct {
+	done   chan struct{}
+	client gnmiClient
+	err    error
+}
+
+// clientKey identifies a cached connection by target and the step config
+// that affects how it is established.
+type clientKey struct {
+	target     string
+	insecure   bool
+	tls        bool
+	serverName string
+	pinnedCert string
+}
+
+func newExecutor(log *slog.Logger, tmplEngine *template.Engine, dryRun bool, defaultTimeout time.Duration) *executor {
+	e := &executor{
+		log:            log,
+		tmplEngine:     tmplEngine,
+		dryRun:         dryRun,
+		defaultTimeout: defaultTimeout,
+		clients:        make(map[clientKey]*pendingClient),
+	}
+	e.newClient = e.newGNMIClient
+	return e
+}
+
+func (e *executor) executeStep(ctx context.Context, step heliosv1alpha1.RunbookStep, params map[string]interface{}) (string, error) {
+	// Wait steps are bounded by their own duration.
+	if step.Action == heliosv1alpha1.ActionWait {
+		return executeWait(ctx, step)
+	}
+
+	timeout, err := e.stepTimeout(step)
+	if err != nil {
+		return "", err
+	}
+	retries, interval, err := stepRetries(step)
+	if err != nil {
+		return "", err
+	}
+
+	// Failed attempts are retried with exponential backoff, each with the
+	// full step timeout, unless the error shows a retry cannot succeed.
+	for attempt := 0; ; attempt++ {
+		output, err := e.attemptStep(ctx, step, params, timeout)
+		if err == nil {
+			return output, nil
+		}
+		var perr *permanentError
+		if attempt == retries || errors.Is(err, gnmiclient.ErrInvalidRequest) || errors.As(err, &perr) {
+			if attempt > 0 {
+				return output, fmt.Errorf("step failed after %d attempts: %w", attempt+1, err)
 			}
-		} else {
-			stepStatuses[i].Status = heliosv1alpha1.StepCompleted
-			stepStatuses[i].Output = output
-			auditLogger.LogStepComplete(ctx, executionName, executionNamespace, runbook.Spec.Name, step.Name, execution.Spec.TriggeredBy, output)
+			return output, err
 		}
 
-		// Update execution status with step progress
-		execution.Status.Steps = stepStatuses
-		if updateErr := k8sClient.Status().Update(ctx, &execution); updateErr != nil {
-			log.Error("failed to update execution status", "error", updateErr)
+		delay := retryDelay(interval, attempt)
+		e.log.Warn("step failed, retrying", "step", step.Name, "attempt", attempt+1, "delay", delay, "error", err)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return "", fmt.Errorf("step cancelled while retrying: %w", errors.Join(ctx.Err(), err))
+		case <-timer.C:
 		}
 	}
+}
 
-	// Mark remaining steps as skipped if we exited early
-	for i := range stepStatuses {
-		if stepStatuses[i].Status == heliosv1alpha1.StepPending {
-			stepStatuses[i].Status = heliosv1alpha1.StepSkipped
-		}
+// attemptStep runs the step's action once, bounded by timeout.
+func (e *executor) attemptStep(ctx context.Context, step heliosv1alpha1.RunbookStep, params map[string]interface{}, timeout time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	output, err := e.runAction(ctx, step, params)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return "", fmt.Errorf("step timed out after %s: %w", timeout, context.DeadlineExceeded)
 	}
+	return output, err
+}
 
-	execution.Status.Steps = stepStatuses
-	if err := k8sClient.Status().Update(ctx, &execution); err != nil {
-		log.Error("failed to update final execution status", "error", err)
+// permanentError marks a step failure that retrying cannot fix, such as a
+// template or config error.
+type permanentError struct {
+	err error
+}
+
+// permanent wraps err, if any, as a permanentError.
+func permanent(err error) error {
+	if err == nil {
+		return nil
 	}
+	return &permanentError{err: err}
+}
 
-	os.Exit(exitCode)
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// defaultRetryInterval is the delay before the first retry of a step that
+// sets config.retries but not config.retry_interval.
+const defaultRetryInterval = time.Second
+
+// maxStepRetries bounds config.retries, and maxRetryDelay the backoff
+// between attempts, so a step cannot wait for days between retries.
+const (
+	maxStepRetries = 10
+	maxRetryDelay  = 5 * time.Minute
+)
+
+// retryDelay returns the delay before retry attempt+1: interval doubled
+// for each earlier attempt, capped at maxRetryDelay.
+func retryDelay(interval time.Duration, attempt int) time.Duration {
+	delay := interval
+	for i := 0; i < attempt && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxRetryDelay)
 }
 
-func executeStep(ctx context.Context, log *slog.Logger, step heliosv1alpha1.RunbookStep, params map[string]interface{}, tmplEngine *template.Engine, dryRun bool) (string, error) {
+// stepRetries reads config.retries, the number of times a failed step is
+// retried, and config.retry_interval, the delay before the first retry,
+// which doubles on each further retry up to maxRetryDelay.
+func stepRetries(step heliosv1alpha1.RunbookStep) (int, time.Duration, error) {
+	var retries int
+	switch v := step.Config["retries"].(type) {
+	case nil:
+	case int:
+		retries = v
+	case int64:
+		retries = int(v)
+	case float64:
+		retries = int(v)
+		if float64(retries) != v {
+			return 0, 0, fmt.Errorf("retries must be a whole number, got %v", v)
+		}
+	case string:
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid retries %q: %w", v, err)
+		}
+		retries = n
+	default:
+		return 0, 0, fmt.Errorf("invalid retries %v", v)
+	}
+	if retries < 0 {
+		return 0, 0, fmt.Errorf("retries must not be negative, got %d", retries)
+	}
+	if retries > maxStepRetries {
+		return 0, 0, fmt.Errorf("retries must be at most %d, got %d", maxStepRetries, retries)
+	}
+
+	interval, err := configDuration(step.Config, "retry_interval")
+	if err != nil {
+		return 0, 0, err
+	}
+	if interval < 0 {
+		return 0, 0, fmt.Errorf("retry_interval must not be negative, got %s", interval)
+	}
+	if interval == 0 {
+		interval = defaultRetryInterval
+	}
+	return retries, interval, nil
+}
+
+func (e *executor) runAction(ctx context.Context, step heliosv1alpha1.RunbookStep, params map[string]interface{}) (string, error) {
 	switch step.Action {
 	case heliosv1alpha1.ActionGNMISet:
-		return executeGNMISet(ctx, log, step, params, tmplEngine, dryRun)
+		return e.executeGNMISet(ctx, step, params)
 	case heliosv1alpha1.ActionGNMIGet:
-		return executeGNMIGet(ctx, log, step, params, tmplEngine)
-	case heliosv1alpha1.ActionWait:
-		return executeWait(ctx, step)
+		return e.executeGNMIGet(ctx, step, params)
+	case heliosv1alpha1.ActionGNMISubscribe:
+		return e.executeGNMISubscribe(ctx, step, params)
+	case heliosv1alpha1.ActionGNMICapabilities:
+		return e.executeGNMICapabilities(ctx, step, params)
+	case heliosv1alpha1.ActionGNMIWait:
+		return e.executeGNMIWait(ctx, step, params)
 	case heliosv1alpha1.ActionNotify:
-		return "notification sent", nil
+		return e.executeNotify(ctx, step, params)
 	case heliosv1alpha1.ActionCondition:
-		return "condition evaluated", nil
+		// Conditions only depend on the parameters, so never retry them.
+		output, err := e.executeCondition(step, params)
+		return output, permanent(err)
 	default:
-		return "", fmt.Errorf("unsupported action: %s", step.Action)
+		return "", permanent(fmt.Errorf("unsupported action: %s", step.Action))
 	}
 }
 
-func executeGNMISet(ctx context.Context, log *slog.Logger, step heliosv1alpha1.RunbookStep, params map[string]interface{}, tmplEngine *template.Engine, dryRun bool) (string, error) {
-	config, err := tmplEngine.RenderConfig(step.Config, params)
+// stepTimeout returns the step's own timeout, or the executor default when
+// the step does not set one.
+func (e *executor) stepTimeout(step heliosv1alpha1.RunbookStep) (time.Duration, error) {
+	if step.Timeout == "" {
+		return e.defaultTimeout, nil
+	}
+	timeout, err := time.ParseDuration(step.Timeout)
 	if err != nil {
-		return "", fmt.Errorf("failed to render config: %w", err)
+		return 0, fmt.Errorf("invalid step timeout %q: %w", step.Timeout, err)
+	}
+	if timeout <= 0 {
+		return 0, fmt.Errorf("step timeout must be positive, got %q", step.Timeout)
+	}
+	return timeout, nil
+}
+
+// renderTarget renders the step config and returns it with the gNMI target.
+func (e *executor) renderTarget(step heliosv1alpha1.RunbookStep, params map[string]interface{}) (map[string]interface{}, string, error) {
+	config, err := controllers.RenderStepConfig(e.tmplEngine, step, params)
+	if err != nil {
+		return nil, "", permanent(fmt.Errorf("failed to render config: %w", err))
 	}
 
 	target, _ := config["target"].(string)
 	if target == "" {
-		return "", fmt.Errorf("gNMI target not specified in step config")
+		return nil, "", permanent(fmt.Errorf("gNMI target not specified in step config"))
+	}
+	return config, target, nil
+}
+
+// connect returns a connected gNMI client for target, reusing the
+// execution's existing connection when there is one. Connections are only
+// shared between steps with the same transport settings, so config.insecure
+// and the TLS options are always honoured. Concurrent steps to the same
+// target wait for a single dial, while dials to different targets proceed
+// in parallel. A failed dial is not cached, so later steps try again.
+// Clients are closed by closeClients, not by the caller.
+func (e *executor) connect(ctx context.Context, target string, config map[string]interface{}) (gnmiClient, error) {
+	key := clientKey{target: target, insecure: configBool(config, "insecure"), tls: stepUsesTLS(config)}
+	key.serverName, _ = config["tls_server_name"].(string)
+	key.pinnedCert, _ = config["tls_pinned_cert"].(string)
+
+	e.mu.Lock()
+	pending, ok := e.clients[key]
+	if !ok {
+		pending = &pendingClient{done: make(chan struct{})}
+		e.clients[key] = pending
+	}
+	e.mu.Unlock()
+
+	if ok {
+		select {
+		case <-pending.done:
+		case <-ctx.Done():
+			return nil, fmt.Errorf("waiting for connection to %s: %w", target, ctx.Err())
+		}
+		if pending.err != nil {
+			return nil, fmt.Errorf("failed to connect to %s: %w", target, pending.err)
+		}
+		return pending.client, nil
+	}
+
+	client := e.newClient(target, config)
+	err := client.Connect(ctx)
+	if err != nil {
+		e.mu.Lock()
+		delete(e.clients, key)
+		e.mu.Unlock()
+		pending.err = err
+	} else {
+		pending.client = client
+	}
+	close(pending.done)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", target, err)
+	}
+	return client, nil
+}
+
+// closeClients closes every connection opened during the execution.
+func (e *executor) closeClients() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for key, pending := range e.clients {
+		select {
+		case <-pending.done:
+		default:
+			// Still dialling; its step has not finished, so it is left to
+			// be cleaned up with the process.
+			continue
+		}
+		if pending.client != nil {
+			if err := pending.client.Close(); err != nil {
+				e.log.Warn("failed to close gNMI connection", "target", key.target, "error", err)
+			}
+		}
+		delete(e.clients, key)
+	}
+}
+
+func (e *executor) executeGNMISet(ctx context.Context, step heliosv1alpha1.RunbookStep, params map[string]interface{}) (string, error) {
+	config, target, err := e.renderTarget(step, params)
+	if err != nil {
+		return "", err
 	}
 
-	if dryRun {
+	if e.dryRun {
 		configJSON, _ := json.Marshal(config)
 		return fmt.Sprintf("[DRY RUN] Would execute gNMI Set on %s: %s", target, string(configJSON)), nil
 	}
 
-	client := gnmiclient.NewClient(target, "", "", log)
-	if err := client.Connect(ctx); err != nil {
-		return "", fmt.Errorf("failed to connect to %s: %w", target, err)
+	client, err := e.connect(ctx, target, config)
+	if err != nil {
+		return "", err
 	}
-	defer client.Close()
 
-	path, _ := config["path"].(string)
+	path := configPath(config)
 	value := config["value"]
 
 	_, err = client.Set(ctx, []gnmiclient.SetRequest{
@@ -203,33 +730,483 @@ func executeGNMISet(ctx context.Context, log *slog.Logger, step heliosv1alpha1.R
 	return fmt.Sprintf("gNMI Set completed on %s path %s", target, path), nil
 }
 
-func executeGNMIGet(ctx context.Context, log *slog.Logger, step heliosv1alpha1.RunbookStep, params map[string]interface{}, tmplEngine *template.Engine) (string, error) {
-	config, err := tmplEngine.RenderConfig(step.Config, params)
+func (e *executor) executeGNMIGet(ctx context.Context, step heliosv1alpha1.RunbookStep, params map[string]interface{}) (string, error) {
+	config, target, err := e.renderTarget(step, params)
 	if err != nil {
-		return "", fmt.Errorf("failed to render config: %w", err)
+		return "", err
 	}
 
-	target, _ := config["target"].(string)
-	if target == "" {
-		return "", fmt.Errorf("gNMI target not specified in step config")
+	opts, err := configGetOptions(config)
+	if err != nil {
+		return "", permanent(err)
 	}
 
-	client := gnmiclient.NewClient(target, "", "", log)
-	if err := client.Connect(ctx); err != nil {
-		return "", fmt.Errorf("failed to connect to %s: %w", target, err)
+	client, err := e.connect(ctx, target, config)
+	if err != nil {
+		return "", err
 	}
-	defer client.Close()
 
-	path, _ := config["path"].(string)
-	resp, err := client.Get(ctx, []string{path})
+	path := configPath(config)
+	resp, err := client.Get(ctx, []string{path}, opts...)
 	if err != nil {
 		return "", err
 	}
 
-	respJSON, _ := json.Marshal(resp)
+	values, err := gnmiclient.DecodeGetResponse(resp)
+	if err != nil {
+		return "", err
+	}
+	respJSON, _ := json.Marshal(values)
 	return string(respJSON), nil
 }
 
+// defaultWaitInterval is how often a gnmi_wait step polls when it does not
+// set config.interval.
+const defaultWaitInterval = 5 * time.Second
+
+// executeGNMIWait polls config.path every config.interval until
+// config.condition renders truthy or the step times out. The condition is a
+// template evaluated against the runbook parameters plus .value, the decoded
+// value at the path (or a path-to-value map if the Get returns several), so
+// "{{ eq .value \"UP\" }}" waits for an interface to come up. The output
+// is the final value.
+func (e *executor) executeGNMIWait(ctx context.Context, step heliosv1alpha1.RunbookStep, params map[string]interface{}) (string, error) {
+	condition, _ := step.Config["condition"].(string)
+	if condition == "" {
+		return "", permanent(fmt.Errorf("condition not specified in step config"))
+	}
+
+	// The condition depends on each polled value, so the up-front render
+	// only checks its syntax.
+	config, target, err := e.renderTarget(step, params)
+	if err != nil {
+		return "", err
+	}
+	interval, err := configDuration(config, "interval")
+	if err != nil {
+		return "", permanent(err)
+	}
+	if interval <= 0 {
+		interval = defaultWaitInterval
+	}
+	opts, err := configGetOptions(config)
+	if err != nil {
+		return "", permanent(err)
+	}
+
+	client, err := e.connect(ctx, target, config)
+	if err != nil {
+		return "", err
+	}
+
+	path := configPath(config)
+	var value interface{}
+	var condErr error
+	_, err = gnmiclient.PollUntil(ctx, client, []string{path}, interval, func(resp *gnmipb.GetResponse) bool {
+		value, condErr = waitValue(resp)
+		if condErr != nil {
+			return false
+		}
+		var result string
+		result, condErr = e.tmplEngine.Render(condition, withValue(params, value))
+		return condErr == nil && !isFalsey(result)
+	}, opts...)
+	if err != nil {
+		if condErr != nil {
+			return "", fmt.Errorf("condition %q on %s: %w", condition, path, errors.Join(condErr, err))
+		}
+		return "", fmt.Errorf("condition %q not met on %s: %w", condition, path, err)
+	}
+
+	outJSON, _ := json.Marshal(value)
+	return string(outJSON), nil
+}
+
+// waitValue decodes resp for a gnmi_wait condition, unwrapping the value
+// when the Get returned a single path.
+func waitValue(resp *gnmipb.GetResponse) (interface{}, error) {
+	values, err := gnmiclient.DecodeGetResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) == 1 {
+		for _, v := range values {
+			return v, nil
+		}
+	}
+	return values, nil
+}
+
+// withValue returns a copy of params with "value" set.
+func withValue(params map[string]interface{}, value interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(params)+1)
+	for k, v := range params {
+		out[k] = v
+	}
+	out["value"] = value
+	return out
+}
+
+// configGetOptions reads the optional config.encoding (e.g. json_ietf,
+// proto, ascii) and config.data_type (all, config, state, operational) of a
+// gnmi_get step.
+func configGetOptions(config map[string]interface{}) ([]gnmiclient.GetOption, error) {
+	encodingStr, _ := config["encoding"].(string)
+	encoding, err := gnmiclient.ParseEncoding(encodingStr)
+	if err != nil {
+		return nil, err
+	}
+	dataTypeStr, _ := config["data_type"].(string)
+	dataType, err := gnmiclient.ParseDataType(dataTypeStr)
+	if err != nil {
+		return nil, err
+	}
+	return []gnmiclient.GetOption{gnmiclient.WithEncoding(encoding), gnmiclient.WithDataType(dataType)}, nil
+}
+
+// errSubscriptionDone stops a gnmi_subscribe stream once the step has what
+// it needs.
+var errSubscriptionDone = errors.New("subscription done")
+
+// executeGNMISubscribe streams config.path with config.mode (sample,
+// on_change or target_defined), config.sample_interval and
+// config.heartbeat_interval. Without config.retryUntil the step completes at
+// the first sync_response; with it, the step waits until an update value
+// contains retryUntil or the step times out. The output is the last
+// notification received.
+func (e *executor) executeGNMISubscribe(ctx context.Context, step heliosv1alpha1.RunbookStep, params map[string]interface{}) (string, error) {
+	config, target, err := e.renderTarget(step, params)
+	if err != nil {
+		return "", err
+	}
+
+	sub, err := configSubscription(config)
+	if err != nil {
+		return "", permanent(err)
+	}
+	retryUntil, _ := config["retryUntil"].(string)
+
+	client, err := e.connect(ctx, target, config)
+	if err != nil {
+		return "", err
+	}
+
+	var last *gnmipb.Notification
+	synced := false
+	err = client.SubscribeTo(ctx, []gnmiclient.Subscription{sub}, gnmipb.SubscriptionList_STREAM, func(resp *gnmipb.SubscribeResponse) error {
+		if resp.GetSyncResponse() {
+			synced = true
+			if retryUntil == "" {
+				return errSubscriptionDone
+			}
+			return nil
+		}
+		n := resp.GetUpdate()
+		if n == nil {
+			return nil
+		}
+		last = n
+		if retryUntil != "" && notificationContains(n, retryUntil) {
+			return errSubscriptionDone
+		}
+		return nil
+	})
+	switch {
+	case errors.Is(err, errSubscriptionDone):
+	case err != nil:
+		return "", err
+	case retryUntil != "":
+		return "", fmt.Errorf("subscription to %s ended before %q was seen", sub.Path, retryUntil)
+	case !synced:
+		return "", fmt.Errorf("subscription to %s ended before sync", sub.Path)
+	}
+
+	outJSON, _ := json.Marshal(last)
+	return string(outJSON), nil
+}
+
+// configSubscription builds the gnmi_subscribe subscription from step config.
+func configSubscription(config map[string]interface{}) (gnmiclient.Subscription, error) {
+	modeStr, _ := config["mode"].(string)
+	mode, err := gnmiclient.ParseSubscriptionMode(modeStr)
+	if err != nil {
+		return gnmiclient.Subscription{}, err
+	}
+	sub := gnmiclient.Subscription{Path: configPath(config), Mode: mode}
+	if sub.SampleInterval, err = configDuration(config, "sample_interval"); err != nil {
+		return gnmiclient.Subscription{}, err
+	}
+	if sub.HeartbeatInterval, err = configDuration(config, "heartbeat_interval"); err != nil {
+		return gnmiclient.Subscription{}, err
+	}
+	return sub, nil
+}
+
+// configDuration reads an optional duration from step config, returning
+// zero when the key is unset.
+func configDuration(config map[string]interface{}, key string) (time.Duration, error) {
+	s, _ := config[key].(string)
+	if s == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", key, s, err)
+	}
+	return d, nil
+}
+
+// notificationContains reports whether any update value in n contains want.
+func notificationContains(n *gnmipb.Notification, want string) bool {
+	for _, u := range n.GetUpdate() {
+		if strings.Contains(typedValueString(u.GetVal()), want) {
+			return true
+		}
+	}
+	return false
+}
+
+// typedValueString renders a scalar or JSON typed value as text.
+func typedValueString(tv *gnmipb.TypedValue) string {
+	switch v := tv.GetValue().(type) {
+	case *gnmipb.TypedValue_StringVal:
+		return v.StringVal
+	case *gnmipb.TypedValue_AsciiVal:
+		return v.AsciiVal
+	case *gnmipb.TypedValue_JsonIetfVal:
+		return string(v.JsonIetfVal)
+	case *gnmipb.TypedValue_JsonVal:
+		return string(v.JsonVal)
+	case *gnmipb.TypedValue_IntVal:
+		return strconv.FormatInt(v.IntVal, 10)
+	case *gnmipb.TypedValue_UintVal:
+		return strconv.FormatUint(v.UintVal, 10)
+	case *gnmipb.TypedValue_BoolVal:
+		return strconv.FormatBool(v.BoolVal)
+	}
+	return ""
+}
+
+// capabilitiesOutput is the step output recorded for a gnmi_capabilities step.
+type capabilitiesOutput struct {
+	GNMIVersion string   `json:"gnmiVersion"`
+	Models      []string `json:"models"`
+	Encodings   []string `json:"encodings"`
+}
+
+func (e *executor) executeGNMICapabilities(ctx context.Context, step heliosv1alpha1.RunbookStep, params map[string]interface{}) (string, error) {
+	config, target, err := e.renderTarget(step, params)
+	if err != nil {
+		return "", err
+	}
+
+	client, err := e.connect(ctx, target, config)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := client.Capabilities(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	out := capabilitiesOutput{GNMIVersion: resp.GetGNMIVersion()}
+	supported := make(map[string]bool)
+	for _, m := range resp.GetSupportedModels() {
+		out.Models = append(out.Models, m.GetName())
+		supported[m.GetName()] = true
+	}
+	for _, enc := range resp.GetSupportedEncodings() {
+		out.Encodings = append(out.Encodings, enc.String())
+	}
+
+	// config.models optionally lists YANG models the device must support.
+	required, _ := config["models"].([]interface{})
+	for _, m := range required {
+		name := fmt.Sprint(m)
+		if !supported[name] {
+			return "", permanent(fmt.Errorf("device %s does not support required model %q", target, name))
+		}
+	}
+
+	outJSON, _ := json.Marshal(out)
+	return string(outJSON), nil
+}
+
+// executeNotify posts the rendered config.message to config.webhook_url,
+// formatted for config.type (slack, teams or webhook).
+func (e *executor) executeNotify(ctx context.Context, step heliosv1alpha1.RunbookStep, params map[string]interface{}) (string, error) {
+	config, err := controllers.RenderStepConfig(e.tmplEngine, step, params)
+	if err != nil {
+		return "", permanent(fmt.Errorf("failed to render config: %w", err))
+	}
+
+	webhookURL, _ := config["webhook_url"].(string)
+	if webhookURL == "" {
+		return "", permanent(fmt.Errorf("webhook_url not specified in step config"))
+	}
+	message, _ := config["message"].(string)
+	if message == "" {
+		return "", permanent(fmt.Errorf("message not specified in step config"))
+	}
+	notifyType, _ := config["type"].(string)
+	if notifyType == "" {
+		notifyType = string(approval.NotifyWebhook)
+	}
+
+	if e.dryRun {
+		return fmt.Sprintf("[DRY RUN] Would send %s notification: %s", notifyType, message), nil
+	}
+
+	approver := approval.NewApprover(webhookURL, approval.NotificationType(notifyType), e.log)
+	if err := approver.SendMessage(ctx, message); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s notification sent", notifyType), nil
+}
+
+// executeCondition renders config.expression and fails the step when the
+// result is falsey, acting as an explicit gate for the steps that follow.
+func (e *executor) executeCondition(step heliosv1alpha1.RunbookStep, params map[string]interface{}) (string, error) {
+	expr, _ := step.Config["expression"].(string)
+	if expr == "" {
+		return "", fmt.Errorf("expression not specified in step config")
+	}
+
+	result, err := e.tmplEngine.Render(expr, params)
+	if err != nil {
+		return "", fmt.Errorf("failed to evaluate expression: %w", err)
+	}
+	if isFalsey(result) {
+		return "false", fmt.Errorf("condition not met: %s", expr)
+	}
+	return "true", nil
+}
+
+// isFalsey reports whether a rendered condition should be treated as false.
+func isFalsey(result string) bool {
+	result = strings.TrimSpace(result)
+	return result == "" || result == "false"
+}
+
+// registerOutput stores a step's output under params["steps"][name] when
+// config.register is set, making it available to later step templates.
+// config.register_jsonpath narrows a JSON output to a single field.
+func registerOutput(params map[string]interface{}, step heliosv1alpha1.RunbookStep, output string) error {
+	name, _ := step.Config["register"].(string)
+	if name == "" {
+		return nil
+	}
+
+	var value interface{} = output
+	if path, _ := step.Config["register_jsonpath"].(string); path != "" {
+		var doc interface{}
+		if err := json.Unmarshal([]byte(output), &doc); err != nil {
+			return fmt.Errorf("output of step %q is not JSON: %w", step.Name, err)
+		}
+		extracted, err := lookupJSONPath(doc, path)
+		if err != nil {
+			return err
+		}
+		value = extracted
+	}
+
+	steps, _ := params["steps"].(map[string]interface{})
+	if steps == nil {
+		steps = make(map[string]interface{})
+		params["steps"] = steps
+	}
+	steps[name] = value
+	return nil
+}
+
+// lookupJSONPath resolves a simple dotted path such as "$.a.b[0].c" against
+// a decoded JSON document.
+func lookupJSONPath(doc interface{}, path string) (interface{}, error) {
+	normalized := strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	normalized = strings.NewReplacer("[", ".", "]", "").Replace(normalized)
+
+	cur := doc
+	for _, part := range strings.Split(normalized, ".") {
+		if part == "" {
+			continue
+		}
+		switch v := cur.(type) {
+		case map[string]interface{}:
+			next, ok := v[part]
+			if !ok {
+				return nil, fmt.Errorf("jsonpath %q: key %q not found", path, part)
+			}
+			cur = next
+		case []interface{}:
+			idx, err := strconv.Atoi(part)
+			if err != nil || idx < 0 || idx >= len(v) {
+				return nil, fmt.Errorf("jsonpath %q: invalid index %q", path, part)
+			}
+			cur = v[idx]
+		default:
+			return nil, fmt.Errorf("jsonpath %q: cannot descend into %q", path, part)
+		}
+	}
+	return cur, nil
+}
+
+// newGNMIClient builds a gNMI client for target using the credentials the
+// operator injects from the configured Secret, if any. Steps dial with TLS
+// verified against the system roots unless config.tls is false; setting
+// config.insecure allows plaintext transport for the step and turns TLS off
+// unless config.tls is also set. config.tls_server_name and
+// config.tls_pinned_cert (a SHA-256 fingerprint) verify the device
+// certificate without relying on its IP SANs.
+func (e *executor) newGNMIClient(target string, config map[string]interface{}) gnmiClient {
+	var opts []gnmiclient.ClientOption
+	if configBool(config, "insecure") {
+		opts = append(opts, gnmiclient.WithInsecure())
+	}
+	if stepUsesTLS(config) {
+		opts = append(opts, gnmiclient.WithTLS(&tls.Config{MinVersion: tls.VersionTLS12}))
+	}
+	if name, _ := config["tls_server_name"].(string); name != "" {
+		opts = append(opts, gnmiclient.WithServerName(name))
+	}
+	if fingerprint, _ := config["tls_pinned_cert"].(string); fingerprint != "" {
+		opts = append(opts, gnmiclient.WithPinnedCert(fingerprint))
+	}
+	return gnmiclient.NewClient(target, os.Getenv("GNMI_USERNAME"), os.Getenv("GNMI_PASSWORD"), e.log, opts...)
+}
+
+// stepUsesTLS reports whether a gNMI step dials with TLS. config.tls
+// decides when set; otherwise TLS is on unless config.insecure is set.
+func stepUsesTLS(config map[string]interface{}) bool {
+	if _, ok := config["tls"]; ok {
+		return configBool(config, "tls")
+	}
+	return !configBool(config, "insecure")
+}
+
+// configPath returns the step's gNMI path, qualified with config.origin
+// (e.g. "openconfig") when one is set.
+func configPath(config map[string]interface{}) string {
+	path, _ := config["path"].(string)
+	if origin, _ := config["origin"].(string); origin != "" {
+		path = origin + ":/" + strings.TrimPrefix(path, "/")
+	}
+	return path
+}
+
+// configBool reads a boolean step config value, accepting rendered
+// template strings such as "true" as well as native booleans.
+func configBool(config map[string]interface{}, key string) bool {
+	switch v := config[key].(type) {
+	case bool:
+		return v
+	case string:
+		b, _ := strconv.ParseBool(v)
+		return b
+	}
+	return false
+}
+
 func executeWait(ctx context.Context, step heliosv1alpha1.RunbookStep) (string, error) {
 	durationStr, _ := step.Config["duration"].(string)
 	if durationStr == "" {
@@ -251,3 +1228,10 @@ func executeWait(ctx context.Context, step heliosv1alpha1.RunbookStep) (string,
 		return fmt.Sprintf("waited %s", duration), nil
 	}
 }
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,1332 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+
+	heliosv1alpha1 "github.com/rhwendt/helios/services/runbook-operator/api/v1alpha1"
+	"github.com/rhwendt/helios/services/runbook-operator/pkg/audit"
+	gnmiclient "github.com/rhwendt/helios/services/runbook-operator/pkg/gnmic"
+	"github.com/rhwendt/helios/services/runbook-operator/pkg/template"
+)
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+}
+
+// --- Mock gNMI client ---
+
+type mockGNMIClient struct {
+	connects    int
+	closes      int
+	getOpts     []gnmiclient.GetOption
+	connectFunc func(ctx context.Context) error
+	getFunc     func(ctx context.Context, paths []string) (*gnmipb.GetResponse, error)
+	setFunc     func(ctx context.Context, requests []gnmiclient.SetRequest) (*gnmipb.SetResponse, error)
+	capFunc     func(ctx context.Context) (*gnmipb.CapabilityResponse, error)
+	subFunc     func(ctx context.Context, subs []gnmiclient.Subscription, mode gnmipb.SubscriptionList_Mode, handler gnmiclient.SubscribeHandler) error
+}
+
+func (m *mockGNMIClient) Connect(ctx context.Context) error {
+	m.connects++
+	if m.connectFunc != nil {
+		return m.connectFunc(ctx)
+	}
+	return nil
+}
+
+func (m *mockGNMIClient) Close() error { m.closes++; return nil }
+
+func (m *mockGNMIClient) Get(ctx context.Context, paths []string, opts ...gnmiclient.GetOption) (*gnmipb.GetResponse, error) {
+	m.getOpts = opts
+	if m.getFunc != nil {
+		return m.getFunc(ctx, paths)
+	}
+	return &gnmipb.GetResponse{}, nil
+}
+
+func (m *mockGNMIClient) Set(ctx context.Context, requests []gnmiclient.SetRequest) (*gnmipb.SetResponse, error) {
+	if m.setFunc != nil {
+		return m.setFunc(ctx, requests)
+	}
+	return &gnmipb.SetResponse{}, nil
+}
+
+func (m *mockGNMIClient) Capabilities(ctx context.Context) (*gnmipb.CapabilityResponse, error) {
+	if m.capFunc != nil {
+		return m.capFunc(ctx)
+	}
+	return &gnmipb.CapabilityResponse{}, nil
+}
+
+func (m *mockGNMIClient) SubscribeTo(ctx context.Context, subs []gnmiclient.Subscription, mode gnmipb.SubscriptionList_Mode, handler gnmiclient.SubscribeHandler) error {
+	if m.subFunc != nil {
+		return m.subFunc(ctx, subs, mode, handler)
+	}
+	return nil
+}
+
+func newTestExecutor(mock *mockGNMIClient) *executor {
+	e := newExecutor(testLogger(), template.NewEngine(), false, 30*time.Second)
+	e.newClient = func(string, map[string]interface{}) gnmiClient { return mock }
+	return e
+}
+
+// blockUntilDone simulates a hung device by waiting for the context to end.
+func blockUntilDone(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// --- Tests ---
+
+func TestExecuteStep_Timeout(t *testing.T) {
+	mock := &mockGNMIClient{
+		getFunc: func(ctx context.Context, paths []string) (*gnmipb.GetResponse, error) {
+			return nil, blockUntilDone(ctx)
+		},
+		setFunc: func(ctx context.Context, requests []gnmiclient.SetRequest) (*gnmipb.SetResponse, error) {
+			return nil, blockUntilDone(ctx)
+		},
+	}
+	e := newTestExecutor(mock)
+
+	for _, action := range []heliosv1alpha1.StepAction{heliosv1alpha1.ActionGNMIGet, heliosv1alpha1.ActionGNMISet} {
+		t.Run(string(action), func(t *testing.T) {
+			step := heliosv1alpha1.RunbookStep{
+				Name:    "hung-device",
+				Action:  action,
+				Timeout: "1s",
+				Config:  map[string]interface{}{"target": "10.0.0.1:6030", "path": "/interfaces"},
+			}
+
+			start := time.Now()
+			_, err := e.executeStep(context.Background(), step, nil)
+			if err == nil {
+				t.Fatal("expected timeout error, got nil")
+			}
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("error = %v, want context.DeadlineExceeded", err)
+			}
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("step took %v, want ~1s", elapsed)
+			}
+		})
+	}
+}
+
+func TestExecuteStep_CompletesWithinTimeout(t *testing.T) {
+	e := newTestExecutor(&mockGNMIClient{})
+	step := heliosv1alpha1.RunbookStep{
+		Name:    "healthy-device",
+		Action:  heliosv1alpha1.ActionGNMIGet,
+		Timeout: "1s",
+		Config:  map[string]interface{}{"target": "10.0.0.1:6030", "path": "/interfaces"},
+	}
+
+	if _, err := e.executeStep(context.Background(), step, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestExecuteStep_RetriesTransientErrors(t *testing.T) {
+	calls := 0
+	mock := &mockGNMIClient{
+		setFunc: func(ctx context.Context, requests []gnmiclient.SetRequest) (*gnmipb.SetResponse, error) {
+			calls++
+			if calls <= 2 {
+				return nil, errors.New("gNMI Set failed: rpc error: code = Unavailable")
+			}
+			return &gnmipb.SetResponse{}, nil
+		},
+	}
+	e := newTestExecutor(mock)
+	step := heliosv1alpha1.RunbookStep{
+		Name:   "flaky-device",
+		Action: heliosv1alpha1.ActionGNMISet,
+		Config: map[string]interface{}{
+			"target":         "10.0.0.1:6030",
+			"path":           "/interfaces/interface[name=Ethernet1]/config/enabled",
+			"value":          false,
+			"retries":        float64(3),
+			"retry_interval": "10ms",
+		},
+	}
+
+	output, err := e.executeStep(context.Background(), step, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("Set called %d times, want 3", calls)
+	}
+	if !strings.Contains(output, "gNMI Set completed") {
+		t.Errorf("output = %q", output)
+	}
+}
+
+func TestExecuteStep_RetryLimits(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		retries   interface{}
+		wantCalls int
+		wantErr   string
+	}{
+		{"gives up after retries", errors.New("connection reset"), "2", 3, "step failed after 3 attempts: connection reset"},
+		{"no retries by default", errors.New("connection reset"), nil, 1, "connection reset"},
+		{"invalid request is not retried", fmt.Errorf("%w: unknown operation: bogus", gnmiclient.ErrInvalidRequest), 3, 1, "unknown operation"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			mock := &mockGNMIClient{
+				getFunc: func(ctx context.Context, paths []string) (*gnmipb.GetResponse, error) {
+					calls++
+					return nil, tt.err
+				},
+			}
+			e := newTestExecutor(mock)
+			config := map[string]interface{}{"target": "10.0.0.1:6030", "path": "/interfaces", "retry_interval": "1ms"}
+			if tt.retries != nil {
+				config["retries"] = tt.retries
+			}
+
+			_, err := e.executeStep(context.Background(), heliosv1alpha1.RunbookStep{Name: "get", Action: heliosv1alpha1.ActionGNMIGet, Config: config}, nil)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("Get called %d times, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+
+	t.Run("template errors are not retried", func(t *testing.T) {
+		e := newTestExecutor(&mockGNMIClient{})
+		e.tmplEngine = template.NewEngine(template.WithStrict())
+		step := heliosv1alpha1.RunbookStep{Name: "get", Action: heliosv1alpha1.ActionGNMIGet, Config: map[string]interface{}{
+			"target": "{{ .missing }}", "path": "/interfaces", "retries": 3, "retry_interval": "1h",
+		}}
+		// A retry would wait an hour, so returning at all shows it was not retried.
+		_, err := e.executeStep(context.Background(), step, map[string]interface{}{})
+		var perr *permanentError
+		if !errors.As(err, &perr) {
+			t.Fatalf("error = %v, want a permanent render error", err)
+		}
+	})
+}
+
+func TestStepRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		config       map[string]interface{}
+		wantRetries  int
+		wantInterval time.Duration
+		wantErr      bool
+	}{
+		{"unset", nil, 0, defaultRetryInterval, false},
+		{"number", map[string]interface{}{"retries": float64(2), "retry_interval": "500ms"}, 2, 500 * time.Millisecond, false},
+		{"rendered string", map[string]interface{}{"retries": "4"}, 4, defaultRetryInterval, false},
+		{"fractional", map[string]interface{}{"retries": 1.5}, 0, 0, true},
+		{"negative", map[string]interface{}{"retries": -1}, 0, 0, true},
+		{"at limit", map[string]interface{}{"retries": maxStepRetries}, maxStepRetries, defaultRetryInterval, false},
+		{"over limit", map[string]interface{}{"retries": 40}, 0, 0, true},
+		{"invalid interval", map[string]interface{}{"retries": 1, "retry_interval": "soon"}, 0, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			retries, interval, err := stepRetries(heliosv1alpha1.RunbookStep{Config: tt.config})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if retries != tt.wantRetries || interval != tt.wantInterval {
+				t.Errorf("got (%d, %s), want (%d, %s)", retries, interval, tt.wantRetries, tt.wantInterval)
+			}
+		})
+	}
+}
+
+func TestStepUsesTLS(t *testing.T) {
+	tests := []struct {
+		name   string
+		config map[string]interface{}
+		want   bool
+	}{
+		{"default", map[string]interface{}{"target": "r1"}, true},
+		{"insecure", map[string]interface{}{"insecure": true}, false},
+		{"tls disabled", map[string]interface{}{"tls": false}, false},
+		{"tls with insecure", map[string]interface{}{"tls": true, "insecure": true}, true},
+		{"rendered string", map[string]interface{}{"tls": "false"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := stepUsesTLS(tt.config); got != tt.want {
+				t.Errorf("stepUsesTLS() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		interval time.Duration
+		attempt  int
+		want     time.Duration
+	}{
+		{time.Second, 0, time.Second},
+		{time.Second, 3, 8 * time.Second},
+		{time.Second, 9, maxRetryDelay},
+		{time.Second, 64, maxRetryDelay},
+		{time.Second, 1000, maxRetryDelay},
+		{time.Hour, 0, maxRetryDelay},
+	}
+	for _, tt := range tests {
+		if got := retryDelay(tt.interval, tt.attempt); got != tt.want {
+			t.Errorf("retryDelay(%s, %d) = %s, want %s", tt.interval, tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestStepTimeout(t *testing.T) {
+	e := newExecutor(testLogger(), template.NewEngine(), false, 45*time.Second)
+
+	tests := []struct {
+		name    string
+		timeout string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"empty uses default", "", 45 * time.Second, false},
+		{"explicit timeout", "5s", 5 * time.Second, false},
+		{"minutes", "2m", 2 * time.Minute, false},
+		{"invalid duration", "soon", 0, true},
+		{"zero duration", "0s", 0, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := e.stepTimeout(heliosv1alpha1.RunbookStep{Timeout: tc.timeout})
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("stepTimeout = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestExecuteNotify(t *testing.T) {
+	tests := []struct {
+		name       string
+		notifyType string
+		status     int
+		wantErr    bool
+		wantField  string
+	}{
+		{"slack message", "slack", http.StatusOK, false, "text"},
+		{"teams message", "teams", http.StatusOK, false, "text"},
+		{"generic webhook", "webhook", http.StatusOK, false, "message"},
+		{"webhook error status", "slack", http.StatusInternalServerError, true, ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var payload map[string]interface{}
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+					t.Errorf("failed to decode payload: %v", err)
+				}
+				w.WriteHeader(tc.status)
+			}))
+			defer srv.Close()
+
+			e := newTestExecutor(&mockGNMIClient{})
+			step := heliosv1alpha1.RunbookStep{
+				Name:   "notify-oncall",
+				Action: heliosv1alpha1.ActionNotify,
+				Config: map[string]interface{}{
+					"webhook_url": srv.URL,
+					"type":        tc.notifyType,
+					"message":     "Draining {{ .interface }} on {{ .device }}",
+				},
+			}
+			params := map[string]interface{}{"device": "spine1", "interface": "Ethernet1"}
+
+			_, err := e.executeStep(context.Background(), step, params)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := payload[tc.wantField]; got != "Draining Ethernet1 on spine1" {
+				t.Errorf("payload[%q] = %v, want rendered message", tc.wantField, got)
+			}
+		})
+	}
+}
+
+func TestExecuteNotify_MissingConfig(t *testing.T) {
+	e := newTestExecutor(&mockGNMIClient{})
+	step := heliosv1alpha1.RunbookStep{
+		Name:   "notify-oncall",
+		Action: heliosv1alpha1.ActionNotify,
+		Config: map[string]interface{}{"message": "hello"},
+	}
+
+	if _, err := e.executeStep(context.Background(), step, nil); err == nil {
+		t.Fatal("expected error for missing webhook_url")
+	}
+}
+
+func TestExecuteCondition(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		params     map[string]interface{}
+		wantOutput string
+		wantErr    bool
+	}{
+		{
+			name:       "true expression",
+			expression: `{{ eq .state "UP" }}`,
+			params:     map[string]interface{}{"state": "UP"},
+			wantOutput: "true",
+		},
+		{
+			name:       "false expression",
+			expression: `{{ eq .state "UP" }}`,
+			params:     map[string]interface{}{"state": "DOWN"},
+			wantOutput: "false",
+			wantErr:    true,
+		},
+		{
+			name:       "empty result is falsey",
+			expression: `{{ if .drained }}yes{{ end }}`,
+			params:     map[string]interface{}{"drained": false},
+			wantOutput: "false",
+			wantErr:    true,
+		},
+		{
+			name:       "template error",
+			expression: `{{ .state`,
+			wantErr:    true,
+		},
+		{
+			name:    "missing expression",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			e := newTestExecutor(&mockGNMIClient{})
+			step := heliosv1alpha1.RunbookStep{
+				Name:   "assert-state",
+				Action: heliosv1alpha1.ActionCondition,
+				Config: map[string]interface{}{},
+			}
+			if tc.expression != "" {
+				step.Config["expression"] = tc.expression
+			}
+
+			output, err := e.executeStep(context.Background(), step, tc.params)
+			if tc.wantErr && err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if !tc.wantErr && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if output != tc.wantOutput {
+				t.Errorf("output = %q, want %q", output, tc.wantOutput)
+			}
+		})
+	}
+}
+
+func TestRegisterOutput_FeedsNextStep(t *testing.T) {
+	var gotValue interface{}
+	mock := &mockGNMIClient{
+		capFunc: func(ctx context.Context) (*gnmipb.CapabilityResponse, error) {
+			return &gnmipb.CapabilityResponse{
+				SupportedModels: []*gnmipb.ModelData{{Name: "openconfig-interfaces"}},
+				GNMIVersion:     "0.7.0",
+			}, nil
+		},
+		setFunc: func(ctx context.Context, requests []gnmiclient.SetRequest) (*gnmipb.SetResponse, error) {
+			gotValue = requests[0].Value
+			return &gnmipb.SetResponse{}, nil
+		},
+	}
+	e := newTestExecutor(mock)
+	params := map[string]interface{}{"device": "spine1:6030"}
+
+	steps := []heliosv1alpha1.RunbookStep{
+		{
+			Name:   "read-version",
+			Action: heliosv1alpha1.ActionGNMICapabilities,
+			Config: map[string]interface{}{
+				"target":            "{{ .device }}",
+				"register":          "version",
+				"register_jsonpath": "$.gnmiVersion",
+			},
+		},
+		{
+			Name:   "write-description",
+			Action: heliosv1alpha1.ActionGNMISet,
+			Config: map[string]interface{}{
+				"target": "{{ .device }}",
+				"path":   "/system/config/motd-banner",
+				"value":  "gNMI {{ .steps.version }}",
+			},
+		},
+	}
+
+	for _, step := range steps {
+		output, err := e.executeStep(context.Background(), step, params)
+		if err != nil {
+			t.Fatalf("step %s: %v", step.Name, err)
+		}
+		if err := registerOutput(params, step, output); err != nil {
+			t.Fatalf("registerOutput %s: %v", step.Name, err)
+		}
+	}
+
+	if gotValue != "gNMI 0.7.0" {
+		t.Errorf("Set value = %v, want %q", gotValue, "gNMI 0.7.0")
+	}
+}
+
+func TestRegisterOutput(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  map[string]interface{}
+		output  string
+		want    interface{}
+		wantErr bool
+	}{
+		{
+			name:   "raw output",
+			config: map[string]interface{}{"register": "result"},
+			output: "waited 5s",
+			want:   "waited 5s",
+		},
+		{
+			name:   "jsonpath field",
+			config: map[string]interface{}{"register": "result", "register_jsonpath": "$.state.oper"},
+			output: `{"state":{"oper":"UP"}}`,
+			want:   "UP",
+		},
+		{
+			name:   "jsonpath array index",
+			config: map[string]interface{}{"register": "result", "register_jsonpath": "models[1]"},
+			output: `{"models":["openconfig-interfaces","openconfig-bgp"]}`,
+			want:   "openconfig-bgp",
+		},
+		{
+			name:    "jsonpath missing key",
+			config:  map[string]interface{}{"register": "result", "register_jsonpath": "$.missing"},
+			output:  `{"state":"UP"}`,
+			wantErr: true,
+		},
+		{
+			name:    "jsonpath on non-JSON output",
+			config:  map[string]interface{}{"register": "result", "register_jsonpath": "$.state"},
+			output:  "not json",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			params := map[string]interface{}{}
+			step := heliosv1alpha1.RunbookStep{Name: "step", Config: tc.config}
+
+			err := registerOutput(params, step, tc.output)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			steps, _ := params["steps"].(map[string]interface{})
+			if got := steps["result"]; got != tc.want {
+				t.Errorf("registered value = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRegisterOutput_NoRegister(t *testing.T) {
+	params := map[string]interface{}{}
+	step := heliosv1alpha1.RunbookStep{Name: "step", Config: map[string]interface{}{}}
+
+	if err := registerOutput(params, step, "output"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := params["steps"]; ok {
+		t.Error("params[steps] should not be set without config.register")
+	}
+}
+
+func TestSelectSteps_Rollback(t *testing.T) {
+	runbook := &heliosv1alpha1.Runbook{
+		Spec: heliosv1alpha1.RunbookSpec{
+			Steps: []heliosv1alpha1.RunbookStep{
+				{
+					Name:   "shutdown-interface",
+					Action: heliosv1alpha1.ActionGNMISet,
+					Config: map[string]interface{}{
+						"target": "spine1:6030",
+						"path":   "/interfaces/interface[name=Ethernet1]/config/enabled",
+						"value":  false,
+					},
+				},
+			},
+			Rollback: []heliosv1alpha1.RunbookStep{
+				{
+					Name:   "restore-interface",
+					Action: heliosv1alpha1.ActionGNMISet,
+					Config: map[string]interface{}{
+						"target": "spine1:6030",
+						"path":   "/interfaces/interface[name=Ethernet1]/config/enabled",
+						"value":  true,
+					},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		rollback bool
+		want     interface{}
+	}{
+		{name: "forward", rollback: false, want: false},
+		{name: "rollback", rollback: true, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var values []interface{}
+			mock := &mockGNMIClient{
+				setFunc: func(ctx context.Context, requests []gnmiclient.SetRequest) (*gnmipb.SetResponse, error) {
+					values = append(values, requests[0].Value)
+					return &gnmipb.SetResponse{}, nil
+				},
+			}
+			e := newTestExecutor(mock)
+
+			for _, step := range selectSteps(runbook, tt.rollback) {
+				if _, err := e.executeStep(context.Background(), step, map[string]interface{}{}); err != nil {
+					t.Fatalf("step %s: %v", step.Name, err)
+				}
+			}
+
+			if len(values) != 1 || values[0] != tt.want {
+				t.Errorf("Set values = %v, want [%v]", values, tt.want)
+			}
+		})
+	}
+}
+
+func stringUpdate(value string) *gnmipb.SubscribeResponse {
+	return &gnmipb.SubscribeResponse{
+		Response: &gnmipb.SubscribeResponse_Update{
+			Update: &gnmipb.Notification{
+				Update: []*gnmipb.Update{{
+					Path: &gnmipb.Path{Elem: []*gnmipb.PathElem{{Name: "session-state"}}},
+					Val:  &gnmipb.TypedValue{Value: &gnmipb.TypedValue_StringVal{StringVal: value}},
+				}},
+			},
+		},
+	}
+}
+
+func TestExecuteGNMISubscribe(t *testing.T) {
+	var gotSubs []gnmiclient.Subscription
+	mock := &mockGNMIClient{
+		subFunc: func(ctx context.Context, subs []gnmiclient.Subscription, mode gnmipb.SubscriptionList_Mode, handler gnmiclient.SubscribeHandler) error {
+			gotSubs = subs
+			for _, resp := range []*gnmipb.SubscribeResponse{
+				stringUpdate("ACTIVE"),
+				{Response: &gnmipb.SubscribeResponse_SyncResponse{SyncResponse: true}},
+				stringUpdate("ESTABLISHED"),
+			} {
+				if err := handler(resp); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+	e := newTestExecutor(mock)
+
+	step := heliosv1alpha1.RunbookStep{
+		Name:   "verify-bgp",
+		Action: heliosv1alpha1.ActionGNMISubscribe,
+		Config: map[string]interface{}{
+			"target":             "10.0.0.1:6030",
+			"path":               "/bgp/neighbors/neighbor/state/session-state",
+			"mode":               "sample",
+			"sample_interval":    "5s",
+			"heartbeat_interval": "1m",
+			"retryUntil":         "ESTABLISHED",
+		},
+	}
+
+	output, err := e.executeStep(context.Background(), step, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(gotSubs) != 1 {
+		t.Fatalf("subscriptions = %d, want 1", len(gotSubs))
+	}
+	want := gnmiclient.Subscription{
+		Path:              "/bgp/neighbors/neighbor/state/session-state",
+		Mode:              gnmipb.SubscriptionMode_SAMPLE,
+		SampleInterval:    5 * time.Second,
+		HeartbeatInterval: time.Minute,
+	}
+	if gotSubs[0] != want {
+		t.Errorf("subscription = %+v, want %+v", gotSubs[0], want)
+	}
+	if !strings.Contains(output, "ESTABLISHED") {
+		t.Errorf("output = %q, want the matching notification", output)
+	}
+}
+
+func TestExecuteGNMISubscribe_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		config map[string]interface{}
+	}{
+		{"unknown mode", map[string]interface{}{"mode": "poll"}},
+		{"invalid sample interval", map[string]interface{}{"mode": "sample", "sample_interval": "fast"}},
+		{"retryUntil never seen", map[string]interface{}{"retryUntil": "ESTABLISHED"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			e := newTestExecutor(&mockGNMIClient{})
+			config := map[string]interface{}{"target": "10.0.0.1:6030", "path": "/bgp"}
+			for k, v := range tc.config {
+				config[k] = v
+			}
+			step := heliosv1alpha1.RunbookStep{Name: "sub", Action: heliosv1alpha1.ActionGNMISubscribe, Config: config}
+			if _, err := e.executeStep(context.Background(), step, nil); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestExecuteGNMIGet_EncodingAndDataType(t *testing.T) {
+	tests := []struct {
+		name         string
+		config       map[string]interface{}
+		wantEncoding gnmipb.Encoding
+		wantType     gnmipb.GetRequest_DataType
+		wantErr      bool
+	}{
+		{
+			name:         "defaults",
+			wantEncoding: gnmipb.Encoding_JSON_IETF,
+			wantType:     gnmipb.GetRequest_ALL,
+		},
+		{
+			name:         "proto state",
+			config:       map[string]interface{}{"encoding": "proto", "data_type": "state"},
+			wantEncoding: gnmipb.Encoding_PROTO,
+			wantType:     gnmipb.GetRequest_STATE,
+		},
+		{
+			name:         "ascii config",
+			config:       map[string]interface{}{"encoding": "ASCII", "data_type": "config"},
+			wantEncoding: gnmipb.Encoding_ASCII,
+			wantType:     gnmipb.GetRequest_CONFIG,
+		},
+		{name: "unknown encoding", config: map[string]interface{}{"encoding": "xml"}, wantErr: true},
+		{name: "unknown data type", config: map[string]interface{}{"data_type": "running"}, wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			mock := &mockGNMIClient{}
+			e := newTestExecutor(mock)
+			config := map[string]interface{}{"target": "10.0.0.1:6030", "path": "/interfaces"}
+			for k, v := range tc.config {
+				config[k] = v
+			}
+			step := heliosv1alpha1.RunbookStep{Name: "get", Action: heliosv1alpha1.ActionGNMIGet, Config: config}
+
+			_, err := e.executeStep(context.Background(), step, nil)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			req := &gnmipb.GetRequest{}
+			for _, opt := range mock.getOpts {
+				opt(req)
+			}
+			if req.Encoding != tc.wantEncoding {
+				t.Errorf("encoding = %v, want %v", req.Encoding, tc.wantEncoding)
+			}
+			if req.Type != tc.wantType {
+				t.Errorf("type = %v, want %v", req.Type, tc.wantType)
+			}
+		})
+	}
+}
+
+func TestExecuteGNMIGet_DecodedOutput(t *testing.T) {
+	mock := &mockGNMIClient{
+		getFunc: func(ctx context.Context, paths []string) (*gnmipb.GetResponse, error) {
+			return &gnmipb.GetResponse{
+				Notification: []*gnmipb.Notification{{
+					Update: []*gnmipb.Update{{
+						Path: &gnmipb.Path{Elem: []*gnmipb.PathElem{{Name: "interfaces"}, {Name: "interface", Key: map[string]string{"name": "Ethernet1"}}, {Name: "state"}}},
+						Val:  &gnmipb.TypedValue{Value: &gnmipb.TypedValue_JsonIetfVal{JsonIetfVal: []byte(`{"oper-status":"UP"}`)}},
+					}},
+				}},
+			}, nil
+		},
+	}
+	e := newTestExecutor(mock)
+	step := heliosv1alpha1.RunbookStep{
+		Name:   "get-state",
+		Action: heliosv1alpha1.ActionGNMIGet,
+		Config: map[string]interface{}{"target": "10.0.0.1:6030", "path": "/interfaces/interface[name=Ethernet1]/state"},
+	}
+
+	output, err := e.executeStep(context.Background(), step, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `{"/interfaces/interface[name=Ethernet1]/state":{"oper-status":"UP"}}`
+	if output != want {
+		t.Errorf("output = %s, want %s", output, want)
+	}
+}
+
+func TestExecutor_ReusesConnectionPerTarget(t *testing.T) {
+	e := newExecutor(testLogger(), template.NewEngine(), false, 30*time.Second)
+	clients := make(map[string]*mockGNMIClient)
+	e.newClient = func(target string, _ map[string]interface{}) gnmiClient {
+		m := &mockGNMIClient{}
+		clients[target] = m
+		return m
+	}
+
+	steps := []heliosv1alpha1.RunbookStep{
+		{Name: "get", Action: heliosv1alpha1.ActionGNMIGet, Config: map[string]interface{}{"target": "spine1:6030", "path": "/interfaces"}},
+		{Name: "set", Action: heliosv1alpha1.ActionGNMISet, Config: map[string]interface{}{"target": "spine1:6030", "path": "/system/config/hostname", "value": "spine1"}},
+		{Name: "other", Action: heliosv1alpha1.ActionGNMIGet, Config: map[string]interface{}{"target": "leaf1:6030", "path": "/interfaces"}},
+	}
+	for _, step := range steps {
+		if _, err := e.executeStep(context.Background(), step, nil); err != nil {
+			t.Fatalf("step %s: %v", step.Name, err)
+		}
+	}
+
+	if len(clients) != 2 {
+		t.Fatalf("created %d clients, want 2", len(clients))
+	}
+	if got := clients["spine1:6030"].connects; got != 1 {
+		t.Errorf("spine1 connects = %d, want 1", got)
+	}
+	for target, m := range clients {
+		if m.closes != 0 {
+			t.Errorf("%s closed before the execution finished", target)
+		}
+	}
+
+	e.closeClients()
+	for target, m := range clients {
+		if m.closes != 1 {
+			t.Errorf("%s closes = %d, want 1", target, m.closes)
+		}
+	}
+}
+
+func TestExecutor_ConcurrentConnect(t *testing.T) {
+	e := newExecutor(testLogger(), template.NewEngine(), false, 30*time.Second)
+	var created atomic.Int32
+	e.newClient = func(string, map[string]interface{}) gnmiClient {
+		created.Add(1)
+		return &mockGNMIClient{}
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := e.connect(context.Background(), "spine1:6030", nil); err != nil {
+				t.Errorf("connect: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := created.Load(); got != 1 {
+		t.Errorf("created %d clients, want 1", got)
+	}
+}
+
+// slowConnect returns a Connect func that blocks until n dials are in
+// progress at once, so callers only connect if they dial concurrently.
+func slowConnect(n int32) func(ctx context.Context) error {
+	var dialling atomic.Int32
+	allDialling := make(chan struct{})
+	return func(ctx context.Context) error {
+		if dialling.Add(1) == n {
+			close(allDialling)
+		}
+		select {
+		case <-allDialling:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func TestExecutor_ConnectsTargetsConcurrently(t *testing.T) {
+	e := newExecutor(testLogger(), template.NewEngine(), false, 30*time.Second)
+	connect := slowConnect(2)
+	e.newClient = func(string, map[string]interface{}) gnmiClient {
+		return &mockGNMIClient{connectFunc: connect}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var wg sync.WaitGroup
+	for _, target := range []string{"spine1:6030", "leaf1:6030"} {
+		wg.Add(1)
+		go func(target string) {
+			defer wg.Done()
+			if _, err := e.connect(ctx, target, nil); err != nil {
+				t.Errorf("connect %s: %v", target, err)
+			}
+		}(target)
+	}
+	wg.Wait()
+}
+
+func TestExecutor_RetriesFailedConnect(t *testing.T) {
+	e := newExecutor(testLogger(), template.NewEngine(), false, 30*time.Second)
+	var created int
+	e.newClient = func(string, map[string]interface{}) gnmiClient {
+		created++
+		if created == 1 {
+			return &mockGNMIClient{connectFunc: func(context.Context) error { return errors.New("connection refused") }}
+		}
+		return &mockGNMIClient{}
+	}
+
+	if _, err := e.connect(context.Background(), "spine1:6030", nil); err == nil {
+		t.Fatal("first connect succeeded, want error")
+	}
+	if _, err := e.connect(context.Background(), "spine1:6030", nil); err != nil {
+		t.Fatalf("second connect: %v", err)
+	}
+	if created != 2 {
+		t.Errorf("created %d clients, want 2", created)
+	}
+}
+
+func TestExecuteGNMIWait(t *testing.T) {
+	statuses := []string{"DOWN", "DOWN", "UP"}
+	calls := 0
+	mock := &mockGNMIClient{
+		getFunc: func(ctx context.Context, paths []string) (*gnmipb.GetResponse, error) {
+			status := statuses[min(calls, len(statuses)-1)]
+			calls++
+			return &gnmipb.GetResponse{
+				Notification: []*gnmipb.Notification{{
+					Update: []*gnmipb.Update{{
+						Path: &gnmipb.Path{Elem: []*gnmipb.PathElem{{Name: "oper-status"}}},
+						Val:  &gnmipb.TypedValue{Value: &gnmipb.TypedValue_JsonIetfVal{JsonIetfVal: []byte(`"` + status + `"`)}},
+					}},
+				}},
+			}, nil
+		},
+	}
+	e := newTestExecutor(mock)
+	step := heliosv1alpha1.RunbookStep{
+		Name:    "wait-for-up",
+		Action:  heliosv1alpha1.ActionGNMIWait,
+		Timeout: "5s",
+		Config: map[string]interface{}{
+			"target":    "10.0.0.1:6030",
+			"path":      "/interfaces/interface[name={{ .interface }}]/state/oper-status",
+			"interval":  "10ms",
+			"condition": `{{ eq .value .want }}`,
+		},
+	}
+	params := map[string]interface{}{"interface": "Ethernet1", "want": "UP"}
+
+	output, err := e.executeStep(context.Background(), step, params)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if output != `"UP"` {
+		t.Errorf("output = %s, want \"UP\"", output)
+	}
+	if calls != 3 {
+		t.Errorf("Get called %d times, want 3", calls)
+	}
+	if _, ok := params["value"]; ok {
+		t.Error("gnmi_wait should not leak .value into the runbook parameters")
+	}
+}
+
+func TestExecuteGNMIWait_Timeout(t *testing.T) {
+	mock := &mockGNMIClient{
+		getFunc: func(ctx context.Context, paths []string) (*gnmipb.GetResponse, error) {
+			return &gnmipb.GetResponse{
+				Notification: []*gnmipb.Notification{{
+					Update: []*gnmipb.Update{{
+						Path: &gnmipb.Path{Elem: []*gnmipb.PathElem{{Name: "oper-status"}}},
+						Val:  &gnmipb.TypedValue{Value: &gnmipb.TypedValue_StringVal{StringVal: "DOWN"}},
+					}},
+				}},
+			}, nil
+		},
+	}
+	e := newTestExecutor(mock)
+	step := heliosv1alpha1.RunbookStep{
+		Name:    "wait-for-up",
+		Action:  heliosv1alpha1.ActionGNMIWait,
+		Timeout: "100ms",
+		Config: map[string]interface{}{
+			"target":    "10.0.0.1:6030",
+			"path":      "/interfaces/interface/state/oper-status",
+			"interval":  "10ms",
+			"condition": `{{ eq .value "UP" }}`,
+		},
+	}
+
+	_, err := e.executeStep(context.Background(), step, nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestExecuteGNMIWait_MissingCondition(t *testing.T) {
+	e := newTestExecutor(&mockGNMIClient{})
+	step := heliosv1alpha1.RunbookStep{
+		Name:   "wait",
+		Action: heliosv1alpha1.ActionGNMIWait,
+		Config: map[string]interface{}{"target": "10.0.0.1:6030", "path": "/interfaces"},
+	}
+	if _, err := e.executeStep(context.Background(), step, nil); err == nil {
+		t.Fatal("expected error for missing condition")
+	}
+}
+
+func testStepAudit() stepAudit {
+	return stepAudit{logger: audit.NewLogger(testLogger()), execution: "exec-1", namespace: "default", runbook: "test"}
+}
+
+func pendingStatuses(steps []heliosv1alpha1.RunbookStep) []heliosv1alpha1.ExecutionStepStatus {
+	statuses := make([]heliosv1alpha1.ExecutionStepStatus, len(steps))
+	for i, step := range steps {
+		statuses[i] = heliosv1alpha1.ExecutionStepStatus{Name: step.Name, Status: heliosv1alpha1.StepPending}
+	}
+	return statuses
+}
+
+func TestStepGroups(t *testing.T) {
+	step := func(group string) heliosv1alpha1.RunbookStep {
+		if group == "" {
+			return heliosv1alpha1.RunbookStep{}
+		}
+		return heliosv1alpha1.RunbookStep{Config: map[string]interface{}{"parallel_group": group}}
+	}
+	steps := []heliosv1alpha1.RunbookStep{step(""), step("a"), step("a"), step(""), step("a"), step("b"), step("b")}
+
+	got := stepGroups(steps)
+	want := [][]int{{0}, {1, 2}, {3}, {4}, {5, 6}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("stepGroups() = %v, want %v", got, want)
+	}
+}
+
+func TestRunSteps_ParallelGroup(t *testing.T) {
+	// Each Set waits until both have started, so the steps only complete if
+	// they run concurrently.
+	var started atomic.Int32
+	bothStarted := make(chan struct{})
+	mock := &mockGNMIClient{
+		setFunc: func(ctx context.Context, requests []gnmiclient.SetRequest) (*gnmipb.SetResponse, error) {
+			if started.Add(1) == 2 {
+				close(bothStarted)
+			}
+			select {
+			case <-bothStarted:
+				return &gnmipb.SetResponse{}, nil
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		},
+	}
+	e := newTestExecutor(mock)
+	e.maxParallel = 2
+
+	bounce := func(name, iface string) heliosv1alpha1.RunbookStep {
+		return heliosv1alpha1.RunbookStep{
+			Name:    name,
+			Action:  heliosv1alpha1.ActionGNMISet,
+			Timeout: "5s",
+			Config: map[string]interface{}{
+				"target":         "10.0.0.1:6030",
+				"path":           "/interfaces/interface[name=" + iface + "]/config/enabled",
+				"value":          false,
+				"parallel_group": "bounce",
+				"register":       strings.ReplaceAll(name, "-", "_"),
+			},
+		}
+	}
+	steps := []heliosv1alpha1.RunbookStep{
+		bounce("disable-eth1", "Ethernet1"),
+		bounce("disable-eth2", "Ethernet2"),
+		{Name: "check", Action: heliosv1alpha1.ActionCondition, Config: map[string]interface{}{
+			"expression": "{{ and .steps.disable_eth1 .steps.disable_eth2 }}",
+		}},
+	}
+	statuses := pendingStatuses(steps)
+	params := map[string]interface{}{}
+
+	progress := 0
+	if !e.runSteps(context.Background(), steps, statuses, params, testStepAudit(), func() { progress++ }) {
+		t.Fatalf("runSteps() failed: %+v", statuses)
+	}
+	for _, st := range statuses {
+		if st.Status != heliosv1alpha1.StepCompleted {
+			t.Errorf("step %s: status = %s (%s), want Completed", st.Name, st.Status, st.Error)
+		}
+		if st.StartTime == nil || st.CompletionTime == nil {
+			t.Errorf("step %s: start or completion time not recorded", st.Name)
+		}
+	}
+	if progress != 2 {
+		t.Errorf("progress called %d times, want once per group (2)", progress)
+	}
+}
+
+func TestRunSteps_ParallelGroupConnectsConcurrently(t *testing.T) {
+	// Each device only accepts the connection once both are being dialled,
+	// so the group only completes if its steps connect in parallel.
+	e := newExecutor(testLogger(), template.NewEngine(), false, 30*time.Second)
+	e.maxParallel = 2
+	connect := slowConnect(2)
+	e.newClient = func(string, map[string]interface{}) gnmiClient {
+		return &mockGNMIClient{connectFunc: connect}
+	}
+
+	get := func(name, target string) heliosv1alpha1.RunbookStep {
+		return heliosv1alpha1.RunbookStep{
+			Name:    name,
+			Action:  heliosv1alpha1.ActionGNMIGet,
+			Timeout: "5s",
+			Config: map[string]interface{}{
+				"target":         target,
+				"path":           "/interfaces",
+				"parallel_group": "collect",
+			},
+		}
+	}
+	steps := []heliosv1alpha1.RunbookStep{
+		get("get-spine1", "spine1:6030"),
+		get("get-leaf1", "leaf1:6030"),
+	}
+	statuses := pendingStatuses(steps)
+
+	if !e.runSteps(context.Background(), steps, statuses, map[string]interface{}{}, testStepAudit(), func() {}) {
+		t.Fatalf("runSteps() failed: %+v", statuses)
+	}
+	for _, st := range statuses {
+		if st.Status != heliosv1alpha1.StepCompleted {
+			t.Errorf("step %s: status = %s (%s), want Completed", st.Name, st.Status, st.Error)
+		}
+	}
+}
+
+func TestRunSteps_ParallelGroupFailure(t *testing.T) {
+	mock := &mockGNMIClient{
+		setFunc: func(ctx context.Context, requests []gnmiclient.SetRequest) (*gnmipb.SetResponse, error) {
+			if strings.Contains(requests[0].Path, "Ethernet1") {
+				return nil, errors.New("device rejected the update")
+			}
+			return &gnmipb.SetResponse{}, nil
+		},
+	}
+	step := func(name, iface string, continueOnError bool) heliosv1alpha1.RunbookStep {
+		return heliosv1alpha1.RunbookStep{
+			Name:            name,
+			Action:          heliosv1alpha1.ActionGNMISet,
+			ContinueOnError: continueOnError,
+			Config: map[string]interface{}{
+				"target":         "10.0.0.1:6030",
+				"path":           "/interfaces/interface[name=" + iface + "]/config/enabled",
+				"value":          false,
+				"parallel_group": "bounce",
+			},
+		}
+	}
+
+	t.Run("ContinueOnError keeps going", func(t *testing.T) {
+		e := newTestExecutor(mock)
+		e.maxParallel = 1
+		steps := []heliosv1alpha1.RunbookStep{step("eth1", "Ethernet1", true), step("eth2", "Ethernet2", false)}
+		statuses := pendingStatuses(steps)
+		if !e.runSteps(context.Background(), steps, statuses, map[string]interface{}{}, testStepAudit(), func() {}) {
+			t.Fatal("runSteps() = false, want true when the failed step continues on error")
+		}
+		if statuses[0].Status != heliosv1alpha1.StepFailed || statuses[1].Status != heliosv1alpha1.StepCompleted {
+			t.Errorf("statuses = %s, %s, want Failed, Completed", statuses[0].Status, statuses[1].Status)
+		}
+	})
+
+	t.Run("failure stops unstarted steps", func(t *testing.T) {
+		e := newTestExecutor(mock)
+		e.maxParallel = 1
+		steps := []heliosv1alpha1.RunbookStep{
+			step("eth1", "Ethernet1", false),
+			step("eth2", "Ethernet2", false),
+			{Name: "after", Action: heliosv1alpha1.ActionCondition, Config: map[string]interface{}{"expression": "true"}},
+		}
+		statuses := pendingStatuses(steps)
+		if e.runSteps(context.Background(), steps, statuses, map[string]interface{}{}, testStepAudit(), func() {}) {
+			t.Fatal("runSteps() = true, want false")
+		}
+		want := []heliosv1alpha1.StepStatus{heliosv1alpha1.StepFailed, heliosv1alpha1.StepPending, heliosv1alpha1.StepPending}
+		for i, st := range statuses {
+			if st.Status != want[i] {
+				t.Errorf("step %s: status = %s, want %s", st.Name, st.Status, want[i])
+			}
+		}
+	})
+}
+
+type recordingOutputSink struct {
+	mu      sync.Mutex
+	outputs map[string]string
+}
+
+func (s *recordingOutputSink) Store(ctx context.Context, step, output string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.outputs == nil {
+		s.outputs = make(map[string]string)
+	}
+	s.outputs[step] = output
+	return "exec-1-output-" + step, nil
+}
+
+func TestRunSteps_TruncatesLargeOutput(t *testing.T) {
+	large := `"` + strings.Repeat("x", 200) + `"`
+	mock := &mockGNMIClient{
+		getFunc: func(ctx context.Context, paths []string) (*gnmipb.GetResponse, error) {
+			return &gnmipb.GetResponse{
+				Notification: []*gnmipb.Notification{{
+					Update: []*gnmipb.Update{{
+						Path: &gnmipb.Path{Elem: []*gnmipb.PathElem{{Name: "system"}}},
+						Val:  &gnmipb.TypedValue{Value: &gnmipb.TypedValue_JsonIetfVal{JsonIetfVal: []byte(large)}},
+					}},
+				}},
+			}, nil
+		},
+	}
+	sink := &recordingOutputSink{}
+	e := newTestExecutor(mock)
+	e.maxOutputBytes = 64
+	e.outputSink = sink
+
+	steps := []heliosv1alpha1.RunbookStep{
+		{Name: "small", Action: heliosv1alpha1.ActionCondition, Config: map[string]interface{}{"expression": "true"}},
+		{Name: "get-system", Action: heliosv1alpha1.ActionGNMIGet, Config: map[string]interface{}{
+			"target": "10.0.0.1:6030", "path": "/system", "register": "system",
+		}},
+	}
+	statuses := pendingStatuses(steps)
+	params := map[string]interface{}{}
+	if !e.runSteps(context.Background(), steps, statuses, params, testStepAudit(), func() {}) {
+		t.Fatalf("runSteps() failed: %+v", statuses)
+	}
+
+	if statuses[0].Output != "true" || statuses[0].OutputTruncated || statuses[0].OutputRef != "" {
+		t.Errorf("small output status = %+v, want it kept whole", statuses[0])
+	}
+
+	got := statuses[1]
+	full := sink.outputs["get-system"]
+	if len(full) <= 64 {
+		t.Fatalf("sink output = %q, want the full output", full)
+	}
+	if !got.OutputTruncated || len(got.Output) != 64 || !strings.HasPrefix(full, got.Output) {
+		t.Errorf("status output = %q (truncated %v), want the first 64 bytes of %q", got.Output, got.OutputTruncated, full)
+	}
+	if got.OutputRef != "exec-1-output-get-system" {
+		t.Errorf("outputRef = %q", got.OutputRef)
+	}
+
+	// Later steps still see the full output.
+	if registered := params["steps"].(map[string]interface{})["system"]; registered != full {
+		t.Errorf("registered output = %v, want the full output", registered)
+	}
+}
+
+func TestTruncateUTF8(t *testing.T) {
+	tests := []struct {
+		in   string
+		n    int
+		want string
+	}{
+		{"short", 10, "short"},
+		{"abcdef", 3, "abc"},
+		{"aé", 2, "a"},
+		{"aéb", 3, "aé"},
+	}
+	for _, tt := range tests {
+		if got := truncateUTF8(tt.in, tt.n); got != tt.want {
+			t.Errorf("truncateUTF8(%q, %d) = %q, want %q", tt.in, tt.n, got, tt.want)
+		}
+	}
+}
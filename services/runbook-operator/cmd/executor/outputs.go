@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	heliosv1alpha1 "github.com/rhwendt/helios/services/runbook-operator/api/v1alpha1"
+)
+
+// outputKey is the ConfigMap key holding a stored step output.
+const outputKey = "output"
+
+// configMapOutputSink stores full step outputs in ConfigMaps owned by the
+// execution, so they are deleted along with it. ConfigMaps are limited to
+// 1MiB, so larger outputs fail to store and remain truncated.
+type configMapOutputSink struct {
+	client    client.Client
+	execution *heliosv1alpha1.RunbookExecution
+}
+
+// Store writes output to the ConfigMap for step, replacing any copy left by
+// an earlier attempt, and returns the ConfigMap name.
+func (s *configMapOutputSink) Store(ctx context.Context, step, output string) (string, error) {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      outputConfigMapName(s.execution.Name, step),
+			Namespace: s.execution.Namespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/name":     "runbook-executor",
+				"app.kubernetes.io/instance": s.execution.Name,
+			},
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: heliosv1alpha1.GroupVersion.String(),
+				Kind:       "RunbookExecution",
+				Name:       s.execution.Name,
+				UID:        s.execution.UID,
+			}},
+		},
+		Data: map[string]string{outputKey: output},
+	}
+
+	err := s.client.Create(ctx, cm)
+	if apierrors.IsAlreadyExists(err) {
+		err = s.client.Update(ctx, cm)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to store output of step %s in ConfigMap %s: %w", step, cm.Name, err)
+	}
+	return cm.Name, nil
+}
+
+// outputConfigMapName derives a valid object name from the execution and
+// step status name, e.g. "bounce-x7k2p-output-rollback-enable".
+func outputConfigMapName(execution, step string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + ('a' - 'A')
+		}
+		return '-'
+	}, step)
+	return execution + "-output-" + strings.Trim(name, "-")
+}
@@ -3,16 +3,24 @@ package main
 import (
 	"log/slog"
 	"os"
+	"strings"
+	"time"
 
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 
 	heliosv1alpha1 "github.com/rhwendt/helios/services/runbook-operator/api/v1alpha1"
 	"github.com/rhwendt/helios/services/runbook-operator/controllers"
+	"github.com/rhwendt/helios/services/runbook-operator/pkg/approval"
+	"github.com/rhwendt/helios/services/runbook-operator/pkg/audit"
+	"github.com/rhwendt/helios/services/runbook-operator/pkg/httpapi"
+	"github.com/rhwendt/helios/services/runbook-operator/pkg/metrics"
 )
 
 var scheme = runtime.NewScheme()
@@ -20,6 +28,7 @@ var scheme = runtime.NewScheme()
 func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 	utilruntime.Must(heliosv1alpha1.AddToScheme(scheme))
+	metrics.Register(ctrlmetrics.Registry)
 }
 
 func main() {
@@ -29,7 +38,19 @@ func main() {
 	metricsAddr := getEnv("METRICS_ADDR", ":8080")
 	probeAddr := getEnv("HEALTH_PROBE_ADDR", ":8081")
 	executorImage := getEnv("EXECUTOR_IMAGE", "ghcr.io/rhwendt/helios/runbook-executor:latest")
+	gnmiCredentialsSecret := os.Getenv("GNMI_CREDENTIALS_SECRET")
+	approvalWebhookURL := os.Getenv("APPROVAL_WEBHOOK_URL")
+	approvalNotifyType := getEnv("APPROVAL_NOTIFY_TYPE", string(approval.NotifyWebhook))
+	approvalSigningSecret := os.Getenv("APPROVAL_SIGNING_SECRET")
 	enableLeaderElection := os.Getenv("ENABLE_LEADER_ELECTION") == "true"
+	enableScriptAction := os.Getenv("ENABLE_SCRIPT_ACTION") == "true"
+	enableWebhooks := os.Getenv("ENABLE_WEBHOOKS") == "true"
+
+	jobTTL, err := time.ParseDuration(getEnv("EXECUTOR_JOB_TTL", controllers.DefaultJobTTL.String()))
+	if err != nil {
+		log.Error("invalid EXECUTOR_JOB_TTL", "error", err)
+		os.Exit(1)
+	}
 
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                 scheme,
@@ -44,24 +65,118 @@ func main() {
 	}
 
 	if err := (&controllers.RunbookReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
-		Log:    log.With("controller", "runbook"),
+		Client:             mgr.GetClient(),
+		Scheme:             mgr.GetScheme(),
+		Log:                log.With("controller", "runbook"),
+		EnableScriptAction: enableScriptAction,
 	}).SetupWithManager(mgr); err != nil {
 		log.Error("unable to create runbook controller", "error", err)
 		os.Exit(1)
 	}
 
+	// The admission webhooks need a serving certificate, so they are only
+	// registered when the deployment provides one. Without the execution
+	// webhook the identity annotations on executions cannot be trusted, so
+	// no resolvers are configured: runbooks with AllowedRoles cannot be
+	// triggered and group approvers cannot approve.
+	var (
+		roleResolver  controllers.RoleResolver
+		groupResolver controllers.GroupResolver
+	)
+	if enableWebhooks {
+		if err := (&controllers.RunbookValidator{EnableScriptAction: enableScriptAction}).SetupWebhookWithManager(mgr); err != nil {
+			log.Error("unable to create runbook webhook", "error", err)
+			os.Exit(1)
+		}
+
+		podNamespace, serviceAccount := os.Getenv("POD_NAMESPACE"), os.Getenv("SERVICE_ACCOUNT_NAME")
+		if podNamespace == "" || serviceAccount == "" {
+			log.Error("POD_NAMESPACE and SERVICE_ACCOUNT_NAME are required when ENABLE_WEBHOOKS is set")
+			os.Exit(1)
+		}
+		defaulter := &controllers.RunbookExecutionDefaulter{
+			TrustedUsers: []string{"system:serviceaccount:" + podNamespace + ":" + serviceAccount},
+		}
+		if err := defaulter.SetupWebhookWithManager(mgr); err != nil {
+			log.Error("unable to create runbookexecution webhook", "error", err)
+			os.Exit(1)
+		}
+		roleResolver = controllers.AnnotationRoleResolver{}
+		groupResolver = controllers.AnnotationGroupResolver{}
+	}
+
+	auditSinks, err := audit.NewSinksFromEnv()
+	if err != nil {
+		log.Error("failed to configure audit sinks", "error", err)
+		os.Exit(1)
+	}
+	auditLogger := audit.NewLogger(log, auditSinks...)
+
+	// Executor Jobs record step events, so they share the operator's Kafka
+	// audit sink. File sinks are local to the operator pod.
+	var executorEnv []corev1.EnvVar
+	if strings.Contains(os.Getenv("AUDIT_SINKS"), "kafka") {
+		executorEnv = append(executorEnv,
+			corev1.EnvVar{Name: "AUDIT_SINKS", Value: "kafka"},
+			corev1.EnvVar{Name: "AUDIT_KAFKA_BROKERS", Value: os.Getenv("AUDIT_KAFKA_BROKERS")},
+			corev1.EnvVar{Name: "AUDIT_KAFKA_TOPIC", Value: os.Getenv("AUDIT_KAFKA_TOPIC")},
+		)
+	}
+
+	// Executor Jobs push their gNMI metrics to a Pushgateway, if configured.
+	if pushURL := os.Getenv("METRICS_PUSHGATEWAY_URL"); pushURL != "" {
+		executorEnv = append(executorEnv, corev1.EnvVar{Name: "METRICS_PUSHGATEWAY_URL", Value: pushURL})
+	}
+
+	// Executor tuning is passed through to the Jobs when set.
+	for _, name := range []string{"MAX_PARALLEL_STEPS", "MAX_STEP_OUTPUT_BYTES", "OFFLOAD_STEP_OUTPUTS"} {
+		if value := os.Getenv(name); value != "" {
+			executorEnv = append(executorEnv, corev1.EnvVar{Name: name, Value: value})
+		}
+	}
+
+	var approver *approval.Approver
+	if approvalWebhookURL != "" {
+		var opts []approval.ApproverOption
+		if approvalSigningSecret != "" {
+			opts = append(opts, approval.WithSigningSecret(approvalSigningSecret))
+		}
+		approver = approval.NewApprover(approvalWebhookURL, approval.NotificationType(approvalNotifyType), log.With("component", "approval"), opts...)
+	}
+
 	if err := (&controllers.RunbookExecutionReconciler{
-		Client:        mgr.GetClient(),
-		Scheme:        mgr.GetScheme(),
-		Log:           log.With("controller", "runbookexecution"),
-		ExecutorImage: executorImage,
+		Client:                mgr.GetClient(),
+		Scheme:                mgr.GetScheme(),
+		Log:                   log.With("controller", "runbookexecution"),
+		ExecutorImage:         executorImage,
+		GNMICredentialsSecret: gnmiCredentialsSecret,
+		RoleResolver:          roleResolver,
+		GroupResolver:         groupResolver,
+		Approver:              approver,
+		Audit:                 auditLogger,
+		ExecutorEnv:           executorEnv,
+		JobTTL:                jobTTL,
 	}).SetupWithManager(mgr); err != nil {
 		log.Error("unable to create runbookexecution controller", "error", err)
 		os.Exit(1)
 	}
 
+	// The execution API is optional; it is served alongside the controllers
+	// when API_ADDR is set.
+	if apiAddr := os.Getenv("API_ADDR"); apiAddr != "" {
+		auth, err := httpapi.LoadTokenFile(os.Getenv("API_TOKEN_FILE"))
+		if err != nil {
+			log.Error("failed to load API tokens", "error", err)
+			os.Exit(1)
+		}
+		apiServer := httpapi.NewServer(apiAddr, mgr.GetClient(), auth,
+			getEnv("API_NAMESPACE", "helios-automation"), log.With("component", "api"))
+		if err := mgr.Add(apiServer); err != nil {
+			log.Error("unable to add execution API", "error", err)
+			os.Exit(1)
+		}
+	}
+
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		log.Error("unable to set up health check", "error", err)
 		os.Exit(1)
@@ -72,7 +187,13 @@ func main() {
 	}
 
 	log.Info("starting runbook operator")
-	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
+	err = mgr.Start(ctrl.SetupSignalHandler())
+	// Close the sinks explicitly so buffered audit events are flushed; a
+	// deferred Close would be skipped by os.Exit.
+	if closeErr := auditLogger.Close(); closeErr != nil {
+		log.Error("failed to close audit sinks", "error", closeErr)
+	}
+	if err != nil {
 		log.Error("operator exited with error", "error", err)
 		os.Exit(1)
 	}
@@ -0,0 +1,151 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	heliosv1alpha1 "github.com/rhwendt/helios/services/runbook-operator/api/v1alpha1"
+	"github.com/rhwendt/helios/services/runbook-operator/pkg/approval"
+	"github.com/rhwendt/helios/services/runbook-operator/pkg/audit"
+)
+
+// ConditionApprovalNotified is set once approvers have been notified about
+// an execution, so that requeues do not notify them again.
+const ConditionApprovalNotified = "ApprovalNotified"
+
+// notifyApprovers sends the approval request for exec and records the
+// ApprovalNotified condition on its status. The caller persists the status.
+// It is a no-op if no Approver is configured or approvers were already
+// notified.
+func (r *RunbookExecutionReconciler) notifyApprovers(ctx context.Context, log *slog.Logger, exec *heliosv1alpha1.RunbookExecution, runbook *heliosv1alpha1.Runbook) error {
+	if r.Approver == nil || meta.IsStatusConditionTrue(exec.Status.Conditions, ConditionApprovalNotified) {
+		return nil
+	}
+
+	approvers := make([]string, 0, len(runbook.Spec.Approvers))
+	for _, a := range runbook.Spec.Approvers {
+		approvers = append(approvers, fmt.Sprintf("%s:%s", a.Type, a.Name))
+	}
+	req := approval.ApprovalRequest{
+		ExecutionName: exec.Name,
+		Namespace:     exec.Namespace,
+		RunbookName:   runbook.Name,
+		TriggeredBy:   exec.Spec.TriggeredBy,
+		RiskLevel:     string(runbook.Spec.RiskLevel),
+		Approvers:     approvers,
+	}
+	if err := r.Approver.SendApprovalNotification(ctx, req); err != nil {
+		return fmt.Errorf("failed to notify approvers: %w", err)
+	}
+
+	r.recordEvent(ctx, exec, audit.EventApprovalRequested, fmt.Sprintf("Approval requested for runbook %s", runbook.Name))
+
+	meta.SetStatusCondition(&exec.Status.Conditions, metav1.Condition{
+		Type:               ConditionApprovalNotified,
+		Status:             metav1.ConditionTrue,
+		Reason:             "NotificationSent",
+		Message:            "Approvers have been notified",
+		LastTransitionTime: metav1.Now(),
+	})
+	log.Info("approvers notified", "approvers", approvers)
+	return nil
+}
+
+// ApprovedByGroupsAnnotation lists, comma-separated, the groups of the
+// identity in Status.ApprovedBy. Only the operator may set it: the execution
+// API writes it from the approver's authenticated identity whenever it
+// records an approval, and RunbookExecutionDefaulter discards it from
+// anyone else.
+const ApprovedByGroupsAnnotation = "helios.io/approved-by-groups"
+
+// GroupResolver returns the groups of the identity that approved an
+// execution.
+type GroupResolver interface {
+	Groups(ctx context.Context, exec *heliosv1alpha1.RunbookExecution, user string) ([]string, error)
+}
+
+// AnnotationGroupResolver resolves groups from the ApprovedByGroupsAnnotation
+// on the execution. The annotation is only trustworthy when the
+// RunbookExecution webhook is installed to protect it.
+type AnnotationGroupResolver struct{}
+
+// Groups implements GroupResolver.
+func (AnnotationGroupResolver) Groups(_ context.Context, exec *heliosv1alpha1.RunbookExecution, _ string) ([]string, error) {
+	return splitList(exec.Annotations[ApprovedByGroupsAnnotation]), nil
+}
+
+// authorizeApprover checks that approvedBy may approve executions of the
+// runbook: either a user approver with that name, or a member of a group
+// approver. Groups are only resolved when no user approver matches, and
+// group approvers cannot be satisfied without a resolver.
+func authorizeApprover(ctx context.Context, resolver GroupResolver, exec *heliosv1alpha1.RunbookExecution, runbook *heliosv1alpha1.Runbook, approvedBy string) error {
+	if len(runbook.Spec.Approvers) == 0 {
+		return nil
+	}
+
+	var groups []string
+	for _, a := range runbook.Spec.Approvers {
+		switch a.Type {
+		case "user":
+			if a.Name == approvedBy {
+				return nil
+			}
+		case "group":
+			groups = append(groups, a.Name)
+		}
+	}
+	if len(groups) == 0 {
+		return fmt.Errorf("%s is not an approver for runbook %s", approvedBy, runbook.Name)
+	}
+
+	if resolver == nil {
+		return fmt.Errorf("%s is not a user approver for runbook %s, and no group resolver is configured", approvedBy, runbook.Name)
+	}
+	memberOf, err := resolver.Groups(ctx, exec, approvedBy)
+	if err != nil {
+		return fmt.Errorf("failed to resolve groups for %s: %w", approvedBy, err)
+	}
+	for _, g := range memberOf {
+		for _, want := range groups {
+			if g == want {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("%s is not an approver for runbook %s", approvedBy, runbook.Name)
+}
+
+// handleDenial cancels exec once an authorized approver has denied it. A
+// denial from anyone else is discarded, as an unauthorized approval is.
+func (r *RunbookExecutionReconciler) handleDenial(ctx context.Context, log *slog.Logger, exec *heliosv1alpha1.RunbookExecution, runbook *heliosv1alpha1.Runbook) (ctrl.Result, error) {
+	deniedBy := exec.Status.DeniedBy
+	reason := exec.Status.DenialReason
+	if reason == "" {
+		reason = "no reason given"
+	}
+
+	err := fmt.Errorf("deniedBy is required")
+	if deniedBy != "" {
+		err = authorizeApprover(ctx, r.GroupResolver, exec, runbook, deniedBy)
+	}
+	if err != nil {
+		log.Warn("ignoring approval denial", "deniedBy", deniedBy, "error", err)
+		exec.Status.ApprovalDecision = ""
+		exec.Status.DeniedBy = ""
+		exec.Status.DenialReason = ""
+		exec.Status.Message = fmt.Sprintf("Denial by %s ignored: %v", deniedBy, err)
+		return ctrl.Result{RequeueAfter: 30 * time.Second}, r.Status().Update(ctx, exec)
+	}
+
+	log.Info("execution denied", "deniedBy", deniedBy, "reason", reason)
+	if r.Audit != nil {
+		r.Audit.LogApprovalDenied(ctx, exec.Name, exec.Namespace, runbook.Name, exec.Spec.TriggeredBy, deniedBy, reason)
+	}
+	return ctrl.Result{}, r.setPhase(ctx, exec, heliosv1alpha1.PhaseCancelled, fmt.Sprintf("Approval denied by %s: %s", deniedBy, reason))
+}
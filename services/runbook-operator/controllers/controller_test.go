@@ -2,14 +2,38 @@ package controllers
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"reflect"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 
 	heliosv1alpha1 "github.com/rhwendt/helios/services/runbook-operator/api/v1alpha1"
+	"github.com/rhwendt/helios/services/runbook-operator/pkg/approval"
+	"github.com/rhwendt/helios/services/runbook-operator/pkg/audit"
+	"github.com/rhwendt/helios/services/runbook-operator/pkg/metrics"
 )
 
 func testLogger() *slog.Logger {
@@ -35,9 +59,13 @@ func TestRunbookValidation(t *testing.T) {
 					Category:  heliosv1alpha1.CategoryInterface,
 					RiskLevel: heliosv1alpha1.RiskMedium,
 					Steps: []heliosv1alpha1.RunbookStep{
-						{Name: "disable-interface", Action: heliosv1alpha1.ActionGNMISet},
+						{Name: "disable-interface", Action: heliosv1alpha1.ActionGNMISet, Config: map[string]interface{}{
+							"target": "{{ .device }}", "path": "/interfaces/interface/config/enabled", "value": false,
+						}},
 						{Name: "wait", Action: heliosv1alpha1.ActionWait},
-						{Name: "enable-interface", Action: heliosv1alpha1.ActionGNMISet},
+						{Name: "enable-interface", Action: heliosv1alpha1.ActionGNMISet, Config: map[string]interface{}{
+							"target": "{{ .device }}", "path": "/interfaces/interface/config/enabled", "value": true,
+						}},
 					},
 				},
 			},
@@ -53,7 +81,9 @@ func TestRunbookValidation(t *testing.T) {
 					RequiresApproval: true,
 					Approvers:        []heliosv1alpha1.Approver{{Type: "group", Name: "noc-leads"}},
 					Steps: []heliosv1alpha1.RunbookStep{
-						{Name: "clear-bgp", Action: heliosv1alpha1.ActionGNMISet},
+						{Name: "clear-bgp", Action: heliosv1alpha1.ActionGNMISet, Config: map[string]interface{}{
+							"target": "{{ .device }}", "path": "/network-instances/network-instance/protocols/protocol/bgp", "value": "clear",
+						}},
 					},
 				},
 			},
@@ -96,6 +126,56 @@ func TestRunbookValidation(t *testing.T) {
 			wantErr: true,
 			errMsg:  "approvers required",
 		},
+		{
+			name: "valid user and group approvers",
+			runbook: &heliosv1alpha1.Runbook{
+				Spec: heliosv1alpha1.RunbookSpec{
+					Name:             "needs-approval",
+					RequiresApproval: true,
+					Approvers: []heliosv1alpha1.Approver{
+						{Type: "user", Name: "noc-lead@example.com"},
+						{Type: "group", Name: "noc-leads"},
+					},
+					Steps: []heliosv1alpha1.RunbookStep{
+						{Name: "step-1", Action: heliosv1alpha1.ActionWait},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "approver with unknown type",
+			runbook: &heliosv1alpha1.Runbook{
+				Spec: heliosv1alpha1.RunbookSpec{
+					Name:             "needs-approval",
+					RequiresApproval: true,
+					Approvers:        []heliosv1alpha1.Approver{{Type: "grp", Name: "noc-leads"}},
+					Steps: []heliosv1alpha1.RunbookStep{
+						{Name: "step-1", Action: heliosv1alpha1.ActionWait},
+					},
+				},
+			},
+			wantErr: true,
+			errMsg:  `approver 0: type must be user or group, got "grp"`,
+		},
+		{
+			name: "approver with empty name",
+			runbook: &heliosv1alpha1.Runbook{
+				Spec: heliosv1alpha1.RunbookSpec{
+					Name:             "needs-approval",
+					RequiresApproval: true,
+					Approvers: []heliosv1alpha1.Approver{
+						{Type: "group", Name: "noc-leads"},
+						{Type: "user", Name: ""},
+					},
+					Steps: []heliosv1alpha1.RunbookStep{
+						{Name: "step-1", Action: heliosv1alpha1.ActionWait},
+					},
+				},
+			},
+			wantErr: true,
+			errMsg:  "approver 1: name is required",
+		},
 		{
 			name: "step with empty name",
 			runbook: &heliosv1alpha1.Runbook{
@@ -122,6 +202,174 @@ func TestRunbookValidation(t *testing.T) {
 			wantErr: true,
 			errMsg:  "action is required",
 		},
+		{
+			name: "invalid execution timeout",
+			runbook: &heliosv1alpha1.Runbook{
+				Spec: heliosv1alpha1.RunbookSpec{
+					Name:             "bad-timeout",
+					ExecutionTimeout: "soon",
+					Steps: []heliosv1alpha1.RunbookStep{
+						{Name: "step-1", Action: heliosv1alpha1.ActionWait},
+					},
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid executionTimeout",
+		},
+		{
+			name: "invalid schedule",
+			runbook: &heliosv1alpha1.Runbook{
+				Spec: heliosv1alpha1.RunbookSpec{
+					Name:     "bad-schedule",
+					Schedule: "every day",
+					Steps: []heliosv1alpha1.RunbookStep{
+						{Name: "step-1", Action: heliosv1alpha1.ActionWait},
+					},
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid schedule",
+		},
+		{
+			name: "schedule with allowed roles",
+			runbook: &heliosv1alpha1.Runbook{
+				Spec: heliosv1alpha1.RunbookSpec{
+					Name:         "scheduled",
+					Schedule:     "*/5 * * * *",
+					AllowedRoles: []string{"noc-lead"},
+					Steps: []heliosv1alpha1.RunbookStep{
+						{Name: "step-1", Action: heliosv1alpha1.ActionWait},
+					},
+				},
+			},
+			wantErr: true,
+			errMsg:  "schedule cannot be combined with allowedRoles",
+		},
+		{
+			name: "schedule with required parameter without default",
+			runbook: &heliosv1alpha1.Runbook{
+				Spec: heliosv1alpha1.RunbookSpec{
+					Name:       "scheduled",
+					Schedule:   "*/5 * * * *",
+					Parameters: []heliosv1alpha1.Parameter{{Name: "device", Type: "device", Required: true}},
+					Steps: []heliosv1alpha1.RunbookStep{
+						{Name: "step-1", Action: heliosv1alpha1.ActionWait},
+					},
+				},
+			},
+			wantErr: true,
+			errMsg:  `parameter "device" is required`,
+		},
+		{
+			name: "schedule with defaulted required parameter",
+			runbook: &heliosv1alpha1.Runbook{
+				Spec: heliosv1alpha1.RunbookSpec{
+					Name:       "scheduled",
+					Schedule:   "*/5 * * * *",
+					Parameters: []heliosv1alpha1.Parameter{{Name: "device", Type: "device", Required: true, Default: "spine1"}},
+					Steps: []heliosv1alpha1.RunbookStep{
+						{Name: "step-1", Action: heliosv1alpha1.ActionWait},
+					},
+				},
+			},
+		},
+		{
+			name: "duplicate parameter",
+			runbook: &heliosv1alpha1.Runbook{
+				Spec: heliosv1alpha1.RunbookSpec{
+					Name: "dup-param",
+					Parameters: []heliosv1alpha1.Parameter{
+						{Name: "device", Type: "device"},
+						{Name: "device", Type: "string"},
+					},
+					Steps: []heliosv1alpha1.RunbookStep{{Name: "wait", Action: heliosv1alpha1.ActionWait}},
+				},
+			},
+			wantErr: true,
+			errMsg:  `parameter "device" is defined more than once`,
+		},
+		{
+			name: "parameter with invalid pattern",
+			runbook: &heliosv1alpha1.Runbook{
+				Spec: heliosv1alpha1.RunbookSpec{
+					Name:       "bad-pattern",
+					Parameters: []heliosv1alpha1.Parameter{{Name: "interface", Type: "interface", Validation: "Ethernet[0-9"}},
+					Steps:      []heliosv1alpha1.RunbookStep{{Name: "wait", Action: heliosv1alpha1.ActionWait}},
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid validation pattern",
+		},
+		{
+			name: "select parameter without options",
+			runbook: &heliosv1alpha1.Runbook{
+				Spec: heliosv1alpha1.RunbookSpec{
+					Name:       "bad-select",
+					Parameters: []heliosv1alpha1.Parameter{{Name: "vrf", Type: "select"}},
+					Steps:      []heliosv1alpha1.RunbookStep{{Name: "wait", Action: heliosv1alpha1.ActionWait}},
+				},
+			},
+			wantErr: true,
+			errMsg:  "select parameters require options",
+		},
+		{
+			name: "parameter default of the wrong type",
+			runbook: &heliosv1alpha1.Runbook{
+				Spec: heliosv1alpha1.RunbookSpec{
+					Name:       "bad-default",
+					Parameters: []heliosv1alpha1.Parameter{{Name: "delay", Type: "integer", Default: "soon"}},
+					Steps:      []heliosv1alpha1.RunbookStep{{Name: "wait", Action: heliosv1alpha1.ActionWait}},
+				},
+			},
+			wantErr: true,
+			errMsg:  `parameter "delay" default must be an integer`,
+		},
+		{
+			name: "step missing required config",
+			runbook: &heliosv1alpha1.Runbook{
+				Spec: heliosv1alpha1.RunbookSpec{
+					Name: "bad-config",
+					Steps: []heliosv1alpha1.RunbookStep{
+						{Name: "wait", Action: heliosv1alpha1.ActionWait},
+						{Name: "set", Action: heliosv1alpha1.ActionGNMISet, Config: map[string]interface{}{"value": "x"}},
+					},
+				},
+			},
+			wantErr: true,
+			errMsg:  "step 1 (set): gnmi_set requires config target, path",
+		},
+		{
+			name: "rollback step missing required config",
+			runbook: &heliosv1alpha1.Runbook{
+				Spec: heliosv1alpha1.RunbookSpec{
+					Name: "bad-rollback",
+					Steps: []heliosv1alpha1.RunbookStep{
+						{Name: "wait", Action: heliosv1alpha1.ActionWait},
+					},
+					Rollback: []heliosv1alpha1.RunbookStep{
+						{Name: "restore", Action: heliosv1alpha1.ActionGNMISet, Config: map[string]interface{}{"value": true}},
+					},
+				},
+			},
+			wantErr: true,
+			errMsg:  "rollback step 0 (restore): gnmi_set requires config target, path",
+		},
+		{
+			name: "rollback step with empty action",
+			runbook: &heliosv1alpha1.Runbook{
+				Spec: heliosv1alpha1.RunbookSpec{
+					Name: "bad-rollback",
+					Steps: []heliosv1alpha1.RunbookStep{
+						{Name: "wait", Action: heliosv1alpha1.ActionWait},
+					},
+					Rollback: []heliosv1alpha1.RunbookStep{
+						{Name: "restore"},
+					},
+				},
+			},
+			wantErr: true,
+			errMsg:  "rollback step 0: action is required",
+		},
 	}
 
 	for _, tc := range tests {
@@ -143,6 +391,187 @@ func TestRunbookValidation(t *testing.T) {
 	}
 }
 
+func TestRunbookValidation_ScriptAction(t *testing.T) {
+	runbook := &heliosv1alpha1.Runbook{
+		Spec: heliosv1alpha1.RunbookSpec{
+			Name: "run-script",
+			Steps: []heliosv1alpha1.RunbookStep{
+				{Name: "script", Action: heliosv1alpha1.ActionScript},
+			},
+		},
+	}
+
+	t.Run("disabled by default", func(t *testing.T) {
+		r := &RunbookReconciler{Log: testLogger()}
+		err := r.validateRunbook(runbook)
+		if err == nil || !containsStr(err.Error(), "script action is disabled") {
+			t.Errorf("error = %v, want script action is disabled", err)
+		}
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		r := &RunbookReconciler{Log: testLogger(), EnableScriptAction: true}
+		if err := r.validateRunbook(runbook); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	rollback := &heliosv1alpha1.Runbook{
+		Spec: heliosv1alpha1.RunbookSpec{
+			Name: "run-script-on-rollback",
+			Steps: []heliosv1alpha1.RunbookStep{
+				{Name: "wait", Action: heliosv1alpha1.ActionWait},
+			},
+			Rollback: []heliosv1alpha1.RunbookStep{
+				{Name: "script", Action: heliosv1alpha1.ActionScript},
+			},
+		},
+	}
+
+	t.Run("disabled for rollback steps", func(t *testing.T) {
+		r := &RunbookReconciler{Log: testLogger()}
+		err := r.validateRunbook(rollback)
+		if err == nil || !containsStr(err.Error(), "rollback step 0: script action is disabled") {
+			t.Errorf("error = %v, want rollback step 0: script action is disabled", err)
+		}
+	})
+
+	t.Run("enabled for rollback steps", func(t *testing.T) {
+		r := &RunbookReconciler{Log: testLogger(), EnableScriptAction: true}
+		if err := r.validateRunbook(rollback); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
+
+func TestRunbookValidator(t *testing.T) {
+	valid := &heliosv1alpha1.Runbook{
+		ObjectMeta: metav1.ObjectMeta{Name: "collect-diagnostics"},
+		Spec: heliosv1alpha1.RunbookSpec{
+			Name:       "collect-diagnostics",
+			Parameters: []heliosv1alpha1.Parameter{{Name: "device", Type: "device", Required: true}},
+			Steps: []heliosv1alpha1.RunbookStep{
+				{Name: "get-system", Action: heliosv1alpha1.ActionGNMIGet, Config: map[string]interface{}{
+					"target": "{{ .device }}", "path": "/system/state",
+				}},
+			},
+		},
+	}
+	invalid := valid.DeepCopy()
+	invalid.Spec.Steps[0].Config = map[string]interface{}{"path": "/system/state"}
+
+	v := &RunbookValidator{}
+	ctx := context.Background()
+
+	if _, err := v.ValidateCreate(ctx, valid); err != nil {
+		t.Errorf("ValidateCreate(valid) error = %v", err)
+	}
+	if _, err := v.ValidateUpdate(ctx, invalid, valid); err != nil {
+		t.Errorf("ValidateUpdate(to valid) error = %v", err)
+	}
+
+	_, err := v.ValidateCreate(ctx, invalid)
+	if err == nil || !containsStr(err.Error(), "invalid runbook collect-diagnostics: step 0 (get-system): gnmi_get requires config target") {
+		t.Errorf("ValidateCreate(invalid) error = %v", err)
+	}
+	if _, err := v.ValidateUpdate(ctx, valid, invalid); err == nil {
+		t.Error("ValidateUpdate(to invalid) should fail")
+	}
+	if _, err := v.ValidateDelete(ctx, invalid); err != nil {
+		t.Errorf("ValidateDelete() error = %v", err)
+	}
+	if _, err := v.ValidateCreate(ctx, &heliosv1alpha1.RunbookExecution{}); err == nil {
+		t.Error("ValidateCreate should reject objects that are not Runbooks")
+	}
+
+	script := valid.DeepCopy()
+	script.Spec.Steps = []heliosv1alpha1.RunbookStep{{Name: "script", Action: heliosv1alpha1.ActionScript}}
+	if _, err := v.ValidateCreate(ctx, script); err == nil {
+		t.Error("script steps should be rejected unless enabled")
+	}
+	if _, err := (&RunbookValidator{EnableScriptAction: true}).ValidateCreate(ctx, script); err != nil {
+		t.Errorf("ValidateCreate(script, enabled) error = %v", err)
+	}
+}
+
+func TestValidateStepConfig(t *testing.T) {
+	tests := []struct {
+		action  heliosv1alpha1.StepAction
+		config  map[string]interface{}
+		missing []string
+	}{
+		{heliosv1alpha1.ActionGNMISet, map[string]interface{}{"target": "r1", "path": "/a", "value": false}, nil},
+		{heliosv1alpha1.ActionGNMISet, map[string]interface{}{"target": "r1", "path": "/a"}, []string{"value"}},
+		{heliosv1alpha1.ActionGNMISet, nil, []string{"target", "path", "value"}},
+		{heliosv1alpha1.ActionGNMIGet, map[string]interface{}{"target": "r1", "path": "/a"}, nil},
+		{heliosv1alpha1.ActionGNMIGet, map[string]interface{}{"target": "", "path": "/a"}, []string{"target"}},
+		{heliosv1alpha1.ActionGNMISubscribe, map[string]interface{}{"target": "r1", "path": "/a"}, nil},
+		{heliosv1alpha1.ActionGNMISubscribe, map[string]interface{}{"target": "r1"}, []string{"path"}},
+		{heliosv1alpha1.ActionGNMICapabilities, map[string]interface{}{"target": "r1"}, nil},
+		{heliosv1alpha1.ActionGNMICapabilities, nil, []string{"target"}},
+		{heliosv1alpha1.ActionGNMIWait, map[string]interface{}{"target": "r1", "path": "/a", "condition": "{{ eq .value \"UP\" }}"}, nil},
+		{heliosv1alpha1.ActionGNMIWait, map[string]interface{}{"target": "r1", "path": "/a"}, []string{"condition"}},
+		{heliosv1alpha1.ActionNotify, map[string]interface{}{"webhook_url": "https://hooks.example.com", "message": "done"}, nil},
+		{heliosv1alpha1.ActionNotify, map[string]interface{}{"message": "done"}, []string{"webhook_url"}},
+		{heliosv1alpha1.ActionCondition, map[string]interface{}{"expression": "{{ .ok }}"}, nil},
+		{heliosv1alpha1.ActionCondition, nil, []string{"expression"}},
+		{heliosv1alpha1.ActionWait, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s %v", tt.action, tt.missing), func(t *testing.T) {
+			err := validateStepConfig("step", 0, heliosv1alpha1.RunbookStep{Name: "step", Action: tt.action, Config: tt.config})
+			if tt.missing == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			var cfgErr *stepConfigError
+			if !errors.As(err, &cfgErr) {
+				t.Fatalf("error = %v, want *stepConfigError", err)
+			}
+			if !reflect.DeepEqual(cfgErr.missing, tt.missing) {
+				t.Errorf("missing = %v, want %v", cfgErr.missing, tt.missing)
+			}
+		})
+	}
+}
+
+func TestRunbookReconcile_InvalidStepConfig(t *testing.T) {
+	ns := "helios-automation"
+	runbook := &heliosv1alpha1.Runbook{
+		ObjectMeta: metav1.ObjectMeta{Name: "bad-config", Namespace: ns},
+		Spec: heliosv1alpha1.RunbookSpec{
+			Name: "bad-config",
+			Steps: []heliosv1alpha1.RunbookStep{
+				{Name: "get", Action: heliosv1alpha1.ActionGNMIGet, Config: map[string]interface{}{"target": "r1"}},
+			},
+		},
+	}
+
+	c := newFakeClient(t, runbook)
+	r := &RunbookReconciler{Client: c, Log: testLogger()}
+
+	ctx := context.Background()
+	key := types.NamespacedName{Name: "bad-config", Namespace: ns}
+	if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key}); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+
+	var got heliosv1alpha1.Runbook
+	if err := c.Get(ctx, key, &got); err != nil {
+		t.Fatalf("failed to get runbook: %v", err)
+	}
+	cond := meta.FindStatusCondition(got.Status.Conditions, "Ready")
+	if cond == nil {
+		t.Fatal("Ready condition not set")
+	}
+	if cond.Status != metav1.ConditionFalse || cond.Reason != "InvalidStepConfig" {
+		t.Errorf("Ready = %s/%s, want False/InvalidStepConfig", cond.Status, cond.Reason)
+	}
+}
+
 // TestStateMachineTransitions tests the state machine logic by verifying
 // which handler method gets called for each phase.
 func TestStateMachineTransitions_PendingNoApproval(t *testing.T) {
@@ -318,15 +747,1538 @@ func TestSetPhase_ContextCancellation(t *testing.T) {
 	}
 }
 
-func containsStr(s, substr string) bool {
-	return len(s) >= len(substr) && searchStr(s, substr)
+func newFakeClient(t *testing.T, objs ...client.Object) client.Client {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := heliosv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to build scheme: %v", err)
+	}
+	if err := batchv1.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to build scheme: %v", err)
+	}
+	return fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objs...).
+		WithStatusSubresource(&heliosv1alpha1.Runbook{}, &heliosv1alpha1.RunbookExecution{}).
+		Build()
 }
 
-func searchStr(s, substr string) bool {
-	for i := 0; i <= len(s)-len(substr); i++ {
-		if s[i:i+len(substr)] == substr {
-			return true
-		}
+func TestHandlePending_Cooldown(t *testing.T) {
+	tests := []struct {
+		name          string
+		completedAgo  time.Duration
+		wantPhase     heliosv1alpha1.ExecutionPhase
+		wantInMessage string
+	}{
+		{
+			name:          "recent execution within cooldown",
+			completedAgo:  2 * time.Minute,
+			wantPhase:     heliosv1alpha1.PhaseCancelled,
+			wantInMessage: "cooldown",
+		},
+		{
+			name:         "previous execution outside cooldown",
+			completedAgo: 15 * time.Minute,
+			wantPhase:    heliosv1alpha1.PhaseRunning,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ns := "helios-automation"
+			runbook := &heliosv1alpha1.Runbook{
+				ObjectMeta: metav1.ObjectMeta{Name: "interface-bounce", Namespace: ns},
+				Spec: heliosv1alpha1.RunbookSpec{
+					Name:     "interface-bounce",
+					Cooldown: "10m",
+				},
+			}
+			completed := metav1.NewTime(time.Now().Add(-tt.completedAgo))
+			prev := &heliosv1alpha1.RunbookExecution{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "previous",
+					Namespace: ns,
+					Labels:    map[string]string{RunbookLabel: "interface-bounce"},
+				},
+				Spec: heliosv1alpha1.RunbookExecutionSpec{
+					RunbookRef: heliosv1alpha1.RunbookRef{Name: "interface-bounce"},
+				},
+				Status: heliosv1alpha1.RunbookExecutionStatus{
+					Phase:          heliosv1alpha1.PhaseCompleted,
+					CompletionTime: &completed,
+				},
+			}
+			current := &heliosv1alpha1.RunbookExecution{
+				ObjectMeta: metav1.ObjectMeta{Name: "current", Namespace: ns},
+				Spec: heliosv1alpha1.RunbookExecutionSpec{
+					RunbookRef: heliosv1alpha1.RunbookRef{Name: "interface-bounce"},
+				},
+				Status: heliosv1alpha1.RunbookExecutionStatus{
+					Phase: heliosv1alpha1.PhasePending,
+				},
+			}
+
+			c := newFakeClient(t, runbook, prev, current)
+			r := &RunbookExecutionReconciler{Client: c, Log: testLogger()}
+
+			ctx := context.Background()
+			var exec heliosv1alpha1.RunbookExecution
+			if err := c.Get(ctx, types.NamespacedName{Name: "current", Namespace: ns}, &exec); err != nil {
+				t.Fatalf("failed to get execution: %v", err)
+			}
+			if _, err := r.handlePending(ctx, testLogger(), &exec); err != nil {
+				t.Fatalf("handlePending() error = %v", err)
+			}
+
+			if exec.Status.Phase != tt.wantPhase {
+				t.Errorf("phase = %q, want %q", exec.Status.Phase, tt.wantPhase)
+			}
+			if tt.wantInMessage != "" && !containsStr(exec.Status.Message, tt.wantInMessage) {
+				t.Errorf("message %q does not contain %q", exec.Status.Message, tt.wantInMessage)
+			}
+			if exec.Labels[RunbookLabel] != "interface-bounce" {
+				t.Errorf("label %s = %q, want interface-bounce", RunbookLabel, exec.Labels[RunbookLabel])
+			}
+		})
+	}
+}
+
+type stubRoleResolver struct {
+	roles []string
+	err   error
+}
+
+func (s stubRoleResolver) Roles(context.Context, *heliosv1alpha1.RunbookExecution) ([]string, error) {
+	return s.roles, s.err
+}
+
+func TestAuthorizeTrigger(t *testing.T) {
+	runbook := &heliosv1alpha1.Runbook{
+		ObjectMeta: metav1.ObjectMeta{Name: "bgp-reset"},
+		Spec: heliosv1alpha1.RunbookSpec{
+			AllowedRoles: []string{"noc-lead", "network-admin"},
+		},
+	}
+	exec := &heliosv1alpha1.RunbookExecution{
+		Spec: heliosv1alpha1.RunbookExecutionSpec{TriggeredBy: "operator@example.com"},
+	}
+
+	tests := []struct {
+		name     string
+		resolver RoleResolver
+		runbook  *heliosv1alpha1.Runbook
+		wantErr  bool
+	}{
+		{
+			name:     "allowed role",
+			resolver: stubRoleResolver{roles: []string{"viewer", "network-admin"}},
+			runbook:  runbook,
+		},
+		{
+			name:     "denied role",
+			resolver: stubRoleResolver{roles: []string{"viewer"}},
+			runbook:  runbook,
+			wantErr:  true,
+		},
+		{
+			name:     "no roles",
+			resolver: stubRoleResolver{},
+			runbook:  runbook,
+			wantErr:  true,
+		},
+		{
+			name:     "resolver error",
+			resolver: stubRoleResolver{err: errors.New("directory unavailable")},
+			runbook:  runbook,
+			wantErr:  true,
+		},
+		{
+			name:    "no resolver configured",
+			runbook: runbook,
+			wantErr: true,
+		},
+		{
+			name:     "runbook without allowed roles",
+			resolver: stubRoleResolver{},
+			runbook:  &heliosv1alpha1.Runbook{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := authorizeTrigger(context.Background(), tt.resolver, exec, tt.runbook)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("authorizeTrigger() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAnnotationRoleResolver(t *testing.T) {
+	exec := &heliosv1alpha1.RunbookExecution{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{TriggeredByRolesAnnotation: "viewer, noc-lead,,"},
+		},
+	}
+	roles, err := AnnotationRoleResolver{}.Roles(context.Background(), exec)
+	if err != nil {
+		t.Fatalf("Roles() error = %v", err)
+	}
+	if len(roles) != 2 || roles[0] != "viewer" || roles[1] != "noc-lead" {
+		t.Errorf("Roles() = %v, want [viewer noc-lead]", roles)
+	}
+}
+
+func TestRunbookExecutionDefaulter(t *testing.T) {
+	const operatorSA = "system:serviceaccount:helios-automation:helios-operator"
+	d := &RunbookExecutionDefaulter{TrustedUsers: []string{operatorSA}}
+
+	stored, err := json.Marshal(&heliosv1alpha1.RunbookExecution{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{
+				TriggeredByRolesAnnotation: "viewer",
+				ApprovedByGroupsAnnotation: "engineering",
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to marshal stored execution: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		operation  admissionv1.Operation
+		user       authenticationv1.UserInfo
+		roles      string
+		wantRoles  string
+		wantGroups string
+	}{
+		{
+			name:      "create stamps requester groups",
+			operation: admissionv1.Create,
+			user:      authenticationv1.UserInfo{Username: "viewer@example.com", Groups: []string{"viewer", "system:authenticated"}},
+			roles:     "network-admin",
+			wantRoles: "viewer,system:authenticated",
+		},
+		{
+			name:      "create without groups clears roles",
+			operation: admissionv1.Create,
+			user:      authenticationv1.UserInfo{Username: "viewer@example.com"},
+			roles:     "network-admin",
+		},
+		{
+			name:       "update restores stored annotations",
+			operation:  admissionv1.Update,
+			user:       authenticationv1.UserInfo{Username: "viewer@example.com", Groups: []string{"network-admin"}},
+			roles:      "network-admin",
+			wantRoles:  "viewer",
+			wantGroups: "engineering",
+		},
+		{
+			name:       "operator may set annotations",
+			operation:  admissionv1.Create,
+			user:       authenticationv1.UserInfo{Username: operatorSA},
+			roles:      "network-admin",
+			wantRoles:  "network-admin",
+			wantGroups: "noc-leads",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exec := &heliosv1alpha1.RunbookExecution{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						TriggeredByRolesAnnotation: tt.roles,
+						ApprovedByGroupsAnnotation: "noc-leads",
+					},
+				},
+			}
+			req := admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				Operation: tt.operation,
+				UserInfo:  tt.user,
+			}}
+			if tt.operation == admissionv1.Update {
+				req.OldObject = runtime.RawExtension{Raw: stored}
+			}
+
+			ctx := admission.NewContextWithRequest(context.Background(), req)
+			if err := d.Default(ctx, exec); err != nil {
+				t.Fatalf("Default() error = %v", err)
+			}
+			if got := exec.Annotations[TriggeredByRolesAnnotation]; got != tt.wantRoles {
+				t.Errorf("roles annotation = %q, want %q", got, tt.wantRoles)
+			}
+			if got := exec.Annotations[ApprovedByGroupsAnnotation]; got != tt.wantGroups {
+				t.Errorf("groups annotation = %q, want %q", got, tt.wantGroups)
+			}
+		})
+	}
+}
+
+func TestHandlePending_Unauthorized(t *testing.T) {
+	ns := "helios-automation"
+	runbook := &heliosv1alpha1.Runbook{
+		ObjectMeta: metav1.ObjectMeta{Name: "bgp-reset", Namespace: ns},
+		Spec: heliosv1alpha1.RunbookSpec{
+			Name:         "bgp-reset",
+			AllowedRoles: []string{"network-admin"},
+		},
+	}
+	current := &heliosv1alpha1.RunbookExecution{
+		ObjectMeta: metav1.ObjectMeta{Name: "current", Namespace: ns},
+		Spec: heliosv1alpha1.RunbookExecutionSpec{
+			RunbookRef:  heliosv1alpha1.RunbookRef{Name: "bgp-reset"},
+			TriggeredBy: "viewer@example.com",
+		},
+		Status: heliosv1alpha1.RunbookExecutionStatus{
+			Phase: heliosv1alpha1.PhasePending,
+		},
+	}
+
+	c := newFakeClient(t, runbook, current)
+	r := &RunbookExecutionReconciler{
+		Client:       c,
+		Log:          testLogger(),
+		RoleResolver: stubRoleResolver{roles: []string{"viewer"}},
+	}
+
+	ctx := context.Background()
+	var exec heliosv1alpha1.RunbookExecution
+	if err := c.Get(ctx, types.NamespacedName{Name: "current", Namespace: ns}, &exec); err != nil {
+		t.Fatalf("failed to get execution: %v", err)
+	}
+	if _, err := r.handlePending(ctx, testLogger(), &exec); err != nil {
+		t.Fatalf("handlePending() error = %v", err)
+	}
+	if exec.Status.Phase != heliosv1alpha1.PhaseFailed {
+		t.Errorf("phase = %q, want Failed", exec.Status.Phase)
+	}
+	if !containsStr(exec.Status.Message, "not authorized") {
+		t.Errorf("message %q does not mention authorization", exec.Status.Message)
+	}
+}
+
+func TestHandlePending_RecordsParameterDefaults(t *testing.T) {
+	ns := "helios-automation"
+	runbook := &heliosv1alpha1.Runbook{
+		ObjectMeta: metav1.ObjectMeta{Name: "drain", Namespace: ns},
+		Spec: heliosv1alpha1.RunbookSpec{
+			Name: "drain",
+			Parameters: []heliosv1alpha1.Parameter{
+				{Name: "device", Type: "device", Required: true},
+				{Name: "mode", Type: "select", Options: []string{"drain", "undrain"}, Default: "drain"},
+			},
+		},
+	}
+	current := &heliosv1alpha1.RunbookExecution{
+		ObjectMeta: metav1.ObjectMeta{Name: "current", Namespace: ns},
+		Spec: heliosv1alpha1.RunbookExecutionSpec{
+			RunbookRef:  heliosv1alpha1.RunbookRef{Name: "drain"},
+			TriggeredBy: "alice@example.com",
+			Parameters:  map[string]interface{}{"device": "spine1"},
+		},
+		Status: heliosv1alpha1.RunbookExecutionStatus{
+			Phase: heliosv1alpha1.PhasePending,
+		},
+	}
+
+	c := newFakeClient(t, runbook, current)
+	r := &RunbookExecutionReconciler{Client: c, Log: testLogger()}
+
+	ctx := context.Background()
+	var exec heliosv1alpha1.RunbookExecution
+	if err := c.Get(ctx, types.NamespacedName{Name: "current", Namespace: ns}, &exec); err != nil {
+		t.Fatalf("failed to get execution: %v", err)
+	}
+	if _, err := r.handlePending(ctx, testLogger(), &exec); err != nil {
+		t.Fatalf("handlePending() error = %v", err)
+	}
+
+	var stored heliosv1alpha1.RunbookExecution
+	if err := c.Get(ctx, types.NamespacedName{Name: "current", Namespace: ns}, &stored); err != nil {
+		t.Fatalf("failed to get execution: %v", err)
+	}
+	want := map[string]interface{}{"device": "spine1", "mode": "drain"}
+	if !reflect.DeepEqual(stored.Spec.Parameters, want) {
+		t.Errorf("parameters = %v, want %v", stored.Spec.Parameters, want)
+	}
+}
+
+func TestHandlePending_NotifiesApprovers(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	ns := "helios-automation"
+	runbook := &heliosv1alpha1.Runbook{
+		ObjectMeta: metav1.ObjectMeta{Name: "bgp-reset", Namespace: ns},
+		Spec: heliosv1alpha1.RunbookSpec{
+			Name:             "bgp-reset",
+			RiskLevel:        heliosv1alpha1.RiskHigh,
+			RequiresApproval: true,
+			Approvers:        []heliosv1alpha1.Approver{{Type: "group", Name: "noc-leads"}},
+		},
+	}
+	current := &heliosv1alpha1.RunbookExecution{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "current",
+			Namespace:         ns,
+			CreationTimestamp: metav1.Now(),
+		},
+		Spec: heliosv1alpha1.RunbookExecutionSpec{
+			RunbookRef:  heliosv1alpha1.RunbookRef{Name: "bgp-reset"},
+			TriggeredBy: "admin@example.com",
+		},
+		Status: heliosv1alpha1.RunbookExecutionStatus{
+			Phase: heliosv1alpha1.PhasePending,
+		},
+	}
+
+	c := newFakeClient(t, runbook, current)
+	r := &RunbookExecutionReconciler{
+		Client:   c,
+		Log:      testLogger(),
+		Approver: approval.NewApprover(server.URL, approval.NotifyWebhook, testLogger()),
+		Audit:    audit.NewLogger(testLogger()),
+	}
+
+	ctx := context.Background()
+	key := types.NamespacedName{Name: "current", Namespace: ns}
+	var exec heliosv1alpha1.RunbookExecution
+	if err := c.Get(ctx, key, &exec); err != nil {
+		t.Fatalf("failed to get execution: %v", err)
+	}
+	if _, err := r.handlePending(ctx, testLogger(), &exec); err != nil {
+		t.Fatalf("handlePending() error = %v", err)
+	}
+	if exec.Status.Phase != heliosv1alpha1.PhasePendingApproval {
+		t.Fatalf("phase = %q, want PendingApproval", exec.Status.Phase)
+	}
+	if got := atomic.LoadInt32(&requests); got != 1 {
+		t.Fatalf("webhook requests = %d, want 1", got)
+	}
+
+	// A requeue while still awaiting approval must not notify again.
+	if err := c.Get(ctx, key, &exec); err != nil {
+		t.Fatalf("failed to get execution: %v", err)
+	}
+	if !meta.IsStatusConditionTrue(exec.Status.Conditions, ConditionApprovalNotified) {
+		t.Errorf("condition %s not set", ConditionApprovalNotified)
+	}
+	if _, err := r.handlePendingApproval(ctx, testLogger(), &exec); err != nil {
+		t.Fatalf("handlePendingApproval() error = %v", err)
+	}
+	if got := atomic.LoadInt32(&requests); got != 1 {
+		t.Errorf("webhook requests after requeue = %d, want 1", got)
+	}
+}
+
+func TestReconcile_CancelRunningExecution(t *testing.T) {
+	ns := "helios-automation"
+	started := metav1.NewTime(time.Now().Add(-time.Minute))
+	current := &heliosv1alpha1.RunbookExecution{
+		ObjectMeta: metav1.ObjectMeta{Name: "current", Namespace: ns},
+		Spec: heliosv1alpha1.RunbookExecutionSpec{
+			RunbookRef:  heliosv1alpha1.RunbookRef{Name: "interface-bounce"},
+			TriggeredBy: "admin@example.com",
+			Cancel:      true,
+		},
+		Status: heliosv1alpha1.RunbookExecutionStatus{
+			Phase:     heliosv1alpha1.PhaseRunning,
+			StartTime: &started,
+			JobName:   "current-executor",
+		},
+	}
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: "current-executor", Namespace: ns},
+	}
+
+	c := newFakeClient(t, current, job)
+	r := &RunbookExecutionReconciler{Client: c, Log: testLogger(), Audit: audit.NewLogger(testLogger())}
+
+	ctx := context.Background()
+	key := types.NamespacedName{Name: "current", Namespace: ns}
+	if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key}); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+
+	var exec heliosv1alpha1.RunbookExecution
+	if err := c.Get(ctx, key, &exec); err != nil {
+		t.Fatalf("failed to get execution: %v", err)
+	}
+	if exec.Status.Phase != heliosv1alpha1.PhaseCancelled {
+		t.Errorf("phase = %q, want Cancelled", exec.Status.Phase)
+	}
+	if exec.Status.CompletionTime == nil {
+		t.Error("CompletionTime should be set after cancellation")
+	}
+
+	err := c.Get(ctx, types.NamespacedName{Name: "current-executor", Namespace: ns}, &batchv1.Job{})
+	if !apierrors.IsNotFound(err) {
+		t.Errorf("executor job still present after cancellation (err = %v)", err)
+	}
+}
+
+func TestCancellable(t *testing.T) {
+	tests := map[heliosv1alpha1.ExecutionPhase]bool{
+		heliosv1alpha1.PhasePending:         true,
+		heliosv1alpha1.PhasePendingApproval: true,
+		heliosv1alpha1.PhaseRunning:         true,
+		heliosv1alpha1.PhaseRollingBack:     false,
+		heliosv1alpha1.PhaseCompleted:       false,
+		heliosv1alpha1.PhaseCancelled:       false,
+	}
+	for phase, want := range tests {
+		if got := cancellable(phase); got != want {
+			t.Errorf("cancellable(%q) = %v, want %v", phase, got, want)
+		}
+	}
+}
+
+func TestHandleRunning_ExecutionTimeout(t *testing.T) {
+	ns := "helios-automation"
+	runbook := &heliosv1alpha1.Runbook{
+		ObjectMeta: metav1.ObjectMeta{Name: "interface-bounce", Namespace: ns},
+		Spec: heliosv1alpha1.RunbookSpec{
+			Name:             "interface-bounce",
+			ExecutionTimeout: "1s",
+		},
+	}
+	started := metav1.NewTime(time.Now().Add(-time.Minute))
+	current := &heliosv1alpha1.RunbookExecution{
+		ObjectMeta: metav1.ObjectMeta{Name: "current", Namespace: ns},
+		Spec: heliosv1alpha1.RunbookExecutionSpec{
+			RunbookRef: heliosv1alpha1.RunbookRef{Name: "interface-bounce"},
+		},
+		Status: heliosv1alpha1.RunbookExecutionStatus{
+			Phase:     heliosv1alpha1.PhaseRunning,
+			StartTime: &started,
+			JobName:   "current-executor",
+		},
+	}
+	// A Job that never reports success or failure.
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: "current-executor", Namespace: ns},
+	}
+
+	c := newFakeClient(t, runbook, current, job)
+	r := &RunbookExecutionReconciler{Client: c, Log: testLogger()}
+
+	ctx := context.Background()
+	key := types.NamespacedName{Name: "current", Namespace: ns}
+	var exec heliosv1alpha1.RunbookExecution
+	if err := c.Get(ctx, key, &exec); err != nil {
+		t.Fatalf("failed to get execution: %v", err)
+	}
+	if _, err := r.handleRunning(ctx, testLogger(), &exec); err != nil {
+		t.Fatalf("handleRunning() error = %v", err)
+	}
+
+	if exec.Status.Phase != heliosv1alpha1.PhaseTimedOut {
+		t.Errorf("phase = %q, want TimedOut", exec.Status.Phase)
+	}
+	err := c.Get(ctx, types.NamespacedName{Name: "current-executor", Namespace: ns}, &batchv1.Job{})
+	if !apierrors.IsNotFound(err) {
+		t.Errorf("executor job still present after timeout (err = %v)", err)
+	}
+}
+
+func TestHandleRunning_JobGarbageCollected(t *testing.T) {
+	ns := "helios-automation"
+	tests := []struct {
+		name      string
+		steps     []heliosv1alpha1.StepStatus
+		wantPhase heliosv1alpha1.ExecutionPhase
+	}{
+		{"all steps completed", []heliosv1alpha1.StepStatus{heliosv1alpha1.StepCompleted, heliosv1alpha1.StepCompleted}, heliosv1alpha1.PhaseCompleted},
+		{"step failed", []heliosv1alpha1.StepStatus{heliosv1alpha1.StepCompleted, heliosv1alpha1.StepFailed}, heliosv1alpha1.PhaseFailed},
+		{"no step statuses", nil, heliosv1alpha1.PhaseFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runbook := &heliosv1alpha1.Runbook{
+				ObjectMeta: metav1.ObjectMeta{Name: "interface-bounce", Namespace: ns},
+				Spec:       heliosv1alpha1.RunbookSpec{Name: "interface-bounce"},
+			}
+			started := metav1.NewTime(time.Now().Add(-2 * time.Hour))
+			// The Job was created, but has since been removed by its TTL.
+			current := &heliosv1alpha1.RunbookExecution{
+				ObjectMeta: metav1.ObjectMeta{Name: "current", Namespace: ns},
+				Spec: heliosv1alpha1.RunbookExecutionSpec{
+					RunbookRef: heliosv1alpha1.RunbookRef{Name: "interface-bounce"},
+				},
+				Status: heliosv1alpha1.RunbookExecutionStatus{
+					Phase:     heliosv1alpha1.PhaseRunning,
+					StartTime: &started,
+					JobName:   "current-executor",
+				},
+			}
+			for i, status := range tt.steps {
+				current.Status.Steps = append(current.Status.Steps, heliosv1alpha1.ExecutionStepStatus{
+					Name: fmt.Sprintf("step-%d", i), Status: status,
+				})
+			}
+
+			c := newFakeClient(t, runbook, current)
+			r := &RunbookExecutionReconciler{Client: c, Scheme: c.Scheme(), Log: testLogger()}
+
+			ctx := context.Background()
+			var exec heliosv1alpha1.RunbookExecution
+			if err := c.Get(ctx, types.NamespacedName{Name: "current", Namespace: ns}, &exec); err != nil {
+				t.Fatalf("failed to get execution: %v", err)
+			}
+			if _, err := r.handleRunning(ctx, testLogger(), &exec); err != nil {
+				t.Fatalf("handleRunning() error = %v", err)
+			}
+
+			if exec.Status.Phase != tt.wantPhase {
+				t.Errorf("phase = %q, want %q", exec.Status.Phase, tt.wantPhase)
+			}
+			err := c.Get(ctx, types.NamespacedName{Name: "current-executor", Namespace: ns}, &batchv1.Job{})
+			if !apierrors.IsNotFound(err) {
+				t.Errorf("executor job recreated (err = %v)", err)
+			}
+		})
+	}
+}
+
+func TestHandleRollingBack_JobGarbageCollected(t *testing.T) {
+	ns := "helios-automation"
+	tests := []struct {
+		name      string
+		rollback  []heliosv1alpha1.StepStatus
+		wantPhase heliosv1alpha1.ExecutionPhase
+	}{
+		{"all rollback steps completed", []heliosv1alpha1.StepStatus{heliosv1alpha1.StepCompleted}, heliosv1alpha1.PhaseRolledBack},
+		{"rollback step failed", []heliosv1alpha1.StepStatus{heliosv1alpha1.StepFailed}, heliosv1alpha1.PhaseFailed},
+		{"no rollback step statuses", nil, heliosv1alpha1.PhaseFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runbook := &heliosv1alpha1.Runbook{
+				ObjectMeta: metav1.ObjectMeta{Name: "interface-bounce", Namespace: ns},
+				Spec: heliosv1alpha1.RunbookSpec{
+					Name:     "interface-bounce",
+					Rollback: []heliosv1alpha1.RunbookStep{{Name: "enable", Action: heliosv1alpha1.ActionGNMISet}},
+				},
+			}
+			started := metav1.NewTime(time.Now().Add(-2 * time.Hour))
+			// The rollback Job was created, but has since been removed by its TTL.
+			current := &heliosv1alpha1.RunbookExecution{
+				ObjectMeta: metav1.ObjectMeta{Name: "current", Namespace: ns},
+				Spec: heliosv1alpha1.RunbookExecutionSpec{
+					RunbookRef: heliosv1alpha1.RunbookRef{Name: "interface-bounce"},
+				},
+				Status: heliosv1alpha1.RunbookExecutionStatus{
+					Phase:     heliosv1alpha1.PhaseRollingBack,
+					StartTime: &started,
+					JobName:   "current-rollback",
+					Steps: []heliosv1alpha1.ExecutionStepStatus{
+						{Name: "disable", Status: heliosv1alpha1.StepFailed},
+					},
+				},
+			}
+			for _, status := range tt.rollback {
+				current.Status.Steps = append(current.Status.Steps, heliosv1alpha1.ExecutionStepStatus{
+					Name: "rollback/enable", Status: status,
+				})
+			}
+
+			c := newFakeClient(t, runbook, current)
+			r := &RunbookExecutionReconciler{Client: c, Scheme: c.Scheme(), Log: testLogger()}
+
+			ctx := context.Background()
+			var exec heliosv1alpha1.RunbookExecution
+			if err := c.Get(ctx, types.NamespacedName{Name: "current", Namespace: ns}, &exec); err != nil {
+				t.Fatalf("failed to get execution: %v", err)
+			}
+			if _, err := r.handleRollingBack(ctx, testLogger(), &exec); err != nil {
+				t.Fatalf("handleRollingBack() error = %v", err)
+			}
+
+			if exec.Status.Phase != tt.wantPhase {
+				t.Errorf("phase = %q, want %q", exec.Status.Phase, tt.wantPhase)
+			}
+			err := c.Get(ctx, types.NamespacedName{Name: "current-rollback", Namespace: ns}, &batchv1.Job{})
+			if !apierrors.IsNotFound(err) {
+				t.Errorf("rollback job recreated (err = %v)", err)
+			}
+		})
+	}
+}
+
+func TestHandleRollingBack_RecordsJobName(t *testing.T) {
+	ns := "helios-automation"
+	runbook := &heliosv1alpha1.Runbook{
+		ObjectMeta: metav1.ObjectMeta{Name: "interface-bounce", Namespace: ns},
+		Spec: heliosv1alpha1.RunbookSpec{
+			Name:     "interface-bounce",
+			Rollback: []heliosv1alpha1.RunbookStep{{Name: "enable", Action: heliosv1alpha1.ActionGNMISet}},
+		},
+	}
+	current := &heliosv1alpha1.RunbookExecution{
+		ObjectMeta: metav1.ObjectMeta{Name: "current", Namespace: ns},
+		Spec: heliosv1alpha1.RunbookExecutionSpec{
+			RunbookRef: heliosv1alpha1.RunbookRef{Name: "interface-bounce"},
+		},
+		Status: heliosv1alpha1.RunbookExecutionStatus{
+			Phase:   heliosv1alpha1.PhaseRollingBack,
+			JobName: "current-executor",
+		},
+	}
+
+	c := newFakeClient(t, runbook, current)
+	r := &RunbookExecutionReconciler{Client: c, Scheme: c.Scheme(), Log: testLogger()}
+
+	ctx := context.Background()
+	var exec heliosv1alpha1.RunbookExecution
+	if err := c.Get(ctx, types.NamespacedName{Name: "current", Namespace: ns}, &exec); err != nil {
+		t.Fatalf("failed to get execution: %v", err)
+	}
+	if _, err := r.handleRollingBack(ctx, testLogger(), &exec); err != nil {
+		t.Fatalf("handleRollingBack() error = %v", err)
+	}
+
+	if err := c.Get(ctx, types.NamespacedName{Name: "current-rollback", Namespace: ns}, &batchv1.Job{}); err != nil {
+		t.Fatalf("rollback job not created: %v", err)
+	}
+	var stored heliosv1alpha1.RunbookExecution
+	if err := c.Get(ctx, types.NamespacedName{Name: "current", Namespace: ns}, &stored); err != nil {
+		t.Fatalf("failed to get execution: %v", err)
+	}
+	if stored.Status.JobName != "current-rollback" {
+		t.Errorf("jobName = %q, want current-rollback", stored.Status.JobName)
+	}
+}
+
+func TestHandleFailed_Rollback(t *testing.T) {
+	ns := "helios-automation"
+	tests := []struct {
+		name       string
+		rolledBack bool
+		wantPhase  heliosv1alpha1.ExecutionPhase
+	}{
+		{"failed run rolls back", false, heliosv1alpha1.PhaseRollingBack},
+		{"failed rollback is terminal", true, heliosv1alpha1.PhaseFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runbook := &heliosv1alpha1.Runbook{
+				ObjectMeta: metav1.ObjectMeta{Name: "interface-bounce", Namespace: ns},
+				Spec: heliosv1alpha1.RunbookSpec{
+					Name:     "interface-bounce",
+					Rollback: []heliosv1alpha1.RunbookStep{{Name: "enable", Action: heliosv1alpha1.ActionGNMISet}},
+				},
+			}
+			current := &heliosv1alpha1.RunbookExecution{
+				ObjectMeta: metav1.ObjectMeta{Name: "current", Namespace: ns},
+				Spec: heliosv1alpha1.RunbookExecutionSpec{
+					RunbookRef: heliosv1alpha1.RunbookRef{Name: "interface-bounce"},
+				},
+				Status: heliosv1alpha1.RunbookExecutionStatus{Phase: heliosv1alpha1.PhaseFailed},
+			}
+			if tt.rolledBack {
+				meta.SetStatusCondition(&current.Status.Conditions, metav1.Condition{
+					Type:   string(heliosv1alpha1.PhaseRollingBack),
+					Status: metav1.ConditionTrue,
+					Reason: string(heliosv1alpha1.PhaseRollingBack),
+				})
+			}
+
+			c := newFakeClient(t, runbook, current)
+			r := &RunbookExecutionReconciler{Client: c, Log: testLogger()}
+
+			ctx := context.Background()
+			var exec heliosv1alpha1.RunbookExecution
+			if err := c.Get(ctx, types.NamespacedName{Name: "current", Namespace: ns}, &exec); err != nil {
+				t.Fatalf("failed to get execution: %v", err)
+			}
+			if _, err := r.handleFailed(ctx, testLogger(), &exec); err != nil {
+				t.Fatalf("handleFailed() error = %v", err)
+			}
+
+			if exec.Status.Phase != tt.wantPhase {
+				t.Errorf("phase = %q, want %q", exec.Status.Phase, tt.wantPhase)
+			}
+			if tt.rolledBack && exec.Status.CompletionTime == nil {
+				t.Error("completionTime not set on a failed rollback")
+			}
+		})
+	}
+}
+
+func counterValue(t *testing.T, c prometheus.Counter) float64 {
+	t.Helper()
+	var m dto.Metric
+	if err := c.Write(&m); err != nil {
+		t.Fatalf("failed to read counter: %v", err)
+	}
+	return m.GetCounter().GetValue()
+}
+
+func TestSetPhase_Metrics(t *testing.T) {
+	ns := "helios-automation"
+	current := &heliosv1alpha1.RunbookExecution{
+		ObjectMeta: metav1.ObjectMeta{Name: "metrics-exec", Namespace: ns},
+		Spec: heliosv1alpha1.RunbookExecutionSpec{
+			RunbookRef: heliosv1alpha1.RunbookRef{Name: "metrics-runbook"},
+		},
+		Status: heliosv1alpha1.RunbookExecutionStatus{
+			Phase: heliosv1alpha1.PhasePending,
+		},
+	}
+
+	c := newFakeClient(t, current)
+	r := &RunbookExecutionReconciler{Client: c, Log: testLogger()}
+
+	ctx := context.Background()
+	var exec heliosv1alpha1.RunbookExecution
+	if err := c.Get(ctx, types.NamespacedName{Name: "metrics-exec", Namespace: ns}, &exec); err != nil {
+		t.Fatalf("failed to get execution: %v", err)
+	}
+
+	running := metrics.ExecutionsTotal.WithLabelValues("metrics-runbook", string(heliosv1alpha1.PhaseRunning))
+	before := counterValue(t, running)
+	if err := r.setPhase(ctx, &exec, heliosv1alpha1.PhaseRunning, "Starting execution"); err != nil {
+		t.Fatalf("setPhase() error = %v", err)
+	}
+	if got := counterValue(t, running) - before; got != 1 {
+		t.Errorf("executions counter increased by %v, want 1", got)
+	}
+}
+
+func TestCreateExecutorJob_TTLAndOwner(t *testing.T) {
+	ns := "helios-automation"
+	current := &heliosv1alpha1.RunbookExecution{
+		ObjectMeta: metav1.ObjectMeta{Name: "current", Namespace: ns, UID: "exec-uid"},
+		Spec: heliosv1alpha1.RunbookExecutionSpec{
+			RunbookRef: heliosv1alpha1.RunbookRef{Name: "interface-bounce"},
+		},
+	}
+
+	c := newFakeClient(t, current)
+	r := &RunbookExecutionReconciler{Client: c, Scheme: c.Scheme(), Log: testLogger(), JobTTL: 10 * time.Minute}
+
+	ctx := context.Background()
+	if err := r.createExecutorJob(ctx, current, "current-executor", false); err != nil {
+		t.Fatalf("createExecutorJob() error = %v", err)
+	}
+
+	var job batchv1.Job
+	if err := c.Get(ctx, types.NamespacedName{Name: "current-executor", Namespace: ns}, &job); err != nil {
+		t.Fatalf("failed to get job: %v", err)
+	}
+	if job.Spec.TTLSecondsAfterFinished == nil || *job.Spec.TTLSecondsAfterFinished != 600 {
+		t.Errorf("ttlSecondsAfterFinished = %v, want 600", job.Spec.TTLSecondsAfterFinished)
+	}
+	if ref := metav1.GetControllerOf(&job); ref == nil || ref.Name != "current" {
+		t.Errorf("controller reference = %v, want execution current", ref)
+	}
+}
+
+func TestReconcile_DeletesStaleJobs(t *testing.T) {
+	ns := "helios-automation"
+	current := &heliosv1alpha1.RunbookExecution{
+		ObjectMeta: metav1.ObjectMeta{Name: "current", Namespace: ns},
+		Spec: heliosv1alpha1.RunbookExecutionSpec{
+			RunbookRef: heliosv1alpha1.RunbookRef{Name: "interface-bounce"},
+		},
+		Status: heliosv1alpha1.RunbookExecutionStatus{
+			Phase: heliosv1alpha1.PhaseCompleted,
+		},
+	}
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "current-executor",
+			Namespace: ns,
+			Labels:    map[string]string{"app.kubernetes.io/instance": "current"},
+		},
+		Status: batchv1.JobStatus{Succeeded: 1},
+	}
+
+	c := newFakeClient(t, current, job)
+	r := &RunbookExecutionReconciler{Client: c, Log: testLogger()}
+
+	ctx := context.Background()
+	if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: "current", Namespace: ns}}); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	err := c.Get(ctx, types.NamespacedName{Name: "current-executor", Namespace: ns}, &batchv1.Job{})
+	if !apierrors.IsNotFound(err) {
+		t.Errorf("stale job still present (err = %v)", err)
+	}
+}
+
+func TestHandlePending_MaxConcurrent(t *testing.T) {
+	tests := []struct {
+		name          string
+		maxConcurrent int32
+		wantPhase     heliosv1alpha1.ExecutionPhase
+		wantRequeue   bool
+	}{
+		{
+			name:        "default limit blocks second execution",
+			wantPhase:   heliosv1alpha1.PhasePending,
+			wantRequeue: true,
+		},
+		{
+			name:          "higher limit allows second execution",
+			maxConcurrent: 2,
+			wantPhase:     heliosv1alpha1.PhaseRunning,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ns := "helios-automation"
+			runbook := &heliosv1alpha1.Runbook{
+				ObjectMeta: metav1.ObjectMeta{Name: "bgp-reset", Namespace: ns},
+				Spec: heliosv1alpha1.RunbookSpec{
+					Name:          "bgp-reset",
+					RiskLevel:     heliosv1alpha1.RiskHigh,
+					MaxConcurrent: tt.maxConcurrent,
+				},
+			}
+			running := &heliosv1alpha1.RunbookExecution{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "running",
+					Namespace: ns,
+					Labels:    map[string]string{RunbookLabel: "bgp-reset"},
+				},
+				Spec: heliosv1alpha1.RunbookExecutionSpec{
+					RunbookRef: heliosv1alpha1.RunbookRef{Name: "bgp-reset"},
+				},
+				Status: heliosv1alpha1.RunbookExecutionStatus{
+					Phase: heliosv1alpha1.PhaseRunning,
+				},
+			}
+			current := &heliosv1alpha1.RunbookExecution{
+				ObjectMeta: metav1.ObjectMeta{Name: "current", Namespace: ns},
+				Spec: heliosv1alpha1.RunbookExecutionSpec{
+					RunbookRef: heliosv1alpha1.RunbookRef{Name: "bgp-reset"},
+				},
+				Status: heliosv1alpha1.RunbookExecutionStatus{
+					Phase: heliosv1alpha1.PhasePending,
+				},
+			}
+
+			c := newFakeClient(t, runbook, running, current)
+			r := &RunbookExecutionReconciler{Client: c, Log: testLogger()}
+
+			ctx := context.Background()
+			var exec heliosv1alpha1.RunbookExecution
+			if err := c.Get(ctx, types.NamespacedName{Name: "current", Namespace: ns}, &exec); err != nil {
+				t.Fatalf("failed to get execution: %v", err)
+			}
+			result, err := r.handlePending(ctx, testLogger(), &exec)
+			if err != nil {
+				t.Fatalf("handlePending() error = %v", err)
+			}
+
+			if exec.Status.Phase != tt.wantPhase {
+				t.Errorf("phase = %q, want %q", exec.Status.Phase, tt.wantPhase)
+			}
+			if (result.RequeueAfter > 0) != tt.wantRequeue {
+				t.Errorf("RequeueAfter = %v, wantRequeue %v", result.RequeueAfter, tt.wantRequeue)
+			}
+		})
+	}
+}
+
+func TestRunbookReconcile_Schedule(t *testing.T) {
+	lastRun := time.Date(2024, time.March, 15, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		now         time.Time
+		wantCreated bool
+		wantRequeue time.Duration
+	}{
+		{
+			name:        "due",
+			now:         lastRun.Add(7 * time.Minute),
+			wantCreated: true,
+			wantRequeue: 3 * time.Minute,
+		},
+		{
+			name:        "not yet due",
+			now:         lastRun.Add(3 * time.Minute),
+			wantRequeue: 2 * time.Minute,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ns := "helios-automation"
+			last := metav1.NewTime(lastRun)
+			runbook := &heliosv1alpha1.Runbook{
+				ObjectMeta: metav1.ObjectMeta{Name: "drain-check", Namespace: ns},
+				Spec: heliosv1alpha1.RunbookSpec{
+					Name:     "drain-check",
+					Schedule: "*/5 * * * *",
+					Steps: []heliosv1alpha1.RunbookStep{
+						{Name: "wait", Action: heliosv1alpha1.ActionWait},
+					},
+				},
+				Status: heliosv1alpha1.RunbookStatus{LastScheduleTime: &last},
+			}
+
+			c := newFakeClient(t, runbook)
+			r := &RunbookReconciler{Client: c, Log: testLogger(), clock: func() time.Time { return tt.now }}
+
+			ctx := context.Background()
+			key := types.NamespacedName{Name: "drain-check", Namespace: ns}
+			result, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+			if err != nil {
+				t.Fatalf("Reconcile() error = %v", err)
+			}
+			if result.RequeueAfter != tt.wantRequeue {
+				t.Errorf("RequeueAfter = %v, want %v", result.RequeueAfter, tt.wantRequeue)
+			}
+
+			var executions heliosv1alpha1.RunbookExecutionList
+			if err := c.List(ctx, &executions, client.InNamespace(ns)); err != nil {
+				t.Fatalf("failed to list executions: %v", err)
+			}
+			if !tt.wantCreated {
+				if len(executions.Items) != 0 {
+					t.Errorf("created %d executions, want none", len(executions.Items))
+				}
+				return
+			}
+			if len(executions.Items) != 1 {
+				t.Fatalf("created %d executions, want 1", len(executions.Items))
+			}
+			exec := executions.Items[0]
+			if exec.Spec.TriggerSource != heliosv1alpha1.TriggerScheduled {
+				t.Errorf("TriggerSource = %q, want scheduled", exec.Spec.TriggerSource)
+			}
+			if exec.Labels[RunbookLabel] != "drain-check" {
+				t.Errorf("label %s = %q, want drain-check", RunbookLabel, exec.Labels[RunbookLabel])
+			}
+
+			var updated heliosv1alpha1.Runbook
+			if err := c.Get(ctx, key, &updated); err != nil {
+				t.Fatalf("failed to get runbook: %v", err)
+			}
+			wantLast := lastRun.Add(5 * time.Minute)
+			if updated.Status.LastScheduleTime == nil || !updated.Status.LastScheduleTime.Time.Equal(wantLast) {
+				t.Errorf("LastScheduleTime = %v, want %v", updated.Status.LastScheduleTime, wantLast)
+			}
+		})
+	}
+}
+
+type stubGroupResolver struct {
+	groups []string
+	err    error
+}
+
+func (s stubGroupResolver) Groups(context.Context, *heliosv1alpha1.RunbookExecution, string) ([]string, error) {
+	return s.groups, s.err
+}
+
+func TestAuthorizeApprover(t *testing.T) {
+	groupApprovers := []heliosv1alpha1.Approver{
+		{Type: "user", Name: "noc-lead@example.com"},
+		{Type: "group", Name: "noc-leads"},
+	}
+
+	tests := []struct {
+		name       string
+		approvers  []heliosv1alpha1.Approver
+		resolver   GroupResolver
+		approvedBy string
+		wantErr    bool
+	}{
+		{name: "no approvers configured", approvedBy: "anyone@example.com"},
+		{
+			name:       "listed user",
+			approvers:  []heliosv1alpha1.Approver{{Type: "user", Name: "noc-lead@example.com"}},
+			approvedBy: "noc-lead@example.com",
+		},
+		{
+			name:       "unlisted user",
+			approvers:  []heliosv1alpha1.Approver{{Type: "user", Name: "noc-lead@example.com"}},
+			approvedBy: "intern@example.com",
+			wantErr:    true,
+		},
+		{
+			name:       "user in approver group",
+			approvers:  groupApprovers,
+			resolver:   stubGroupResolver{groups: []string{"engineering", "noc-leads"}},
+			approvedBy: "someone@example.com",
+		},
+		{
+			name:       "user not in approver group",
+			approvers:  groupApprovers,
+			resolver:   stubGroupResolver{groups: []string{"engineering"}},
+			approvedBy: "someone@example.com",
+			wantErr:    true,
+		},
+		{
+			name:       "group name is not a user name",
+			approvers:  []heliosv1alpha1.Approver{{Type: "group", Name: "noc-leads"}},
+			resolver:   stubGroupResolver{},
+			approvedBy: "noc-leads",
+			wantErr:    true,
+		},
+		{
+			name:       "listed user skips group resolution",
+			approvers:  groupApprovers,
+			resolver:   stubGroupResolver{err: errors.New("directory unavailable")},
+			approvedBy: "noc-lead@example.com",
+		},
+		{
+			name:       "resolver error",
+			approvers:  groupApprovers,
+			resolver:   stubGroupResolver{err: errors.New("directory unavailable")},
+			approvedBy: "someone@example.com",
+			wantErr:    true,
+		},
+		{
+			name:       "group approver without resolver",
+			approvers:  groupApprovers,
+			approvedBy: "someone@example.com",
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runbook := &heliosv1alpha1.Runbook{Spec: heliosv1alpha1.RunbookSpec{Approvers: tt.approvers}}
+			exec := &heliosv1alpha1.RunbookExecution{}
+			err := authorizeApprover(context.Background(), tt.resolver, exec, runbook, tt.approvedBy)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("authorizeApprover() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAnnotationGroupResolver(t *testing.T) {
+	exec := &heliosv1alpha1.RunbookExecution{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{ApprovedByGroupsAnnotation: "engineering, noc-leads,"},
+		},
+	}
+	groups, err := AnnotationGroupResolver{}.Groups(context.Background(), exec, "someone@example.com")
+	if err != nil {
+		t.Fatalf("Groups() error = %v", err)
+	}
+	if len(groups) != 2 || groups[0] != "engineering" || groups[1] != "noc-leads" {
+		t.Errorf("Groups() = %v, want [engineering noc-leads]", groups)
+	}
+}
+
+func TestHandlePendingApproval_GroupMember(t *testing.T) {
+	ns := "helios-automation"
+	runbook := &heliosv1alpha1.Runbook{
+		ObjectMeta: metav1.ObjectMeta{Name: "bgp-reset", Namespace: ns},
+		Spec: heliosv1alpha1.RunbookSpec{
+			Name:             "bgp-reset",
+			RequiresApproval: true,
+			Approvers:        []heliosv1alpha1.Approver{{Type: "group", Name: "noc-leads"}},
+		},
+	}
+	current := &heliosv1alpha1.RunbookExecution{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "current",
+			Namespace:         ns,
+			CreationTimestamp: metav1.Now(),
+			Annotations:       map[string]string{ApprovedByGroupsAnnotation: "noc-leads"},
+		},
+		Spec: heliosv1alpha1.RunbookExecutionSpec{
+			RunbookRef:  heliosv1alpha1.RunbookRef{Name: "bgp-reset"},
+			TriggeredBy: "operator@example.com",
+		},
+		Status: heliosv1alpha1.RunbookExecutionStatus{
+			Phase:      heliosv1alpha1.PhasePendingApproval,
+			ApprovedBy: "noc-lead@example.com",
+		},
+	}
+
+	c := newFakeClient(t, runbook, current)
+	r := &RunbookExecutionReconciler{Client: c, Log: testLogger(), GroupResolver: AnnotationGroupResolver{}}
+
+	ctx := context.Background()
+	var exec heliosv1alpha1.RunbookExecution
+	if err := c.Get(ctx, types.NamespacedName{Name: "current", Namespace: ns}, &exec); err != nil {
+		t.Fatalf("failed to get execution: %v", err)
+	}
+	if _, err := r.handlePendingApproval(ctx, testLogger(), &exec); err != nil {
+		t.Fatalf("handlePendingApproval() error = %v", err)
+	}
+	if exec.Status.Phase != heliosv1alpha1.PhaseApproved {
+		t.Errorf("phase = %q, want Approved", exec.Status.Phase)
+	}
+}
+
+func TestHandlePendingApproval_RejectsUnlistedApprover(t *testing.T) {
+	ns := "helios-automation"
+	runbook := &heliosv1alpha1.Runbook{
+		ObjectMeta: metav1.ObjectMeta{Name: "bgp-reset", Namespace: ns},
+		Spec: heliosv1alpha1.RunbookSpec{
+			Name:             "bgp-reset",
+			RequiresApproval: true,
+			Approvers:        []heliosv1alpha1.Approver{{Type: "user", Name: "noc-lead@example.com"}},
+		},
+	}
+	current := &heliosv1alpha1.RunbookExecution{
+		ObjectMeta: metav1.ObjectMeta{Name: "current", Namespace: ns, CreationTimestamp: metav1.Now()},
+		Spec: heliosv1alpha1.RunbookExecutionSpec{
+			RunbookRef:  heliosv1alpha1.RunbookRef{Name: "bgp-reset"},
+			TriggeredBy: "operator@example.com",
+		},
+		Status: heliosv1alpha1.RunbookExecutionStatus{
+			Phase:      heliosv1alpha1.PhasePendingApproval,
+			ApprovedBy: "intern@example.com",
+		},
+	}
+
+	c := newFakeClient(t, runbook, current)
+	r := &RunbookExecutionReconciler{Client: c, Log: testLogger(), Audit: audit.NewLogger(testLogger())}
+
+	ctx := context.Background()
+	var exec heliosv1alpha1.RunbookExecution
+	if err := c.Get(ctx, types.NamespacedName{Name: "current", Namespace: ns}, &exec); err != nil {
+		t.Fatalf("failed to get execution: %v", err)
+	}
+	if _, err := r.handlePendingApproval(ctx, testLogger(), &exec); err != nil {
+		t.Fatalf("handlePendingApproval() error = %v", err)
+	}
+
+	if exec.Status.Phase != heliosv1alpha1.PhasePendingApproval {
+		t.Errorf("phase = %q, want PendingApproval", exec.Status.Phase)
+	}
+	if exec.Status.ApprovedBy != "" {
+		t.Errorf("ApprovedBy = %q, want it cleared", exec.Status.ApprovedBy)
+	}
+}
+
+type recordingSink struct {
+	events []audit.AuditEvent
+}
+
+func (s *recordingSink) Write(event audit.AuditEvent) error {
+	s.events = append(s.events, event)
+	return nil
+}
+
+func TestReconcile_ApprovalDenied(t *testing.T) {
+	ns := "helios-automation"
+	runbook := &heliosv1alpha1.Runbook{
+		ObjectMeta: metav1.ObjectMeta{Name: "bgp-reset", Namespace: ns},
+		Spec: heliosv1alpha1.RunbookSpec{
+			Name:             "bgp-reset",
+			RequiresApproval: true,
+			Approvers:        []heliosv1alpha1.Approver{{Type: "user", Name: "noc-lead@example.com"}},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		deniedBy  string
+		wantPhase heliosv1alpha1.ExecutionPhase
+		wantEvent bool
+	}{
+		{name: "approver denies", deniedBy: "noc-lead@example.com", wantPhase: heliosv1alpha1.PhaseCancelled, wantEvent: true},
+		{name: "non-approver denial ignored", deniedBy: "intern@example.com", wantPhase: heliosv1alpha1.PhasePendingApproval},
+		{name: "denial without identity ignored", wantPhase: heliosv1alpha1.PhasePendingApproval},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			current := &heliosv1alpha1.RunbookExecution{
+				ObjectMeta: metav1.ObjectMeta{Name: "current", Namespace: ns, CreationTimestamp: metav1.Now()},
+				Spec: heliosv1alpha1.RunbookExecutionSpec{
+					RunbookRef:  heliosv1alpha1.RunbookRef{Name: "bgp-reset"},
+					TriggeredBy: "operator@example.com",
+				},
+				Status: heliosv1alpha1.RunbookExecutionStatus{
+					Phase:            heliosv1alpha1.PhasePendingApproval,
+					ApprovalDecision: heliosv1alpha1.ApprovalDenied,
+					DeniedBy:         tt.deniedBy,
+					DenialReason:     "change freeze",
+				},
+			}
+
+			c := newFakeClient(t, runbook, current)
+			sink := &recordingSink{}
+			r := &RunbookExecutionReconciler{Client: c, Log: testLogger(), Audit: audit.NewLogger(testLogger(), sink)}
+
+			ctx := context.Background()
+			key := types.NamespacedName{Name: "current", Namespace: ns}
+			if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key}); err != nil {
+				t.Fatalf("Reconcile() error = %v", err)
+			}
+
+			var exec heliosv1alpha1.RunbookExecution
+			if err := c.Get(ctx, key, &exec); err != nil {
+				t.Fatalf("failed to get execution: %v", err)
+			}
+			if exec.Status.Phase != tt.wantPhase {
+				t.Errorf("phase = %q, want %q", exec.Status.Phase, tt.wantPhase)
+			}
+			if tt.wantPhase == heliosv1alpha1.PhasePendingApproval && exec.Status.ApprovalDecision != "" {
+				t.Errorf("ApprovalDecision = %q, want it cleared", exec.Status.ApprovalDecision)
+			}
+
+			denied := false
+			for _, e := range sink.events {
+				if e.EventType == audit.EventApprovalDenied && e.Details["reason"] == "change freeze" {
+					denied = true
+				}
+			}
+			if denied != tt.wantEvent {
+				t.Errorf("ApprovalDenied event recorded = %v, want %v", denied, tt.wantEvent)
+			}
+
+			var jobs batchv1.JobList
+			if err := c.List(ctx, &jobs, client.InNamespace(ns)); err != nil {
+				t.Fatalf("failed to list jobs: %v", err)
+			}
+			if len(jobs.Items) != 0 {
+				t.Errorf("created %d executor jobs, want none", len(jobs.Items))
+			}
+		})
+	}
+}
+
+func containsStr(s, substr string) bool {
+	return len(s) >= len(substr) && searchStr(s, substr)
+}
+
+func searchStr(s, substr string) bool {
+	for i := 0; i <= len(s)-len(substr); i++ {
+		if s[i:i+len(substr)] == substr {
+			return true
+		}
+	}
+	return false
+}
+
+func TestValidateParameters(t *testing.T) {
+	specs := []heliosv1alpha1.Parameter{
+		{Name: "device", Type: "device", Required: true},
+		{Name: "interface", Type: "interface", Required: true, Validation: `^Ethernet\d+(/\d+)?$`},
+		{Name: "mtu", Type: "integer", Default: float64(9214)},
+		{Name: "shutdown", Type: "boolean"},
+		{Name: "mode", Type: "select", Options: []string{"drain", "undrain"}, Default: "drain"},
+	}
+
+	tests := []struct {
+		name    string
+		params  map[string]interface{}
+		want    map[string]interface{}
+		wantErr string
+	}{
+		{
+			name:   "valid with defaults filled",
+			params: map[string]interface{}{"device": "spine1", "interface": "Ethernet1/1"},
+			want: map[string]interface{}{
+				"device": "spine1", "interface": "Ethernet1/1", "mtu": float64(9214), "mode": "drain",
+			},
+		},
+		{
+			name: "provided values win over defaults",
+			params: map[string]interface{}{
+				"device": "spine1", "interface": "Ethernet2", "mtu": int64(1500), "mode": "undrain", "shutdown": true,
+			},
+			want: map[string]interface{}{
+				"device": "spine1", "interface": "Ethernet2", "mtu": int64(1500), "mode": "undrain", "shutdown": true,
+			},
+		},
+		{
+			name:    "missing required parameter",
+			params:  map[string]interface{}{"interface": "Ethernet1"},
+			wantErr: `parameter "device" is required`,
+		},
+		{
+			name:    "integer type mismatch",
+			params:  map[string]interface{}{"device": "spine1", "interface": "Ethernet1", "mtu": "big"},
+			wantErr: `parameter "mtu" must be an integer`,
+		},
+		{
+			name:    "fractional integer",
+			params:  map[string]interface{}{"device": "spine1", "interface": "Ethernet1", "mtu": 1500.5},
+			wantErr: `parameter "mtu" must be an integer`,
+		},
+		{
+			name:    "boolean type mismatch",
+			params:  map[string]interface{}{"device": "spine1", "interface": "Ethernet1", "shutdown": "yes"},
+			wantErr: `parameter "shutdown" must be a boolean`,
+		},
+		{
+			name:    "string type mismatch",
+			params:  map[string]interface{}{"device": float64(1), "interface": "Ethernet1"},
+			wantErr: `parameter "device" must be a string`,
+		},
+		{
+			name:    "regex failure",
+			params:  map[string]interface{}{"device": "spine1", "interface": "Management0"},
+			wantErr: `parameter "interface" value "Management0" does not match`,
+		},
+		{
+			name:    "value outside options",
+			params:  map[string]interface{}{"device": "spine1", "interface": "Ethernet1", "mode": "reboot"},
+			wantErr: `parameter "mode" value "reboot" is not one of [drain, undrain]`,
+		},
+		{
+			name:    "multiple problems reported together",
+			params:  map[string]interface{}{"mode": "reboot"},
+			wantErr: `parameter "device" is required; parameter "interface" is required; parameter "mode"`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ValidateParameters(specs, tc.params)
+			if tc.wantErr != "" {
+				if err == nil {
+					t.Fatalf("expected error containing %q, got nil", tc.wantErr)
+				}
+				if !containsStr(err.Error(), tc.wantErr) {
+					t.Errorf("error = %q, want to contain %q", err.Error(), tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(got) != len(tc.want) {
+				t.Fatalf("params = %v, want %v", got, tc.want)
+			}
+			for k, want := range tc.want {
+				if got[k] != want {
+					t.Errorf("params[%q] = %v (%T), want %v (%T)", k, got[k], got[k], want, want)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateParameters_InvalidPattern(t *testing.T) {
+	specs := []heliosv1alpha1.Parameter{{Name: "device", Type: "string", Validation: "["}}
+
+	_, err := ValidateParameters(specs, map[string]interface{}{"device": "spine1"})
+	if err == nil || !containsStr(err.Error(), "invalid validation pattern") {
+		t.Errorf("error = %v, want invalid validation pattern", err)
+	}
+}
+
+func TestValidateParameters_DoesNotMutateInput(t *testing.T) {
+	specs := []heliosv1alpha1.Parameter{{Name: "mtu", Type: "integer", Default: float64(9214)}}
+	params := map[string]interface{}{}
+
+	if _, err := ValidateParameters(specs, params); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := params["mtu"]; ok {
+		t.Error("ValidateParameters should not add defaults to the input map")
+	}
+}
+
+func TestPreviewExecution(t *testing.T) {
+	runbook := &heliosv1alpha1.Runbook{
+		Spec: heliosv1alpha1.RunbookSpec{
+			Steps: []heliosv1alpha1.RunbookStep{
+				{Name: "disable", Action: heliosv1alpha1.ActionGNMISet, Config: map[string]interface{}{
+					"target": "{{ .device }}",
+					"path":   "/interfaces/interface[name={{ .interface }}]/config/enabled",
+					"value":  false,
+				}},
+				{Name: "wait-up", Action: heliosv1alpha1.ActionGNMIWait, Config: map[string]interface{}{
+					"target":    "{{ .device }}",
+					"path":      "/interfaces/interface[name={{ .interface }}]/state/oper-status",
+					"condition": `{{ eq .value "UP" }}`,
+				}},
+			},
+			Rollback: []heliosv1alpha1.RunbookStep{
+				{Name: "enable", Action: heliosv1alpha1.ActionGNMISet, Config: map[string]interface{}{
+					"target": "{{ .device | upper }}",
+					"path":   "/interfaces/interface[name={{ .interface }}]/config/enabled",
+					"value":  true,
+				}},
+			},
+		},
+	}
+	params := map[string]interface{}{"device": "router1", "interface": "Ethernet1"}
+
+	previews, err := PreviewExecution(runbook, params)
+	if err != nil {
+		t.Fatalf("PreviewExecution() error = %v", err)
+	}
+
+	want := []StepPreview{
+		{Name: "disable", Action: heliosv1alpha1.ActionGNMISet, Config: map[string]interface{}{
+			"target": "router1",
+			"path":   "/interfaces/interface[name=Ethernet1]/config/enabled",
+			"value":  false,
+		}},
+		{Name: "wait-up", Action: heliosv1alpha1.ActionGNMIWait, Config: map[string]interface{}{
+			"target":    "router1",
+			"path":      "/interfaces/interface[name=Ethernet1]/state/oper-status",
+			"condition": `{{ eq .value "UP" }}`,
+		}},
+		{Name: "rollback/enable", Action: heliosv1alpha1.ActionGNMISet, Config: map[string]interface{}{
+			"target": "ROUTER1",
+			"path":   "/interfaces/interface[name=Ethernet1]/config/enabled",
+			"value":  true,
+		}},
+	}
+	if !reflect.DeepEqual(previews, want) {
+		t.Errorf("previews = %+v, want %+v", previews, want)
+	}
+}
+
+func TestPreviewExecution_TemplateErrors(t *testing.T) {
+	runbook := &heliosv1alpha1.Runbook{
+		Spec: heliosv1alpha1.RunbookSpec{
+			StrictTemplates: true,
+			Steps: []heliosv1alpha1.RunbookStep{
+				{Name: "ok", Action: heliosv1alpha1.ActionGNMIGet, Config: map[string]interface{}{
+					"target": "{{ .device }}", "path": "/system",
+				}},
+				{Name: "unclosed", Action: heliosv1alpha1.ActionGNMIGet, Config: map[string]interface{}{
+					"target": "{{ .device", "path": "/system",
+				}},
+				{Name: "missing-param", Action: heliosv1alpha1.ActionGNMIGet, Config: map[string]interface{}{
+					"target": "{{ .devcie }}", "path": "/system",
+				}},
+				{Name: "bad-condition", Action: heliosv1alpha1.ActionGNMIWait, Config: map[string]interface{}{
+					"target": "{{ .device }}", "path": "/system", "condition": "{{ if }}",
+				}},
+			},
+		},
+	}
+
+	previews, err := PreviewExecution(runbook, map[string]interface{}{"device": "router1"})
+	if err == nil || !strings.Contains(err.Error(), "3 of 4 steps failed") {
+		t.Fatalf("error = %v, want 3 of 4 steps failed", err)
+	}
+	if len(previews) != 4 {
+		t.Fatalf("previews = %d, want 4", len(previews))
+	}
+	if previews[0].Error != "" || previews[0].Config["target"] != "router1" {
+		t.Errorf("valid step preview = %+v", previews[0])
+	}
+	for _, p := range previews[1:] {
+		if p.Error == "" {
+			t.Errorf("step %s: expected a template error", p.Name)
+		}
+		if p.Config != nil {
+			t.Errorf("step %s: config = %v, want none on error", p.Name, p.Config)
+		}
+	}
+	if !strings.Contains(previews[2].Error, "devcie") {
+		t.Errorf("missing parameter error = %q, want it to name the parameter", previews[2].Error)
 	}
-	return false
 }
@@ -0,0 +1,126 @@
+package controllers
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+
+	heliosv1alpha1 "github.com/rhwendt/helios/services/runbook-operator/api/v1alpha1"
+)
+
+// ValidateParameters checks execution parameters against the runbook's
+// parameter schema. It returns the effective parameters, with schema
+// defaults filled in for any that were omitted.
+func ValidateParameters(specs []heliosv1alpha1.Parameter, values map[string]interface{}) (map[string]interface{}, error) {
+	effective := make(map[string]interface{}, len(values)+len(specs))
+	for k, v := range values {
+		effective[k] = v
+	}
+
+	var problems []string
+	for _, spec := range specs {
+		val, ok := values[spec.Name]
+		if !ok || val == nil {
+			switch {
+			case spec.Default != nil:
+				effective[spec.Name] = spec.Default
+			case spec.Required:
+				problems = append(problems, fmt.Sprintf("parameter %q is required", spec.Name))
+			}
+			continue
+		}
+
+		if err := validateParameter(spec, val); err != nil {
+			problems = append(problems, fmt.Sprintf("parameter %q %v", spec.Name, err))
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("invalid parameters: %s", strings.Join(problems, "; "))
+	}
+	return effective, nil
+}
+
+// validateParameterSpecs checks a runbook's parameter definitions: names
+// must be unique, patterns must compile, select parameters need options and
+// defaults must satisfy their own definition.
+func validateParameterSpecs(specs []heliosv1alpha1.Parameter) error {
+	seen := make(map[string]bool, len(specs))
+	for i, spec := range specs {
+		if spec.Name == "" {
+			return fmt.Errorf("parameter %d: name is required", i)
+		}
+		if seen[spec.Name] {
+			return fmt.Errorf("parameter %q is defined more than once", spec.Name)
+		}
+		seen[spec.Name] = true
+
+		if spec.Validation != "" {
+			if _, err := regexp.Compile(spec.Validation); err != nil {
+				return fmt.Errorf("parameter %q has an invalid validation pattern %q: %v", spec.Name, spec.Validation, err)
+			}
+		}
+		if spec.Type == "select" && len(spec.Options) == 0 {
+			return fmt.Errorf("parameter %q: select parameters require options", spec.Name)
+		}
+		if spec.Default != nil {
+			if err := validateParameter(spec, spec.Default); err != nil {
+				return fmt.Errorf("parameter %q default %v", spec.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func validateParameter(spec heliosv1alpha1.Parameter, val interface{}) error {
+	switch spec.Type {
+	case "integer":
+		if !isInteger(val) {
+			return fmt.Errorf("must be an integer, got %T", val)
+		}
+	case "boolean":
+		if _, ok := val.(bool); !ok {
+			return fmt.Errorf("must be a boolean, got %T", val)
+		}
+	default:
+		// string, device, interface and select are all carried as strings.
+		if _, ok := val.(string); !ok {
+			return fmt.Errorf("must be a string, got %T", val)
+		}
+	}
+
+	str := fmt.Sprint(val)
+	if spec.Validation != "" {
+		re, err := regexp.Compile(spec.Validation)
+		if err != nil {
+			return fmt.Errorf("has an invalid validation pattern %q: %v", spec.Validation, err)
+		}
+		if !re.MatchString(str) {
+			return fmt.Errorf("value %q does not match %q", str, spec.Validation)
+		}
+	}
+
+	if len(spec.Options) > 0 {
+		for _, opt := range spec.Options {
+			if opt == str {
+				return nil
+			}
+		}
+		return fmt.Errorf("value %q is not one of [%s]", str, strings.Join(spec.Options, ", "))
+	}
+	return nil
+}
+
+// isInteger accepts the integer representations produced by JSON and
+// Kubernetes decoding.
+func isInteger(val interface{}) bool {
+	switch v := val.(type) {
+	case int, int32, int64:
+		return true
+	case float64:
+		return v == math.Trunc(v) && !math.IsInf(v, 0)
+	default:
+		return false
+	}
+}
@@ -0,0 +1,98 @@
+package controllers
+
+import (
+	"fmt"
+
+	heliosv1alpha1 "github.com/rhwendt/helios/services/runbook-operator/api/v1alpha1"
+	"github.com/rhwendt/helios/services/runbook-operator/pkg/template"
+)
+
+// StepPreview is the rendered config of a single runbook step.
+type StepPreview struct {
+	Name   string                    `json:"name"`
+	Action heliosv1alpha1.StepAction `json:"action"`
+	Config map[string]interface{}    `json:"config,omitempty"`
+	Error  string                    `json:"error,omitempty"`
+}
+
+// PreviewExecution renders the config of every step, then every rollback
+// step, of runbook with params, without contacting any device. Configs are
+// rendered by RenderStepConfig, as the executor renders them. params should
+// already include parameter defaults. A step whose templates fail to render
+// carries the error in its preview, and the returned error reports how many
+// steps failed.
+//
+// Values only known at run time cannot be previewed: a gnmi_wait condition
+// is checked for syntax but not rendered, and references to registered
+// outputs of earlier steps render empty, or fail with strict templates.
+func PreviewExecution(runbook *heliosv1alpha1.Runbook, params map[string]interface{}) ([]StepPreview, error) {
+	var opts []template.EngineOption
+	if runbook.Spec.StrictTemplates {
+		opts = append(opts, template.WithStrict())
+	}
+	e := template.NewEngine(opts...)
+
+	var previews []StepPreview
+	failed := 0
+	preview := func(name string, step heliosv1alpha1.RunbookStep) {
+		p := StepPreview{Name: name, Action: step.Action}
+		config, err := previewStep(e, step, params)
+		if err != nil {
+			p.Error = err.Error()
+			failed++
+		}
+		p.Config = config
+		previews = append(previews, p)
+	}
+	for _, step := range runbook.Spec.Steps {
+		preview(step.Name, step)
+	}
+	for _, step := range runbook.Spec.Rollback {
+		preview("rollback/"+step.Name, step)
+	}
+
+	if failed > 0 {
+		return previews, fmt.Errorf("%d of %d steps failed to render", failed, len(previews))
+	}
+	return previews, nil
+}
+
+func previewStep(e *template.Engine, step heliosv1alpha1.RunbookStep, params map[string]interface{}) (map[string]interface{}, error) {
+	if step.Condition != "" {
+		if err := e.Validate(step.Condition); err != nil {
+			return nil, fmt.Errorf("step condition: %w", err)
+		}
+	}
+	return RenderStepConfig(e, step, params)
+}
+
+// RenderStepConfig renders step's config with params. It is shared by the
+// executor and PreviewExecution so that a preview shows exactly what a step
+// sends. A gnmi_wait condition depends on each polled value, so it is only
+// checked for syntax and returned unrendered.
+func RenderStepConfig(e *template.Engine, step heliosv1alpha1.RunbookStep, params map[string]interface{}) (map[string]interface{}, error) {
+	if step.Action != heliosv1alpha1.ActionGNMIWait {
+		return e.RenderConfig(step.Config, params)
+	}
+
+	condition, hasCondition := step.Config["condition"]
+	if s, _ := condition.(string); s != "" {
+		if err := e.Validate(s); err != nil {
+			return nil, fmt.Errorf("config key \"condition\": %w", err)
+		}
+	}
+	config := make(map[string]interface{}, len(step.Config))
+	for k, v := range step.Config {
+		if k != "condition" {
+			config[k] = v
+		}
+	}
+	rendered, err := e.RenderConfig(config, params)
+	if err != nil {
+		return nil, err
+	}
+	if hasCondition {
+		rendered["condition"] = condition
+	}
+	return rendered, nil
+}
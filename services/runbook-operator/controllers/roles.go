@@ -0,0 +1,68 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	heliosv1alpha1 "github.com/rhwendt/helios/services/runbook-operator/api/v1alpha1"
+)
+
+// TriggeredByRolesAnnotation lists, comma-separated, the roles held by the
+// identity in Spec.TriggeredBy. RunbookExecutionDefaulter stamps it from the
+// creator's Kubernetes groups; only the operator may set it directly.
+const TriggeredByRolesAnnotation = "helios.io/triggered-by-roles"
+
+// RoleResolver returns the roles held by the identity that triggered an
+// execution.
+type RoleResolver interface {
+	Roles(ctx context.Context, exec *heliosv1alpha1.RunbookExecution) ([]string, error)
+}
+
+// AnnotationRoleResolver resolves roles from the TriggeredByRolesAnnotation
+// on the execution. The annotation is only trustworthy when the
+// RunbookExecution webhook is installed to stamp it.
+type AnnotationRoleResolver struct{}
+
+// Roles implements RoleResolver.
+func (AnnotationRoleResolver) Roles(_ context.Context, exec *heliosv1alpha1.RunbookExecution) ([]string, error) {
+	return splitList(exec.Annotations[TriggeredByRolesAnnotation]), nil
+}
+
+// splitList splits a comma-separated annotation value, dropping blanks.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// authorizeTrigger checks that the triggering identity holds one of the
+// runbook's AllowedRoles. Runbooks without AllowedRoles may be run by anyone;
+// runbooks with AllowedRoles cannot be run at all without a resolver.
+func authorizeTrigger(ctx context.Context, resolver RoleResolver, exec *heliosv1alpha1.RunbookExecution, runbook *heliosv1alpha1.Runbook) error {
+	if len(runbook.Spec.AllowedRoles) == 0 {
+		return nil
+	}
+	if resolver == nil {
+		return fmt.Errorf("runbook %s requires one of roles %s, but no role resolver is configured",
+			runbook.Name, strings.Join(runbook.Spec.AllowedRoles, ", "))
+	}
+
+	roles, err := resolver.Roles(ctx, exec)
+	if err != nil {
+		return fmt.Errorf("failed to resolve roles for %q: %w", exec.Spec.TriggeredBy, err)
+	}
+	for _, role := range roles {
+		for _, allowed := range runbook.Spec.AllowedRoles {
+			if role == allowed {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("%q is not authorized to run runbook %s (requires one of: %s)",
+		exec.Spec.TriggeredBy, runbook.Name, strings.Join(runbook.Spec.AllowedRoles, ", "))
+}
@@ -2,8 +2,10 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"time"
 
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -19,10 +21,18 @@ type RunbookReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
 	Log    *slog.Logger
+
+	// EnableScriptAction permits script steps, which run arbitrary commands
+	// in the executor. Runbooks using them are rejected when it is unset.
+	EnableScriptAction bool
+
+	// clock overrides time.Now for schedule evaluation in tests.
+	clock func() time.Time
 }
 
 // +kubebuilder:rbac:groups=helios.io,resources=runbooks,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=helios.io,resources=runbooks/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=helios.io,resources=runbookexecutions,verbs=get;list;create
 
 func (r *RunbookReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	log := r.Log.With("runbook", req.NamespacedName)
@@ -35,10 +45,15 @@ func (r *RunbookReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 	// Validate runbook schema
 	if err := r.validateRunbook(&runbook); err != nil {
 		log.Error("runbook validation failed", "error", err)
+		reason := "ValidationFailed"
+		var cfgErr *stepConfigError
+		if errors.As(err, &cfgErr) {
+			reason = "InvalidStepConfig"
+		}
 		meta.SetStatusCondition(&runbook.Status.Conditions, metav1.Condition{
 			Type:               "Ready",
 			Status:             metav1.ConditionFalse,
-			Reason:             "ValidationFailed",
+			Reason:             reason,
 			Message:            err.Error(),
 			LastTransitionTime: metav1.Now(),
 		})
@@ -61,7 +76,7 @@ func (r *RunbookReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 	}
 
 	log.Info("runbook reconciled successfully", "category", runbook.Spec.Category)
-	return ctrl.Result{}, nil
+	return r.reconcileSchedule(ctx, log, &runbook)
 }
 
 func (r *RunbookReconciler) validateRunbook(rb *heliosv1alpha1.Runbook) error {
@@ -74,12 +89,49 @@ func (r *RunbookReconciler) validateRunbook(rb *heliosv1alpha1.Runbook) error {
 	if rb.Spec.RequiresApproval && len(rb.Spec.Approvers) == 0 {
 		return fmt.Errorf("approvers required when requiresApproval is true")
 	}
-	for i, step := range rb.Spec.Steps {
+	for i, a := range rb.Spec.Approvers {
+		if a.Type != "user" && a.Type != "group" {
+			return fmt.Errorf("approver %d: type must be user or group, got %q", i, a.Type)
+		}
+		if a.Name == "" {
+			return fmt.Errorf("approver %d: name is required", i)
+		}
+	}
+	if rb.Spec.ExecutionTimeout != "" {
+		if d, err := time.ParseDuration(rb.Spec.ExecutionTimeout); err != nil || d <= 0 {
+			return fmt.Errorf("invalid executionTimeout %q", rb.Spec.ExecutionTimeout)
+		}
+	}
+	if err := validateParameterSpecs(rb.Spec.Parameters); err != nil {
+		return err
+	}
+	if rb.Spec.Schedule != "" {
+		if err := validateSchedule(rb); err != nil {
+			return err
+		}
+	}
+	if err := r.validateSteps("step", rb.Spec.Steps); err != nil {
+		return err
+	}
+	return r.validateSteps("rollback step", rb.Spec.Rollback)
+}
+
+// validateSteps checks the name, action and config of each step, and that
+// script steps are enabled. kind prefixes errors so that rollback steps can
+// be told apart from the main steps.
+func (r *RunbookReconciler) validateSteps(kind string, steps []heliosv1alpha1.RunbookStep) error {
+	for i, step := range steps {
 		if step.Name == "" {
-			return fmt.Errorf("step %d: name is required", i)
+			return fmt.Errorf("%s %d: name is required", kind, i)
 		}
 		if step.Action == "" {
-			return fmt.Errorf("step %d: action is required", i)
+			return fmt.Errorf("%s %d: action is required", kind, i)
+		}
+		if step.Action == heliosv1alpha1.ActionScript && !r.EnableScriptAction {
+			return fmt.Errorf("%s %d: script action is disabled; set ENABLE_SCRIPT_ACTION to allow it", kind, i)
+		}
+		if err := validateStepConfig(kind, i, step); err != nil {
+			return err
 		}
 	}
 	return nil
@@ -0,0 +1,61 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	heliosv1alpha1 "github.com/rhwendt/helios/services/runbook-operator/api/v1alpha1"
+)
+
+// RunbookValidator rejects invalid Runbooks at admission, so that kubectl
+// apply fails immediately instead of leaving a Ready=False runbook behind.
+// It runs the same checks as the Runbook reconciler.
+type RunbookValidator struct {
+	// EnableScriptAction permits script steps, as on RunbookReconciler.
+	EnableScriptAction bool
+}
+
+var _ admission.CustomValidator = &RunbookValidator{}
+
+// +kubebuilder:webhook:path=/validate-helios-io-v1alpha1-runbook,mutating=false,failurePolicy=fail,sideEffects=None,groups=helios.io,resources=runbooks,verbs=create;update,versions=v1alpha1,name=vrunbook.helios.io,admissionReviewVersions=v1
+
+// SetupWebhookWithManager registers the validator with the manager's
+// webhook server.
+func (v *RunbookValidator) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&heliosv1alpha1.Runbook{}).
+		WithValidator(v).
+		Complete()
+}
+
+// ValidateCreate implements admission.CustomValidator.
+func (v *RunbookValidator) ValidateCreate(_ context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, v.validate(obj)
+}
+
+// ValidateUpdate implements admission.CustomValidator.
+func (v *RunbookValidator) ValidateUpdate(_ context.Context, _, newObj runtime.Object) (admission.Warnings, error) {
+	return nil, v.validate(newObj)
+}
+
+// ValidateDelete implements admission.CustomValidator. Deletes are always
+// allowed.
+func (v *RunbookValidator) ValidateDelete(context.Context, runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+func (v *RunbookValidator) validate(obj runtime.Object) error {
+	rb, ok := obj.(*heliosv1alpha1.Runbook)
+	if !ok {
+		return fmt.Errorf("expected a Runbook, got %T", obj)
+	}
+	r := &RunbookReconciler{EnableScriptAction: v.EnableScriptAction}
+	if err := r.validateRunbook(rb); err != nil {
+		return fmt.Errorf("invalid runbook %s: %w", rb.Name, err)
+	}
+	return nil
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"reflect"
+	"strings"
 	"time"
 
 	batchv1 "k8s.io/api/batch/v1"
@@ -16,14 +18,51 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	heliosv1alpha1 "github.com/rhwendt/helios/services/runbook-operator/api/v1alpha1"
+	"github.com/rhwendt/helios/services/runbook-operator/pkg/approval"
+	"github.com/rhwendt/helios/services/runbook-operator/pkg/audit"
+	"github.com/rhwendt/helios/services/runbook-operator/pkg/metrics"
 )
 
+// RunbookLabel is set on executions to the name of the runbook they run so
+// that executions of the same runbook can be listed together.
+const RunbookLabel = "helios.io/runbook"
+
+// CancelAnnotation requests cancellation of an execution when set to
+// "true", as an alternative to Spec.Cancel.
+const CancelAnnotation = "helios.io/cancel"
+
+// DefaultJobTTL is how long finished executor Jobs are kept before
+// Kubernetes garbage-collects them.
+const DefaultJobTTL = time.Hour
+
+// concurrencyRequeue is how often an execution held back by its runbook's
+// MaxConcurrent limit checks again for capacity.
+const concurrencyRequeue = 15 * time.Second
+
 // RunbookExecutionReconciler reconciles a RunbookExecution object.
 type RunbookExecutionReconciler struct {
 	client.Client
 	Scheme        *runtime.Scheme
 	Log           *slog.Logger
 	ExecutorImage string
+	// GNMICredentialsSecret names a Secret with "username" and "password"
+	// keys that is exposed to executor Jobs for gNMI authentication.
+	GNMICredentialsSecret string
+	// RoleResolver resolves the roles of the identity that triggered an
+	// execution. If nil, runbooks with AllowedRoles cannot be triggered.
+	RoleResolver RoleResolver
+	// GroupResolver resolves the groups of the identity that approved an
+	// execution, for group approvers. If nil, only user approvers match.
+	GroupResolver GroupResolver
+	// Approver, if set, notifies approvers when an execution awaits approval.
+	Approver *approval.Approver
+	// Audit, if set, records audit events for executions.
+	Audit *audit.Logger
+	// JobTTL is how long finished executor Jobs are kept. Defaults to
+	// DefaultJobTTL.
+	JobTTL time.Duration
+	// ExecutorEnv is added to the environment of every executor Job.
+	ExecutorEnv []corev1.EnvVar
 }
 
 // +kubebuilder:rbac:groups=helios.io,resources=runbookexecutions,verbs=get;list;watch;create;update;patch;delete
@@ -39,6 +78,10 @@ func (r *RunbookExecutionReconciler) Reconcile(ctx context.Context, req ctrl.Req
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
+	if cancelRequested(&execution) && cancellable(execution.Status.Phase) {
+		return r.handleCancel(ctx, log, &execution)
+	}
+
 	// State machine reconciliation
 	switch execution.Status.Phase {
 	case "", heliosv1alpha1.PhasePending:
@@ -55,8 +98,8 @@ func (r *RunbookExecutionReconciler) Reconcile(ctx context.Context, req ctrl.Req
 		return r.handleRollingBack(ctx, log, &execution)
 	case heliosv1alpha1.PhaseCompleted, heliosv1alpha1.PhaseCancelled,
 		heliosv1alpha1.PhaseTimedOut, heliosv1alpha1.PhaseRolledBack:
-		// Terminal states, no further reconciliation needed
-		return ctrl.Result{}, nil
+		// Terminal states only need their leftover Jobs cleaned up
+		return ctrl.Result{}, r.deleteStaleJobs(ctx, log, &execution)
 	default:
 		log.Warn("unknown phase", "phase", execution.Status.Phase)
 		return ctrl.Result{}, nil
@@ -70,32 +113,101 @@ func (r *RunbookExecutionReconciler) handlePending(ctx context.Context, log *slo
 		return ctrl.Result{}, r.setPhase(ctx, exec, heliosv1alpha1.PhaseFailed, fmt.Sprintf("failed to get runbook: %v", err))
 	}
 
+	params, err := ValidateParameters(runbook.Spec.Parameters, exec.Spec.Parameters)
+	if err != nil {
+		log.Info("rejecting execution with invalid parameters", "error", err)
+		return ctrl.Result{}, r.setPhase(ctx, exec, heliosv1alpha1.PhaseFailed, err.Error())
+	}
+
+	if err := authorizeTrigger(ctx, r.RoleResolver, exec, runbook); err != nil {
+		log.Warn("rejecting unauthorized execution", "triggeredBy", exec.Spec.TriggeredBy, "error", err)
+		return ctrl.Result{}, r.setPhase(ctx, exec, heliosv1alpha1.PhaseFailed, err.Error())
+	}
+
+	// Defaults are recorded on the execution so the executor runs with, and
+	// the execution shows, the values in effect when it was accepted.
+	update := false
+	if len(params) > 0 && !reflect.DeepEqual(params, exec.Spec.Parameters) {
+		exec.Spec.Parameters = params
+		update = true
+	}
+	if exec.Labels[RunbookLabel] != exec.Spec.RunbookRef.Name {
+		if exec.Labels == nil {
+			exec.Labels = make(map[string]string)
+		}
+		exec.Labels[RunbookLabel] = exec.Spec.RunbookRef.Name
+		update = true
+	}
+	if update {
+		if err := r.Update(ctx, exec); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	remaining, err := r.cooldownRemaining(ctx, exec, runbook)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if remaining > 0 {
+		log.Info("runbook in cooldown, cancelling execution", "remaining", remaining)
+		return ctrl.Result{}, r.setPhase(ctx, exec, heliosv1alpha1.PhaseCancelled,
+			fmt.Sprintf("Runbook %s is in cooldown for another %s", runbook.Name, remaining.Round(time.Second)))
+	}
+
 	// Check if runbook requires approval
 	if runbook.Spec.RequiresApproval {
 		log.Info("runbook requires approval, transitioning to PendingApproval")
+		if err := r.notifyApprovers(ctx, log, exec, runbook); err != nil {
+			// Retried from PendingApproval until it succeeds.
+			log.Warn("approval notification failed", "error", err)
+		}
 		return ctrl.Result{}, r.setPhase(ctx, exec, heliosv1alpha1.PhasePendingApproval, "Awaiting approval")
 	}
 
-	// No approval needed, transition to Running
+	// No approval needed, transition to Running once there is capacity
+	if blocked, err := r.concurrencyLimitReached(ctx, log, exec, runbook); err != nil || blocked {
+		return ctrl.Result{RequeueAfter: concurrencyRequeue}, err
+	}
 	now := metav1.Now()
 	exec.Status.StartTime = &now
 	return ctrl.Result{}, r.setPhase(ctx, exec, heliosv1alpha1.PhaseRunning, "Starting execution")
 }
 
 func (r *RunbookExecutionReconciler) handlePendingApproval(ctx context.Context, log *slog.Logger, exec *heliosv1alpha1.RunbookExecution) (ctrl.Result, error) {
+	runbook, err := r.getRunbook(ctx, exec)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// A denial (approvalDecision set externally) ends the execution.
+	if exec.Status.ApprovalDecision == heliosv1alpha1.ApprovalDenied {
+		return r.handleDenial(ctx, log, exec, runbook)
+	}
+
 	// Check if approved (approvedBy field set externally)
-	if exec.Status.ApprovedBy != "" {
-		log.Info("execution approved", "approvedBy", exec.Status.ApprovedBy)
+	if approvedBy := exec.Status.ApprovedBy; approvedBy != "" {
+		if err := authorizeApprover(ctx, r.GroupResolver, exec, runbook, approvedBy); err != nil {
+			log.Warn("rejecting approval", "approvedBy", approvedBy, "error", err)
+			if r.Audit != nil {
+				r.Audit.LogApprovalDenied(ctx, exec.Name, exec.Namespace, runbook.Name, exec.Spec.TriggeredBy, approvedBy, err.Error())
+			}
+			exec.Status.ApprovedBy = ""
+			exec.Status.ApprovedAt = nil
+			exec.Status.Message = fmt.Sprintf("Approval by %s rejected: %v", approvedBy, err)
+			return ctrl.Result{RequeueAfter: 30 * time.Second}, r.Status().Update(ctx, exec)
+		}
+
+		log.Info("execution approved", "approvedBy", approvedBy)
+		if r.Audit != nil {
+			r.Audit.LogApprovalGranted(ctx, exec.Name, exec.Namespace, runbook.Name, exec.Spec.TriggeredBy, approvedBy)
+		}
 		now := metav1.Now()
+		metrics.ApprovalWaitSeconds.WithLabelValues(exec.Spec.RunbookRef.Name).Observe(now.Sub(exec.CreationTimestamp.Time).Seconds())
 		exec.Status.StartTime = &now
 		return ctrl.Result{}, r.setPhase(ctx, exec, heliosv1alpha1.PhaseApproved, "Approved, starting execution")
 	}
 
 	// Check approval timeout
-	runbook, err := r.getRunbook(ctx, exec)
-	if err != nil {
-		return ctrl.Result{}, err
-	}
 	timeout, _ := time.ParseDuration(runbook.Spec.ApprovalTimeout)
 	if timeout == 0 {
 		timeout = time.Hour
@@ -105,10 +217,25 @@ func (r *RunbookExecutionReconciler) handlePendingApproval(ctx context.Context,
 		return ctrl.Result{}, r.setPhase(ctx, exec, heliosv1alpha1.PhaseTimedOut, "Approval timeout exceeded")
 	}
 
+	if r.Approver != nil && !meta.IsStatusConditionTrue(exec.Status.Conditions, ConditionApprovalNotified) {
+		if err := r.notifyApprovers(ctx, log, exec, runbook); err != nil {
+			log.Warn("approval notification failed", "error", err)
+		} else if err := r.Status().Update(ctx, exec); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
 	return ctrl.Result{RequeueAfter: 30 * time.Second}, nil
 }
 
 func (r *RunbookExecutionReconciler) handleApproved(ctx context.Context, log *slog.Logger, exec *heliosv1alpha1.RunbookExecution) (ctrl.Result, error) {
+	runbook, err := r.getRunbook(ctx, exec)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if blocked, err := r.concurrencyLimitReached(ctx, log, exec, runbook); err != nil || blocked {
+		return ctrl.Result{RequeueAfter: concurrencyRequeue}, err
+	}
 	now := metav1.Now()
 	exec.Status.StartTime = &now
 	return ctrl.Result{}, r.setPhase(ctx, exec, heliosv1alpha1.PhaseRunning, "Starting execution")
@@ -123,9 +250,27 @@ func (r *RunbookExecutionReconciler) handleRunning(ctx context.Context, log *slo
 		if client.IgnoreNotFound(err) != nil {
 			return ctrl.Result{}, err
 		}
+		// A Job that was already created may have been garbage-collected
+		// after its TTL, e.g. while the operator was down. Recreating it
+		// would run every step against the devices again, so the outcome
+		// is taken from the step statuses the executor recorded instead.
+		if exec.Status.JobName == jobName {
+			log.Warn("executor job no longer exists", "jobName", jobName)
+			if stepsCompleted(exec.Status.Steps) {
+				now := metav1.Now()
+				exec.Status.CompletionTime = &now
+				if exec.Status.StartTime != nil {
+					exec.Status.Duration = now.Sub(exec.Status.StartTime.Time).Round(time.Second).String()
+				}
+				return ctrl.Result{}, r.setPhase(ctx, exec, heliosv1alpha1.PhaseCompleted,
+					fmt.Sprintf("Executor job %s no longer exists; all steps had completed", jobName))
+			}
+			return ctrl.Result{}, r.setPhase(ctx, exec, heliosv1alpha1.PhaseFailed,
+				fmt.Sprintf("Executor job %s no longer exists; its result was not recorded", jobName))
+		}
 		// Create executor Job
 		log.Info("creating executor job", "jobName", jobName)
-		if err := r.createExecutorJob(ctx, exec, jobName); err != nil {
+		if err := r.createExecutorJob(ctx, exec, jobName, false); err != nil {
 			return ctrl.Result{}, err
 		}
 		exec.Status.JobName = jobName
@@ -142,31 +287,86 @@ func (r *RunbookExecutionReconciler) handleRunning(ctx context.Context, log *slo
 		return ctrl.Result{}, r.setPhase(ctx, exec, heliosv1alpha1.PhaseCompleted, "Execution completed successfully")
 	}
 	if job.Status.Failed > 0 {
+		for _, step := range exec.Status.Steps {
+			if step.Status == heliosv1alpha1.StepFailed {
+				metrics.StepFailuresTotal.WithLabelValues(exec.Spec.RunbookRef.Name).Inc()
+			}
+		}
 		return ctrl.Result{}, r.setPhase(ctx, exec, heliosv1alpha1.PhaseFailed, "Executor job failed")
 	}
 
+	timedOut, timeout, err := r.executionTimedOut(ctx, exec)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if timedOut {
+		log.Warn("execution timeout exceeded, deleting executor job", "timeout", timeout, "jobName", jobName)
+		if err := r.Delete(ctx, &job, client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to delete executor job: %w", err)
+		}
+		now := metav1.Now()
+		exec.Status.CompletionTime = &now
+		exec.Status.Duration = now.Sub(exec.Status.StartTime.Time).Round(time.Second).String()
+		return ctrl.Result{}, r.setPhase(ctx, exec, heliosv1alpha1.PhaseTimedOut, fmt.Sprintf("Execution timed out after %s", timeout))
+	}
+
 	return ctrl.Result{RequeueAfter: 10 * time.Second}, nil
 }
 
+// stepsCompleted reports whether the executor recorded every step as
+// completed.
+func stepsCompleted(steps []heliosv1alpha1.ExecutionStepStatus) bool {
+	if len(steps) == 0 {
+		return false
+	}
+	for _, step := range steps {
+		if step.Status != heliosv1alpha1.StepCompleted {
+			return false
+		}
+	}
+	return true
+}
+
+// rollbackSteps returns the statuses the executor recorded for rollback
+// steps.
+func rollbackSteps(steps []heliosv1alpha1.ExecutionStepStatus) []heliosv1alpha1.ExecutionStepStatus {
+	var out []heliosv1alpha1.ExecutionStepStatus
+	for _, step := range steps {
+		if strings.HasPrefix(step.Name, "rollback/") {
+			out = append(out, step)
+		}
+	}
+	return out
+}
+
 func (r *RunbookExecutionReconciler) handleFailed(ctx context.Context, log *slog.Logger, exec *heliosv1alpha1.RunbookExecution) (ctrl.Result, error) {
 	runbook, err := r.getRunbook(ctx, exec)
 	if err != nil {
 		return ctrl.Result{}, err
 	}
 
-	// If runbook has rollback steps, initiate rollback
-	if len(runbook.Spec.Rollback) > 0 {
+	// If runbook has rollback steps, initiate rollback. A failed rollback
+	// also ends in Failed, and is not rolled back again.
+	rolledBack := meta.IsStatusConditionTrue(exec.Status.Conditions, string(heliosv1alpha1.PhaseRollingBack))
+	if len(runbook.Spec.Rollback) > 0 && !rolledBack {
 		log.Info("initiating rollback")
 		return ctrl.Result{}, r.setPhase(ctx, exec, heliosv1alpha1.PhaseRollingBack, "Initiating rollback")
 	}
 
-	// No rollback defined, stay in Failed
+	// No rollback defined, or it already ran: stay in Failed
+	if exec.Status.CompletionTime != nil {
+		return ctrl.Result{}, nil
+	}
 	now := metav1.Now()
 	exec.Status.CompletionTime = &now
 	if exec.Status.StartTime != nil {
 		exec.Status.Duration = now.Sub(exec.Status.StartTime.Time).Round(time.Second).String()
 	}
-	return ctrl.Result{}, r.Status().Update(ctx, exec)
+	if err := r.Status().Update(ctx, exec); err != nil {
+		return ctrl.Result{}, err
+	}
+	observeDuration(exec)
+	return ctrl.Result{}, nil
 }
 
 func (r *RunbookExecutionReconciler) handleRollingBack(ctx context.Context, log *slog.Logger, exec *heliosv1alpha1.RunbookExecution) (ctrl.Result, error) {
@@ -178,11 +378,28 @@ func (r *RunbookExecutionReconciler) handleRollingBack(ctx context.Context, log
 		if client.IgnoreNotFound(err) != nil {
 			return ctrl.Result{}, err
 		}
+		// As in handleRunning, a rollback Job that was garbage-collected is
+		// not recreated; its outcome comes from the recorded step statuses.
+		if exec.Status.JobName == jobName {
+			log.Warn("rollback job no longer exists", "jobName", jobName)
+			if stepsCompleted(rollbackSteps(exec.Status.Steps)) {
+				now := metav1.Now()
+				exec.Status.CompletionTime = &now
+				if exec.Status.StartTime != nil {
+					exec.Status.Duration = now.Sub(exec.Status.StartTime.Time).Round(time.Second).String()
+				}
+				return ctrl.Result{}, r.setPhase(ctx, exec, heliosv1alpha1.PhaseRolledBack,
+					fmt.Sprintf("Rollback job %s no longer exists; all rollback steps had completed", jobName))
+			}
+			return ctrl.Result{}, r.setPhase(ctx, exec, heliosv1alpha1.PhaseFailed,
+				fmt.Sprintf("Rollback job %s no longer exists; its result was not recorded", jobName))
+		}
 		log.Info("creating rollback job", "jobName", jobName)
-		if err := r.createExecutorJob(ctx, exec, jobName); err != nil {
+		if err := r.createExecutorJob(ctx, exec, jobName, true); err != nil {
 			return ctrl.Result{}, err
 		}
-		return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
+		exec.Status.JobName = jobName
+		return ctrl.Result{RequeueAfter: 5 * time.Second}, r.Status().Update(ctx, exec)
 	}
 
 	if job.Status.Succeeded > 0 {
@@ -200,6 +417,168 @@ func (r *RunbookExecutionReconciler) handleRollingBack(ctx context.Context, log
 	return ctrl.Result{RequeueAfter: 10 * time.Second}, nil
 }
 
+func (r *RunbookExecutionReconciler) handleCancel(ctx context.Context, log *slog.Logger, exec *heliosv1alpha1.RunbookExecution) (ctrl.Result, error) {
+	if exec.Status.JobName != "" {
+		job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: exec.Status.JobName, Namespace: exec.Namespace}}
+		log.Info("deleting executor job for cancelled execution", "jobName", job.Name)
+		if err := r.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to delete executor job: %w", err)
+		}
+	}
+
+	now := metav1.Now()
+	exec.Status.CompletionTime = &now
+	if exec.Status.StartTime != nil {
+		exec.Status.Duration = now.Sub(exec.Status.StartTime.Time).Round(time.Second).String()
+	}
+	r.recordEvent(ctx, exec, audit.EventExecutionCancelled, fmt.Sprintf("Execution cancelled in phase %s", exec.Status.Phase))
+	return ctrl.Result{}, r.setPhase(ctx, exec, heliosv1alpha1.PhaseCancelled, "Execution cancelled")
+}
+
+// cancelRequested reports whether cancellation was requested through the
+// spec or the CancelAnnotation.
+func cancelRequested(exec *heliosv1alpha1.RunbookExecution) bool {
+	return exec.Spec.Cancel || exec.Annotations[CancelAnnotation] == "true"
+}
+
+// cancellable reports whether an execution in phase may still be cancelled.
+// Rollbacks are left to finish so devices are not left half-restored.
+func cancellable(phase heliosv1alpha1.ExecutionPhase) bool {
+	switch phase {
+	case "", heliosv1alpha1.PhasePending, heliosv1alpha1.PhasePendingApproval,
+		heliosv1alpha1.PhaseApproved, heliosv1alpha1.PhaseRunning:
+		return true
+	default:
+		return false
+	}
+}
+
+// deleteStaleJobs removes finished Jobs of a terminal execution that were
+// created without a TTL and would otherwise never be garbage-collected.
+func (r *RunbookExecutionReconciler) deleteStaleJobs(ctx context.Context, log *slog.Logger, exec *heliosv1alpha1.RunbookExecution) error {
+	var jobs batchv1.JobList
+	if err := r.List(ctx, &jobs,
+		client.InNamespace(exec.Namespace),
+		client.MatchingLabels{"app.kubernetes.io/instance": exec.Name},
+	); err != nil {
+		return fmt.Errorf("failed to list executor jobs: %w", err)
+	}
+
+	for i := range jobs.Items {
+		job := &jobs.Items[i]
+		if job.Spec.TTLSecondsAfterFinished != nil || (job.Status.Succeeded == 0 && job.Status.Failed == 0) {
+			continue
+		}
+		log.Info("deleting stale executor job", "jobName", job.Name)
+		if err := r.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("failed to delete job %s: %w", job.Name, err)
+		}
+	}
+	return nil
+}
+
+// recordEvent writes an audit event for exec if an audit logger is set.
+func (r *RunbookExecutionReconciler) recordEvent(ctx context.Context, exec *heliosv1alpha1.RunbookExecution, eventType audit.EventType, message string) {
+	if r.Audit == nil {
+		return
+	}
+	r.Audit.LogEvent(ctx, audit.AuditEvent{
+		EventType:     eventType,
+		ExecutionName: exec.Name,
+		Namespace:     exec.Namespace,
+		RunbookName:   exec.Spec.RunbookRef.Name,
+		TriggeredBy:   exec.Spec.TriggeredBy,
+		Message:       message,
+	})
+}
+
+// executionTimedOut reports whether exec has been running longer than its
+// runbook's ExecutionTimeout. Runbooks without a valid timeout never time out.
+func (r *RunbookExecutionReconciler) executionTimedOut(ctx context.Context, exec *heliosv1alpha1.RunbookExecution) (bool, time.Duration, error) {
+	if exec.Status.StartTime == nil {
+		return false, 0, nil
+	}
+	runbook, err := r.getRunbook(ctx, exec)
+	if err != nil {
+		return false, 0, err
+	}
+	if runbook.Spec.ExecutionTimeout == "" {
+		return false, 0, nil
+	}
+	timeout, err := time.ParseDuration(runbook.Spec.ExecutionTimeout)
+	if err != nil || timeout <= 0 {
+		return false, 0, nil
+	}
+	return time.Since(exec.Status.StartTime.Time) > timeout, timeout, nil
+}
+
+// concurrencyLimitReached reports whether the runbook already has
+// MaxConcurrent executions running, in which case exec must wait.
+func (r *RunbookExecutionReconciler) concurrencyLimitReached(ctx context.Context, log *slog.Logger, exec *heliosv1alpha1.RunbookExecution, runbook *heliosv1alpha1.Runbook) (bool, error) {
+	limit := int(runbook.Spec.MaxConcurrent)
+	if limit <= 0 {
+		limit = 1
+	}
+
+	var executions heliosv1alpha1.RunbookExecutionList
+	if err := r.List(ctx, &executions,
+		client.InNamespace(exec.Namespace),
+		client.MatchingLabels{RunbookLabel: exec.Spec.RunbookRef.Name},
+	); err != nil {
+		return false, fmt.Errorf("failed to list executions: %w", err)
+	}
+
+	active := 0
+	for _, other := range executions.Items {
+		if other.Name == exec.Name {
+			continue
+		}
+		switch other.Status.Phase {
+		case heliosv1alpha1.PhaseRunning, heliosv1alpha1.PhaseRollingBack:
+			active++
+		}
+	}
+	if active >= limit {
+		log.Info("runbook concurrency limit reached, waiting", "active", active, "limit", limit)
+		return true, nil
+	}
+	return false, nil
+}
+
+// cooldownRemaining returns how much of the runbook's cooldown is left since
+// the most recent completed execution of the same runbook, or zero.
+func (r *RunbookExecutionReconciler) cooldownRemaining(ctx context.Context, exec *heliosv1alpha1.RunbookExecution, runbook *heliosv1alpha1.Runbook) (time.Duration, error) {
+	if runbook.Spec.Cooldown == "" {
+		return 0, nil
+	}
+	cooldown, err := time.ParseDuration(runbook.Spec.Cooldown)
+	if err != nil || cooldown <= 0 {
+		return 0, nil
+	}
+
+	var executions heliosv1alpha1.RunbookExecutionList
+	if err := r.List(ctx, &executions,
+		client.InNamespace(exec.Namespace),
+		client.MatchingLabels{RunbookLabel: exec.Spec.RunbookRef.Name},
+	); err != nil {
+		return 0, fmt.Errorf("failed to list executions: %w", err)
+	}
+
+	var last time.Time
+	for _, other := range executions.Items {
+		if other.Name == exec.Name || other.Status.Phase != heliosv1alpha1.PhaseCompleted || other.Status.CompletionTime == nil {
+			continue
+		}
+		if t := other.Status.CompletionTime.Time; t.After(last) {
+			last = t
+		}
+	}
+	if last.IsZero() {
+		return 0, nil
+	}
+	return cooldown - time.Since(last), nil
+}
+
 func (r *RunbookExecutionReconciler) getRunbook(ctx context.Context, exec *heliosv1alpha1.RunbookExecution) (*heliosv1alpha1.Runbook, error) {
 	ns := exec.Spec.RunbookRef.Namespace
 	if ns == "" {
@@ -222,11 +601,57 @@ func (r *RunbookExecutionReconciler) setPhase(ctx context.Context, exec *heliosv
 		Message:            message,
 		LastTransitionTime: metav1.Now(),
 	})
-	return r.Status().Update(ctx, exec)
+	if err := r.Status().Update(ctx, exec); err != nil {
+		return err
+	}
+
+	metrics.ExecutionsTotal.WithLabelValues(exec.Spec.RunbookRef.Name, string(phase)).Inc()
+	switch phase {
+	case heliosv1alpha1.PhaseCompleted, heliosv1alpha1.PhaseCancelled,
+		heliosv1alpha1.PhaseTimedOut, heliosv1alpha1.PhaseRolledBack:
+		observeDuration(exec)
+	}
+	return nil
+}
+
+// observeDuration records the run time of a finished execution.
+func observeDuration(exec *heliosv1alpha1.RunbookExecution) {
+	if exec.Status.StartTime == nil || exec.Status.CompletionTime == nil {
+		return
+	}
+	d := exec.Status.CompletionTime.Sub(exec.Status.StartTime.Time)
+	metrics.ExecutionDurationSeconds.WithLabelValues(exec.Spec.RunbookRef.Name, string(exec.Status.Phase)).Observe(d.Seconds())
 }
 
-func (r *RunbookExecutionReconciler) createExecutorJob(ctx context.Context, exec *heliosv1alpha1.RunbookExecution, jobName string) error {
+// createExecutorJob launches the executor for exec. When rollback is set the
+// executor runs the runbook's rollback steps instead of its forward steps.
+func (r *RunbookExecutionReconciler) createExecutorJob(ctx context.Context, exec *heliosv1alpha1.RunbookExecution, jobName string, rollback bool) error {
 	backoffLimit := int32(0)
+	ttl := r.JobTTL
+	if ttl <= 0 {
+		ttl = DefaultJobTTL
+	}
+	ttlSeconds := int32(ttl.Seconds())
+	env := []corev1.EnvVar{
+		{
+			Name:  "EXECUTION_NAME",
+			Value: exec.Name,
+		},
+		{
+			Name:  "EXECUTION_NAMESPACE",
+			Value: exec.Namespace,
+		},
+	}
+	if rollback {
+		env = append(env, corev1.EnvVar{Name: "ROLLBACK", Value: "true"})
+	}
+	env = append(env, r.ExecutorEnv...)
+	if r.GNMICredentialsSecret != "" {
+		env = append(env,
+			secretEnvVar("GNMI_USERNAME", r.GNMICredentialsSecret, "username"),
+			secretEnvVar("GNMI_PASSWORD", r.GNMICredentialsSecret, "password"),
+		)
+	}
 	job := &batchv1.Job{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      jobName,
@@ -238,7 +663,8 @@ func (r *RunbookExecutionReconciler) createExecutorJob(ctx context.Context, exec
 			},
 		},
 		Spec: batchv1.JobSpec{
-			BackoffLimit: &backoffLimit,
+			BackoffLimit:            &backoffLimit,
+			TTLSecondsAfterFinished: &ttlSeconds,
 			Template: corev1.PodTemplateSpec{
 				Spec: corev1.PodSpec{
 					RestartPolicy: corev1.RestartPolicyNever,
@@ -246,25 +672,32 @@ func (r *RunbookExecutionReconciler) createExecutorJob(ctx context.Context, exec
 						{
 							Name:  "executor",
 							Image: r.ExecutorImage,
-							Env: []corev1.EnvVar{
-								{
-									Name:  "EXECUTION_NAME",
-									Value: exec.Name,
-								},
-								{
-									Name:  "EXECUTION_NAMESPACE",
-									Value: exec.Namespace,
-								},
-							},
+							Env:   env,
 						},
 					},
 				},
 			},
 		},
 	}
+	if err := ctrl.SetControllerReference(exec, job, r.Scheme); err != nil {
+		return fmt.Errorf("failed to set owner reference: %w", err)
+	}
 	return r.Create(ctx, job)
 }
 
+// secretEnvVar returns an env var sourced from key in the named Secret.
+func secretEnvVar(name, secret, key string) corev1.EnvVar {
+	return corev1.EnvVar{
+		Name: name,
+		ValueFrom: &corev1.EnvVarSource{
+			SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: secret},
+				Key:                  key,
+			},
+		},
+	}
+}
+
 func (r *RunbookExecutionReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&heliosv1alpha1.RunbookExecution{}).
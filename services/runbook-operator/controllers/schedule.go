@@ -0,0 +1,137 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	heliosv1alpha1 "github.com/rhwendt/helios/services/runbook-operator/api/v1alpha1"
+	"github.com/rhwendt/helios/services/runbook-operator/pkg/schedule"
+)
+
+// ScheduledTriggeredBy is recorded as the TriggeredBy of executions created
+// from a runbook's schedule.
+const ScheduledTriggeredBy = "system:scheduler"
+
+// validateSchedule checks that a scheduled runbook can actually be run by
+// the scheduler. Scheduled executions carry no parameters and are
+// triggered by ScheduledTriggeredBy, which holds no roles, so a schedule
+// cannot be combined with AllowedRoles or with required parameters that
+// have no default.
+func validateSchedule(rb *heliosv1alpha1.Runbook) error {
+	if _, err := schedule.Parse(rb.Spec.Schedule); err != nil {
+		return fmt.Errorf("invalid schedule: %w", err)
+	}
+	if len(rb.Spec.AllowedRoles) > 0 {
+		return fmt.Errorf("schedule cannot be combined with allowedRoles: scheduled runs are triggered by %s, which holds no roles", ScheduledTriggeredBy)
+	}
+	if _, err := ValidateParameters(rb.Spec.Parameters, nil); err != nil {
+		return fmt.Errorf("schedule requires every required parameter to have a default: %w", err)
+	}
+	return nil
+}
+
+// reconcileSchedule creates an execution when the runbook's schedule is due
+// and requeues for the next scheduled time. Missed runs are not backfilled;
+// only the most recent one is started.
+func (r *RunbookReconciler) reconcileSchedule(ctx context.Context, log *slog.Logger, runbook *heliosv1alpha1.Runbook) (ctrl.Result, error) {
+	if runbook.Spec.Schedule == "" {
+		return ctrl.Result{}, nil
+	}
+	sched, err := schedule.Parse(runbook.Spec.Schedule)
+	if err != nil {
+		// Already reported through the Ready condition.
+		return ctrl.Result{}, nil
+	}
+
+	now := r.now()
+	last := runbook.CreationTimestamp.Time
+	if runbook.Status.LastScheduleTime != nil {
+		last = runbook.Status.LastScheduleTime.Time
+	}
+
+	due := time.Time{}
+	for next := sched.Next(last); !next.IsZero() && !next.After(now); next = sched.Next(next) {
+		due = next
+	}
+	if !due.IsZero() {
+		if err := r.startScheduledExecution(ctx, log, runbook, due); err != nil {
+			return ctrl.Result{}, err
+		}
+		scheduled := metav1.NewTime(due)
+		runbook.Status.LastScheduleTime = &scheduled
+		if err := r.Status().Update(ctx, runbook); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	next := sched.Next(now)
+	if next.IsZero() {
+		return ctrl.Result{}, nil
+	}
+	return ctrl.Result{RequeueAfter: next.Sub(now)}, nil
+}
+
+// startScheduledExecution creates the execution for the run scheduled at
+// due, unless the runbook already has MaxConcurrent executions in flight.
+// Approval is handled by the execution controller as for any other trigger.
+func (r *RunbookReconciler) startScheduledExecution(ctx context.Context, log *slog.Logger, runbook *heliosv1alpha1.Runbook, due time.Time) error {
+	var executions heliosv1alpha1.RunbookExecutionList
+	if err := r.List(ctx, &executions,
+		client.InNamespace(runbook.Namespace),
+		client.MatchingLabels{RunbookLabel: runbook.Name},
+	); err != nil {
+		return fmt.Errorf("failed to list executions: %w", err)
+	}
+
+	limit := int(runbook.Spec.MaxConcurrent)
+	if limit <= 0 {
+		limit = 1
+	}
+	inFlight := 0
+	for _, exec := range executions.Items {
+		switch exec.Status.Phase {
+		case heliosv1alpha1.PhaseCompleted, heliosv1alpha1.PhaseCancelled,
+			heliosv1alpha1.PhaseTimedOut, heliosv1alpha1.PhaseRolledBack, heliosv1alpha1.PhaseFailed:
+		default:
+			inFlight++
+		}
+	}
+	if inFlight >= limit {
+		log.Info("skipping scheduled run, concurrency limit reached", "scheduledAt", due, "inFlight", inFlight, "limit", limit)
+		return nil
+	}
+
+	exec := &heliosv1alpha1.RunbookExecution{
+		ObjectMeta: metav1.ObjectMeta{
+			// Named after the scheduled minute so a retried reconcile
+			// cannot start the same run twice.
+			Name:      fmt.Sprintf("%s-%d", runbook.Name, due.Unix()/60),
+			Namespace: runbook.Namespace,
+			Labels:    map[string]string{RunbookLabel: runbook.Name},
+		},
+		Spec: heliosv1alpha1.RunbookExecutionSpec{
+			RunbookRef:    heliosv1alpha1.RunbookRef{Name: runbook.Name, Namespace: runbook.Namespace},
+			TriggeredBy:   ScheduledTriggeredBy,
+			TriggerSource: heliosv1alpha1.TriggerScheduled,
+		},
+	}
+	if err := r.Create(ctx, exec); err != nil && !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to create scheduled execution: %w", err)
+	}
+	log.Info("created scheduled execution", "execution", exec.Name, "scheduledAt", due)
+	return nil
+}
+
+func (r *RunbookReconciler) now() time.Time {
+	if r.clock != nil {
+		return r.clock()
+	}
+	return time.Now()
+}
@@ -0,0 +1,61 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+
+	heliosv1alpha1 "github.com/rhwendt/helios/services/runbook-operator/api/v1alpha1"
+)
+
+// requiredStepConfig lists the config keys each action cannot run without.
+// Actions missing from the map, such as wait, have no required keys.
+var requiredStepConfig = map[heliosv1alpha1.StepAction][]string{
+	heliosv1alpha1.ActionGNMISet:          {"target", "path", "value"},
+	heliosv1alpha1.ActionGNMIGet:          {"target", "path"},
+	heliosv1alpha1.ActionGNMISubscribe:    {"target", "path"},
+	heliosv1alpha1.ActionGNMICapabilities: {"target"},
+	heliosv1alpha1.ActionGNMIWait:         {"target", "path", "condition"},
+	heliosv1alpha1.ActionNotify:           {"webhook_url", "message"},
+	heliosv1alpha1.ActionCondition:        {"expression"},
+}
+
+// stepConfigError reports a step whose config is missing required keys.
+type stepConfigError struct {
+	kind    string
+	index   int
+	step    string
+	action  heliosv1alpha1.StepAction
+	missing []string
+}
+
+func (e *stepConfigError) Error() string {
+	return fmt.Sprintf("%s %d (%s): %s requires config %s",
+		e.kind, e.index, e.step, e.action, strings.Join(e.missing, ", "))
+}
+
+// validateStepConfig checks that step has every config key its action
+// requires, so a misconfigured runbook is rejected before any step runs.
+// Values may be templates; only their presence is checked here. kind names
+// the step list, "step" or "rollback step", in the error.
+func validateStepConfig(kind string, index int, step heliosv1alpha1.RunbookStep) error {
+	var missing []string
+	for _, key := range requiredStepConfig[step.Action] {
+		if isEmptyConfig(step.Config[key]) {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) > 0 {
+		return &stepConfigError{kind: kind, index: index, step: step.Name, action: step.Action, missing: missing}
+	}
+	return nil
+}
+
+// isEmptyConfig reports whether a config value is absent or an empty
+// string. Other zero values such as false or 0 are valid settings.
+func isEmptyConfig(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	s, ok := v.(string)
+	return ok && s == ""
+}
@@ -3,10 +3,15 @@ package approval
 import (
 	"bytes"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"strconv"
 	"time"
 )
 
@@ -14,11 +19,22 @@ import (
 type NotificationType string
 
 const (
-	NotifySlack    NotificationType = "slack"
-	NotifyTeams    NotificationType = "teams"
-	NotifyWebhook  NotificationType = "webhook"
+	NotifySlack   NotificationType = "slack"
+	NotifyTeams   NotificationType = "teams"
+	NotifyWebhook NotificationType = "webhook"
 )
 
+const (
+	// DefaultMaxAttempts is the number of delivery attempts per notification.
+	DefaultMaxAttempts = 3
+	// DefaultRetryBaseDelay is the delay before the first retry.
+	DefaultRetryBaseDelay = time.Second
+)
+
+// SignatureHeader carries the HMAC-SHA256 signature of a notification body
+// when a signing secret is configured, formatted as "sha256=<hex digest>".
+const SignatureHeader = "X-Helios-Signature"
+
 // ApprovalRequest represents a pending approval request.
 type ApprovalRequest struct {
 	ExecutionName string
@@ -31,20 +47,59 @@ type ApprovalRequest struct {
 
 // Approver dispatches approval notifications and checks approval status.
 type Approver struct {
-	webhookURL string
-	notifyType NotificationType
-	httpClient *http.Client
-	log        *slog.Logger
+	webhookURL    string
+	notifyType    NotificationType
+	httpClient    *http.Client
+	log           *slog.Logger
+	signingSecret []byte
+	maxAttempts   int
+	baseDelay     time.Duration
+}
+
+// ApproverOption configures an Approver.
+type ApproverOption func(*Approver)
+
+// WithSigningSecret signs every notification body with HMAC-SHA256 using
+// secret and sends the result in the SignatureHeader.
+func WithSigningSecret(secret string) ApproverOption {
+	return func(a *Approver) {
+		a.signingSecret = []byte(secret)
+	}
+}
+
+// WithRetries sets how many times a notification is attempted in total
+// and the delay before the first retry, which doubles on each attempt.
+func WithRetries(attempts int, baseDelay time.Duration) ApproverOption {
+	return func(a *Approver) {
+		if attempts < 1 {
+			attempts = 1
+		}
+		a.maxAttempts = attempts
+		a.baseDelay = baseDelay
+	}
 }
 
 // NewApprover creates a new Approver.
-func NewApprover(webhookURL string, notifyType NotificationType, log *slog.Logger) *Approver {
-	return &Approver{
-		webhookURL: webhookURL,
-		notifyType: notifyType,
-		httpClient: &http.Client{Timeout: 10 * time.Second},
-		log:        log,
+func NewApprover(webhookURL string, notifyType NotificationType, log *slog.Logger, opts ...ApproverOption) *Approver {
+	a := &Approver{
+		webhookURL:  webhookURL,
+		notifyType:  notifyType,
+		httpClient:  &http.Client{Timeout: 10 * time.Second},
+		log:         log,
+		maxAttempts: DefaultMaxAttempts,
+		baseDelay:   DefaultRetryBaseDelay,
+	}
+	for _, opt := range opts {
+		opt(a)
 	}
+	return a
+}
+
+// Sign returns the SignatureHeader value for body under secret.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
 }
 
 // SendApprovalNotification sends a notification requesting approval.
@@ -64,24 +119,130 @@ func (a *Approver) SendApprovalNotification(ctx context.Context, req ApprovalReq
 		return fmt.Errorf("failed to build notification payload: %w", err)
 	}
 
+	if err := a.post(ctx, payload); err != nil {
+		return err
+	}
+
+	a.log.Info("approval notification sent", "execution", req.ExecutionName, "type", a.notifyType)
+	return nil
+}
+
+// SendMessage posts a free-form message to the configured channel.
+func (a *Approver) SendMessage(ctx context.Context, message string) error {
+	var payload []byte
+	var err error
+
+	switch a.notifyType {
+	case NotifySlack:
+		payload, err = json.Marshal(map[string]string{"text": message})
+	case NotifyTeams:
+		payload, err = json.Marshal(map[string]string{
+			"@type":    "MessageCard",
+			"@context": "http://schema.org/extensions",
+			"summary":  message,
+			"text":     message,
+		})
+	default:
+		payload, err = json.Marshal(map[string]string{"message": message})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to build notification payload: %w", err)
+	}
+
+	if err := a.post(ctx, payload); err != nil {
+		return err
+	}
+
+	a.log.Info("notification sent", "type", a.notifyType)
+	return nil
+}
+
+// post delivers payload to the webhook, retrying 429 and 5xx responses
+// with exponential backoff. A Retry-After header overrides the backoff.
+func (a *Approver) post(ctx context.Context, payload []byte) error {
+	var lastErr error
+	for attempt := 1; attempt <= a.maxAttempts; attempt++ {
+		retryAfter, err := a.postOnce(ctx, payload)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+
+		var rerr *retryableError
+		if !errors.As(err, &rerr) || attempt == a.maxAttempts {
+			break
+		}
+
+		delay := a.baseDelay << (attempt - 1)
+		if retryAfter > 0 {
+			delay = retryAfter
+		}
+		a.log.Warn("notification delivery failed, retrying", "attempt", attempt, "delay", delay, "error", err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("failed to send notification: %w", ctx.Err())
+		case <-timer.C:
+		}
+	}
+
+	if a.maxAttempts > 1 {
+		return fmt.Errorf("notification failed after %d attempts: %w", a.maxAttempts, lastErr)
+	}
+	return lastErr
+}
+
+// retryableError marks a webhook response that is worth retrying.
+type retryableError struct {
+	statusCode int
+}
+
+func (e *retryableError) Error() string {
+	return fmt.Sprintf("notification webhook returned status %d", e.statusCode)
+}
+
+func (a *Approver) postOnce(ctx context.Context, payload []byte) (time.Duration, error) {
 	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, a.webhookURL, bytes.NewReader(payload))
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return 0, fmt.Errorf("failed to create request: %w", err)
 	}
 	httpReq.Header.Set("Content-Type", "application/json")
+	if len(a.signingSecret) > 0 {
+		httpReq.Header.Set(SignatureHeader, Sign(a.signingSecret, payload))
+	}
 
 	resp, err := a.httpClient.Do(httpReq)
 	if err != nil {
-		return fmt.Errorf("failed to send notification: %w", err)
+		return 0, fmt.Errorf("failed to send notification: %w", err)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		return parseRetryAfter(resp.Header.Get("Retry-After")), &retryableError{statusCode: resp.StatusCode}
+	}
 	if resp.StatusCode >= 400 {
-		return fmt.Errorf("notification webhook returned status %d", resp.StatusCode)
+		return 0, fmt.Errorf("notification webhook returned status %d", resp.StatusCode)
 	}
+	return 0, nil
+}
 
-	a.log.Info("approval notification sent", "execution", req.ExecutionName, "type", a.notifyType)
-	return nil
+// parseRetryAfter parses a Retry-After header given either as seconds or
+// as an HTTP date. It returns zero if the header is absent or invalid.
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
 }
 
 func (a *Approver) buildSlackPayload(req ApprovalRequest) ([]byte, error) {
@@ -0,0 +1,145 @@
+package approval
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+}
+
+func TestSendApprovalNotification_Signature(t *testing.T) {
+	const secret = "s3cret"
+
+	var gotSig string
+	var gotBody []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotSig = r.Header.Get(SignatureHeader)
+		gotBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	a := NewApprover(server.URL, NotifyWebhook, testLogger(), WithSigningSecret(secret))
+	req := ApprovalRequest{ExecutionName: "exec-1", Namespace: "helios-automation", RunbookName: "bgp-reset"}
+	if err := a.SendApprovalNotification(context.Background(), req); err != nil {
+		t.Fatalf("SendApprovalNotification() error = %v", err)
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(gotBody)
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	if gotSig != want {
+		t.Errorf("%s = %q, want %q", SignatureHeader, gotSig, want)
+	}
+}
+
+func TestSendApprovalNotification_Unsigned(t *testing.T) {
+	var gotSig string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotSig = r.Header.Get(SignatureHeader)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	a := NewApprover(server.URL, NotifyWebhook, testLogger())
+	if err := a.SendApprovalNotification(context.Background(), ApprovalRequest{RunbookName: "bgp-reset"}); err != nil {
+		t.Fatalf("SendApprovalNotification() error = %v", err)
+	}
+	if gotSig != "" {
+		t.Errorf("%s = %q, want no signature", SignatureHeader, gotSig)
+	}
+}
+
+func TestSendApprovalNotification_RetriesTransientFailures(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	a := NewApprover(server.URL, NotifySlack, testLogger(), WithRetries(3, time.Millisecond))
+	if err := a.SendApprovalNotification(context.Background(), ApprovalRequest{RunbookName: "bgp-reset"}); err != nil {
+		t.Fatalf("SendApprovalNotification() error = %v", err)
+	}
+	if got := atomic.LoadInt32(&requests); got != 3 {
+		t.Errorf("requests = %d, want 3", got)
+	}
+}
+
+func TestSendApprovalNotification_GivesUp(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	a := NewApprover(server.URL, NotifyWebhook, testLogger(), WithRetries(2, time.Millisecond))
+	err := a.SendApprovalNotification(context.Background(), ApprovalRequest{RunbookName: "bgp-reset"})
+	if err == nil {
+		t.Fatal("expected error after exhausting retries")
+	}
+	if !strings.Contains(err.Error(), "after 2 attempts") {
+		t.Errorf("error = %v, want attempt count", err)
+	}
+	if got := atomic.LoadInt32(&requests); got != 2 {
+		t.Errorf("requests = %d, want 2", got)
+	}
+}
+
+func TestSendApprovalNotification_ClientErrorNotRetried(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	a := NewApprover(server.URL, NotifyWebhook, testLogger(), WithRetries(3, time.Millisecond))
+	if err := a.SendApprovalNotification(context.Background(), ApprovalRequest{}); err == nil {
+		t.Fatal("expected error for 400 response")
+	}
+	if got := atomic.LoadInt32(&requests); got != 1 {
+		t.Errorf("requests = %d, want 1", got)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"", 0},
+		{"2", 2 * time.Second},
+		{"-1", 0},
+		{"soon", 0},
+		{time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat), 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.in); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+
+	future := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	if got := parseRetryAfter(future); got <= 0 || got > time.Minute {
+		t.Errorf("parseRetryAfter(%q) = %v, want within a minute", future, got)
+	}
+}
@@ -0,0 +1,63 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// KafkaSink publishes audit events as JSON to a Kafka topic.
+type KafkaSink struct {
+	producer *kafka.Producer
+	topic    string
+}
+
+// NewKafkaSink creates a KafkaSink producing to topic on brokers.
+func NewKafkaSink(brokers, topic string) (*KafkaSink, error) {
+	p, err := kafka.NewProducer(&kafka.ConfigMap{
+		"bootstrap.servers":   brokers,
+		"acks":                "all",
+		"enable.idempotence":  true,
+		"delivery.timeout.ms": 30000,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating Kafka producer: %w", err)
+	}
+	return &KafkaSink{producer: p, topic: topic}, nil
+}
+
+// Write publishes event and waits for the broker to acknowledge it, keyed
+// by execution so events of one execution stay ordered.
+func (s *KafkaSink) Write(event AuditEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("marshaling audit event: %w", err)
+	}
+
+	deliveryChan := make(chan kafka.Event, 1)
+	err = s.producer.Produce(&kafka.Message{
+		TopicPartition: kafka.TopicPartition{
+			Topic:     &s.topic,
+			Partition: kafka.PartitionAny,
+		},
+		Key:   []byte(event.Namespace + "/" + event.ExecutionName),
+		Value: data,
+	}, deliveryChan)
+	if err != nil {
+		return fmt.Errorf("producing audit event: %w", err)
+	}
+
+	m := (<-deliveryChan).(*kafka.Message)
+	if m.TopicPartition.Error != nil {
+		return fmt.Errorf("delivering audit event: %w", m.TopicPartition.Error)
+	}
+	return nil
+}
+
+// Close flushes outstanding events and shuts down the producer.
+func (s *KafkaSink) Close() error {
+	s.producer.Flush(5000)
+	s.producer.Close()
+	return nil
+}
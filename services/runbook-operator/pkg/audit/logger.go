@@ -2,7 +2,9 @@ package audit
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"time"
 )
@@ -11,18 +13,19 @@ import (
 type EventType string
 
 const (
-	EventExecutionCreated  EventType = "ExecutionCreated"
-	EventExecutionStarted  EventType = "ExecutionStarted"
-	EventStepStarted       EventType = "StepStarted"
-	EventStepCompleted     EventType = "StepCompleted"
-	EventStepFailed        EventType = "StepFailed"
-	EventApprovalRequested EventType = "ApprovalRequested"
-	EventApprovalGranted   EventType = "ApprovalGranted"
-	EventApprovalDenied    EventType = "ApprovalDenied"
-	EventRollbackStarted   EventType = "RollbackStarted"
-	EventRollbackCompleted EventType = "RollbackCompleted"
+	EventExecutionCreated   EventType = "ExecutionCreated"
+	EventExecutionStarted   EventType = "ExecutionStarted"
+	EventStepStarted        EventType = "StepStarted"
+	EventStepCompleted      EventType = "StepCompleted"
+	EventStepFailed         EventType = "StepFailed"
+	EventApprovalRequested  EventType = "ApprovalRequested"
+	EventApprovalGranted    EventType = "ApprovalGranted"
+	EventApprovalDenied     EventType = "ApprovalDenied"
+	EventRollbackStarted    EventType = "RollbackStarted"
+	EventRollbackCompleted  EventType = "RollbackCompleted"
 	EventExecutionCompleted EventType = "ExecutionCompleted"
-	EventExecutionFailed   EventType = "ExecutionFailed"
+	EventExecutionFailed    EventType = "ExecutionFailed"
+	EventExecutionCancelled EventType = "ExecutionCancelled"
 )
 
 // AuditEvent represents a single audit log entry.
@@ -40,16 +43,32 @@ type AuditEvent struct {
 
 // Logger provides structured audit logging for runbook executions.
 type Logger struct {
-	log *slog.Logger
+	log   *slog.Logger
+	sinks []Sink
 }
 
-// NewLogger creates a new audit Logger.
-func NewLogger(log *slog.Logger) *Logger {
+// NewLogger creates a new audit Logger. Events are always written to log
+// and additionally to every sink.
+func NewLogger(log *slog.Logger, sinks ...Sink) *Logger {
 	return &Logger{
-		log: log.With("component", "audit"),
+		log:   log.With("component", "audit"),
+		sinks: sinks,
 	}
 }
 
+// Close closes any sinks that hold resources.
+func (l *Logger) Close() error {
+	var errs []error
+	for _, s := range l.sinks {
+		if c, ok := s.(io.Closer); ok {
+			if err := c.Close(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
 // LogEvent records an audit event to structured logging.
 func (l *Logger) LogEvent(_ context.Context, event AuditEvent) {
 	event.Timestamp = time.Now()
@@ -71,6 +90,12 @@ func (l *Logger) LogEvent(_ context.Context, event AuditEvent) {
 	}
 
 	l.log.LogAttrs(context.Background(), slog.LevelInfo, "audit_event", attrs...)
+
+	for _, sink := range l.sinks {
+		if err := sink.Write(event); err != nil {
+			l.log.Error("failed to write audit event to sink", "event_type", event.EventType, "error", err)
+		}
+	}
 }
 
 // LogStepStart logs the start of a step execution.
@@ -113,3 +138,29 @@ func (l *Logger) LogStepFailed(ctx context.Context, execName, ns, runbook, step,
 		Details:       map[string]string{"error": errMsg},
 	})
 }
+
+// LogApprovalGranted logs that an execution was approved.
+func (l *Logger) LogApprovalGranted(ctx context.Context, execName, ns, runbook, triggeredBy, approvedBy string) {
+	l.LogEvent(ctx, AuditEvent{
+		EventType:     EventApprovalGranted,
+		ExecutionName: execName,
+		Namespace:     ns,
+		RunbookName:   runbook,
+		TriggeredBy:   triggeredBy,
+		Message:       fmt.Sprintf("Approval granted by %s", approvedBy),
+		Details:       map[string]string{"approvedBy": approvedBy},
+	})
+}
+
+// LogApprovalDenied logs that an approval was rejected.
+func (l *Logger) LogApprovalDenied(ctx context.Context, execName, ns, runbook, triggeredBy, approvedBy, reason string) {
+	l.LogEvent(ctx, AuditEvent{
+		EventType:     EventApprovalDenied,
+		ExecutionName: execName,
+		Namespace:     ns,
+		RunbookName:   runbook,
+		TriggeredBy:   triggeredBy,
+		Message:       fmt.Sprintf("Approval by %s denied: %s", approvedBy, reason),
+		Details:       map[string]string{"approvedBy": approvedBy, "reason": reason},
+	})
+}
@@ -0,0 +1,67 @@
+package audit
+
+import (
+	"context"
+	"testing"
+)
+
+type recordingSink struct {
+	events []AuditEvent
+}
+
+func (s *recordingSink) Write(event AuditEvent) error {
+	s.events = append(s.events, event)
+	return nil
+}
+
+func TestLogger_ApprovalEvents(t *testing.T) {
+	tests := []struct {
+		name        string
+		log         func(l *Logger)
+		wantType    EventType
+		wantDetails map[string]string
+	}{
+		{
+			name: "granted",
+			log: func(l *Logger) {
+				l.LogApprovalGranted(context.Background(), "exec-1", "helios-automation", "bgp-reset", "operator@example.com", "noc-lead@example.com")
+			},
+			wantType:    EventApprovalGranted,
+			wantDetails: map[string]string{"approvedBy": "noc-lead@example.com"},
+		},
+		{
+			name: "denied",
+			log: func(l *Logger) {
+				l.LogApprovalDenied(context.Background(), "exec-1", "helios-automation", "bgp-reset", "operator@example.com", "intern@example.com", "not an approver")
+			},
+			wantType:    EventApprovalDenied,
+			wantDetails: map[string]string{"approvedBy": "intern@example.com", "reason": "not an approver"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := &recordingSink{}
+			tt.log(NewLogger(testLogger(), sink))
+
+			if len(sink.events) != 1 {
+				t.Fatalf("got %d events, want 1", len(sink.events))
+			}
+			event := sink.events[0]
+			if event.EventType != tt.wantType {
+				t.Errorf("EventType = %q, want %q", event.EventType, tt.wantType)
+			}
+			if event.ExecutionName != "exec-1" || event.Namespace != "helios-automation" || event.RunbookName != "bgp-reset" {
+				t.Errorf("event identifies %s/%s (%s), want helios-automation/exec-1 (bgp-reset)", event.Namespace, event.ExecutionName, event.RunbookName)
+			}
+			if event.TriggeredBy != "operator@example.com" {
+				t.Errorf("TriggeredBy = %q, want operator@example.com", event.TriggeredBy)
+			}
+			for k, want := range tt.wantDetails {
+				if got := event.Details[k]; got != want {
+					t.Errorf("Details[%q] = %q, want %q", k, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Sink persists audit events outside of the process log.
+type Sink interface {
+	Write(event AuditEvent) error
+}
+
+// FileSink appends audit events to a file as JSON lines.
+type FileSink struct {
+	mu   sync.Mutex
+	file *os.File
+	enc  *json.Encoder
+}
+
+// NewFileSink opens path for appending, creating it if necessary.
+func NewFileSink(path string) (*FileSink, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("opening audit file: %w", err)
+	}
+	return &FileSink{file: f, enc: json.NewEncoder(f)}, nil
+}
+
+// Write appends event as a single JSON line and syncs it to disk.
+func (s *FileSink) Write(event AuditEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.enc.Encode(event); err != nil {
+		return fmt.Errorf("writing audit event: %w", err)
+	}
+	return s.file.Sync()
+}
+
+// Close closes the underlying file.
+func (s *FileSink) Close() error {
+	return s.file.Close()
+}
+
+// NewSinksFromEnv builds the sinks named in the comma-separated AUDIT_SINKS
+// variable: "file" (AUDIT_FILE_PATH) and "kafka" (AUDIT_KAFKA_BROKERS,
+// AUDIT_KAFKA_TOPIC).
+func NewSinksFromEnv() ([]Sink, error) {
+	var sinks []Sink
+	for _, name := range strings.Split(os.Getenv("AUDIT_SINKS"), ",") {
+		switch strings.TrimSpace(name) {
+		case "":
+		case "file":
+			path := os.Getenv("AUDIT_FILE_PATH")
+			if path == "" {
+				return nil, fmt.Errorf("AUDIT_FILE_PATH is required for the file audit sink")
+			}
+			s, err := NewFileSink(path)
+			if err != nil {
+				return nil, err
+			}
+			sinks = append(sinks, s)
+		case "kafka":
+			brokers := os.Getenv("AUDIT_KAFKA_BROKERS")
+			topic := os.Getenv("AUDIT_KAFKA_TOPIC")
+			if brokers == "" || topic == "" {
+				return nil, fmt.Errorf("AUDIT_KAFKA_BROKERS and AUDIT_KAFKA_TOPIC are required for the kafka audit sink")
+			}
+			s, err := NewKafkaSink(brokers, topic)
+			if err != nil {
+				return nil, err
+			}
+			sinks = append(sinks, s)
+		default:
+			return nil, fmt.Errorf("unknown audit sink %q", name)
+		}
+	}
+	return sinks, nil
+}
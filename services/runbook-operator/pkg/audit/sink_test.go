@@ -0,0 +1,134 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+}
+
+func readEvents(t *testing.T, path string) []AuditEvent {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open audit file: %v", err)
+	}
+	defer f.Close()
+
+	var events []AuditEvent
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var event AuditEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("line %q is not valid JSON: %v", scanner.Text(), err)
+		}
+		events = append(events, event)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("failed to read audit file: %v", err)
+	}
+	return events
+}
+
+func TestFileSink_WritesJSONLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	sink, err := NewFileSink(path)
+	if err != nil {
+		t.Fatalf("NewFileSink() error = %v", err)
+	}
+
+	l := NewLogger(testLogger(), sink)
+	l.LogStepStart(context.Background(), "exec-1", "helios-automation", "bgp-reset", "disable-peer", "admin@example.com")
+	l.LogStepFailed(context.Background(), "exec-1", "helios-automation", "bgp-reset", "disable-peer", "admin@example.com", "connection refused")
+	if err := l.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	events := readEvents(t, path)
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2", len(events))
+	}
+	if events[0].EventType != EventStepStarted || events[1].EventType != EventStepFailed {
+		t.Errorf("event types = %q, %q", events[0].EventType, events[1].EventType)
+	}
+	if events[1].Details["error"] != "connection refused" {
+		t.Errorf("details = %v, want error detail", events[1].Details)
+	}
+	if events[0].Timestamp.IsZero() {
+		t.Error("timestamp should be set")
+	}
+}
+
+func TestFileSink_Appends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	for i := 0; i < 2; i++ {
+		sink, err := NewFileSink(path)
+		if err != nil {
+			t.Fatalf("NewFileSink() error = %v", err)
+		}
+		if err := sink.Write(AuditEvent{EventType: EventExecutionCreated, ExecutionName: "exec-1"}); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		sink.Close()
+	}
+
+	if got := len(readEvents(t, path)); got != 2 {
+		t.Errorf("got %d events after reopening, want 2", got)
+	}
+}
+
+type failingSink struct{ calls int }
+
+func (s *failingSink) Write(AuditEvent) error {
+	s.calls++
+	return errors.New("sink unavailable")
+}
+
+func TestLogger_SinkErrorDoesNotStopFanOut(t *testing.T) {
+	failing := &failingSink{}
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	file, err := NewFileSink(path)
+	if err != nil {
+		t.Fatalf("NewFileSink() error = %v", err)
+	}
+	defer file.Close()
+
+	l := NewLogger(testLogger(), failing, file)
+	l.LogEvent(context.Background(), AuditEvent{EventType: EventExecutionStarted, ExecutionName: "exec-1"})
+
+	if failing.calls != 1 {
+		t.Errorf("failing sink called %d times, want 1", failing.calls)
+	}
+	if got := len(readEvents(t, path)); got != 1 {
+		t.Errorf("file sink got %d events, want 1", got)
+	}
+}
+
+func TestNewSinksFromEnv(t *testing.T) {
+	t.Setenv("AUDIT_SINKS", "")
+	sinks, err := NewSinksFromEnv()
+	if err != nil || len(sinks) != 0 {
+		t.Errorf("NewSinksFromEnv() = %v, %v; want no sinks", sinks, err)
+	}
+
+	t.Setenv("AUDIT_SINKS", "file")
+	t.Setenv("AUDIT_FILE_PATH", filepath.Join(t.TempDir(), "audit.jsonl"))
+	sinks, err = NewSinksFromEnv()
+	if err != nil || len(sinks) != 1 {
+		t.Fatalf("NewSinksFromEnv() = %v, %v; want one file sink", sinks, err)
+	}
+	sinks[0].(*FileSink).Close()
+
+	t.Setenv("AUDIT_SINKS", "syslog")
+	if _, err := NewSinksFromEnv(); err == nil {
+		t.Error("expected error for unknown sink")
+	}
+}
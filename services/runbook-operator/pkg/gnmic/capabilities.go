@@ -0,0 +1,30 @@
+package gnmic
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+// Capabilities queries the device for its supported models, encodings and
+// gNMI version.
+func (c *Client) Capabilities(ctx context.Context) (*gnmipb.CapabilityResponse, error) {
+	if c.gnmiClient == nil {
+		return nil, fmt.Errorf("client not connected")
+	}
+
+	ctx, cancel := context.WithTimeout(c.withCredentials(ctx), c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	resp, err := c.gnmiClient.Capabilities(ctx, &gnmipb.CapabilityRequest{})
+	observeRPC("capabilities", start, err)
+	if err != nil {
+		return nil, fmt.Errorf("gNMI Capabilities failed: %w", err)
+	}
+
+	c.log.Info("gNMI Capabilities completed", "models", len(resp.GetSupportedModels()), "version", resp.GetGNMIVersion())
+	return resp, nil
+}
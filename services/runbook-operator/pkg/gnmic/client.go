@@ -2,14 +2,20 @@ package gnmic
 
 import (
 	"context"
+	"crypto/sha256"
+	"crypto/subtle"
 	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
 	"fmt"
 	"log/slog"
+	"strings"
 	"time"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
 
 	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
 )
@@ -20,10 +26,14 @@ type Client struct {
 	username   string
 	password   string
 	tlsConfig  *tls.Config
+	serverName string
+	pinnedCert string
+	insecure   bool
 	conn       *grpc.ClientConn
 	gnmiClient gnmipb.GNMIClient
 	log        *slog.Logger
 	timeout    time.Duration
+	inFlight   chan struct{}
 }
 
 // ClientOption configures a Client.
@@ -36,6 +46,31 @@ func WithTLS(config *tls.Config) ClientOption {
 	}
 }
 
+// WithServerName sets the name used to verify the device certificate, for
+// devices whose certificates do not carry their IP address as a SAN.
+func WithServerName(name string) ClientOption {
+	return func(c *Client) {
+		c.serverName = name
+	}
+}
+
+// WithPinnedCert trusts only a device certificate whose SHA-256 fingerprint
+// matches fingerprint, given in hex with or without colons. The pin replaces
+// CA chain and hostname verification.
+func WithPinnedCert(fingerprint string) ClientOption {
+	return func(c *Client) {
+		c.pinnedCert = fingerprint
+	}
+}
+
+// WithInsecure allows the client to dial without TLS when no TLS
+// configuration is provided. Intended for labs and plaintext-only devices.
+func WithInsecure() ClientOption {
+	return func(c *Client) {
+		c.insecure = true
+	}
+}
+
 // WithTimeout sets the default timeout for operations.
 func WithTimeout(timeout time.Duration) ClientOption {
 	return func(c *Client) {
@@ -43,6 +78,20 @@ func WithTimeout(timeout time.Duration) ClientOption {
 	}
 }
 
+// WithMaxInFlight limits the number of concurrent Get, Set and Subscribe
+// RPCs the client sends to the device. Calls beyond the limit wait for a
+// free slot or for their context to end. A limit of zero or less leaves
+// RPCs unlimited, which is the default.
+func WithMaxInFlight(n int) ClientOption {
+	return func(c *Client) {
+		if n > 0 {
+			c.inFlight = make(chan struct{}, n)
+		} else {
+			c.inFlight = nil
+		}
+	}
+}
+
 // NewClient creates a new gNMI client.
 func NewClient(address, username, password string, log *slog.Logger, opts ...ClientOption) *Client {
 	c := &Client{
@@ -60,11 +109,9 @@ func NewClient(address, username, password string, log *slog.Logger, opts ...Cli
 
 // Connect establishes a gRPC connection to the device.
 func (c *Client) Connect(ctx context.Context) error {
-	var transportCreds credentials.TransportCredentials
-	if c.tlsConfig != nil {
-		transportCreds = credentials.NewTLS(c.tlsConfig)
-	} else {
-		transportCreds = insecure.NewCredentials()
+	transportCreds, err := c.transportCredentials()
+	if err != nil {
+		return err
 	}
 
 	dialCtx, cancel := context.WithTimeout(ctx, c.timeout)
@@ -84,6 +131,84 @@ func (c *Client) Connect(ctx context.Context) error {
 	return nil
 }
 
+// transportCredentials selects TLS when configured, falling back to
+// plaintext only if the client was created with WithInsecure.
+func (c *Client) transportCredentials() (credentials.TransportCredentials, error) {
+	cfg, err := c.buildTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	if cfg != nil {
+		return credentials.NewTLS(cfg), nil
+	}
+	if !c.insecure {
+		return nil, fmt.Errorf("TLS configuration is required for %s", c.address)
+	}
+	c.log.Warn("using insecure plaintext gNMI transport", "address", c.address)
+	return insecure.NewCredentials(), nil
+}
+
+// buildTLSConfig returns the TLS configuration for the connection, or nil
+// if none was requested. Setting a server name or pinned certificate
+// enables TLS on its own.
+func (c *Client) buildTLSConfig() (*tls.Config, error) {
+	if c.tlsConfig == nil && c.serverName == "" && c.pinnedCert == "" {
+		return nil, nil
+	}
+
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if c.tlsConfig != nil {
+		cfg = c.tlsConfig.Clone()
+	}
+	if c.serverName != "" {
+		cfg.ServerName = c.serverName
+	}
+	if c.pinnedCert != "" {
+		want, err := parseFingerprint(c.pinnedCert)
+		if err != nil {
+			return nil, err
+		}
+		// The pin is the trust anchor, so the default chain verification,
+		// which would reject self-signed device certificates, is skipped.
+		cfg.InsecureSkipVerify = true
+		cfg.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return fmt.Errorf("%s presented no certificate", c.address)
+			}
+			got := sha256.Sum256(rawCerts[0])
+			if subtle.ConstantTimeCompare(got[:], want) != 1 {
+				return fmt.Errorf("certificate fingerprint %x for %s does not match pinned certificate", got, c.address)
+			}
+			return nil
+		}
+	}
+	return cfg, nil
+}
+
+// parseFingerprint decodes a hex SHA-256 fingerprint such as "AB:CD:..."
+// or "abcd...".
+func parseFingerprint(fingerprint string) ([]byte, error) {
+	b, err := hex.DecodeString(strings.ReplaceAll(fingerprint, ":", ""))
+	if err != nil || len(b) != sha256.Size {
+		return nil, fmt.Errorf("invalid SHA-256 certificate fingerprint %q", fingerprint)
+	}
+	return b, nil
+}
+
+// acquire reserves an in-flight RPC slot, returning a function that
+// releases it. It returns ctx's error if the context ends while waiting.
+func (c *Client) acquire(ctx context.Context) (func(), error) {
+	if c.inFlight == nil {
+		return func() {}, nil
+	}
+	select {
+	case c.inFlight <- struct{}{}:
+		return func() { <-c.inFlight }, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("waiting for an in-flight RPC slot on %s: %w", c.address, ctx.Err())
+	}
+}
+
 // Close closes the gRPC connection.
 func (c *Client) Close() error {
 	if c.conn != nil {
@@ -92,6 +217,15 @@ func (c *Client) Close() error {
 	return nil
 }
 
+// withCredentials attaches the configured username and password to the
+// outgoing RPC metadata, as expected by devices using per-request auth.
+func (c *Client) withCredentials(ctx context.Context) context.Context {
+	if c.username == "" {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, "username", c.username, "password", c.password)
+}
+
 // GNMIClient returns the underlying gNMI client.
 func (c *Client) GNMIClient() gnmipb.GNMIClient {
 	return c.gnmiClient
@@ -2,15 +2,23 @@ package gnmic
 
 import (
 	"context"
+	"crypto/sha256"
 	"crypto/tls"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"io"
 	"log/slog"
 	"os"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/metadata"
 )
@@ -63,6 +71,8 @@ type mockSubscribeStream struct {
 	responses []*gnmipb.SubscribeResponse
 	idx       int
 	sentReq   *gnmipb.SubscribeRequest
+	// endErr is returned once responses are exhausted; io.EOF if nil.
+	endErr error
 }
 
 func (m *mockSubscribeStream) Send(req *gnmipb.SubscribeRequest) error {
@@ -72,6 +82,9 @@ func (m *mockSubscribeStream) Send(req *gnmipb.SubscribeRequest) error {
 
 func (m *mockSubscribeStream) Recv() (*gnmipb.SubscribeResponse, error) {
 	if m.idx >= len(m.responses) {
+		if m.endErr != nil {
+			return nil, m.endErr
+		}
 		return nil, io.EOF
 	}
 	resp := m.responses[m.idx]
@@ -79,9 +92,9 @@ func (m *mockSubscribeStream) Recv() (*gnmipb.SubscribeResponse, error) {
 	return resp, nil
 }
 
-func (m *mockSubscribeStream) Header() (metadata.MD, error) { return nil, nil }
-func (m *mockSubscribeStream) Trailer() metadata.MD         { return nil }
-func (m *mockSubscribeStream) CloseSend() error             { return nil }
+func (m *mockSubscribeStream) Header() (metadata.MD, error)  { return nil, nil }
+func (m *mockSubscribeStream) Trailer() metadata.MD          { return nil }
+func (m *mockSubscribeStream) CloseSend() error              { return nil }
 func (m *mockSubscribeStream) Context() context.Context      { return context.Background() }
 func (m *mockSubscribeStream) SendMsg(msg interface{}) error { return nil }
 func (m *mockSubscribeStream) RecvMsg(msg interface{}) error { return nil }
@@ -168,6 +181,258 @@ func TestClient_Close(t *testing.T) {
 	}
 }
 
+func TestClient_TransportCredentials(t *testing.T) {
+	tests := []struct {
+		name         string
+		opts         []ClientOption
+		wantErr      bool
+		wantProtocol string
+	}{
+		{
+			name:    "no TLS and not insecure fails",
+			wantErr: true,
+		},
+		{
+			name:         "WithInsecure selects plaintext",
+			opts:         []ClientOption{WithInsecure()},
+			wantProtocol: "insecure",
+		},
+		{
+			name:         "TLS takes precedence over insecure",
+			opts:         []ClientOption{WithTLS(&tls.Config{}), WithInsecure()},
+			wantProtocol: "tls",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c := NewClient("10.0.0.1:6030", "", "", testLogger(), tc.opts...)
+			creds, err := c.transportCredentials()
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				if !containsStr(err.Error(), "TLS configuration is required") {
+					t.Errorf("error = %q, want to contain 'TLS configuration is required'", err.Error())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := creds.Info().SecurityProtocol; got != tc.wantProtocol {
+				t.Errorf("SecurityProtocol = %q, want %q", got, tc.wantProtocol)
+			}
+		})
+	}
+}
+
+func TestClient_PinnedCert(t *testing.T) {
+	deviceCert := []byte("device certificate DER")
+	sum := sha256.Sum256(deviceCert)
+	fingerprint := strings.ToUpper(hex.EncodeToString(sum[:]))
+	var colons []string
+	for i := 0; i < len(fingerprint); i += 2 {
+		colons = append(colons, fingerprint[i:i+2])
+	}
+
+	tests := []struct {
+		name        string
+		fingerprint string
+		certs       [][]byte
+		wantErr     bool
+	}{
+		{name: "matching fingerprint", fingerprint: fingerprint, certs: [][]byte{deviceCert}},
+		{name: "matching colon-separated fingerprint", fingerprint: strings.Join(colons, ":"), certs: [][]byte{deviceCert, []byte("intermediate")}},
+		{name: "mismatching fingerprint", fingerprint: fingerprint, certs: [][]byte{[]byte("other certificate")}, wantErr: true},
+		{name: "pinned leaf not first", fingerprint: fingerprint, certs: [][]byte{[]byte("other"), deviceCert}, wantErr: true},
+		{name: "no certificate", fingerprint: fingerprint, wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c := NewClient("10.0.0.1:6030", "", "", testLogger(), WithServerName("spine1.lab"), WithPinnedCert(tc.fingerprint))
+			cfg, err := c.buildTLSConfig()
+			if err != nil {
+				t.Fatalf("buildTLSConfig: %v", err)
+			}
+			if cfg.ServerName != "spine1.lab" {
+				t.Errorf("ServerName = %q, want spine1.lab", cfg.ServerName)
+			}
+			err = cfg.VerifyPeerCertificate(tc.certs, nil)
+			if tc.wantErr && err == nil {
+				t.Error("expected fingerprint verification error")
+			}
+			if !tc.wantErr && err != nil {
+				t.Errorf("unexpected verification error: %v", err)
+			}
+		})
+	}
+}
+
+func TestClient_TLSConfigOptions(t *testing.T) {
+	t.Run("server name alone enables TLS", func(t *testing.T) {
+		c := NewClient("10.0.0.1:6030", "", "", testLogger(), WithServerName("spine1.lab"))
+		creds, err := c.transportCredentials()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := creds.Info().SecurityProtocol; got != "tls" {
+			t.Errorf("SecurityProtocol = %q, want tls", got)
+		}
+	})
+
+	t.Run("base config is not modified", func(t *testing.T) {
+		base := &tls.Config{ServerName: "original"}
+		c := NewClient("10.0.0.1:6030", "", "", testLogger(), WithTLS(base), WithServerName("spine1.lab"))
+		cfg, err := c.buildTLSConfig()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cfg.ServerName != "spine1.lab" || base.ServerName != "original" {
+			t.Errorf("ServerName = %q (base %q), want spine1.lab (base original)", cfg.ServerName, base.ServerName)
+		}
+	})
+
+	t.Run("invalid fingerprint", func(t *testing.T) {
+		c := NewClient("10.0.0.1:6030", "", "", testLogger(), WithPinnedCert("not-hex"))
+		if _, err := c.transportCredentials(); err == nil {
+			t.Fatal("expected error for invalid fingerprint")
+		}
+	})
+}
+
+func TestClient_Capabilities(t *testing.T) {
+	tests := []struct {
+		name        string
+		capFunc     func(ctx context.Context, in *gnmipb.CapabilityRequest, opts ...grpc.CallOption) (*gnmipb.CapabilityResponse, error)
+		wantErr     bool
+		wantModels  []string
+		wantVersion string
+	}{
+		{
+			name: "returns supported models and encodings",
+			capFunc: func(ctx context.Context, in *gnmipb.CapabilityRequest, opts ...grpc.CallOption) (*gnmipb.CapabilityResponse, error) {
+				return &gnmipb.CapabilityResponse{
+					SupportedModels: []*gnmipb.ModelData{
+						{Name: "openconfig-interfaces", Organization: "OpenConfig working group", Version: "2.4.3"},
+						{Name: "openconfig-bgp", Organization: "OpenConfig working group", Version: "6.1.0"},
+					},
+					SupportedEncodings: []gnmipb.Encoding{gnmipb.Encoding_JSON_IETF, gnmipb.Encoding_PROTO},
+					GNMIVersion:        "0.7.0",
+				}, nil
+			},
+			wantModels:  []string{"openconfig-interfaces", "openconfig-bgp"},
+			wantVersion: "0.7.0",
+		},
+		{
+			name: "RPC error",
+			capFunc: func(ctx context.Context, in *gnmipb.CapabilityRequest, opts ...grpc.CallOption) (*gnmipb.CapabilityResponse, error) {
+				return nil, io.ErrUnexpectedEOF
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c := NewClient("10.0.0.1:6030", "admin", "secret", testLogger())
+			c.gnmiClient = &mockGNMIClient{capFunc: tc.capFunc}
+
+			resp, err := c.Capabilities(context.Background())
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.GetGNMIVersion() != tc.wantVersion {
+				t.Errorf("GNMIVersion = %q, want %q", resp.GetGNMIVersion(), tc.wantVersion)
+			}
+			var models []string
+			for _, m := range resp.GetSupportedModels() {
+				models = append(models, m.GetName())
+			}
+			if !equalStrings(models, tc.wantModels) {
+				t.Errorf("models = %v, want %v", models, tc.wantModels)
+			}
+		})
+	}
+
+	t.Run("fails when not connected", func(t *testing.T) {
+		c := NewClient("10.0.0.1:6030", "admin", "secret", testLogger())
+		if _, err := c.Capabilities(context.Background()); err == nil || !containsStr(err.Error(), "not connected") {
+			t.Errorf("error = %v, want to contain 'not connected'", err)
+		}
+	})
+}
+
+func TestClient_CredentialsMetadata(t *testing.T) {
+	tests := []struct {
+		name         string
+		username     string
+		password     string
+		wantUsername []string
+		wantPassword []string
+	}{
+		{
+			name:         "credentials attached to outgoing context",
+			username:     "admin",
+			password:     "secret",
+			wantUsername: []string{"admin"},
+			wantPassword: []string{"secret"},
+		},
+		{
+			name: "no metadata without username",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var getMD, setMD, subMD metadata.MD
+			mock := &mockGNMIClient{
+				getFunc: func(ctx context.Context, in *gnmipb.GetRequest, opts ...grpc.CallOption) (*gnmipb.GetResponse, error) {
+					getMD, _ = metadata.FromOutgoingContext(ctx)
+					return &gnmipb.GetResponse{}, nil
+				},
+				setFunc: func(ctx context.Context, in *gnmipb.SetRequest, opts ...grpc.CallOption) (*gnmipb.SetResponse, error) {
+					setMD, _ = metadata.FromOutgoingContext(ctx)
+					return &gnmipb.SetResponse{}, nil
+				},
+				subscribeFunc: func(ctx context.Context, opts ...grpc.CallOption) (gnmipb.GNMI_SubscribeClient, error) {
+					subMD, _ = metadata.FromOutgoingContext(ctx)
+					return &mockSubscribeStream{}, nil
+				},
+			}
+
+			c := NewClient("10.0.0.1:6030", tc.username, tc.password, testLogger())
+			c.gnmiClient = mock
+
+			if _, err := c.Get(context.Background(), []string{"/interfaces"}); err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			if _, err := c.Set(context.Background(), []SetRequest{{Operation: SetDelete, Path: "/interfaces"}}); err != nil {
+				t.Fatalf("Set: %v", err)
+			}
+			if err := c.Subscribe(context.Background(), []string{"/interfaces"}, gnmipb.SubscriptionList_ONCE, func(*gnmipb.SubscribeResponse) error { return nil }); err != nil {
+				t.Fatalf("Subscribe: %v", err)
+			}
+
+			for rpc, md := range map[string]metadata.MD{"Get": getMD, "Set": setMD, "Subscribe": subMD} {
+				if got := md.Get("username"); !equalStrings(got, tc.wantUsername) {
+					t.Errorf("%s username metadata = %v, want %v", rpc, got, tc.wantUsername)
+				}
+				if got := md.Get("password"); !equalStrings(got, tc.wantPassword) {
+					t.Errorf("%s password metadata = %v, want %v", rpc, got, tc.wantPassword)
+				}
+			}
+		})
+	}
+}
+
 func TestClient_Get(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -240,6 +505,114 @@ func TestClient_Get(t *testing.T) {
 	}
 }
 
+func TestClient_Get_Options(t *testing.T) {
+	tests := []struct {
+		name         string
+		opts         []GetOption
+		wantEncoding gnmipb.Encoding
+		wantType     gnmipb.GetRequest_DataType
+	}{
+		{
+			name:         "defaults",
+			wantEncoding: gnmipb.Encoding_JSON_IETF,
+			wantType:     gnmipb.GetRequest_ALL,
+		},
+		{
+			name:         "proto encoding",
+			opts:         []GetOption{WithEncoding(gnmipb.Encoding_PROTO)},
+			wantEncoding: gnmipb.Encoding_PROTO,
+			wantType:     gnmipb.GetRequest_ALL,
+		},
+		{
+			name:         "ascii state only",
+			opts:         []GetOption{WithEncoding(gnmipb.Encoding_ASCII), WithDataType(gnmipb.GetRequest_STATE)},
+			wantEncoding: gnmipb.Encoding_ASCII,
+			wantType:     gnmipb.GetRequest_STATE,
+		},
+		{
+			name:         "config only",
+			opts:         []GetOption{WithDataType(gnmipb.GetRequest_CONFIG)},
+			wantEncoding: gnmipb.Encoding_JSON_IETF,
+			wantType:     gnmipb.GetRequest_CONFIG,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var capturedReq *gnmipb.GetRequest
+			c := NewClient("10.0.0.1:6030", "admin", "secret", testLogger())
+			c.gnmiClient = &mockGNMIClient{
+				getFunc: func(ctx context.Context, in *gnmipb.GetRequest, opts ...grpc.CallOption) (*gnmipb.GetResponse, error) {
+					capturedReq = in
+					return &gnmipb.GetResponse{}, nil
+				},
+			}
+
+			if _, err := c.Get(context.Background(), []string{"/interfaces"}, tc.opts...); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if capturedReq.Encoding != tc.wantEncoding {
+				t.Errorf("encoding = %v, want %v", capturedReq.Encoding, tc.wantEncoding)
+			}
+			if capturedReq.Type != tc.wantType {
+				t.Errorf("type = %v, want %v", capturedReq.Type, tc.wantType)
+			}
+		})
+	}
+}
+
+func TestParseEncodingAndDataType(t *testing.T) {
+	if got, err := ParseEncoding(""); err != nil || got != gnmipb.Encoding_JSON_IETF {
+		t.Errorf("ParseEncoding(\"\") = %v, %v; want JSON_IETF", got, err)
+	}
+	if got, err := ParseEncoding("proto"); err != nil || got != gnmipb.Encoding_PROTO {
+		t.Errorf("ParseEncoding(proto) = %v, %v; want PROTO", got, err)
+	}
+	if _, err := ParseEncoding("xml"); err == nil {
+		t.Error("ParseEncoding(xml) expected error")
+	}
+	if got, err := ParseDataType(""); err != nil || got != gnmipb.GetRequest_ALL {
+		t.Errorf("ParseDataType(\"\") = %v, %v; want ALL", got, err)
+	}
+	if got, err := ParseDataType("state"); err != nil || got != gnmipb.GetRequest_STATE {
+		t.Errorf("ParseDataType(state) = %v, %v; want STATE", got, err)
+	}
+	if _, err := ParseDataType("running"); err == nil {
+		t.Error("ParseDataType(running) expected error")
+	}
+}
+
+func TestPollUntil(t *testing.T) {
+	calls := 0
+	g := GetterFunc(func(ctx context.Context, paths []string, opts ...GetOption) (*gnmipb.GetResponse, error) {
+		calls++
+		if calls == 1 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return &gnmipb.GetResponse{Notification: make([]*gnmipb.Notification, calls)}, nil
+	})
+
+	resp, err := PollUntil(context.Background(), g, []string{"/interfaces"}, time.Millisecond, func(r *gnmipb.GetResponse) bool {
+		return len(r.Notification) == 3
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(resp.Notification) != 3 || calls != 3 {
+		t.Errorf("got %d notifications after %d calls, want 3 after 3", len(resp.Notification), calls)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	resp, err = PollUntil(ctx, g, []string{"/interfaces"}, time.Millisecond, func(*gnmipb.GetResponse) bool { return false })
+	if err != context.DeadlineExceeded {
+		t.Errorf("error = %v, want context.DeadlineExceeded", err)
+	}
+	if resp == nil {
+		t.Error("expected the last response on timeout")
+	}
+}
+
 func TestClient_Set(t *testing.T) {
 	tests := []struct {
 		name         string
@@ -313,6 +686,9 @@ func TestClient_Set(t *testing.T) {
 				if tc.errContains != "" && !containsStr(err.Error(), tc.errContains) {
 					t.Errorf("error = %q, want to contain %q", err.Error(), tc.errContains)
 				}
+				if !errors.Is(err, ErrInvalidRequest) {
+					t.Errorf("error = %v, want ErrInvalidRequest", err)
+				}
 				return
 			}
 			if err != nil {
@@ -331,6 +707,258 @@ func TestClient_Set(t *testing.T) {
 	}
 }
 
+func TestClient_Set_UnionReplace(t *testing.T) {
+	tests := []struct {
+		name              string
+		prefix            string
+		requests          []SetRequest
+		gnmiVersion       string
+		wantUnionReplaces int
+		wantUpdates       int
+		wantPrefix        []string
+		wantErr           bool
+		errContains       string
+	}{
+		{
+			name: "union_replace operation",
+			requests: []SetRequest{
+				{Operation: SetUnionReplace, Path: "openconfig:/interfaces", Value: map[string]interface{}{"interface": []interface{}{}}},
+			},
+			gnmiVersion:       "0.10.0",
+			wantUnionReplaces: 1,
+		},
+		{
+			name:   "union_replace with prefix",
+			prefix: "/interfaces/interface[name=Ethernet1]",
+			requests: []SetRequest{
+				{Operation: SetUnionReplace, Path: "config", Value: map[string]interface{}{"mtu": 9000}},
+				{Operation: SetUpdate, Path: "config/description", Value: "uplink"},
+			},
+			gnmiVersion:       "0.10.1",
+			wantUnionReplaces: 1,
+			wantUpdates:       1,
+			wantPrefix:        []string{"interfaces", "interface"},
+		},
+		{
+			name:   "prefix without union_replace skips capabilities",
+			prefix: "/system",
+			requests: []SetRequest{
+				{Operation: SetUpdate, Path: "config/hostname", Value: "r1"},
+			},
+			gnmiVersion: "0.7.0",
+			wantUpdates: 1,
+			wantPrefix:  []string{"system"},
+		},
+		{
+			name: "target too old for union_replace",
+			requests: []SetRequest{
+				{Operation: SetUnionReplace, Path: "/interfaces", Value: map[string]interface{}{}},
+			},
+			gnmiVersion: "0.7.0",
+			wantErr:     true,
+			errContains: "does not support union_replace",
+		},
+		{
+			name: "target without gNMI version",
+			requests: []SetRequest{
+				{Operation: SetUnionReplace, Path: "/interfaces", Value: map[string]interface{}{}},
+			},
+			wantErr:     true,
+			errContains: "does not support union_replace",
+		},
+		{
+			name:   "invalid prefix returns error",
+			prefix: "/interfaces/interface[name=Ethernet1",
+			requests: []SetRequest{
+				{Operation: SetUpdate, Path: "config/mtu", Value: 9000},
+			},
+			wantErr:     true,
+			errContains: "invalid prefix",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var capturedReq *gnmipb.SetRequest
+			mock := &mockGNMIClient{
+				setFunc: func(ctx context.Context, in *gnmipb.SetRequest, opts ...grpc.CallOption) (*gnmipb.SetResponse, error) {
+					capturedReq = in
+					return &gnmipb.SetResponse{}, nil
+				},
+				capFunc: func(ctx context.Context, in *gnmipb.CapabilityRequest, opts ...grpc.CallOption) (*gnmipb.CapabilityResponse, error) {
+					return &gnmipb.CapabilityResponse{GNMIVersion: tc.gnmiVersion}, nil
+				},
+			}
+
+			c := NewClient("10.0.0.1:6030", "admin", "secret", testLogger())
+			c.gnmiClient = mock
+
+			_, err := c.SetWithPrefix(context.Background(), tc.prefix, tc.requests)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				if tc.errContains != "" && !containsStr(err.Error(), tc.errContains) {
+					t.Errorf("error = %q, want to contain %q", err.Error(), tc.errContains)
+				}
+				if capturedReq != nil {
+					t.Error("Set RPC should not be sent on error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(capturedReq.UnionReplace) != tc.wantUnionReplaces {
+				t.Errorf("union_replaces = %d, want %d", len(capturedReq.UnionReplace), tc.wantUnionReplaces)
+			}
+			if len(capturedReq.Update) != tc.wantUpdates {
+				t.Errorf("updates = %d, want %d", len(capturedReq.Update), tc.wantUpdates)
+			}
+			var prefix []string
+			for _, e := range capturedReq.GetPrefix().GetElem() {
+				prefix = append(prefix, e.GetName())
+			}
+			if !equalStrings(prefix, tc.wantPrefix) {
+				t.Errorf("prefix = %v, want %v", prefix, tc.wantPrefix)
+			}
+		})
+	}
+}
+
+func TestVersionAtLeast(t *testing.T) {
+	tests := []struct {
+		version string
+		want    bool
+	}{
+		{"0.10.0", true},
+		{"0.10.1", true},
+		{"1.0", true},
+		{"0.9.9", false},
+		{"0.7.0", false},
+		{"", false},
+		{"0.10.x", false},
+	}
+
+	for _, tc := range tests {
+		if got := versionAtLeast(tc.version, unionReplaceMinVersion); got != tc.want {
+			t.Errorf("versionAtLeast(%q) = %v, want %v", tc.version, got, tc.want)
+		}
+	}
+}
+
+func TestClient_RPCMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	RegisterMetrics(reg)
+
+	c := NewClient("10.0.0.1:6030", "admin", "secret", testLogger())
+	fail := false
+	c.gnmiClient = &mockGNMIClient{
+		setFunc: func(ctx context.Context, in *gnmipb.SetRequest, opts ...grpc.CallOption) (*gnmipb.SetResponse, error) {
+			if fail {
+				return nil, io.ErrUnexpectedEOF
+			}
+			return &gnmipb.SetResponse{}, nil
+		},
+	}
+	success := RPCsTotal.WithLabelValues("set", "success")
+	failure := RPCsTotal.WithLabelValues("set", "error")
+	beforeSuccess, beforeFailure := testutil.ToFloat64(success), testutil.ToFloat64(failure)
+
+	req := []SetRequest{{Operation: SetUpdate, Path: "/system/config/hostname", Value: "r1"}}
+	if _, err := c.Set(context.Background(), req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	fail = true
+	if _, err := c.Set(context.Background(), req); err == nil {
+		t.Fatal("expected error")
+	}
+
+	if got := testutil.ToFloat64(success) - beforeSuccess; got != 1 {
+		t.Errorf("set success counter increased by %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(failure) - beforeFailure; got != 1 {
+		t.Errorf("set error counter increased by %v, want 1", got)
+	}
+	if n, err := testutil.GatherAndCount(reg, "helios_gnmi_rpc_duration_seconds"); err != nil || n == 0 {
+		t.Errorf("duration histogram series = %d, %v; want at least 1", n, err)
+	}
+}
+
+func TestClient_MaxInFlight(t *testing.T) {
+	const limit = 2
+	c := NewClient("10.0.0.1:6030", "admin", "secret", testLogger(), WithMaxInFlight(limit))
+
+	var mu sync.Mutex
+	inFlight, peak := 0, 0
+	track := func() {
+		mu.Lock()
+		inFlight++
+		if inFlight > peak {
+			peak = inFlight
+		}
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+	}
+	c.gnmiClient = &mockGNMIClient{
+		getFunc: func(ctx context.Context, in *gnmipb.GetRequest, opts ...grpc.CallOption) (*gnmipb.GetResponse, error) {
+			track()
+			return &gnmipb.GetResponse{}, nil
+		},
+		setFunc: func(ctx context.Context, in *gnmipb.SetRequest, opts ...grpc.CallOption) (*gnmipb.SetResponse, error) {
+			track()
+			return &gnmipb.SetResponse{}, nil
+		},
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var err error
+			if i%2 == 0 {
+				_, err = c.Get(context.Background(), []string{"/interfaces"})
+			} else {
+				_, err = c.Set(context.Background(), []SetRequest{{Operation: SetDelete, Path: "/interfaces"}})
+			}
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if peak > limit {
+		t.Errorf("peak concurrent RPCs = %d, want at most %d", peak, limit)
+	}
+
+	t.Run("context ends while waiting", func(t *testing.T) {
+		c := NewClient("10.0.0.1:6030", "admin", "secret", testLogger(), WithMaxInFlight(1))
+		c.gnmiClient = &mockGNMIClient{}
+		c.inFlight <- struct{}{}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		if _, err := c.Get(ctx, []string{"/interfaces"}); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("err = %v, want context.DeadlineExceeded", err)
+		}
+	})
+
+	t.Run("unlimited by default", func(t *testing.T) {
+		if c := NewClient("10.0.0.1:6030", "admin", "secret", testLogger()); c.inFlight != nil {
+			t.Error("inFlight should be nil by default")
+		}
+	})
+}
+
 func TestClient_Set_ValueEncoding(t *testing.T) {
 	var capturedReq *gnmipb.SetRequest
 	mock := &mockGNMIClient{
@@ -438,6 +1066,299 @@ func TestClient_Subscribe(t *testing.T) {
 	if len(subList.Subscription) != 1 {
 		t.Fatalf("subscriptions = %d, want 1", len(subList.Subscription))
 	}
+	if subList.Subscription[0].Mode != gnmipb.SubscriptionMode_TARGET_DEFINED {
+		t.Errorf("subscription mode = %v, want TARGET_DEFINED", subList.Subscription[0].Mode)
+	}
+}
+
+func TestClient_SubscribeTo(t *testing.T) {
+	stream := &mockSubscribeStream{}
+	c := NewClient("10.0.0.1:6030", "admin", "secret", testLogger())
+	c.gnmiClient = &mockGNMIClient{
+		subscribeFunc: func(ctx context.Context, opts ...grpc.CallOption) (gnmipb.GNMI_SubscribeClient, error) {
+			return stream, nil
+		},
+	}
+
+	subs := []Subscription{
+		{Path: "/interfaces/interface/state/counters", Mode: gnmipb.SubscriptionMode_SAMPLE, SampleInterval: 10 * time.Second, HeartbeatInterval: time.Minute},
+		{Path: "/interfaces/interface/state/oper-status", Mode: gnmipb.SubscriptionMode_ON_CHANGE, HeartbeatInterval: 30 * time.Second},
+	}
+	err := c.SubscribeTo(context.Background(), subs, gnmipb.SubscriptionList_STREAM, func(*gnmipb.SubscribeResponse) error { return nil })
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got := stream.sentReq.GetSubscribe().Subscription
+	if len(got) != 2 {
+		t.Fatalf("subscriptions = %d, want 2", len(got))
+	}
+	if got[0].Mode != gnmipb.SubscriptionMode_SAMPLE {
+		t.Errorf("subscription[0] mode = %v, want SAMPLE", got[0].Mode)
+	}
+	if got[0].SampleInterval != uint64(10*time.Second) {
+		t.Errorf("subscription[0] sample interval = %d, want %d", got[0].SampleInterval, uint64(10*time.Second))
+	}
+	if got[0].HeartbeatInterval != uint64(time.Minute) {
+		t.Errorf("subscription[0] heartbeat interval = %d, want %d", got[0].HeartbeatInterval, uint64(time.Minute))
+	}
+	if got[1].Mode != gnmipb.SubscriptionMode_ON_CHANGE {
+		t.Errorf("subscription[1] mode = %v, want ON_CHANGE", got[1].Mode)
+	}
+	if got[1].SampleInterval != 0 {
+		t.Errorf("subscription[1] sample interval = %d, want 0", got[1].SampleInterval)
+	}
+	if got[1].HeartbeatInterval != uint64(30*time.Second) {
+		t.Errorf("subscription[1] heartbeat interval = %d, want %d", got[1].HeartbeatInterval, uint64(30*time.Second))
+	}
+}
+
+func TestClient_Subscribe_OnceReturnsAtSync(t *testing.T) {
+	stream := &mockSubscribeStream{
+		responses: []*gnmipb.SubscribeResponse{
+			{Response: &gnmipb.SubscribeResponse_Update{Update: &gnmipb.Notification{}}},
+			{Response: &gnmipb.SubscribeResponse_SyncResponse{SyncResponse: true}},
+		},
+		// The target keeps the stream open after sync; reading past it fails.
+		endErr: errors.New("read past sync_response"),
+	}
+	c := NewClient("10.0.0.1:6030", "admin", "secret", testLogger())
+	c.gnmiClient = &mockGNMIClient{
+		subscribeFunc: func(ctx context.Context, opts ...grpc.CallOption) (gnmipb.GNMI_SubscribeClient, error) {
+			return stream, nil
+		},
+	}
+
+	var received int
+	err := c.Subscribe(context.Background(), []string{"/interfaces"}, gnmipb.SubscriptionList_ONCE, func(*gnmipb.SubscribeResponse) error {
+		received++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if received != 2 {
+		t.Errorf("handler called %d times, want 2 (update and sync)", received)
+	}
+
+	t.Run("STREAM keeps reading after sync", func(t *testing.T) {
+		stream.idx = 0
+		err := c.Subscribe(context.Background(), []string{"/interfaces"}, gnmipb.SubscriptionList_STREAM, func(*gnmipb.SubscribeResponse) error { return nil })
+		if err == nil || !strings.Contains(err.Error(), "read past sync_response") {
+			t.Errorf("error = %v, want the stream error after sync", err)
+		}
+	})
+}
+
+func TestClient_SubscribeValues(t *testing.T) {
+	update := func(name, val string) *gnmipb.SubscribeResponse {
+		return &gnmipb.SubscribeResponse{
+			Response: &gnmipb.SubscribeResponse_Update{
+				Update: &gnmipb.Notification{
+					Prefix: &gnmipb.Path{Origin: "openconfig", Elem: []*gnmipb.PathElem{
+						{Name: "interfaces"},
+						{Name: "interface", Key: map[string]string{"name": name}},
+					}},
+					Update: []*gnmipb.Update{{
+						Path: &gnmipb.Path{Elem: []*gnmipb.PathElem{{Name: "state"}}},
+						Val:  &gnmipb.TypedValue{Value: &gnmipb.TypedValue_JsonIetfVal{JsonIetfVal: []byte(val)}},
+					}},
+				},
+			},
+		}
+	}
+	stream := &mockSubscribeStream{
+		responses: []*gnmipb.SubscribeResponse{
+			update("Ethernet1", `{"oper-status":"UP","mtu":9214}`),
+			{Response: &gnmipb.SubscribeResponse_SyncResponse{SyncResponse: true}},
+			update("Ethernet2", `{"oper-status":"DOWN"}`),
+		},
+	}
+	c := NewClient("10.0.0.1:6030", "admin", "secret", testLogger())
+	c.gnmiClient = &mockGNMIClient{
+		subscribeFunc: func(ctx context.Context, opts ...grpc.CallOption) (gnmipb.GNMI_SubscribeClient, error) {
+			return stream, nil
+		},
+	}
+
+	got := make(map[string]interface{})
+	err := c.SubscribeValues(context.Background(), []string{"/interfaces/interface/state"}, gnmipb.SubscriptionList_ONCE, func(path string, val interface{}) {
+		got[path] = val
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := map[string]interface{}{
+		"openconfig:/interfaces/interface[name=Ethernet1]/state": map[string]interface{}{"oper-status": "UP", "mtu": float64(9214)},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("values = %v, want %v", got, want)
+	}
+	if mode := stream.sentReq.GetSubscribe().Mode; mode != gnmipb.SubscriptionList_ONCE {
+		t.Errorf("mode = %v, want ONCE", mode)
+	}
+
+	t.Run("invalid JSON", func(t *testing.T) {
+		stream.responses = []*gnmipb.SubscribeResponse{update("Ethernet1", `{`)}
+		stream.idx = 0
+		err := c.SubscribeValues(context.Background(), []string{"/interfaces"}, gnmipb.SubscriptionList_ONCE, func(string, interface{}) {})
+		if err == nil || !strings.Contains(err.Error(), "decoding") {
+			t.Errorf("error = %v, want decoding error", err)
+		}
+	})
+}
+
+func TestClient_SubscribeTo_InvalidIntervals(t *testing.T) {
+	tests := []struct {
+		name string
+		sub  Subscription
+	}{
+		{"sample interval on ON_CHANGE", Subscription{Path: "/interfaces", Mode: gnmipb.SubscriptionMode_ON_CHANGE, SampleInterval: time.Second}},
+		{"negative heartbeat", Subscription{Path: "/interfaces", Mode: gnmipb.SubscriptionMode_SAMPLE, HeartbeatInterval: -time.Second}},
+		{"invalid path", Subscription{Path: "/interfaces/interface[name=Ethernet1", Mode: gnmipb.SubscriptionMode_SAMPLE}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c := NewClient("10.0.0.1:6030", "admin", "secret", testLogger())
+			c.gnmiClient = &mockGNMIClient{
+				subscribeFunc: func(ctx context.Context, opts ...grpc.CallOption) (gnmipb.GNMI_SubscribeClient, error) {
+					t.Error("stream should not be opened for an invalid subscription")
+					return &mockSubscribeStream{}, nil
+				},
+			}
+			err := c.SubscribeTo(context.Background(), []Subscription{tc.sub}, gnmipb.SubscriptionList_STREAM, nil)
+			if err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestParseSubscriptionMode(t *testing.T) {
+	tests := []struct {
+		mode    string
+		want    gnmipb.SubscriptionMode
+		wantErr bool
+	}{
+		{"", gnmipb.SubscriptionMode_TARGET_DEFINED, false},
+		{"sample", gnmipb.SubscriptionMode_SAMPLE, false},
+		{"on_change", gnmipb.SubscriptionMode_ON_CHANGE, false},
+		{"TARGET_DEFINED", gnmipb.SubscriptionMode_TARGET_DEFINED, false},
+		{"poll", 0, true},
+	}
+
+	for _, tc := range tests {
+		got, err := ParseSubscriptionMode(tc.mode)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("ParseSubscriptionMode(%q) expected error", tc.mode)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("ParseSubscriptionMode(%q) = %v, %v; want %v", tc.mode, got, err, tc.want)
+		}
+	}
+}
+
+func TestDecodeGetResponse(t *testing.T) {
+	ethernet1 := &gnmipb.PathElem{Name: "interface", Key: map[string]string{"name": "Ethernet1/1"}}
+
+	tests := []struct {
+		name string
+		resp *gnmipb.GetResponse
+		want map[string]interface{}
+	}{
+		{
+			name: "single JSON_IETF update",
+			resp: &gnmipb.GetResponse{
+				Notification: []*gnmipb.Notification{{
+					Update: []*gnmipb.Update{{
+						Path: &gnmipb.Path{Elem: []*gnmipb.PathElem{{Name: "interfaces"}, ethernet1, {Name: "state"}}},
+						Val:  &gnmipb.TypedValue{Value: &gnmipb.TypedValue_JsonIetfVal{JsonIetfVal: []byte(`{"oper-status":"UP","mtu":9000}`)}},
+					}},
+				}},
+			},
+			want: map[string]interface{}{
+				"/interfaces/interface[name=Ethernet1/1]/state": map[string]interface{}{"oper-status": "UP", "mtu": float64(9000)},
+			},
+		},
+		{
+			name: "multiple updates with prefix and scalar values",
+			resp: &gnmipb.GetResponse{
+				Notification: []*gnmipb.Notification{
+					{
+						Prefix: &gnmipb.Path{Origin: "openconfig", Elem: []*gnmipb.PathElem{{Name: "interfaces"}, ethernet1}},
+						Update: []*gnmipb.Update{
+							{
+								Path: &gnmipb.Path{Elem: []*gnmipb.PathElem{{Name: "state"}, {Name: "oper-status"}}},
+								Val:  &gnmipb.TypedValue{Value: &gnmipb.TypedValue_StringVal{StringVal: "UP"}},
+							},
+							{
+								Path: &gnmipb.Path{Elem: []*gnmipb.PathElem{{Name: "state"}, {Name: "enabled"}}},
+								Val:  &gnmipb.TypedValue{Value: &gnmipb.TypedValue_BoolVal{BoolVal: true}},
+							},
+						},
+					},
+					{
+						Update: []*gnmipb.Update{{
+							Path: &gnmipb.Path{Elem: []*gnmipb.PathElem{{Name: "system"}, {Name: "state"}, {Name: "boot-time"}}},
+							Val:  &gnmipb.TypedValue{Value: &gnmipb.TypedValue_UintVal{UintVal: 1700000000}},
+						}},
+					},
+				},
+			},
+			want: map[string]interface{}{
+				"openconfig:/interfaces/interface[name=Ethernet1/1]/state/oper-status": "UP",
+				"openconfig:/interfaces/interface[name=Ethernet1/1]/state/enabled":     true,
+				"/system/state/boot-time": uint64(1700000000),
+			},
+		},
+		{
+			name: "empty response",
+			resp: &gnmipb.GetResponse{},
+			want: map[string]interface{}{},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := DecodeGetResponse(tc.resp)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("DecodeGetResponse() = %#v, want %#v", got, tc.want)
+			}
+		})
+	}
+
+	t.Run("invalid JSON", func(t *testing.T) {
+		resp := &gnmipb.GetResponse{
+			Notification: []*gnmipb.Notification{{
+				Update: []*gnmipb.Update{{
+					Path: &gnmipb.Path{Elem: []*gnmipb.PathElem{{Name: "system"}}},
+					Val:  &gnmipb.TypedValue{Value: &gnmipb.TypedValue_JsonIetfVal{JsonIetfVal: []byte(`{`)}},
+				}},
+			}},
+		}
+		if _, err := DecodeGetResponse(resp); err == nil {
+			t.Fatal("expected error for invalid JSON value")
+		}
+	})
+}
+
+func TestJoinPaths_RoundTrip(t *testing.T) {
+	want := `openconfig:/acl/entry[description=a\]b][id=10]/state`
+	p, err := parsePath(want)
+	if err != nil {
+		t.Fatalf("parsePath error: %v", err)
+	}
+	if got := joinPaths(nil, p); got != want {
+		t.Errorf("joinPaths() = %q, want %q", got, want)
+	}
 }
 
 func TestParsePath(t *testing.T) {
@@ -471,6 +1392,141 @@ func TestParsePath(t *testing.T) {
 	}
 }
 
+func TestParsePath_Keys(t *testing.T) {
+	type elem struct {
+		name string
+		keys map[string]string
+	}
+	tests := []struct {
+		name    string
+		path    string
+		want    []elem
+		wantErr bool
+	}{
+		{
+			name: "single key",
+			path: "/interfaces/interface[name=Ethernet1]/config",
+			want: []elem{
+				{name: "interfaces"},
+				{name: "interface", keys: map[string]string{"name": "Ethernet1"}},
+				{name: "config"},
+			},
+		},
+		{
+			name: "multiple keys",
+			path: "/network-instances/network-instance[name=default]/protocols/protocol[identifier=BGP][name=bgp]",
+			want: []elem{
+				{name: "network-instances"},
+				{name: "network-instance", keys: map[string]string{"name": "default"}},
+				{name: "protocols"},
+				{name: "protocol", keys: map[string]string{"identifier": "BGP", "name": "bgp"}},
+			},
+		},
+		{
+			name: "slash in key value",
+			path: "/interfaces/interface[name=Ethernet1/1]/state/counters",
+			want: []elem{
+				{name: "interfaces"},
+				{name: "interface", keys: map[string]string{"name": "Ethernet1/1"}},
+				{name: "state"},
+				{name: "counters"},
+			},
+		},
+		{
+			name: "escaped bracket in key value",
+			path: `/acl/entry[description=a\]b]`,
+			want: []elem{
+				{name: "acl"},
+				{name: "entry", keys: map[string]string{"description": "a]b"}},
+			},
+		},
+		{
+			name: "empty key value",
+			path: "/interfaces/interface[name=]",
+			want: []elem{
+				{name: "interfaces"},
+				{name: "interface", keys: map[string]string{"name": ""}},
+			},
+		},
+		{name: "unterminated bracket", path: "/interfaces/interface[name=Ethernet1", wantErr: true},
+		{name: "missing equals", path: "/interfaces/interface[name]", wantErr: true},
+		{name: "empty key name", path: "/interfaces/interface[=Ethernet1]", wantErr: true},
+		{name: "missing element name", path: "/interfaces/[name=Ethernet1]", wantErr: true},
+		{name: "stray closing bracket", path: "/interfaces/interface]", wantErr: true},
+		{name: "text after key", path: "/interfaces/interface[name=Ethernet1]x", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := parsePath(tc.path)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("parsePath(%q) expected error, got %v", tc.path, p)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parsePath error: %v", err)
+			}
+			if len(p.Elem) != len(tc.want) {
+				t.Fatalf("elem count = %d, want %d", len(p.Elem), len(tc.want))
+			}
+			for i, want := range tc.want {
+				got := p.Elem[i]
+				if got.Name != want.name {
+					t.Errorf("elem[%d].Name = %q, want %q", i, got.Name, want.name)
+				}
+				if len(got.Key) != len(want.keys) {
+					t.Errorf("elem[%d].Key = %v, want %v", i, got.Key, want.keys)
+					continue
+				}
+				for k, v := range want.keys {
+					if got.Key[k] != v {
+						t.Errorf("elem[%d].Key[%q] = %q, want %q", i, k, got.Key[k], v)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestParsePath_Origin(t *testing.T) {
+	tests := []struct {
+		name       string
+		path       string
+		wantOrigin string
+		wantElem   []string
+	}{
+		{"openconfig origin", "openconfig:/interfaces/interface", "openconfig", []string{"interfaces", "interface"}},
+		{"vendor origin", "eos_native:/Sysdb/interface", "eos_native", []string{"Sysdb", "interface"}},
+		{"bare path", "/interfaces/interface", "", []string{"interfaces", "interface"}},
+		{"origin only", "openconfig:", "openconfig", nil},
+		{"origin with root", "openconfig:/", "openconfig", nil},
+		{"module-qualified element", "openconfig-interfaces:interfaces/interface", "", []string{"openconfig-interfaces:interfaces", "interface"}},
+		{"colon in key value", "/interfaces/interface[name=eth0:1]", "", []string{"interfaces", "interface"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := parsePath(tc.path)
+			if err != nil {
+				t.Fatalf("parsePath error: %v", err)
+			}
+			if p.Origin != tc.wantOrigin {
+				t.Errorf("Origin = %q, want %q", p.Origin, tc.wantOrigin)
+			}
+			if len(p.Elem) != len(tc.wantElem) {
+				t.Fatalf("elem count = %d, want %d", len(p.Elem), len(tc.wantElem))
+			}
+			for i, want := range tc.wantElem {
+				if p.Elem[i].Name != want {
+					t.Errorf("elem[%d] = %q, want %q", i, p.Elem[i].Name, want)
+				}
+			}
+		})
+	}
+}
+
 func TestEncodeValue(t *testing.T) {
 	tests := []struct {
 		name  string
@@ -515,6 +1571,8 @@ func TestSplitPath(t *testing.T) {
 		{"trailing slash", "/a/b/", []string{"a", "b"}},
 		{"double slash", "/a//b", []string{"a", "b"}},
 		{"no leading slash", "a/b", []string{"a", "b"}},
+		{"slash inside key", "/a[k=x/y]/b", []string{"a[k=x/y]", "b"}},
+		{"escaped bracket inside key", `/a[k=x\]/y]/b`, []string{`a[k=x\]/y]`, "b"}},
 	}
 
 	for _, tc := range tests {
@@ -542,6 +1600,9 @@ func TestSetOperation_Constants(t *testing.T) {
 	if SetDelete != "delete" {
 		t.Errorf("SetDelete = %q, want %q", SetDelete, "delete")
 	}
+	if SetUnionReplace != "union_replace" {
+		t.Errorf("SetUnionReplace = %q, want %q", SetUnionReplace, "union_replace")
+	}
 }
 
 func containsStr(s, substr string) bool {
@@ -552,3 +1613,15 @@ func containsStr(s, substr string) bool {
 	}
 	return false
 }
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,119 @@
+package gnmic
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+// DecodeGetResponse flattens the updates in resp into a map from path, in
+// the same string form parsePath accepts, to decoded value. JSON values are
+// unmarshalled so they can be navigated by templates. When several updates
+// share a path the last one wins.
+func DecodeGetResponse(resp *gnmipb.GetResponse) (map[string]interface{}, error) {
+	out := make(map[string]interface{})
+	for _, n := range resp.GetNotification() {
+		for _, u := range n.GetUpdate() {
+			path := joinPaths(n.GetPrefix(), u.GetPath())
+			val, err := DecodeValue(u.GetVal())
+			if err != nil {
+				return nil, fmt.Errorf("decoding %s: %w", path, err)
+			}
+			out[path] = val
+		}
+	}
+	return out, nil
+}
+
+// DecodeValue converts a gNMI typed value into a plain Go value. JSON and
+// JSON_IETF values are unmarshalled; leaf-lists become slices.
+func DecodeValue(tv *gnmipb.TypedValue) (interface{}, error) {
+	switch v := tv.GetValue().(type) {
+	case nil:
+		return nil, nil
+	case *gnmipb.TypedValue_JsonIetfVal:
+		return decodeJSON(v.JsonIetfVal)
+	case *gnmipb.TypedValue_JsonVal:
+		return decodeJSON(v.JsonVal)
+	case *gnmipb.TypedValue_StringVal:
+		return v.StringVal, nil
+	case *gnmipb.TypedValue_AsciiVal:
+		return v.AsciiVal, nil
+	case *gnmipb.TypedValue_IntVal:
+		return v.IntVal, nil
+	case *gnmipb.TypedValue_UintVal:
+		return v.UintVal, nil
+	case *gnmipb.TypedValue_BoolVal:
+		return v.BoolVal, nil
+	case *gnmipb.TypedValue_FloatVal:
+		return v.FloatVal, nil
+	case *gnmipb.TypedValue_DoubleVal:
+		return v.DoubleVal, nil
+	case *gnmipb.TypedValue_BytesVal:
+		return v.BytesVal, nil
+	case *gnmipb.TypedValue_ProtoBytes:
+		return v.ProtoBytes, nil
+	case *gnmipb.TypedValue_LeaflistVal:
+		var list []interface{}
+		for _, elem := range v.LeaflistVal.GetElement() {
+			val, err := DecodeValue(elem)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, val)
+		}
+		return list, nil
+	default:
+		return nil, fmt.Errorf("unsupported value type %T", v)
+	}
+}
+
+func decodeJSON(data []byte) (interface{}, error) {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// joinPaths formats prefix followed by path as a string such as
+// "openconfig:/interfaces/interface[name=Ethernet1]/state". The prefix
+// origin is used unless path sets its own.
+func joinPaths(prefix, path *gnmipb.Path) string {
+	origin := path.GetOrigin()
+	if origin == "" {
+		origin = prefix.GetOrigin()
+	}
+
+	var b strings.Builder
+	if origin != "" {
+		b.WriteString(origin)
+		b.WriteByte(':')
+	}
+	elems := append(append([]*gnmipb.PathElem{}, prefix.GetElem()...), path.GetElem()...)
+	if len(elems) == 0 {
+		b.WriteByte('/')
+	}
+	for _, e := range elems {
+		b.WriteByte('/')
+		b.WriteString(e.GetName())
+		keys := make([]string, 0, len(e.GetKey()))
+		for k := range e.GetKey() {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(&b, "[%s=%s]", k, escapeKeyValue(e.GetKey()[k]))
+		}
+	}
+	return b.String()
+}
+
+// escapeKeyValue escapes the characters parseElem treats specially inside
+// a key value.
+func escapeKeyValue(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `]`, `\]`).Replace(v)
+}
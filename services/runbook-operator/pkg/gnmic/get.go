@@ -3,13 +3,59 @@ package gnmic
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
 )
 
+// GetOption configures a gNMI Get request.
+type GetOption func(*gnmipb.GetRequest)
+
+// WithEncoding sets the encoding requested from the target. The default is
+// JSON_IETF.
+func WithEncoding(encoding gnmipb.Encoding) GetOption {
+	return func(r *gnmipb.GetRequest) {
+		r.Encoding = encoding
+	}
+}
+
+// WithDataType restricts a Get to config, state or operational data. The
+// default is ALL.
+func WithDataType(dataType gnmipb.GetRequest_DataType) GetOption {
+	return func(r *gnmipb.GetRequest) {
+		r.Type = dataType
+	}
+}
+
+// ParseEncoding converts a step config encoding such as "json_ietf" or
+// "proto" to its gNMI value. An empty encoding is JSON_IETF.
+func ParseEncoding(encoding string) (gnmipb.Encoding, error) {
+	if encoding == "" {
+		return gnmipb.Encoding_JSON_IETF, nil
+	}
+	v, ok := gnmipb.Encoding_value[strings.ToUpper(encoding)]
+	if !ok {
+		return 0, fmt.Errorf("unknown encoding %q", encoding)
+	}
+	return gnmipb.Encoding(v), nil
+}
+
+// ParseDataType converts a step config data type such as "config" or
+// "state" to its gNMI value. An empty data type is ALL.
+func ParseDataType(dataType string) (gnmipb.GetRequest_DataType, error) {
+	if dataType == "" {
+		return gnmipb.GetRequest_ALL, nil
+	}
+	v, ok := gnmipb.GetRequest_DataType_value[strings.ToUpper(dataType)]
+	if !ok {
+		return 0, fmt.Errorf("unknown data type %q", dataType)
+	}
+	return gnmipb.GetRequest_DataType(v), nil
+}
+
 // Get performs a single gNMI Get request.
-func (c *Client) Get(ctx context.Context, paths []string) (*gnmipb.GetResponse, error) {
+func (c *Client) Get(ctx context.Context, paths []string, opts ...GetOption) (*gnmipb.GetResponse, error) {
 	if c.gnmiClient == nil {
 		return nil, fmt.Errorf("client not connected")
 	}
@@ -18,7 +64,7 @@ func (c *Client) Get(ctx context.Context, paths []string) (*gnmipb.GetResponse,
 	for _, p := range paths {
 		path, err := parsePath(p)
 		if err != nil {
-			return nil, fmt.Errorf("invalid path %q: %w", p, err)
+			return nil, invalidRequest(fmt.Errorf("invalid path %q: %w", p, err))
 		}
 		gnmiPaths = append(gnmiPaths, path)
 	}
@@ -28,11 +74,22 @@ func (c *Client) Get(ctx context.Context, paths []string) (*gnmipb.GetResponse,
 		Type:     gnmipb.GetRequest_ALL,
 		Encoding: gnmipb.Encoding_JSON_IETF,
 	}
+	for _, opt := range opts {
+		opt(getReq)
+	}
 
-	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	release, err := c.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(c.withCredentials(ctx), c.timeout)
 	defer cancel()
 
+	start := time.Now()
 	resp, err := c.gnmiClient.Get(ctx, getReq)
+	observeRPC("get", start, err)
 	if err != nil {
 		return nil, fmt.Errorf("gNMI Get failed: %w", err)
 	}
@@ -41,27 +98,59 @@ func (c *Client) Get(ctx context.Context, paths []string) (*gnmipb.GetResponse,
 	return resp, nil
 }
 
+// Getter is the subset of Client used by PollUntil.
+type Getter interface {
+	Get(ctx context.Context, paths []string, opts ...GetOption) (*gnmipb.GetResponse, error)
+}
+
+// GetterFunc adapts a function to a Getter.
+type GetterFunc func(ctx context.Context, paths []string, opts ...GetOption) (*gnmipb.GetResponse, error)
+
+// Get calls f.
+func (f GetterFunc) Get(ctx context.Context, paths []string, opts ...GetOption) (*gnmipb.GetResponse, error) {
+	return f(ctx, paths, opts...)
+}
+
 // Poll performs repeated Get requests until a condition is met or timeout expires.
 func (c *Client) Poll(ctx context.Context, paths []string, interval time.Duration, retryUntil func(*gnmipb.GetResponse) bool) (*gnmipb.GetResponse, error) {
-	deadline := time.After(c.timeout)
+	pollCtx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	logged := GetterFunc(func(ctx context.Context, paths []string, opts ...GetOption) (*gnmipb.GetResponse, error) {
+		resp, err := c.Get(ctx, paths, opts...)
+		if err != nil && ctx.Err() == nil {
+			c.log.Warn("poll attempt failed", "error", err)
+		}
+		return resp, err
+	})
+	resp, err := PollUntil(pollCtx, logged, paths, interval, retryUntil)
+	if err != nil && ctx.Err() == nil && pollCtx.Err() != nil {
+		return nil, fmt.Errorf("poll timeout exceeded")
+	}
+	return resp, err
+}
+
+// PollUntil Gets paths from g immediately and then every interval until
+// retryUntil reports true or ctx ends. Failed attempts are retried. When ctx
+// ends first, the last successful response, if any, is returned with ctx's
+// error.
+func PollUntil(ctx context.Context, g Getter, paths []string, interval time.Duration, retryUntil func(*gnmipb.GetResponse) bool, opts ...GetOption) (*gnmipb.GetResponse, error) {
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
+	var last *gnmipb.GetResponse
 	for {
-		select {
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		case <-deadline:
-			return nil, fmt.Errorf("poll timeout exceeded")
-		case <-ticker.C:
-			resp, err := c.Get(ctx, paths)
-			if err != nil {
-				c.log.Warn("poll attempt failed", "error", err)
-				continue
-			}
+		if resp, err := g.Get(ctx, paths, opts...); err == nil {
+			last = resp
 			if retryUntil(resp) {
 				return resp, nil
 			}
 		}
+
+		select {
+		case <-ctx.Done():
+			return last, ctx.Err()
+		case <-ticker.C:
+		}
 	}
 }
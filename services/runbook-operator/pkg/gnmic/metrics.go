@@ -0,0 +1,38 @@
+package gnmic
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// RPCsTotal counts gNMI RPCs by operation and outcome.
+	RPCsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "helios_gnmi_rpcs_total",
+		Help: "Total gNMI RPCs by operation and outcome",
+	}, []string{"operation", "outcome"})
+
+	// RPCDurationSeconds observes gNMI RPC latency. For Subscribe it is the
+	// lifetime of the stream.
+	RPCDurationSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "helios_gnmi_rpc_duration_seconds",
+		Help:    "Duration of gNMI RPCs",
+		Buckets: prometheus.ExponentialBuckets(0.01, 2, 14),
+	}, []string{"operation", "outcome"})
+)
+
+// RegisterMetrics adds the gNMI client metrics to reg.
+func RegisterMetrics(reg prometheus.Registerer) {
+	reg.MustRegister(RPCsTotal, RPCDurationSeconds)
+}
+
+// observeRPC records the outcome and duration of an RPC started at start.
+func observeRPC(operation string, start time.Time, err error) {
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+	}
+	RPCsTotal.WithLabelValues(operation, outcome).Inc()
+	RPCDurationSeconds.WithLabelValues(operation, outcome).Observe(time.Since(start).Seconds())
+}
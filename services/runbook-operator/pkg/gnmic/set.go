@@ -3,7 +3,11 @@ package gnmic
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
 )
@@ -12,11 +16,33 @@ import (
 type SetOperation string
 
 const (
-	SetUpdate  SetOperation = "update"
-	SetReplace SetOperation = "replace"
-	SetDelete  SetOperation = "delete"
+	SetUpdate       SetOperation = "update"
+	SetReplace      SetOperation = "replace"
+	SetDelete       SetOperation = "delete"
+	SetUnionReplace SetOperation = "union_replace"
 )
 
+// ErrInvalidRequest is matched by errors for requests that can never
+// succeed, such as an unparseable path or an unknown Set operation, so
+// callers know not to retry them.
+var ErrInvalidRequest = errors.New("invalid gNMI request")
+
+// invalidRequestError wraps err so that it matches ErrInvalidRequest
+// without changing its message.
+type invalidRequestError struct {
+	err error
+}
+
+func invalidRequest(err error) error {
+	return &invalidRequestError{err: err}
+}
+
+func (e *invalidRequestError) Error() string   { return e.err.Error() }
+func (e *invalidRequestError) Unwrap() []error { return []error{ErrInvalidRequest, e.err} }
+
+// unionReplaceMinVersion is the first gNMI version to define union_replace.
+var unionReplaceMinVersion = [3]int{0, 10, 0}
+
 // SetRequest represents a gNMI Set request.
 type SetRequest struct {
 	Operation SetOperation
@@ -24,25 +50,40 @@ type SetRequest struct {
 	Value     interface{}
 }
 
-// Set performs a gNMI Set operation with update, replace, or delete.
+// Set performs a gNMI Set operation with update, replace, delete or
+// union_replace.
 func (c *Client) Set(ctx context.Context, requests []SetRequest) (*gnmipb.SetResponse, error) {
+	return c.SetWithPrefix(ctx, "", requests)
+}
+
+// SetWithPrefix performs a gNMI Set with every request path relative to
+// prefix. An empty prefix sends no prefix. Requests using union_replace are
+// only sent if the target advertises a gNMI version that supports it.
+func (c *Client) SetWithPrefix(ctx context.Context, prefix string, requests []SetRequest) (*gnmipb.SetResponse, error) {
 	if c.gnmiClient == nil {
 		return nil, fmt.Errorf("client not connected")
 	}
 
 	setReq := &gnmipb.SetRequest{}
+	if prefix != "" {
+		p, err := parsePath(prefix)
+		if err != nil {
+			return nil, invalidRequest(fmt.Errorf("invalid prefix %q: %w", prefix, err))
+		}
+		setReq.Prefix = p
+	}
 
 	for _, req := range requests {
 		path, err := parsePath(req.Path)
 		if err != nil {
-			return nil, fmt.Errorf("invalid path %q: %w", req.Path, err)
+			return nil, invalidRequest(fmt.Errorf("invalid path %q: %w", req.Path, err))
 		}
 
 		switch req.Operation {
 		case SetUpdate:
 			typedVal, err := encodeValue(req.Value)
 			if err != nil {
-				return nil, fmt.Errorf("failed to encode value: %w", err)
+				return nil, invalidRequest(fmt.Errorf("failed to encode value: %w", err))
 			}
 			setReq.Update = append(setReq.Update, &gnmipb.Update{
 				Path: path,
@@ -51,31 +92,92 @@ func (c *Client) Set(ctx context.Context, requests []SetRequest) (*gnmipb.SetRes
 		case SetReplace:
 			typedVal, err := encodeValue(req.Value)
 			if err != nil {
-				return nil, fmt.Errorf("failed to encode value: %w", err)
+				return nil, invalidRequest(fmt.Errorf("failed to encode value: %w", err))
 			}
 			setReq.Replace = append(setReq.Replace, &gnmipb.Update{
 				Path: path,
 				Val:  typedVal,
 			})
+		case SetUnionReplace:
+			typedVal, err := encodeValue(req.Value)
+			if err != nil {
+				return nil, invalidRequest(fmt.Errorf("failed to encode value: %w", err))
+			}
+			setReq.UnionReplace = append(setReq.UnionReplace, &gnmipb.Update{
+				Path: path,
+				Val:  typedVal,
+			})
 		case SetDelete:
 			setReq.Delete = append(setReq.Delete, path)
 		default:
-			return nil, fmt.Errorf("unknown operation: %s", req.Operation)
+			return nil, invalidRequest(fmt.Errorf("unknown operation: %s", req.Operation))
 		}
 	}
 
-	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	if len(setReq.UnionReplace) > 0 {
+		if err := c.checkUnionReplace(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	release, err := c.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(c.withCredentials(ctx), c.timeout)
 	defer cancel()
 
+	start := time.Now()
 	resp, err := c.gnmiClient.Set(ctx, setReq)
+	observeRPC("set", start, err)
 	if err != nil {
 		return nil, fmt.Errorf("gNMI Set failed: %w", err)
 	}
 
-	c.log.Info("gNMI Set completed", "updates", len(setReq.Update), "replaces", len(setReq.Replace), "deletes", len(setReq.Delete))
+	c.log.Info("gNMI Set completed", "updates", len(setReq.Update), "replaces", len(setReq.Replace),
+		"union_replaces", len(setReq.UnionReplace), "deletes", len(setReq.Delete))
 	return resp, nil
 }
 
+// checkUnionReplace returns an error unless the target's advertised gNMI
+// version supports union_replace.
+func (c *Client) checkUnionReplace(ctx context.Context) error {
+	caps, err := c.Capabilities(ctx)
+	if err != nil {
+		return fmt.Errorf("checking union_replace support: %w", err)
+	}
+	version := caps.GetGNMIVersion()
+	if !versionAtLeast(version, unionReplaceMinVersion) {
+		return fmt.Errorf("target %s does not support union_replace: gNMI version %q, need 0.10.0 or later", c.address, version)
+	}
+	return nil
+}
+
+// versionAtLeast reports whether a dotted version such as "0.10.0" is at
+// least min. Unparseable versions are treated as too old.
+func versionAtLeast(version string, min [3]int) bool {
+	parts := strings.Split(version, ".")
+	if len(parts) == 0 || len(parts) > 3 {
+		return false
+	}
+	var v [3]int
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return false
+		}
+		v[i] = n
+	}
+	for i := range v {
+		if v[i] != min[i] {
+			return v[i] > min[i]
+		}
+	}
+	return true
+}
+
 func encodeValue(value interface{}) (*gnmipb.TypedValue, error) {
 	jsonBytes, err := json.Marshal(value)
 	if err != nil {
@@ -88,37 +190,126 @@ func encodeValue(value interface{}) (*gnmipb.TypedValue, error) {
 	}, nil
 }
 
+// parsePath converts a string path into a gNMI Path. A leading origin
+// token such as "openconfig:/interfaces" sets Path.Origin.
 func parsePath(pathStr string) (*gnmipb.Path, error) {
 	path := &gnmipb.Path{}
+	path.Origin, pathStr = splitOrigin(pathStr)
 	if pathStr == "" || pathStr == "/" {
 		return path, nil
 	}
 
-	// Simple path parsing: split by '/'
-	elements := splitPath(pathStr)
-	for _, elem := range elements {
-		if elem != "" {
-			path.Elem = append(path.Elem, &gnmipb.PathElem{Name: elem})
+	for _, elem := range splitPath(pathStr) {
+		pe, err := parseElem(elem)
+		if err != nil {
+			return nil, err
 		}
+		path.Elem = append(path.Elem, pe)
 	}
 	return path, nil
 }
 
+// splitOrigin separates a leading "origin:" token from the path. The colon
+// must be followed by '/' or end the string, so module-qualified element
+// names like "openconfig-interfaces:interfaces" are left untouched.
+func splitOrigin(pathStr string) (string, string) {
+	if strings.HasPrefix(pathStr, "/") {
+		return "", pathStr
+	}
+	i := strings.IndexByte(pathStr, ':')
+	if i <= 0 || strings.ContainsAny(pathStr[:i], "/[") {
+		return "", pathStr
+	}
+	rest := pathStr[i+1:]
+	if rest != "" && rest[0] != '/' {
+		return "", pathStr
+	}
+	return pathStr[:i], rest
+}
+
+// splitPath splits a path on '/', ignoring separators inside list key
+// brackets so that values such as [name=Ethernet1/1] stay intact.
 func splitPath(path string) []string {
 	var result []string
 	current := ""
+	inKey := false
+	escaped := false
 	for _, ch := range path {
-		if ch == '/' {
+		switch {
+		case escaped:
+			escaped = false
+		case inKey && ch == '\\':
+			escaped = true
+		case ch == '[':
+			inKey = true
+		case ch == ']':
+			inKey = false
+		case ch == '/' && !inKey:
 			if current != "" {
 				result = append(result, current)
 				current = ""
 			}
-		} else {
-			current += string(ch)
+			continue
 		}
+		current += string(ch)
 	}
 	if current != "" {
 		result = append(result, current)
 	}
 	return result
 }
+
+// parseElem parses a single path element such as interface[name=Ethernet1]
+// into its name and list keys. Within a key value a backslash escapes the
+// next character, allowing literal ']' or backslashes in values.
+func parseElem(elem string) (*gnmipb.PathElem, error) {
+	open := strings.IndexByte(elem, '[')
+	if open < 0 {
+		if strings.IndexByte(elem, ']') >= 0 {
+			return nil, fmt.Errorf("unexpected ']' in element %q", elem)
+		}
+		return &gnmipb.PathElem{Name: elem}, nil
+	}
+	if open == 0 {
+		return nil, fmt.Errorf("missing name in element %q", elem)
+	}
+
+	pe := &gnmipb.PathElem{Name: elem[:open], Key: make(map[string]string)}
+	rest := elem[open:]
+	for rest != "" {
+		if rest[0] != '[' {
+			return nil, fmt.Errorf("unexpected %q after key in element %q", rest, elem)
+		}
+		eq := strings.IndexByte(rest, '=')
+		if eq < 0 {
+			return nil, fmt.Errorf("missing '=' in key of element %q", elem)
+		}
+		name := rest[1:eq]
+		if name == "" || strings.ContainsAny(name, "[]") {
+			return nil, fmt.Errorf("invalid key name %q in element %q", name, elem)
+		}
+
+		var value strings.Builder
+		closed := false
+		i := eq + 1
+		for ; i < len(rest); i++ {
+			ch := rest[i]
+			if ch == '\\' && i+1 < len(rest) {
+				i++
+				value.WriteByte(rest[i])
+				continue
+			}
+			if ch == ']' {
+				closed = true
+				break
+			}
+			value.WriteByte(ch)
+		}
+		if !closed {
+			return nil, fmt.Errorf("unterminated key in element %q", elem)
+		}
+		pe.Key[name] = value.String()
+		rest = rest[i+1:]
+	}
+	return pe, nil
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"strings"
+	"time"
 
 	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
 )
@@ -11,22 +13,49 @@ import (
 // SubscribeHandler is called for each subscription response.
 type SubscribeHandler func(*gnmipb.SubscribeResponse) error
 
-// Subscribe creates a streaming gNMI subscription for validation.
+// Subscription describes a single path in a subscription list. Intervals are
+// only sent when non-zero; SampleInterval applies to SAMPLE mode and
+// HeartbeatInterval to SAMPLE and ON_CHANGE.
+type Subscription struct {
+	Path              string
+	Mode              gnmipb.SubscriptionMode
+	SampleInterval    time.Duration
+	HeartbeatInterval time.Duration
+}
+
+// ParseSubscriptionMode converts a step config mode such as "sample" or
+// "on_change" to its gNMI value. An empty mode is TARGET_DEFINED.
+func ParseSubscriptionMode(mode string) (gnmipb.SubscriptionMode, error) {
+	if mode == "" {
+		return gnmipb.SubscriptionMode_TARGET_DEFINED, nil
+	}
+	v, ok := gnmipb.SubscriptionMode_value[strings.ToUpper(mode)]
+	if !ok {
+		return 0, fmt.Errorf("unknown subscription mode %q", mode)
+	}
+	return gnmipb.SubscriptionMode(v), nil
+}
+
+// Subscribe creates a streaming gNMI subscription for validation, leaving
+// the sampling mode of each path to the target.
 func (c *Client) Subscribe(ctx context.Context, paths []string, mode gnmipb.SubscriptionList_Mode, handler SubscribeHandler) error {
+	subs := make([]Subscription, len(paths))
+	for i, p := range paths {
+		subs[i] = Subscription{Path: p, Mode: gnmipb.SubscriptionMode_TARGET_DEFINED}
+	}
+	return c.SubscribeTo(ctx, subs, mode, handler)
+}
+
+// SubscribeTo creates a streaming gNMI subscription with a per-path mode
+// and sample and heartbeat intervals.
+func (c *Client) SubscribeTo(ctx context.Context, subs []Subscription, mode gnmipb.SubscriptionList_Mode, handler SubscribeHandler) error {
 	if c.gnmiClient == nil {
 		return fmt.Errorf("client not connected")
 	}
 
-	var subscriptions []*gnmipb.Subscription
-	for _, p := range paths {
-		path, err := parsePath(p)
-		if err != nil {
-			return fmt.Errorf("invalid path %q: %w", p, err)
-		}
-		subscriptions = append(subscriptions, &gnmipb.Subscription{
-			Path: path,
-			Mode: gnmipb.SubscriptionMode_TARGET_DEFINED,
-		})
+	subscriptions, err := buildSubscriptions(subs)
+	if err != nil {
+		return err
 	}
 
 	subReq := &gnmipb.SubscribeRequest{
@@ -39,16 +68,31 @@ func (c *Client) Subscribe(ctx context.Context, paths []string, mode gnmipb.Subs
 		},
 	}
 
-	stream, err := c.gnmiClient.Subscribe(ctx)
+	// The slot is held for the lifetime of the stream.
+	release, err := c.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	// Only stream failures count as errors; a handler ending the
+	// subscription early is a successful RPC.
+	start := time.Now()
+	var rpcErr error
+	defer func() { observeRPC("subscribe", start, rpcErr) }()
+
+	stream, err := c.gnmiClient.Subscribe(c.withCredentials(ctx))
 	if err != nil {
+		rpcErr = err
 		return fmt.Errorf("failed to create subscribe stream: %w", err)
 	}
 
 	if err := stream.Send(subReq); err != nil {
+		rpcErr = err
 		return fmt.Errorf("failed to send subscribe request: %w", err)
 	}
 
-	c.log.Info("gNMI Subscribe started", "paths", len(paths), "mode", mode.String())
+	c.log.Info("gNMI Subscribe started", "paths", len(subs), "mode", mode.String())
 
 	for {
 		resp, err := stream.Recv()
@@ -56,10 +100,60 @@ func (c *Client) Subscribe(ctx context.Context, paths []string, mode gnmipb.Subs
 			if err == io.EOF {
 				return nil
 			}
+			rpcErr = err
 			return fmt.Errorf("subscribe stream error: %w", err)
 		}
 		if err := handler(resp); err != nil {
 			return err
 		}
+		// A ONCE subscription is complete at sync, even if the target
+		// leaves the stream open.
+		if mode == gnmipb.SubscriptionList_ONCE && resp.GetSyncResponse() {
+			return nil
+		}
+	}
+}
+
+// SubscribeValues subscribes to paths and calls onValue with each update's
+// path, formatted as by DecodeGetResponse, and decoded value. For ONCE
+// subscriptions it returns once the target signals sync; otherwise it runs
+// until the stream ends or ctx is cancelled.
+func (c *Client) SubscribeValues(ctx context.Context, paths []string, mode gnmipb.SubscriptionList_Mode, onValue func(path string, val interface{})) error {
+	return c.Subscribe(ctx, paths, mode, func(resp *gnmipb.SubscribeResponse) error {
+		n := resp.GetUpdate()
+		for _, u := range n.GetUpdate() {
+			path := joinPaths(n.GetPrefix(), u.GetPath())
+			val, err := DecodeValue(u.GetVal())
+			if err != nil {
+				return fmt.Errorf("decoding %s: %w", path, err)
+			}
+			onValue(path, val)
+		}
+		return nil
+	})
+}
+
+// buildSubscriptions converts subs to gNMI subscriptions, rejecting negative
+// intervals and a sample interval on a non-SAMPLE path.
+func buildSubscriptions(subs []Subscription) ([]*gnmipb.Subscription, error) {
+	var subscriptions []*gnmipb.Subscription
+	for _, sub := range subs {
+		path, err := parsePath(sub.Path)
+		if err != nil {
+			return nil, invalidRequest(fmt.Errorf("invalid path %q: %w", sub.Path, err))
+		}
+		if sub.SampleInterval < 0 || sub.HeartbeatInterval < 0 {
+			return nil, invalidRequest(fmt.Errorf("negative interval for path %q", sub.Path))
+		}
+		if sub.SampleInterval > 0 && sub.Mode != gnmipb.SubscriptionMode_SAMPLE {
+			return nil, invalidRequest(fmt.Errorf("sample interval set for %s subscription to %q", sub.Mode, sub.Path))
+		}
+		subscriptions = append(subscriptions, &gnmipb.Subscription{
+			Path:              path,
+			Mode:              sub.Mode,
+			SampleInterval:    uint64(sub.SampleInterval.Nanoseconds()),
+			HeartbeatInterval: uint64(sub.HeartbeatInterval.Nanoseconds()),
+		})
 	}
+	return subscriptions, nil
 }
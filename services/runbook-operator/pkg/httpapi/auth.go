@@ -0,0 +1,83 @@
+package httpapi
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Identity is an authenticated API caller.
+type Identity struct {
+	Name   string
+	Groups []string
+}
+
+// Authenticator identifies the caller of an API request.
+type Authenticator interface {
+	Authenticate(r *http.Request) (Identity, error)
+}
+
+// errUnauthenticated is returned when a request carries no valid credentials.
+var errUnauthenticated = errors.New("missing or invalid bearer token")
+
+// TokenAuthenticator authenticates requests by their bearer token.
+type TokenAuthenticator struct {
+	tokens map[string]Identity
+}
+
+// NewTokenAuthenticator returns an authenticator for the given token to
+// identity mapping.
+func NewTokenAuthenticator(tokens map[string]Identity) *TokenAuthenticator {
+	return &TokenAuthenticator{tokens: tokens}
+}
+
+// LoadTokenFile reads a static token file in the kube-apiserver format:
+// one "token,user" or "token,user,\"group1,group2\"" record per line.
+func LoadTokenFile(path string) (*TokenAuthenticator, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open token file: %w", err)
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse token file: %w", err)
+	}
+
+	tokens := make(map[string]Identity, len(records))
+	for i, rec := range records {
+		if len(rec) < 2 || rec[0] == "" || rec[1] == "" {
+			return nil, fmt.Errorf("token file line %d: want token,user[,groups]", i+1)
+		}
+		id := Identity{Name: rec[1]}
+		if len(rec) > 2 {
+			for _, g := range strings.Split(rec[2], ",") {
+				if g = strings.TrimSpace(g); g != "" {
+					id.Groups = append(id.Groups, g)
+				}
+			}
+		}
+		tokens[rec[0]] = id
+	}
+	return NewTokenAuthenticator(tokens), nil
+}
+
+// Authenticate implements Authenticator.
+func (a *TokenAuthenticator) Authenticate(r *http.Request) (Identity, error) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return Identity{}, errUnauthenticated
+	}
+	id, ok := a.tokens[token]
+	if !ok {
+		return Identity{}, errUnauthenticated
+	}
+	return id, nil
+}
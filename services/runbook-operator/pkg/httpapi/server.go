@@ -0,0 +1,370 @@
+// Package httpapi serves a small authenticated HTTP API for triggering and
+// approving runbook executions without kubectl.
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	heliosv1alpha1 "github.com/rhwendt/helios/services/runbook-operator/api/v1alpha1"
+	"github.com/rhwendt/helios/services/runbook-operator/controllers"
+)
+
+const (
+	// maxBodyBytes bounds request bodies, which only carry parameters.
+	maxBodyBytes = 1 << 20
+	// readHeaderTimeout bounds how long a client may take to send headers.
+	readHeaderTimeout = 10 * time.Second
+)
+
+// Server handles execution API requests against the Kubernetes API.
+type Server struct {
+	addr      string
+	client    client.Client
+	auth      Authenticator
+	namespace string
+	log       *slog.Logger
+}
+
+// NewServer creates an API server listening on addr. Requests operate in
+// namespace only; naming any other namespace is forbidden, since every
+// request is served with the operator's own cluster-wide client.
+func NewServer(addr string, c client.Client, auth Authenticator, namespace string, log *slog.Logger) *Server {
+	return &Server{
+		addr:      addr,
+		client:    c,
+		auth:      auth,
+		namespace: namespace,
+		log:       log,
+	}
+}
+
+// Handler returns the API routes.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /executions", s.authenticated(s.createExecution))
+	mux.HandleFunc("GET /executions/{name}", s.authenticated(s.getExecution))
+	mux.HandleFunc("POST /executions/{name}/approve", s.authenticated(s.approveExecution))
+	mux.HandleFunc("POST /runbooks/{name}/preview", s.authenticated(s.previewRunbook))
+	return mux
+}
+
+// Start serves the API until ctx is cancelled. It implements
+// manager.Runnable so the server shares the operator's lifecycle.
+func (s *Server) Start(ctx context.Context) error {
+	server := &http.Server{Addr: s.addr, Handler: s.Handler(), ReadHeaderTimeout: readHeaderTimeout}
+	errCh := make(chan error, 1)
+	go func() {
+		s.log.Info("starting execution API", "addr", s.addr)
+		errCh <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return fmt.Errorf("execution API server failed: %w", err)
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return server.Shutdown(shutdownCtx)
+}
+
+// NeedLeaderElection lets every operator replica serve the API.
+func (s *Server) NeedLeaderElection() bool {
+	return false
+}
+
+type handlerFunc func(w http.ResponseWriter, r *http.Request, id Identity)
+
+func (s *Server) authenticated(next handlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := s.auth.Authenticate(r)
+		if err != nil {
+			writeError(w, http.StatusUnauthorized, err)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+		next(w, r, id)
+	}
+}
+
+// createRequest is the body of POST /executions.
+type createRequest struct {
+	Runbook    string                 `json:"runbook"`
+	Namespace  string                 `json:"namespace,omitempty"`
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+	DryRun     bool                   `json:"dryRun,omitempty"`
+}
+
+// createExecution creates a RunbookExecution triggered by the caller. The
+// caller's groups are recorded as its roles for AllowedRoles checks.
+func (s *Server) createExecution(w http.ResponseWriter, r *http.Request, id Identity) {
+	var req createRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if req.Runbook == "" {
+		writeError(w, http.StatusBadRequest, errors.New("runbook is required"))
+		return
+	}
+	ns, err := s.allowedNamespace(req.Namespace)
+	if err != nil {
+		writeError(w, http.StatusForbidden, err)
+		return
+	}
+
+	var runbook heliosv1alpha1.Runbook
+	if err := s.client.Get(r.Context(), types.NamespacedName{Name: req.Runbook, Namespace: ns}, &runbook); err != nil {
+		s.writeAPIError(w, err)
+		return
+	}
+	if _, err := controllers.ValidateParameters(runbook.Spec.Parameters, req.Parameters); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	exec := &heliosv1alpha1.RunbookExecution{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: req.Runbook + "-",
+			Namespace:    ns,
+			Labels:       map[string]string{controllers.RunbookLabel: req.Runbook},
+		},
+		Spec: heliosv1alpha1.RunbookExecutionSpec{
+			RunbookRef:    heliosv1alpha1.RunbookRef{Name: req.Runbook, Namespace: ns},
+			Parameters:    req.Parameters,
+			TriggeredBy:   id.Name,
+			TriggerSource: heliosv1alpha1.TriggerAPI,
+			DryRun:        req.DryRun,
+		},
+	}
+	if len(id.Groups) > 0 {
+		exec.Annotations = map[string]string{controllers.TriggeredByRolesAnnotation: strings.Join(id.Groups, ",")}
+	}
+	if err := s.client.Create(r.Context(), exec); err != nil {
+		s.writeAPIError(w, err)
+		return
+	}
+
+	s.log.Info("execution created", "execution", exec.Name, "namespace", ns, "triggeredBy", id.Name)
+	writeJSON(w, http.StatusCreated, exec)
+}
+
+func (s *Server) getExecution(w http.ResponseWriter, r *http.Request, _ Identity) {
+	key, err := s.namespacedName(r)
+	if err != nil {
+		writeError(w, http.StatusForbidden, err)
+		return
+	}
+	var exec heliosv1alpha1.RunbookExecution
+	if err := s.client.Get(r.Context(), key, &exec); err != nil {
+		s.writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, &exec)
+}
+
+// approveExecution records the caller as the approver of a pending
+// execution. Whether the caller may approve is decided by the controller,
+// which rejects approvals from anyone not listed in the runbook. The
+// caller's groups always replace any recorded ones, so that an approval
+// cannot inherit groups set by someone else.
+func (s *Server) approveExecution(w http.ResponseWriter, r *http.Request, id Identity) {
+	key, err := s.namespacedName(r)
+	if err != nil {
+		writeError(w, http.StatusForbidden, err)
+		return
+	}
+	ctx := r.Context()
+
+	// The approver's groups go in an annotation and the approval in the
+	// status, which are separate writes. They are retried together on a
+	// conflict so the approval is only ever recorded with its own groups.
+	var exec heliosv1alpha1.RunbookExecution
+	var notPending error
+	annotated := false
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		exec = heliosv1alpha1.RunbookExecution{}
+		if err := s.client.Get(ctx, key, &exec); err != nil {
+			return err
+		}
+		if exec.Status.Phase != heliosv1alpha1.PhasePendingApproval {
+			notPending = fmt.Errorf("execution %s is %s, not awaiting approval", exec.Name, exec.Status.Phase)
+			return nil
+		}
+
+		setApproverGroups(&exec, id.Groups)
+		if err := s.client.Update(ctx, &exec); err != nil {
+			return err
+		}
+		annotated = true
+
+		now := metav1.Now()
+		exec.Status.ApprovedBy = id.Name
+		exec.Status.ApprovedAt = &now
+		return s.client.Status().Update(ctx, &exec)
+	})
+	if notPending != nil {
+		writeError(w, http.StatusConflict, notPending)
+		return
+	}
+	if err != nil {
+		if annotated {
+			s.clearApproverGroups(ctx, key)
+		}
+		s.writeAPIError(w, err)
+		return
+	}
+
+	s.log.Info("execution approval submitted", "execution", exec.Name, "namespace", exec.Namespace, "approvedBy", id.Name)
+	writeJSON(w, http.StatusAccepted, &exec)
+}
+
+// setApproverGroups records groups as the approver's, replacing any groups
+// recorded by an earlier approver.
+func setApproverGroups(exec *heliosv1alpha1.RunbookExecution, groups []string) {
+	if len(groups) == 0 {
+		delete(exec.Annotations, controllers.ApprovedByGroupsAnnotation)
+		return
+	}
+	if exec.Annotations == nil {
+		exec.Annotations = make(map[string]string)
+	}
+	exec.Annotations[controllers.ApprovedByGroupsAnnotation] = strings.Join(groups, ",")
+}
+
+// clearApproverGroups removes approver groups written for an approval that
+// could not be recorded, so they cannot be credited to a later one.
+func (s *Server) clearApproverGroups(ctx context.Context, key types.NamespacedName) {
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		var exec heliosv1alpha1.RunbookExecution
+		if err := s.client.Get(ctx, key, &exec); err != nil {
+			return err
+		}
+		if _, ok := exec.Annotations[controllers.ApprovedByGroupsAnnotation]; !ok {
+			return nil
+		}
+		delete(exec.Annotations, controllers.ApprovedByGroupsAnnotation)
+		return s.client.Update(ctx, &exec)
+	})
+	if err != nil {
+		s.log.Error("failed to clear approver groups", "execution", key.Name, "namespace", key.Namespace, "error", err)
+	}
+}
+
+// previewRequest is the body of POST /runbooks/{name}/preview.
+type previewRequest struct {
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+}
+
+// previewResponse lists each step's rendered config. Error is set when any
+// step failed to render.
+type previewResponse struct {
+	Steps []controllers.StepPreview `json:"steps"`
+	Error string                    `json:"error,omitempty"`
+}
+
+// previewRunbook renders the runbook's step configs with the given
+// parameters without creating an execution, so operators can check what a
+// destructive runbook will send before running it.
+func (s *Server) previewRunbook(w http.ResponseWriter, r *http.Request, _ Identity) {
+	key, err := s.namespacedName(r)
+	if err != nil {
+		writeError(w, http.StatusForbidden, err)
+		return
+	}
+	var req previewRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+
+	var runbook heliosv1alpha1.Runbook
+	if err := s.client.Get(r.Context(), key, &runbook); err != nil {
+		s.writeAPIError(w, err)
+		return
+	}
+	params, err := controllers.ValidateParameters(runbook.Spec.Parameters, req.Parameters)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	steps, err := controllers.PreviewExecution(&runbook, params)
+	if err != nil {
+		writeJSON(w, http.StatusUnprocessableEntity, previewResponse{Steps: steps, Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, previewResponse{Steps: steps})
+}
+
+// namespacedName returns the object named in the request path, in the
+// namespace given by the namespace query parameter or the default.
+func (s *Server) namespacedName(r *http.Request) (types.NamespacedName, error) {
+	ns, err := s.allowedNamespace(r.URL.Query().Get("namespace"))
+	if err != nil {
+		return types.NamespacedName{}, err
+	}
+	return types.NamespacedName{Name: r.PathValue("name"), Namespace: ns}, nil
+}
+
+// allowedNamespace returns the namespace a request operates in. Only the
+// server's namespace is allowed; an empty namespace selects it.
+func (s *Server) allowedNamespace(ns string) (string, error) {
+	if ns != "" && ns != s.namespace {
+		return "", fmt.Errorf("namespace %q is not served by this API", ns)
+	}
+	return s.namespace, nil
+}
+
+// writeAPIError maps Kubernetes API errors to HTTP status codes.
+func (s *Server) writeAPIError(w http.ResponseWriter, err error) {
+	switch {
+	case apierrors.IsNotFound(err):
+		writeError(w, http.StatusNotFound, err)
+	case apierrors.IsConflict(err), apierrors.IsAlreadyExists(err):
+		writeError(w, http.StatusConflict, err)
+	case apierrors.IsInvalid(err), apierrors.IsBadRequest(err):
+		writeError(w, http.StatusBadRequest, err)
+	default:
+		s.log.Error("execution API request failed", "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+	}
+}
+
+// decodeBody decodes the JSON request body into v. It writes an error
+// response and returns false if the body is too large or malformed.
+func decodeBody(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	err := json.NewDecoder(r.Body).Decode(v)
+	if err == nil {
+		return true
+	}
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Errorf("request body exceeds %d bytes", tooLarge.Limit))
+		return false
+	}
+	writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+	return false
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,406 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	heliosv1alpha1 "github.com/rhwendt/helios/services/runbook-operator/api/v1alpha1"
+	"github.com/rhwendt/helios/services/runbook-operator/controllers"
+)
+
+const ns = "helios-automation"
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+}
+
+func newFakeClient(t *testing.T, objs ...client.Object) client.Client {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := heliosv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to build scheme: %v", err)
+	}
+	return fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objs...).
+		WithStatusSubresource(&heliosv1alpha1.RunbookExecution{}).
+		Build()
+}
+
+func newTestServer(t *testing.T, objs ...client.Object) (*Server, client.Client) {
+	t.Helper()
+	c := newFakeClient(t, objs...)
+	return newTestServerWithClient(c), c
+}
+
+func newTestServerWithClient(c client.Client) *Server {
+	auth := NewTokenAuthenticator(map[string]Identity{
+		"operator-token": {Name: "operator@example.com", Groups: []string{"noc"}},
+		"lead-token":     {Name: "noc-lead@example.com", Groups: []string{"noc-leads"}},
+		"guest-token":    {Name: "guest@example.com"},
+	})
+	return NewServer(":0", c, auth, ns, testLogger())
+}
+
+func do(t *testing.T, s *Server, method, path, token, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	return rec
+}
+
+var testRunbook = &heliosv1alpha1.Runbook{
+	ObjectMeta: metav1.ObjectMeta{Name: "interface-bounce", Namespace: ns},
+	Spec: heliosv1alpha1.RunbookSpec{
+		Name: "interface-bounce",
+		Parameters: []heliosv1alpha1.Parameter{
+			{Name: "device", Type: "device", Required: true},
+		},
+	},
+}
+
+func TestServer_Unauthenticated(t *testing.T) {
+	s, _ := newTestServer(t, testRunbook.DeepCopy())
+
+	for _, token := range []string{"", "wrong-token"} {
+		rec := do(t, s, http.MethodGet, "/executions/anything", token, "")
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("token %q: status = %d, want %d", token, rec.Code, http.StatusUnauthorized)
+		}
+	}
+}
+
+func TestServer_BodyTooLarge(t *testing.T) {
+	s, _ := newTestServer(t, testRunbook)
+	body := `{"runbook":"interface-bounce","parameters":{"device":"` + strings.Repeat("x", maxBodyBytes) + `"}}`
+	rec := do(t, s, http.MethodPost, "/executions", "operator-token", body)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("status = %d, want %d: %s", rec.Code, http.StatusRequestEntityTooLarge, rec.Body.String())
+	}
+}
+
+func TestServer_CreateExecution(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{
+			name:       "valid",
+			body:       `{"runbook":"interface-bounce","parameters":{"device":"router1"},"dryRun":true}`,
+			wantStatus: http.StatusCreated,
+		},
+		{name: "missing runbook", body: `{}`, wantStatus: http.StatusBadRequest},
+		{name: "malformed body", body: `{"runbook":`, wantStatus: http.StatusBadRequest},
+		{name: "unknown runbook", body: `{"runbook":"nope"}`, wantStatus: http.StatusNotFound},
+		{name: "missing required parameter", body: `{"runbook":"interface-bounce"}`, wantStatus: http.StatusBadRequest},
+		{
+			name:       "explicit server namespace",
+			body:       `{"runbook":"interface-bounce","namespace":"helios-automation","parameters":{"device":"router1"},"dryRun":true}`,
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name:       "other namespace",
+			body:       `{"runbook":"interface-bounce","namespace":"kube-system","parameters":{"device":"router1"}}`,
+			wantStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, c := newTestServer(t, testRunbook.DeepCopy())
+			rec := do(t, s, http.MethodPost, "/executions", "operator-token", tt.body)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if tt.wantStatus != http.StatusCreated {
+				return
+			}
+
+			var created heliosv1alpha1.RunbookExecution
+			if err := json.NewDecoder(rec.Body).Decode(&created); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			var exec heliosv1alpha1.RunbookExecution
+			if err := c.Get(context.Background(), types.NamespacedName{Name: created.Name, Namespace: ns}, &exec); err != nil {
+				t.Fatalf("execution not created: %v", err)
+			}
+			if exec.Spec.TriggeredBy != "operator@example.com" {
+				t.Errorf("TriggeredBy = %q, want the authenticated caller", exec.Spec.TriggeredBy)
+			}
+			if exec.Spec.TriggerSource != heliosv1alpha1.TriggerAPI || !exec.Spec.DryRun {
+				t.Errorf("spec = %+v, want api trigger source and dry run", exec.Spec)
+			}
+			if exec.Annotations[controllers.TriggeredByRolesAnnotation] != "noc" {
+				t.Errorf("roles annotation = %q, want noc", exec.Annotations[controllers.TriggeredByRolesAnnotation])
+			}
+			if exec.Labels[controllers.RunbookLabel] != "interface-bounce" {
+				t.Errorf("runbook label = %q, want interface-bounce", exec.Labels[controllers.RunbookLabel])
+			}
+		})
+	}
+}
+
+func TestServer_GetExecution(t *testing.T) {
+	exec := &heliosv1alpha1.RunbookExecution{
+		ObjectMeta: metav1.ObjectMeta{Name: "bounce-1", Namespace: ns},
+		Spec:       heliosv1alpha1.RunbookExecutionSpec{RunbookRef: heliosv1alpha1.RunbookRef{Name: "interface-bounce"}},
+		Status:     heliosv1alpha1.RunbookExecutionStatus{Phase: heliosv1alpha1.PhaseRunning},
+	}
+	s, _ := newTestServer(t, exec)
+
+	rec := do(t, s, http.MethodGet, "/executions/bounce-1", "operator-token", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	var got heliosv1alpha1.RunbookExecution
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if got.Status.Phase != heliosv1alpha1.PhaseRunning {
+		t.Errorf("phase = %q, want Running", got.Status.Phase)
+	}
+
+	if rec := do(t, s, http.MethodGet, "/executions/missing", "operator-token", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("missing execution status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestServer_OtherNamespaceForbidden(t *testing.T) {
+	other := &heliosv1alpha1.RunbookExecution{
+		ObjectMeta: metav1.ObjectMeta{Name: "bounce-1", Namespace: "kube-system"},
+		Status:     heliosv1alpha1.RunbookExecutionStatus{Phase: heliosv1alpha1.PhasePendingApproval},
+	}
+	s, _ := newTestServer(t, other)
+
+	for _, req := range []struct{ method, path string }{
+		{http.MethodGet, "/executions/bounce-1?namespace=kube-system"},
+		{http.MethodPost, "/executions/bounce-1/approve?namespace=kube-system"},
+		{http.MethodPost, "/runbooks/interface-bounce/preview?namespace=kube-system"},
+	} {
+		if rec := do(t, s, req.method, req.path, "lead-token", `{}`); rec.Code != http.StatusForbidden {
+			t.Errorf("%s %s: status = %d, want %d", req.method, req.path, rec.Code, http.StatusForbidden)
+		}
+	}
+}
+
+func TestServer_ApproveExecution(t *testing.T) {
+	pending := func(phase heliosv1alpha1.ExecutionPhase) *heliosv1alpha1.RunbookExecution {
+		return &heliosv1alpha1.RunbookExecution{
+			ObjectMeta: metav1.ObjectMeta{Name: "bounce-1", Namespace: ns},
+			Spec:       heliosv1alpha1.RunbookExecutionSpec{RunbookRef: heliosv1alpha1.RunbookRef{Name: "interface-bounce"}},
+			Status:     heliosv1alpha1.RunbookExecutionStatus{Phase: phase},
+		}
+	}
+
+	t.Run("records the caller as approver", func(t *testing.T) {
+		s, c := newTestServer(t, pending(heliosv1alpha1.PhasePendingApproval))
+		rec := do(t, s, http.MethodPost, "/executions/bounce-1/approve", "lead-token", "")
+		if rec.Code != http.StatusAccepted {
+			t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusAccepted, rec.Body.String())
+		}
+
+		var exec heliosv1alpha1.RunbookExecution
+		if err := c.Get(context.Background(), types.NamespacedName{Name: "bounce-1", Namespace: ns}, &exec); err != nil {
+			t.Fatalf("failed to get execution: %v", err)
+		}
+		if exec.Status.ApprovedBy != "noc-lead@example.com" || exec.Status.ApprovedAt == nil {
+			t.Errorf("approval = %q at %v, want noc-lead@example.com", exec.Status.ApprovedBy, exec.Status.ApprovedAt)
+		}
+		if exec.Annotations[controllers.ApprovedByGroupsAnnotation] != "noc-leads" {
+			t.Errorf("groups annotation = %q, want noc-leads", exec.Annotations[controllers.ApprovedByGroupsAnnotation])
+		}
+	})
+
+	t.Run("clears groups recorded by someone else", func(t *testing.T) {
+		exec := pending(heliosv1alpha1.PhasePendingApproval)
+		exec.Annotations = map[string]string{controllers.ApprovedByGroupsAnnotation: "noc-leads"}
+		s, c := newTestServer(t, exec)
+		rec := do(t, s, http.MethodPost, "/executions/bounce-1/approve", "guest-token", "")
+		if rec.Code != http.StatusAccepted {
+			t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusAccepted, rec.Body.String())
+		}
+
+		var got heliosv1alpha1.RunbookExecution
+		if err := c.Get(context.Background(), types.NamespacedName{Name: "bounce-1", Namespace: ns}, &got); err != nil {
+			t.Fatalf("failed to get execution: %v", err)
+		}
+		if got.Status.ApprovedBy != "guest@example.com" {
+			t.Errorf("approvedBy = %q, want guest@example.com", got.Status.ApprovedBy)
+		}
+		if groups, ok := got.Annotations[controllers.ApprovedByGroupsAnnotation]; ok {
+			t.Errorf("groups annotation = %q, want it removed", groups)
+		}
+	})
+
+	t.Run("retries both writes on a status conflict", func(t *testing.T) {
+		c := newFakeClient(t, pending(heliosv1alpha1.PhasePendingApproval))
+		conflicts := 1
+		c = interceptor.NewClient(c.(client.WithWatch), interceptor.Funcs{
+			SubResourceUpdate: func(ctx context.Context, c client.Client, sub string, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+				if conflicts > 0 {
+					conflicts--
+					return apierrors.NewConflict(schema.GroupResource{Resource: "runbookexecutions"}, obj.GetName(), errors.New("modified"))
+				}
+				return c.SubResource(sub).Update(ctx, obj, opts...)
+			},
+		})
+		s := newTestServerWithClient(c)
+		rec := do(t, s, http.MethodPost, "/executions/bounce-1/approve", "lead-token", "")
+		if rec.Code != http.StatusAccepted {
+			t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusAccepted, rec.Body.String())
+		}
+
+		var got heliosv1alpha1.RunbookExecution
+		if err := c.Get(context.Background(), types.NamespacedName{Name: "bounce-1", Namespace: ns}, &got); err != nil {
+			t.Fatalf("failed to get execution: %v", err)
+		}
+		if got.Status.ApprovedBy != "noc-lead@example.com" {
+			t.Errorf("approvedBy = %q, want noc-lead@example.com", got.Status.ApprovedBy)
+		}
+		if got.Annotations[controllers.ApprovedByGroupsAnnotation] != "noc-leads" {
+			t.Errorf("groups annotation = %q, want noc-leads", got.Annotations[controllers.ApprovedByGroupsAnnotation])
+		}
+	})
+
+	t.Run("clears groups when the approval is not recorded", func(t *testing.T) {
+		c := newFakeClient(t, pending(heliosv1alpha1.PhasePendingApproval))
+		c = interceptor.NewClient(c.(client.WithWatch), interceptor.Funcs{
+			SubResourceUpdate: func(context.Context, client.Client, string, client.Object, ...client.SubResourceUpdateOption) error {
+				return errors.New("apiserver unavailable")
+			},
+		})
+		s := newTestServerWithClient(c)
+		rec := do(t, s, http.MethodPost, "/executions/bounce-1/approve", "lead-token", "")
+		if rec.Code != http.StatusInternalServerError {
+			t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusInternalServerError, rec.Body.String())
+		}
+
+		var got heliosv1alpha1.RunbookExecution
+		if err := c.Get(context.Background(), types.NamespacedName{Name: "bounce-1", Namespace: ns}, &got); err != nil {
+			t.Fatalf("failed to get execution: %v", err)
+		}
+		if groups, ok := got.Annotations[controllers.ApprovedByGroupsAnnotation]; ok {
+			t.Errorf("groups annotation = %q, want it removed", groups)
+		}
+	})
+
+	t.Run("rejects executions not awaiting approval", func(t *testing.T) {
+		s, _ := newTestServer(t, pending(heliosv1alpha1.PhaseRunning))
+		if rec := do(t, s, http.MethodPost, "/executions/bounce-1/approve", "lead-token", ""); rec.Code != http.StatusConflict {
+			t.Errorf("status = %d, want %d", rec.Code, http.StatusConflict)
+		}
+	})
+
+	t.Run("unknown execution", func(t *testing.T) {
+		s, _ := newTestServer(t)
+		if rec := do(t, s, http.MethodPost, "/executions/missing/approve", "lead-token", ""); rec.Code != http.StatusNotFound {
+			t.Errorf("status = %d, want %d", rec.Code, http.StatusNotFound)
+		}
+	})
+}
+
+func TestServer_PreviewRunbook(t *testing.T) {
+	runbook := testRunbook.DeepCopy()
+	runbook.Spec.Steps = []heliosv1alpha1.RunbookStep{
+		{Name: "get-state", Action: heliosv1alpha1.ActionGNMIGet, Config: map[string]interface{}{
+			"target": "{{ .device }}", "path": "/interfaces",
+		}},
+	}
+	broken := runbook.DeepCopy()
+	broken.Name = "broken"
+	broken.Spec.Steps[0].Config = map[string]interface{}{"target": "{{ .device", "path": "/interfaces"}
+
+	tests := []struct {
+		name       string
+		path       string
+		body       string
+		wantStatus int
+		wantTarget interface{}
+	}{
+		{"renders step config", "/runbooks/interface-bounce/preview", `{"parameters": {"device": "router1"}}`, http.StatusOK, "router1"},
+		{"invalid parameters", "/runbooks/interface-bounce/preview", `{}`, http.StatusBadRequest, nil},
+		{"template error", "/runbooks/broken/preview", `{"parameters": {"device": "router1"}}`, http.StatusUnprocessableEntity, nil},
+		{"unknown runbook", "/runbooks/missing/preview", `{}`, http.StatusNotFound, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestServer(t, runbook.DeepCopy(), broken.DeepCopy())
+			rec := do(t, s, http.MethodPost, tt.path, "operator-token", tt.body)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK && tt.wantStatus != http.StatusUnprocessableEntity {
+				return
+			}
+
+			var resp previewResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if len(resp.Steps) != 1 {
+				t.Fatalf("steps = %d, want 1", len(resp.Steps))
+			}
+			if got := resp.Steps[0].Config["target"]; got != tt.wantTarget && tt.wantTarget != nil {
+				t.Errorf("target = %v, want %v", got, tt.wantTarget)
+			}
+			if (resp.Error != "") != (tt.wantStatus == http.StatusUnprocessableEntity) {
+				t.Errorf("error = %q", resp.Error)
+			}
+			if tt.wantStatus == http.StatusUnprocessableEntity && resp.Steps[0].Error == "" {
+				t.Error("expected the failing step to carry its template error")
+			}
+		})
+	}
+}
+
+func TestLoadTokenFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.csv")
+	content := "abc123,noc-lead@example.com,\"noc-leads,admins\"\ndef456,operator@example.com\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	auth, err := LoadTokenFile(path)
+	if err != nil {
+		t.Fatalf("LoadTokenFile() error = %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer abc123")
+	id, err := auth.Authenticate(req)
+	if err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	if id.Name != "noc-lead@example.com" || len(id.Groups) != 2 || id.Groups[1] != "admins" {
+		t.Errorf("identity = %+v, want noc-lead@example.com in [noc-leads admins]", id)
+	}
+
+	if err := os.WriteFile(path, []byte("lonely-token\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadTokenFile(path); err == nil {
+		t.Error("expected error for a record without a user")
+	}
+}
@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// ExecutionsTotal counts execution phase transitions.
+	ExecutionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "helios_runbook_executions_total",
+		Help: "Total runbook execution phase transitions",
+	}, []string{"runbook", "phase"})
+
+	// StepFailuresTotal counts failed executor steps.
+	StepFailuresTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "helios_runbook_step_failures_total",
+		Help: "Total failed runbook steps",
+	}, []string{"runbook"})
+
+	// ApprovalWaitSeconds observes how long executions wait for approval.
+	ApprovalWaitSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "helios_runbook_approval_wait_seconds",
+		Help:    "Time between execution creation and approval",
+		Buckets: prometheus.ExponentialBuckets(30, 2, 10),
+	}, []string{"runbook"})
+
+	// ExecutionDurationSeconds observes the run time of finished executions.
+	ExecutionDurationSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "helios_runbook_execution_duration_seconds",
+		Help:    "Duration of finished runbook executions",
+		Buckets: prometheus.ExponentialBuckets(1, 2, 12),
+	}, []string{"runbook", "phase"})
+)
+
+// Register adds the operator metrics to reg.
+func Register(reg prometheus.Registerer) {
+	reg.MustRegister(
+		ExecutionsTotal,
+		StepFailuresTotal,
+		ApprovalWaitSeconds,
+		ExecutionDurationSeconds,
+	)
+}
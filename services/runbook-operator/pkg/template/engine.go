@@ -2,18 +2,65 @@ package template
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"sync"
 	"text/template"
+	"time"
+)
+
+const (
+	// DefaultMaxOutputSize bounds the size of a single rendered template.
+	DefaultMaxOutputSize = 1 << 20
+	// DefaultRenderTimeout bounds how long a single template may execute.
+	DefaultRenderTimeout = 5 * time.Second
+)
+
+var (
+	errOutputTooLarge = errors.New("template output exceeds size limit")
+	errRenderTimeout  = errors.New("template execution timed out")
 )
 
 // Engine renders Go templates for parameter substitution in runbook steps.
 type Engine struct {
-	funcMap template.FuncMap
+	funcMap       template.FuncMap
+	strict        bool
+	maxOutputSize int
+	timeout       time.Duration
+}
+
+// EngineOption configures an Engine.
+type EngineOption func(*Engine)
+
+// WithStrict makes rendering fail when a template references a missing
+// parameter instead of producing "<no value>".
+func WithStrict() EngineOption {
+	return func(e *Engine) {
+		e.strict = true
+	}
+}
+
+// WithMaxOutputSize limits the number of bytes a single render may produce.
+func WithMaxOutputSize(n int) EngineOption {
+	return func(e *Engine) {
+		e.maxOutputSize = n
+	}
+}
+
+// WithRenderTimeout limits how long a single render may run.
+func WithRenderTimeout(d time.Duration) EngineOption {
+	return func(e *Engine) {
+		e.timeout = d
+	}
 }
 
 // NewEngine creates a new template engine.
-func NewEngine() *Engine {
-	return &Engine{
+func NewEngine(opts ...EngineOption) *Engine {
+	e := &Engine{
+		maxOutputSize: DefaultMaxOutputSize,
+		timeout:       DefaultRenderTimeout,
 		funcMap: template.FuncMap{
 			"default": func(def, val interface{}) interface{} {
 				if val == nil || val == "" {
@@ -21,25 +68,118 @@ func NewEngine() *Engine {
 				}
 				return val
 			},
+			"upper": strings.ToUpper,
+			"lower": strings.ToLower,
+			"trim":  strings.TrimSpace,
+			"trimSuffix": func(suffix, s string) string {
+				return strings.TrimSuffix(s, suffix)
+			},
+			"replace": func(old, new, s string) string {
+				return strings.ReplaceAll(s, old, new)
+			},
+			"join": join,
+			"split": func(sep, s string) []string {
+				return strings.Split(s, sep)
+			},
+			"quote": strconv.Quote,
+
+			"cidrHost":    cidrHost,
+			"cidrNetwork": cidrNetwork,
+			"ipAdd":       ipAdd,
+			"stripCIDR":   stripCIDR,
 		},
 	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// join concatenates the elements of a list with sep. Arguments are ordered
+// so it can be used in a pipeline: {{ .interfaces | join "," }}.
+func join(sep string, list interface{}) (string, error) {
+	switch v := list.(type) {
+	case []string:
+		return strings.Join(v, sep), nil
+	case []interface{}:
+		parts := make([]string, len(v))
+		for i, elem := range v {
+			parts[i] = fmt.Sprint(elem)
+		}
+		return strings.Join(parts, sep), nil
+	default:
+		return "", fmt.Errorf("join: expected a list, got %T", list)
+	}
 }
 
 // Render processes a template string with the given parameters.
 func (e *Engine) Render(tmplStr string, params map[string]interface{}) (string, error) {
-	tmpl, err := template.New("runbook").Funcs(e.funcMap).Parse(tmplStr)
+	tmpl := template.New("runbook").Funcs(e.funcMap)
+	if e.strict {
+		tmpl = tmpl.Option("missingkey=error")
+	}
+	tmpl, err := tmpl.Parse(tmplStr)
 	if err != nil {
 		return "", fmt.Errorf("failed to parse template: %w", err)
 	}
 
-	var buf bytes.Buffer
-	if err := tmpl.Execute(&buf, params); err != nil {
-		return "", fmt.Errorf("failed to execute template: %w", err)
+	buf := &limitedBuffer{limit: e.maxOutputSize}
+	done := make(chan error, 1)
+	go func() {
+		done <- tmpl.Execute(buf, params)
+	}()
+
+	timer := time.NewTimer(e.timeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return "", fmt.Errorf("failed to execute template: %w", err)
+		}
+	case <-timer.C:
+		// Abort the execution at its next write; text/template has no
+		// other way to interrupt a running template.
+		buf.cancel()
+		return "", fmt.Errorf("failed to execute template: %w after %s", errRenderTimeout, e.timeout)
 	}
 
 	return buf.String(), nil
 }
 
+// limitedBuffer is a write buffer that fails once its limit is exceeded or
+// after it has been cancelled.
+type limitedBuffer struct {
+	mu        sync.Mutex
+	buf       bytes.Buffer
+	limit     int
+	cancelled bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.cancelled {
+		return 0, errRenderTimeout
+	}
+	if b.limit > 0 && b.buf.Len()+len(p) > b.limit {
+		return 0, fmt.Errorf("%w of %d bytes", errOutputTooLarge, b.limit)
+	}
+	return b.buf.Write(p)
+}
+
+func (b *limitedBuffer) cancel() {
+	b.mu.Lock()
+	b.cancelled = true
+	b.mu.Unlock()
+}
+
+func (b *limitedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
 // Validate checks if a template string is valid without executing it.
 func (e *Engine) Validate(tmplStr string) error {
 	_, err := template.New("validate").Funcs(e.funcMap).Parse(tmplStr)
@@ -53,22 +193,34 @@ func (e *Engine) Validate(tmplStr string) error {
 func (e *Engine) RenderConfig(config map[string]interface{}, params map[string]interface{}) (map[string]interface{}, error) {
 	result := make(map[string]interface{})
 	for key, val := range config {
-		switch v := val.(type) {
-		case string:
-			rendered, err := e.Render(v, params)
-			if err != nil {
-				return nil, fmt.Errorf("failed to render config key %q: %w", key, err)
-			}
-			result[key] = rendered
-		case map[string]interface{}:
-			nested, err := e.RenderConfig(v, params)
+		rendered, err := e.renderValue(val, params)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render config key %q: %w", key, err)
+		}
+		result[key] = rendered
+	}
+	return result, nil
+}
+
+// renderValue renders strings and recurses into nested maps and slices,
+// leaving other values untouched.
+func (e *Engine) renderValue(val interface{}, params map[string]interface{}) (interface{}, error) {
+	switch v := val.(type) {
+	case string:
+		return e.Render(v, params)
+	case map[string]interface{}:
+		return e.RenderConfig(v, params)
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, elem := range v {
+			rendered, err := e.renderValue(elem, params)
 			if err != nil {
-				return nil, err
+				return nil, fmt.Errorf("index %d: %w", i, err)
 			}
-			result[key] = nested
-		default:
-			result[key] = val
+			result[i] = rendered
 		}
+		return result, nil
+	default:
+		return val, nil
 	}
-	return result, nil
 }
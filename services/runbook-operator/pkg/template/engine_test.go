@@ -1,8 +1,11 @@
 package template
 
 import (
+	"errors"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestEngine_Render(t *testing.T) {
@@ -226,6 +229,80 @@ func TestEngine_RenderConfig(t *testing.T) {
 	}
 }
 
+func TestEngine_RenderConfig_Slices(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  map[string]interface{}
+		params  map[string]interface{}
+		want    map[string]interface{}
+		wantErr bool
+	}{
+		{
+			name: "list of templated strings",
+			config: map[string]interface{}{
+				"paths": []interface{}{"/interfaces/interface[name={{ .p }}]/state", "/interfaces/interface[name={{ .p }}]/config"},
+			},
+			params: map[string]interface{}{"p": "Ethernet1"},
+			want: map[string]interface{}{
+				"paths": []interface{}{"/interfaces/interface[name=Ethernet1]/state", "/interfaces/interface[name=Ethernet1]/config"},
+			},
+		},
+		{
+			name: "list of maps",
+			config: map[string]interface{}{
+				"updates": []interface{}{
+					map[string]interface{}{"path": "/interfaces/interface[name={{ .p }}]/config/enabled", "value": false},
+					map[string]interface{}{"path": "/interfaces/interface[name={{ .p }}]/config/description", "value": "drained by {{ .user }}"},
+				},
+			},
+			params: map[string]interface{}{"p": "Ethernet1", "user": "oncall"},
+			want: map[string]interface{}{
+				"updates": []interface{}{
+					map[string]interface{}{"path": "/interfaces/interface[name=Ethernet1]/config/enabled", "value": false},
+					map[string]interface{}{"path": "/interfaces/interface[name=Ethernet1]/config/description", "value": "drained by oncall"},
+				},
+			},
+		},
+		{
+			name: "non-string elements preserved and nested slices rendered",
+			config: map[string]interface{}{
+				"mixed": []interface{}{42, true, nil, []interface{}{"{{ .p }}"}},
+			},
+			params: map[string]interface{}{"p": "Ethernet1"},
+			want: map[string]interface{}{
+				"mixed": []interface{}{42, true, nil, []interface{}{"Ethernet1"}},
+			},
+		},
+		{
+			name: "invalid template in list",
+			config: map[string]interface{}{
+				"paths": []interface{}{"ok", "{{ .unclosed"},
+			},
+			wantErr: true,
+		},
+	}
+
+	engine := NewEngine()
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := engine.RenderConfig(tc.config, tc.params)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(result, tc.want) {
+				t.Errorf("RenderConfig = %#v, want %#v", result, tc.want)
+			}
+		})
+	}
+}
+
 func TestEngine_Render_SecurityEdgeCases(t *testing.T) {
 	engine := NewEngine()
 
@@ -250,3 +327,163 @@ func TestEngine_Render_SecurityEdgeCases(t *testing.T) {
 		}
 	})
 }
+
+func TestEngine_Render_Strict(t *testing.T) {
+	params := map[string]interface{}{
+		"device": "router-1",
+		"target": map[string]interface{}{"address": "10.0.0.1"},
+	}
+
+	tests := []struct {
+		name       string
+		template   string
+		want       string
+		wantErr    bool
+		lenientOut string
+	}{
+		{
+			name:       "present key",
+			template:   "device={{ .device }}",
+			want:       "device=router-1",
+			lenientOut: "device=router-1",
+		},
+		{
+			name:       "absent key",
+			template:   "interface={{ .interface }}",
+			wantErr:    true,
+			lenientOut: "interface=<no value>",
+		},
+		{
+			name:       "nested absent key",
+			template:   "port={{ .target.port }}",
+			wantErr:    true,
+			lenientOut: "port=<no value>",
+		},
+		{
+			name:       "nested present key",
+			template:   "address={{ .target.address }}",
+			want:       "address=10.0.0.1",
+			lenientOut: "address=10.0.0.1",
+		},
+	}
+
+	strict := NewEngine(WithStrict())
+	lenient := NewEngine()
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := strict.Render(tc.template, params)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("strict Render(%q) = %q, want error", tc.template, got)
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("strict Render(%q) error: %v", tc.template, err)
+				}
+				if got != tc.want {
+					t.Errorf("strict Render(%q) = %q, want %q", tc.template, got, tc.want)
+				}
+			}
+
+			got, err = lenient.Render(tc.template, params)
+			if err != nil {
+				t.Fatalf("lenient Render(%q) error: %v", tc.template, err)
+			}
+			if got != tc.lenientOut {
+				t.Errorf("lenient Render(%q) = %q, want %q", tc.template, got, tc.lenientOut)
+			}
+		})
+	}
+}
+
+func TestEngine_StringFunctions(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		params   map[string]interface{}
+		want     string
+		wantErr  bool
+	}{
+		{"upper", "{{ upper .v }}", map[string]interface{}{"v": "eth1"}, "ETH1", false},
+		{"lower", "{{ .v | lower }}", map[string]interface{}{"v": "Ethernet1"}, "ethernet1", false},
+		{"trim", "[{{ trim .v }}]", map[string]interface{}{"v": "  spine1 \n"}, "[spine1]", false},
+		{"trimSuffix", `{{ .v | trimSuffix ".example.com" }}`, map[string]interface{}{"v": "spine1.example.com"}, "spine1", false},
+		{"replace", `{{ .v | replace "/" "_" }}`, map[string]interface{}{"v": "Ethernet1/1"}, "Ethernet1_1", false},
+		{"join strings", `{{ .v | join "," }}`, map[string]interface{}{"v": []string{"a", "b", "c"}}, "a,b,c", false},
+		{"join interfaces", `{{ .v | join "," }}`, map[string]interface{}{"v": []interface{}{"Ethernet1", 2}}, "Ethernet1,2", false},
+		{"join non-list", `{{ .v | join "," }}`, map[string]interface{}{"v": 42}, "", true},
+		{"split", `{{ range split "," .v }}[{{ . }}]{{ end }}`, map[string]interface{}{"v": "a,b"}, "[a][b]", false},
+		{"split and join", `{{ .v | split "," | join ";" }}`, map[string]interface{}{"v": "a,b"}, "a;b", false},
+		{"quote", "{{ quote .v }}", map[string]interface{}{"v": `say "hi"`}, `"say \"hi\""`, false},
+	}
+
+	engine := NewEngine()
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := engine.Validate(tc.template); err != nil {
+				t.Fatalf("Validate(%q) error: %v", tc.template, err)
+			}
+
+			got, err := engine.Render(tc.template, tc.params)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("Render(%q) = %q, want error", tc.template, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Render(%q) error: %v", tc.template, err)
+			}
+			if got != tc.want {
+				t.Errorf("Render(%q) = %q, want %q", tc.template, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestEngine_Render_OutputLimit(t *testing.T) {
+	// 100,000 iterations of a 1KB body would expand to ~100MB.
+	bomb := "{{ range .items }}" + strings.Repeat("x", 1024) + "{{ end }}"
+	params := map[string]interface{}{"items": make([]struct{}, 100_000)}
+
+	t.Run("default limit cuts off large output", func(t *testing.T) {
+		_, err := NewEngine().Render(bomb, params)
+		if err == nil {
+			t.Fatal("expected error for oversized output")
+		}
+		if !errors.Is(err, errOutputTooLarge) {
+			t.Errorf("error = %v, want errOutputTooLarge", err)
+		}
+	})
+
+	t.Run("custom limit", func(t *testing.T) {
+		engine := NewEngine(WithMaxOutputSize(16))
+		if _, err := engine.Render("0123456789", nil); err != nil {
+			t.Fatalf("unexpected error under limit: %v", err)
+		}
+		if _, err := engine.Render("0123456789abcdefXYZ", nil); !errors.Is(err, errOutputTooLarge) {
+			t.Errorf("error = %v, want errOutputTooLarge", err)
+		}
+	})
+}
+
+func TestEngine_Render_Timeout(t *testing.T) {
+	engine := NewEngine(WithRenderTimeout(50 * time.Millisecond))
+	engine.funcMap["slow"] = func() string {
+		time.Sleep(20 * time.Millisecond)
+		return "."
+	}
+
+	start := time.Now()
+	_, err := engine.Render("{{ range .items }}{{ slow }}{{ end }}", map[string]interface{}{"items": make([]struct{}, 1000)})
+	if err == nil {
+		t.Fatal("expected timeout error")
+	}
+	if !errors.Is(err, errRenderTimeout) {
+		t.Errorf("error = %v, want errRenderTimeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Render took %v, want it cancelled near the 50ms timeout", elapsed)
+	}
+}
@@ -0,0 +1,95 @@
+package template
+
+import (
+	"fmt"
+	"math/big"
+	"net/netip"
+	"strings"
+)
+
+// cidrHost returns the address of host number n within prefix, e.g.
+// cidrHost "10.0.0.0/24" 5 yields "10.0.0.5".
+func cidrHost(prefix string, n int) (string, error) {
+	p, err := netip.ParsePrefix(prefix)
+	if err != nil {
+		return "", fmt.Errorf("cidrHost: %w", err)
+	}
+	p = p.Masked()
+
+	hostBits := p.Addr().BitLen() - p.Bits()
+	if n < 0 || (hostBits < 63 && int64(n) >= int64(1)<<hostBits) {
+		return "", fmt.Errorf("cidrHost: host number %d out of range for %s", n, p)
+	}
+	addr, err := addToAddr(p.Addr(), int64(n))
+	if err != nil {
+		return "", fmt.Errorf("cidrHost: %w", err)
+	}
+	return addr.String(), nil
+}
+
+// cidrNetwork returns the masked network prefix of a CIDR, e.g.
+// cidrNetwork "10.0.0.17/24" yields "10.0.0.0/24".
+func cidrNetwork(cidr string) (string, error) {
+	p, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return "", fmt.Errorf("cidrNetwork: %w", err)
+	}
+	return p.Masked().String(), nil
+}
+
+// ipAdd offsets an address by n, preserving a prefix length if present,
+// e.g. ipAdd "10.0.0.1/31" 1 yields "10.0.0.2/31".
+func ipAdd(ip string, n int) (string, error) {
+	if strings.Contains(ip, "/") {
+		p, err := netip.ParsePrefix(ip)
+		if err != nil {
+			return "", fmt.Errorf("ipAdd: %w", err)
+		}
+		addr, err := addToAddr(p.Addr(), int64(n))
+		if err != nil {
+			return "", fmt.Errorf("ipAdd: %w", err)
+		}
+		return netip.PrefixFrom(addr, p.Bits()).String(), nil
+	}
+
+	a, err := netip.ParseAddr(ip)
+	if err != nil {
+		return "", fmt.Errorf("ipAdd: %w", err)
+	}
+	addr, err := addToAddr(a, int64(n))
+	if err != nil {
+		return "", fmt.Errorf("ipAdd: %w", err)
+	}
+	return addr.String(), nil
+}
+
+// stripCIDR removes the prefix length from an address, validating it.
+func stripCIDR(cidr string) (string, error) {
+	if !strings.Contains(cidr, "/") {
+		a, err := netip.ParseAddr(cidr)
+		if err != nil {
+			return "", fmt.Errorf("stripCIDR: %w", err)
+		}
+		return a.String(), nil
+	}
+	p, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return "", fmt.Errorf("stripCIDR: %w", err)
+	}
+	return p.Addr().String(), nil
+}
+
+// addToAddr offsets addr by n, failing if the result leaves the address
+// family's range.
+func addToAddr(addr netip.Addr, n int64) (netip.Addr, error) {
+	v := new(big.Int).SetBytes(addr.AsSlice())
+	v.Add(v, big.NewInt(n))
+
+	size := addr.BitLen() / 8
+	if v.Sign() < 0 || v.BitLen() > addr.BitLen() {
+		return netip.Addr{}, fmt.Errorf("%s offset by %d is out of range", addr, n)
+	}
+	b := v.FillBytes(make([]byte, size))
+	out, _ := netip.AddrFromSlice(b)
+	return out.WithZone(addr.Zone()), nil
+}
@@ -0,0 +1,62 @@
+package template
+
+import "testing"
+
+func TestEngine_NetFunctions(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		params   map[string]interface{}
+		want     string
+		wantErr  bool
+	}{
+		// cidrHost
+		{"cidrHost ipv4", `{{ cidrHost .p 5 }}`, map[string]interface{}{"p": "10.0.0.0/24"}, "10.0.0.5", false},
+		{"cidrHost unmasked ipv4", `{{ cidrHost .p 1 }}`, map[string]interface{}{"p": "10.0.0.77/30"}, "10.0.0.77", false},
+		{"cidrHost ipv6", `{{ cidrHost .p 16 }}`, map[string]interface{}{"p": "2001:db8::/64"}, "2001:db8::10", false},
+		{"cidrHost out of range", `{{ cidrHost .p 4 }}`, map[string]interface{}{"p": "10.0.0.0/30"}, "", true},
+		{"cidrHost negative", `{{ cidrHost .p -1 }}`, map[string]interface{}{"p": "10.0.0.0/24"}, "", true},
+		{"cidrHost bad prefix", `{{ cidrHost .p 1 }}`, map[string]interface{}{"p": "10.0.0.0"}, "", true},
+
+		// cidrNetwork
+		{"cidrNetwork ipv4", `{{ cidrNetwork .p }}`, map[string]interface{}{"p": "10.0.0.17/24"}, "10.0.0.0/24", false},
+		{"cidrNetwork ipv6", `{{ cidrNetwork .p }}`, map[string]interface{}{"p": "2001:db8::1/48"}, "2001:db8::/48", false},
+		{"cidrNetwork bad", `{{ cidrNetwork .p }}`, map[string]interface{}{"p": "not-a-cidr"}, "", true},
+
+		// ipAdd
+		{"ipAdd ipv4", `{{ ipAdd .gateway 1 }}`, map[string]interface{}{"gateway": "10.0.0.1"}, "10.0.0.2", false},
+		{"ipAdd ipv4 carry", `{{ ipAdd .gateway 1 }}`, map[string]interface{}{"gateway": "10.0.0.255"}, "10.0.1.0", false},
+		{"ipAdd negative", `{{ ipAdd .gateway -1 }}`, map[string]interface{}{"gateway": "10.0.1.0"}, "10.0.0.255", false},
+		{"ipAdd keeps prefix", `{{ ipAdd .gateway 1 }}`, map[string]interface{}{"gateway": "10.0.0.0/31"}, "10.0.0.1/31", false},
+		{"ipAdd ipv6", `{{ ipAdd .gateway 1 }}`, map[string]interface{}{"gateway": "2001:db8::ffff"}, "2001:db8::1:0", false},
+		{"ipAdd overflow", `{{ ipAdd .gateway 1 }}`, map[string]interface{}{"gateway": "255.255.255.255"}, "", true},
+		{"ipAdd underflow", `{{ ipAdd .gateway -1 }}`, map[string]interface{}{"gateway": "::"}, "", true},
+		{"ipAdd bad ip", `{{ ipAdd .gateway 1 }}`, map[string]interface{}{"gateway": "10.0.0.256"}, "", true},
+
+		// stripCIDR
+		{"stripCIDR ipv4", `{{ stripCIDR .p }}`, map[string]interface{}{"p": "10.0.0.1/24"}, "10.0.0.1", false},
+		{"stripCIDR ipv6", `{{ stripCIDR .p }}`, map[string]interface{}{"p": "2001:db8::1/64"}, "2001:db8::1", false},
+		{"stripCIDR bare address", `{{ stripCIDR .p }}`, map[string]interface{}{"p": "10.0.0.1"}, "10.0.0.1", false},
+		{"stripCIDR bad", `{{ stripCIDR .p }}`, map[string]interface{}{"p": "spine1/24"}, "", true},
+	}
+
+	engine := NewEngine()
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := engine.Render(tc.template, tc.params)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("Render(%q) = %q, want error", tc.template, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Render(%q) error: %v", tc.template, err)
+			}
+			if got != tc.want {
+				t.Errorf("Render(%q) = %q, want %q", tc.template, got, tc.want)
+			}
+		})
+	}
+}
@@ -2,11 +2,15 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
@@ -42,9 +46,13 @@ var (
 		Name: "helios_target_sync_snmp_targets",
 		Help: "Number of SNMP targets generated",
 	})
-	syncBlackboxTargets = promauto.NewGauge(prometheus.GaugeOpts{
+	syncBlackboxTargets = promauto.NewGaugeVec(prometheus.GaugeOpts{
 		Name: "helios_target_sync_blackbox_targets",
-		Help: "Number of blackbox targets generated",
+		Help: "Number of blackbox targets generated per probe",
+	}, []string{"probe"})
+	syncInventoryTargets = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "helios_target_sync_inventory_targets",
+		Help: "Number of inventory targets generated",
 	})
 	syncErrors = promauto.NewCounter(prometheus.CounterOpts{
 		Name: "helios_target_sync_errors_total",
@@ -53,12 +61,26 @@ var (
 )
 
 func main() {
-	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
-	slog.SetDefault(logger)
+	once := flag.Bool("once", false, "sync once and exit, ignoring SYNC_INTERVAL")
+	validateOnly := flag.Bool("validate", false, "print the generated targets to stdout without syncing them to Kubernetes; exits non-zero on errors or warnings")
+	flag.Parse()
 
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
 
+	// Targets go to stdout when validating, so logs move to stderr.
+	if *validateOnly {
+		logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelInfo}))
+		if err := validate(ctx, os.Stdout, logger); err != nil {
+			logger.Error("validation failed", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	slog.SetDefault(logger)
+
 	// Start metrics server
 	mux := http.NewServeMux()
 	mux.Handle("/metrics", promhttp.Handler())
@@ -69,120 +91,420 @@ func main() {
 		}
 	}()
 
-	if err := run(ctx, logger); err != nil {
-		syncErrors.Inc()
-		logger.Error("sync failed", "error", err)
+	syncInterval, err := time.ParseDuration(envOrDefault("SYNC_INTERVAL", "0"))
+	if err != nil {
+		logger.Error("invalid SYNC_INTERVAL", "error", err)
+		os.Exit(1)
+	}
+	if *once {
+		syncInterval = 0
+	}
+	election, err := leaderElectionFromEnv(syncInterval)
+	if err != nil {
+		logger.Error("invalid leader election config", "error", err)
+		os.Exit(1)
+	}
+
+	// Configuration and clients are built once, so a bad config fails at
+	// startup and the NetBox connections are reused across syncs.
+	cfg, err := loadConfig(logger)
+	if err != nil {
+		logger.Error("invalid configuration", "error", err)
+		os.Exit(1)
+	}
+	k8sClient, err := newKubernetesClient()
+	if err != nil {
+		logger.Error("failed to create kubernetes client", "error", err)
 		os.Exit(1)
 	}
+	targetNamespace := envOrDefault("TARGET_NAMESPACE", "helios-collection")
+	cmUpdater := k8sclient.NewConfigMapUpdater(k8sClient, targetNamespace, logger)
+	var guard *k8sclient.LeaseGuard
+	if election != nil {
+		guard = k8sclient.NewLeaseGuard(k8sClient, targetNamespace, election.lease, election.identity, election.duration, logger)
+	}
+
+	// Without an interval, sync once and exit; periodicity comes from a CronJob.
+	if syncInterval <= 0 {
+		if err := run(ctx, logger, cfg, cmUpdater, guard); err != nil {
+			recordSyncError(err)
+			logger.Error("sync failed", "error", err)
+			os.Exit(1)
+		}
+		logger.Info("target sync completed successfully")
+		return
+	}
 
-	logger.Info("target sync completed successfully")
+	logger.Info("starting continuous target sync", "interval", syncInterval)
+	syncLoop(ctx, syncInterval, func(ctx context.Context) error {
+		return run(ctx, logger, cfg, cmUpdater, guard)
+	}, logger)
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer shutdownCancel()
+	metricsServer.Shutdown(shutdownCtx)
+	logger.Info("target sync stopped")
 }
 
-func run(ctx context.Context, logger *slog.Logger) error {
-	start := time.Now()
+// syncLoop calls sync immediately and then every interval until ctx is
+// cancelled. A failed sync is logged and counted, and retried on the next
+// tick.
+func syncLoop(ctx context.Context, interval time.Duration, sync func(context.Context) error, logger *slog.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := sync(ctx); err != nil && ctx.Err() == nil {
+			recordSyncError(err)
+			logger.Error("sync failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// syncConfig is the NetBox client and generator settings read from the
+// environment, shared by syncing and validation.
+type syncConfig struct {
+	netbox    *netbox.Client
+	profiles  generator.SubscriptionProfiles
+	intervals generator.ScrapeIntervals
+}
 
+// loadConfig builds the NetBox client and generator settings from the
+// environment.
+func loadConfig(logger *slog.Logger) (syncConfig, error) {
 	netboxURL := envOrDefault("NETBOX_URL", "http://netbox.helios-integration.svc.cluster.local")
 	netboxToken := envOrDefault("NETBOX_API_TOKEN", "")
-	targetNamespace := envOrDefault("TARGET_NAMESPACE", "helios-collection")
+	profilesPath := envOrDefault("SUBSCRIPTION_PROFILES_FILE", "")
+	deviceFilter := envOrDefault("NETBOX_DEVICE_FILTER", netbox.DefaultDeviceFilter)
+	deviceStatus := envOrDefault("NETBOX_DEVICE_STATUS", netbox.DefaultDeviceStatus)
 
-	if netboxToken == "" {
-		return fmt.Errorf("NETBOX_API_TOKEN is required")
+	intervals, err := generator.ParseScrapeIntervals(envOrDefault("TIER_SCRAPE_INTERVALS", ""))
+	if err != nil {
+		return syncConfig{}, fmt.Errorf("parsing TIER_SCRAPE_INTERVALS: %w", err)
 	}
 
-	// Initialize NetBox client
-	nbClient := netbox.NewClient(netboxURL, netboxToken, logger)
+	if netboxToken == "" {
+		return syncConfig{}, fmt.Errorf("NETBOX_API_TOKEN is required")
+	}
 
-	// Initialize Kubernetes client
-	config, err := rest.InClusterConfig()
+	netboxTimeout, err := time.ParseDuration(envOrDefault("NETBOX_TIMEOUT", netbox.DefaultTimeout.String()))
 	if err != nil {
-		return fmt.Errorf("getting in-cluster config: %w", err)
+		return syncConfig{}, fmt.Errorf("parsing NETBOX_TIMEOUT: %w", err)
 	}
-	k8sClient, err := kubernetes.NewForConfig(config)
+	netboxMaxIdleConns, err := strconv.Atoi(envOrDefault("NETBOX_MAX_IDLE_CONNS_PER_HOST", strconv.Itoa(netbox.DefaultMaxIdleConnsPerHost)))
 	if err != nil {
-		return fmt.Errorf("creating kubernetes client: %w", err)
+		return syncConfig{}, fmt.Errorf("parsing NETBOX_MAX_IDLE_CONNS_PER_HOST: %w", err)
 	}
-
-	cmUpdater := k8sclient.NewConfigMapUpdater(k8sClient, targetNamespace, logger)
-
-	// Query NetBox for monitored devices
-	devices, err := nbClient.ListMonitoredDevices(ctx)
+	netboxInsecure, err := strconv.ParseBool(envOrDefault("NETBOX_TLS_INSECURE_SKIP_VERIFY", "false"))
 	if err != nil {
-		return fmt.Errorf("listing monitored devices: %w", err)
+		return syncConfig{}, fmt.Errorf("parsing NETBOX_TLS_INSECURE_SKIP_VERIFY: %w", err)
 	}
-	syncDevicesTotal.Set(float64(len(devices)))
-
-	// Generate gNMI targets
-	gnmicData, gnmicCount, err := generator.GenerateGNMICTargets(devices)
-	if err != nil {
-		return fmt.Errorf("generating gnmic targets: %w", err)
+	if netboxInsecure {
+		logger.Warn("NetBox TLS certificate verification is disabled")
 	}
-	syncGNMITargets.Set(float64(gnmicCount))
-
-	err = cmUpdater.UpdateConfigMap(ctx, "helios-gnmic-targets", map[string]string{
-		"targets.yaml": string(gnmicData),
-	}, map[string]string{
-		"app.kubernetes.io/name":      "gnmic",
-		"app.kubernetes.io/component": "targets",
-		"helios.io/generated-by":      "target-generator",
+	netboxHTTP, err := netbox.NewHTTPClient(netbox.HTTPConfig{
+		Timeout:             netboxTimeout,
+		MaxIdleConnsPerHost: netboxMaxIdleConns,
+		CAFile:              envOrDefault("NETBOX_CA_CERT", ""),
+		InsecureSkipVerify:  netboxInsecure,
 	})
 	if err != nil {
-		return fmt.Errorf("updating gnmic ConfigMap: %w", err)
+		return syncConfig{}, err
 	}
 
-	// Generate SNMP targets
-	snmpData, snmpCount, err := generator.GenerateSNMPTargets(devices)
-	if err != nil {
-		return fmt.Errorf("generating snmp targets: %w", err)
+	profiles := generator.DefaultSubscriptionProfiles()
+	if profilesPath != "" {
+		loaded, err := generator.LoadSubscriptionProfiles(profilesPath)
+		if err != nil {
+			return syncConfig{}, err
+		}
+		profiles = loaded
 	}
-	syncSNMPTargets.Set(float64(snmpCount))
+	profiles = profiles.WithDefaultSubscriptions(splitList(os.Getenv("DEFAULT_SUBSCRIPTIONS")))
 
-	err = cmUpdater.UpdateConfigMap(ctx, "helios-snmp-targets", map[string]string{
-		"snmp-targets.json": string(snmpData),
-	}, map[string]string{
-		"app.kubernetes.io/name":      "snmp-exporter",
-		"app.kubernetes.io/component": "targets",
-		"helios.io/generated-by":      "target-generator",
-	})
+	nbClient := netbox.NewClient(netboxURL, netboxToken, logger,
+		netbox.WithDeviceFilter(deviceFilter),
+		netbox.WithDeviceStatus(deviceStatus),
+		netbox.WithSites(splitList(os.Getenv("NETBOX_SITE"))...),
+		netbox.WithRegions(splitList(os.Getenv("NETBOX_REGION"))...),
+		netbox.WithTags(splitList(os.Getenv("NETBOX_TAG"))...),
+		netbox.WithHTTPClient(netboxHTTP))
+
+	return syncConfig{netbox: nbClient, profiles: profiles, intervals: intervals}, nil
+}
+
+// defaultLeaseDuration is the shortest lease taken when leader election is
+// enabled; continuous syncs default to twice SYNC_INTERVAL.
+const defaultLeaseDuration = time.Minute
+
+// leaderElection names the Lease that keeps two target generators from
+// writing the same ConfigMaps at once.
+type leaderElection struct {
+	lease    string
+	identity string
+	duration time.Duration
+}
+
+// leaderElectionFromEnv returns the leader election config, or nil unless
+// ENABLE_LEADER_ELECTION is "true". The lease must outlast the time between
+// syncs or the holder would lose it before renewing.
+func leaderElectionFromEnv(syncInterval time.Duration) (*leaderElection, error) {
+	if os.Getenv("ENABLE_LEADER_ELECTION") != "true" {
+		return nil, nil
+	}
+
+	identity, err := os.Hostname()
 	if err != nil {
-		return fmt.Errorf("updating snmp ConfigMap: %w", err)
+		return nil, fmt.Errorf("getting hostname for lease identity: %w", err)
 	}
 
-	// Generate blackbox targets
-	bbTargets, bbCount, err := generator.GenerateBlackboxTargets(devices)
+	duration := max(2*syncInterval, defaultLeaseDuration)
+	if v := os.Getenv("LEADER_ELECTION_LEASE_DURATION"); v != "" {
+		if duration, err = time.ParseDuration(v); err != nil {
+			return nil, fmt.Errorf("parsing LEADER_ELECTION_LEASE_DURATION: %w", err)
+		}
+		if duration <= syncInterval {
+			return nil, fmt.Errorf("LEADER_ELECTION_LEASE_DURATION %s must be longer than SYNC_INTERVAL %s", duration, syncInterval)
+		}
+	}
+
+	return &leaderElection{
+		lease:    envOrDefault("LEADER_ELECTION_LEASE_NAME", "helios-target-generator"),
+		identity: identity,
+		duration: duration,
+	}, nil
+}
+
+// newKubernetesClient returns a clientset for the cluster the generator runs
+// in.
+func newKubernetesClient() (kubernetes.Interface, error) {
+	config, err := rest.InClusterConfig()
 	if err != nil {
-		return fmt.Errorf("generating blackbox targets: %w", err)
+		return nil, fmt.Errorf("getting in-cluster config: %w", err)
 	}
-	syncBlackboxTargets.Set(float64(bbCount))
+	k8sClient, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("creating kubernetes client: %w", err)
+	}
+	return k8sClient, nil
+}
 
-	bbData := make(map[string]string)
-	for filename, data := range bbTargets {
-		bbData[filename] = string(data)
+// run performs one sync. When guard is set, only the lease holder syncs.
+func run(ctx context.Context, logger *slog.Logger, cfg syncConfig, cmUpdater *k8sclient.ConfigMapUpdater, guard *k8sclient.LeaseGuard) error {
+	start := time.Now()
+
+	// Only the lease holder syncs, so a second instance cannot race it on
+	// the same ConfigMaps.
+	if guard != nil {
+		leader, err := guard.Acquire(ctx)
+		if err != nil {
+			return fmt.Errorf("acquiring leader lease: %w", err)
+		}
+		if !leader {
+			logger.Info("another target generator holds the lease, skipping sync")
+			return nil
+		}
 	}
 
-	err = cmUpdater.UpdateConfigMap(ctx, "helios-blackbox-targets", bbData, map[string]string{
-		"app.kubernetes.io/name":      "blackbox-exporter",
-		"app.kubernetes.io/component": "targets",
-		"helios.io/generated-by":      "target-generator",
-	})
+	// Query NetBox for monitored devices
+	devices, err := cfg.netbox.ListMonitoredDevices(ctx)
 	if err != nil {
-		return fmt.Errorf("updating blackbox ConfigMap: %w", err)
+		return fmt.Errorf("listing monitored devices: %w", err)
 	}
+	syncDevicesTotal.Set(float64(len(devices)))
 
+	counts, err := syncTargets(ctx, targetStages(devices, cfg.profiles, cfg.intervals), len(devices), cmUpdater, logger)
 	duration := time.Since(start)
 	syncDuration.Set(duration.Seconds())
+	if err != nil {
+		return err
+	}
 	syncLastSuccess.SetToCurrentTime()
 
 	logger.Info("sync complete",
 		"devices", len(devices),
-		"gnmi_targets", gnmicCount,
-		"snmp_targets", snmpCount,
-		"blackbox_targets", bbCount,
+		"gnmi_targets", counts["gnmi"],
+		"snmp_targets", counts["snmp"],
+		"blackbox_targets", counts["blackbox"],
+		"inventory_targets", counts["inventory"],
 		"duration", duration,
 	)
 
 	return nil
 }
 
+// syncStage generates the targets of one collector along with the ConfigMap
+// that carries them. record updates the stage's metrics once the ConfigMap
+// has been reconciled.
+type syncStage struct {
+	name     string
+	generate func() (k8sclient.ConfigMapSpec, int, error)
+	record   func(count int)
+}
+
+// targetStages returns the stages that turn devices into collector targets.
+func targetStages(devices []netbox.Device, profiles generator.SubscriptionProfiles, intervals generator.ScrapeIntervals) []syncStage {
+	var probeCounts map[string]int
+	return []syncStage{
+		{
+			name: "gnmi",
+			generate: func() (k8sclient.ConfigMapSpec, int, error) {
+				data, count, err := generator.GenerateGNMICTargets(devices, profiles, intervals)
+				if err != nil {
+					return k8sclient.ConfigMapSpec{}, 0, fmt.Errorf("generating gnmic targets: %w", err)
+				}
+				return k8sclient.ConfigMapSpec{
+					Name:   "helios-gnmic-targets",
+					Data:   map[string]string{"targets.yaml": string(data)},
+					Labels: targetLabels("gnmic"),
+				}, count, nil
+			},
+			record: func(count int) { syncGNMITargets.Set(float64(count)) },
+		},
+		{
+			name: "snmp",
+			generate: func() (k8sclient.ConfigMapSpec, int, error) {
+				data, count, err := generator.GenerateSNMPTargets(devices, intervals)
+				if err != nil {
+					return k8sclient.ConfigMapSpec{}, 0, fmt.Errorf("generating snmp targets: %w", err)
+				}
+				return k8sclient.ConfigMapSpec{
+					Name:   "helios-snmp-targets",
+					Data:   map[string]string{"snmp-targets.json": string(data)},
+					Labels: targetLabels("snmp-exporter"),
+				}, count, nil
+			},
+			record: func(count int) { syncSNMPTargets.Set(float64(count)) },
+		},
+		{
+			name: "blackbox",
+			generate: func() (k8sclient.ConfigMapSpec, int, error) {
+				files, counts, err := generator.GenerateBlackboxTargets(devices, intervals)
+				if err != nil {
+					return k8sclient.ConfigMapSpec{}, 0, fmt.Errorf("generating blackbox targets: %w", err)
+				}
+				probeCounts = counts
+				count := 0
+				for _, n := range counts {
+					count += n
+				}
+				data := make(map[string]string)
+				for filename, contents := range files {
+					data[filename] = string(contents)
+				}
+				return k8sclient.ConfigMapSpec{
+					Name:   "helios-blackbox-targets",
+					Data:   data,
+					Labels: targetLabels("blackbox-exporter"),
+				}, count, nil
+			},
+			record: func(int) {
+				// Probes that no longer have targets drop out of the metric.
+				syncBlackboxTargets.Reset()
+				for probe, n := range probeCounts {
+					syncBlackboxTargets.WithLabelValues(probe).Set(float64(n))
+				}
+			},
+		},
+		{
+			name: "inventory",
+			generate: func() (k8sclient.ConfigMapSpec, int, error) {
+				data, count, err := generator.GenerateInventoryTargets(devices)
+				if err != nil {
+					return k8sclient.ConfigMapSpec{}, 0, fmt.Errorf("generating inventory targets: %w", err)
+				}
+				return k8sclient.ConfigMapSpec{
+					Name:   "helios-inventory-targets",
+					Data:   map[string]string{"inventory-targets.json": string(data)},
+					Labels: targetLabels("helios-inventory"),
+				}, count, nil
+			},
+			record: func(count int) { syncInventoryTargets.Set(float64(count)) },
+		},
+	}
+}
+
+// targetLabels returns the labels of the targets ConfigMap consumed by app.
+func targetLabels(app string) map[string]string {
+	return map[string]string{
+		"app.kubernetes.io/name":      app,
+		"app.kubernetes.io/component": "targets",
+		"helios.io/generated-by":      "target-generator",
+	}
+}
+
+// stageErrors holds the failures of the stages of one sync. Each has already
+// been counted in syncErrors.
+type stageErrors []error
+
+func (e stageErrors) Error() string   { return errors.Join(e...).Error() }
+func (e stageErrors) Unwrap() []error { return e }
+
+// recordSyncError counts a failed sync in syncErrors, unless it failed in
+// stages that were already counted individually.
+func recordSyncError(err error) {
+	var stages stageErrors
+	if !errors.As(err, &stages) {
+		syncErrors.Inc()
+	}
+}
+
+// syncTargets runs every stage and reconciles the ConfigMap of each one that
+// generated successfully, so a bad target for one collector does not hold
+// back the others. Each failed stage is logged and counted in syncErrors,
+// and the failures are returned together once every stage has been tried.
+// It returns the target count of each successful stage by name.
+func syncTargets(ctx context.Context, stages []syncStage, deviceCount int, updater *k8sclient.ConfigMapUpdater, logger *slog.Logger) (map[string]int, error) {
+	counts := make(map[string]int)
+	var failed stageErrors
+	for _, stage := range stages {
+		if err := ctx.Err(); err != nil {
+			return counts, err
+		}
+
+		spec, count, err := stage.generate()
+		if err == nil {
+			err = updater.Reconcile(ctx, deviceCount, []k8sclient.ConfigMapSpec{spec})
+			if err != nil {
+				err = fmt.Errorf("reconciling %s targets: %w", stage.name, err)
+			}
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return counts, ctx.Err()
+			}
+			syncErrors.Inc()
+			logger.Error("sync stage failed", "stage", stage.name, "error", err)
+			failed = append(failed, err)
+			continue
+		}
+		stage.record(count)
+		counts[stage.name] = count
+	}
+
+	if len(failed) > 0 {
+		return counts, failed
+	}
+	return counts, nil
+}
+
+// splitList splits a comma-separated list, dropping empty entries.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 func envOrDefault(key, defaultValue string) string {
 	if v := os.Getenv(key); v != "" {
 		return v
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/rhwendt/helios/services/target-generator/internal/generator"
+	k8sclient "github.com/rhwendt/helios/services/target-generator/internal/kubernetes"
+	"github.com/rhwendt/helios/services/target-generator/internal/netbox"
+)
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+}
+
+func TestSyncLoop_RunsMultipleCycles(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var cycles int
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		syncLoop(ctx, time.Millisecond, func(ctx context.Context) error {
+			cycles++
+			if cycles == 3 {
+				cancel()
+			}
+			return nil
+		}, testLogger())
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("syncLoop did not return after context cancellation")
+	}
+	if cycles != 3 {
+		t.Errorf("sync ran %d times, want 3", cycles)
+	}
+}
+
+func TestSyncLoop_ContinuesAfterError(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	before := testutil.ToFloat64(syncErrors)
+	var cycles int
+	syncLoop(ctx, time.Millisecond, func(ctx context.Context) error {
+		cycles++
+		if cycles == 2 {
+			cancel()
+			return nil
+		}
+		return errors.New("netbox unavailable")
+	}, testLogger())
+
+	if cycles != 2 {
+		t.Errorf("sync ran %d times, want 2", cycles)
+	}
+	if got := testutil.ToFloat64(syncErrors) - before; got != 1 {
+		t.Errorf("sync errors increased by %v, want 1", got)
+	}
+}
+
+func TestSyncLoop_StopsOnCancelledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	before := testutil.ToFloat64(syncErrors)
+	var cycles int
+	syncLoop(ctx, time.Hour, func(ctx context.Context) error {
+		cycles++
+		return ctx.Err()
+	}, testLogger())
+
+	if cycles != 1 {
+		t.Errorf("sync ran %d times, want 1", cycles)
+	}
+	if got := testutil.ToFloat64(syncErrors) - before; got != 0 {
+		t.Errorf("cancelled sync counted as error: %v", got)
+	}
+}
+
+func TestSyncTargets_StageFailureDoesNotBlockOthers(t *testing.T) {
+	devices := []netbox.Device{
+		{
+			Name:      "router-1",
+			PrimaryIP: "10.0.0.1",
+			Platform:  "eos",
+			CustomFields: netbox.DeviceCustomFields{
+				GNMIEnabled: true,
+				SNMPEnabled: true,
+			},
+		},
+		{
+			// http_2xx needs an http(s) URL, so blackbox generation fails.
+			Name:      "router-2",
+			PrimaryIP: "10.0.0.2",
+			CustomFields: netbox.DeviceCustomFields{
+				BlackboxProbes:  []string{"http_2xx"},
+				BlackboxHTTPURL: "ftp://10.0.0.2",
+			},
+		},
+	}
+	client := fake.NewSimpleClientset()
+	updater := k8sclient.NewConfigMapUpdater(client, "helios-collection", testLogger())
+
+	before := testutil.ToFloat64(syncErrors)
+	stages := targetStages(devices, generator.DefaultSubscriptionProfiles(), nil)
+	counts, err := syncTargets(context.Background(), stages, len(devices), updater, testLogger())
+	if err == nil {
+		t.Fatal("expected the blackbox stage error")
+	}
+	var failed stageErrors
+	if !errors.As(err, &failed) || len(failed) != 1 {
+		t.Fatalf("error = %v, want one stage error", err)
+	}
+
+	for _, name := range []string{"helios-gnmic-targets", "helios-snmp-targets", "helios-inventory-targets"} {
+		if _, err := client.CoreV1().ConfigMaps("helios-collection").Get(context.Background(), name, metav1.GetOptions{}); err != nil {
+			t.Errorf("ConfigMap %s not written: %v", name, err)
+		}
+	}
+	if _, err := client.CoreV1().ConfigMaps("helios-collection").Get(context.Background(), "helios-blackbox-targets", metav1.GetOptions{}); err == nil {
+		t.Error("blackbox ConfigMap written despite its generation failing")
+	}
+	if counts["gnmi"] != 1 || counts["snmp"] != 1 {
+		t.Errorf("counts = %v, want one gNMI and one SNMP target", counts)
+	}
+	if _, ok := counts["blackbox"]; ok {
+		t.Errorf("counts = %v, want no blackbox count", counts)
+	}
+
+	if got := testutil.ToFloat64(syncErrors) - before; got != 1 {
+		t.Errorf("sync errors increased by %v, want 1 per failed stage", got)
+	}
+	recordSyncError(err)
+	if got := testutil.ToFloat64(syncErrors) - before; got != 1 {
+		t.Errorf("stage errors counted again by recordSyncError: %v", got)
+	}
+}
+
+func TestSyncTargets_BlackboxTargetsPerProbe(t *testing.T) {
+	devices := []netbox.Device{
+		{Name: "router-1", PrimaryIP: "10.0.0.1", CustomFields: netbox.DeviceCustomFields{BlackboxProbes: []string{"icmp", "tcp_connect"}}},
+		{Name: "router-2", PrimaryIP: "10.0.0.2"},
+	}
+	updater := k8sclient.NewConfigMapUpdater(fake.NewSimpleClientset(), "helios-collection", testLogger())
+
+	syncBlackboxTargets.WithLabelValues("http_2xx").Set(4)
+	counts, err := syncTargets(context.Background(), targetStages(devices, generator.DefaultSubscriptionProfiles(), nil), len(devices), updater, testLogger())
+	if err != nil {
+		t.Fatalf("syncTargets() error = %v", err)
+	}
+
+	if counts["blackbox"] != 3 {
+		t.Errorf("blackbox count = %d, want 3", counts["blackbox"])
+	}
+	for probe, want := range map[string]float64{"icmp": 2, "tcp_connect": 1} {
+		if got := testutil.ToFloat64(syncBlackboxTargets.WithLabelValues(probe)); got != want {
+			t.Errorf("blackbox targets{probe=%q} = %v, want %v", probe, got, want)
+		}
+	}
+	if got := testutil.CollectAndCount(syncBlackboxTargets); got != 2 {
+		t.Errorf("blackbox target series = %d, want 2 after the stale http_2xx probe is dropped", got)
+	}
+}
+
+func TestSplitList(t *testing.T) {
+	got := splitList(" dc1, ,dc2,")
+	if len(got) != 2 || got[0] != "dc1" || got[1] != "dc2" {
+		t.Errorf("splitList() = %q, want [dc1 dc2]", got)
+	}
+	if got := splitList(""); got != nil {
+		t.Errorf("splitList(\"\") = %q, want nil", got)
+	}
+}
+
+func TestLeaderElectionFromEnv(t *testing.T) {
+	t.Setenv("ENABLE_LEADER_ELECTION", "")
+	if election, err := leaderElectionFromEnv(5 * time.Minute); err != nil || election != nil {
+		t.Fatalf("leaderElectionFromEnv() disabled = %+v, %v, want nil", election, err)
+	}
+
+	t.Setenv("ENABLE_LEADER_ELECTION", "true")
+	election, err := leaderElectionFromEnv(5 * time.Minute)
+	if err != nil {
+		t.Fatalf("leaderElectionFromEnv() error = %v", err)
+	}
+	if election.duration != 10*time.Minute || election.lease != "helios-target-generator" || election.identity == "" {
+		t.Errorf("leaderElectionFromEnv() = %+v, want a 10m helios-target-generator lease", election)
+	}
+	if election, _ := leaderElectionFromEnv(0); election.duration != defaultLeaseDuration {
+		t.Errorf("one-shot lease duration = %s, want %s", election.duration, defaultLeaseDuration)
+	}
+
+	t.Setenv("LEADER_ELECTION_LEASE_DURATION", "2m")
+	if _, err := leaderElectionFromEnv(5 * time.Minute); err == nil {
+		t.Error("expected error for a lease shorter than SYNC_INTERVAL")
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"sync/atomic"
+)
+
+// validate fetches devices from NetBox and writes the targets every stage
+// generates to w instead of syncing them, so NetBox data can be checked
+// without a cluster. It fails if a stage fails or if fetching or generating
+// logged any warnings, such as skipped devices or duplicate target names.
+func validate(ctx context.Context, w io.Writer, logger *slog.Logger) error {
+	warnings := &warningCounter{Handler: logger.Handler(), count: new(atomic.Int64)}
+	logger = slog.New(warnings)
+	// The generators log through the default logger.
+	slog.SetDefault(logger)
+
+	cfg, err := loadConfig(logger)
+	if err != nil {
+		return err
+	}
+	// Configuration warnings, like disabled TLS verification, are the
+	// operator's choice and say nothing about the NetBox data.
+	baseline := warnings.count.Load()
+
+	devices, err := cfg.netbox.ListMonitoredDevices(ctx)
+	if err != nil {
+		return fmt.Errorf("listing monitored devices: %w", err)
+	}
+	logger.Info("fetched devices", "devices", len(devices))
+
+	if err := writeTargets(w, targetStages(devices, cfg.profiles, cfg.intervals)); err != nil {
+		return err
+	}
+	if n := warnings.count.Load() - baseline; n > 0 {
+		return fmt.Errorf("warnings logged while generating targets: %d", n)
+	}
+	return nil
+}
+
+// writeTargets writes the ConfigMap data generated by each stage to w, every
+// file under a "# <configmap>/<key>" header. Failed stages are skipped and
+// their errors returned together once every stage has been tried.
+func writeTargets(w io.Writer, stages []syncStage) error {
+	var errs []error
+	for _, stage := range stages {
+		spec, _, err := stage.generate()
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		keys := make([]string, 0, len(spec.Data))
+		for key := range spec.Data {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if _, err := fmt.Fprintf(w, "# %s/%s\n%s\n", spec.Name, key, spec.Data[key]); err != nil {
+				return err
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// warningCounter is a slog.Handler that counts the records at warning level
+// or above, even those the wrapped handler's level filters out.
+type warningCounter struct {
+	slog.Handler
+	count *atomic.Int64
+}
+
+func (h *warningCounter) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= slog.LevelWarn || h.Handler.Enabled(ctx, level)
+}
+
+func (h *warningCounter) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level >= slog.LevelWarn {
+		h.count.Add(1)
+	}
+	if !h.Handler.Enabled(ctx, r.Level) {
+		return nil
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *warningCounter) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &warningCounter{Handler: h.Handler.WithAttrs(attrs), count: h.count}
+}
+
+func (h *warningCounter) WithGroup(name string) slog.Handler {
+	return &warningCounter{Handler: h.Handler.WithGroup(name), count: h.count}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeNetBox serves devices from the device list and no virtual machines.
+func fakeNetBox(t *testing.T, devices []map[string]interface{}) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/dcim/devices/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"count": len(devices), "next": nil, "results": devices})
+	})
+	mux.HandleFunc("/api/virtualization/virtual-machines/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"count": 0, "next": nil, "results": []interface{}{}})
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// setNetBoxEnv points loadConfig at url and restores the default logger,
+// which validate replaces.
+func setNetBoxEnv(t *testing.T, url string) {
+	t.Helper()
+	t.Setenv("NETBOX_URL", url)
+	t.Setenv("NETBOX_API_TOKEN", "test-token")
+	defaultLogger := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(defaultLogger) })
+}
+
+func netboxDevice(id int, name, ip string) map[string]interface{} {
+	return map[string]interface{}{
+		"id": id, "name": name, "primary_ip_address": ip,
+		"site": "dc1", "role": "router", "manufacturer": "arista",
+		"status": "active",
+		"custom_fields": map[string]interface{}{
+			"gnmi_enabled": true, "gnmi_port": 6030,
+			"snmp_enabled": true, "snmp_module": "arista_sw",
+		},
+	}
+}
+
+func TestValidate_PrintsTargets(t *testing.T) {
+	server := fakeNetBox(t, []map[string]interface{}{
+		netboxDevice(1, "router-1", "10.0.0.1"),
+		netboxDevice(2, "router-2", "10.0.0.2"),
+	})
+	setNetBoxEnv(t, server.URL)
+
+	var out bytes.Buffer
+	if err := validate(context.Background(), &out, testLogger()); err != nil {
+		t.Fatalf("validate() error = %v", err)
+	}
+
+	for _, want := range []string{
+		"# helios-gnmic-targets/targets.yaml\n",
+		"# helios-snmp-targets/snmp-targets.json\n",
+		"# helios-inventory-targets/inventory-targets.json\n",
+		"10.0.0.1:6030",
+		"router-2:6030",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestValidate_FailsOnWarnings(t *testing.T) {
+	// Two devices with the same name make the gnmic generator warn about
+	// the duplicate key.
+	server := fakeNetBox(t, []map[string]interface{}{
+		netboxDevice(1, "router-1", "10.0.0.1"),
+		netboxDevice(2, "router-1", "10.0.0.2"),
+	})
+	setNetBoxEnv(t, server.URL)
+
+	var out bytes.Buffer
+	err := validate(context.Background(), &out, testLogger())
+	if err == nil || !strings.Contains(err.Error(), "warnings logged while generating targets: 1") {
+		t.Fatalf("validate() error = %v, want a warning count", err)
+	}
+	// The targets are still printed so the duplicate can be inspected.
+	if !strings.Contains(out.String(), "10.0.0.2:6030") {
+		t.Errorf("output missing the disambiguated target:\n%s", out.String())
+	}
+}
+
+func TestValidate_IgnoresConfigWarnings(t *testing.T) {
+	server := fakeNetBox(t, []map[string]interface{}{netboxDevice(1, "router-1", "10.0.0.1")})
+	setNetBoxEnv(t, server.URL)
+	t.Setenv("NETBOX_TLS_INSECURE_SKIP_VERIFY", "true")
+
+	if err := validate(context.Background(), &bytes.Buffer{}, testLogger()); err != nil {
+		t.Fatalf("validate() error = %v", err)
+	}
+}
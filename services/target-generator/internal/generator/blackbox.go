@@ -3,15 +3,18 @@ package generator
 import (
 	"encoding/json"
 	"fmt"
+	"net"
+	"strconv"
 
 	"github.com/rhwendt/helios/services/target-generator/internal/netbox"
 )
 
 // GenerateBlackboxTargets converts NetBox devices to Prometheus file_sd JSON for blackbox_exporter.
-// Returns separate target lists per probe type (icmp, tcp_connect, http_2xx).
-func GenerateBlackboxTargets(devices []netbox.Device) (map[string][]byte, int, error) {
+// Returns separate target lists per probe type (icmp, tcp_connect, http_2xx)
+// along with the number of targets for each probe.
+// Devices whose tier is in intervals get a per-target scrape interval.
+func GenerateBlackboxTargets(devices []netbox.Device, intervals ScrapeIntervals) (map[string][]byte, map[string]int, error) {
 	probeTargets := make(map[string][]PrometheusFileSDEntry)
-	count := 0
 
 	for _, d := range devices {
 		if d.PrimaryIP == "" {
@@ -29,41 +32,57 @@ func GenerateBlackboxTargets(devices []netbox.Device) (map[string][]byte, int, e
 				continue
 			}
 
+			labels := sanitizeLabels(map[string]string{
+				"device":         d.Name,
+				"site":           d.Site,
+				"region":         d.Region,
+				"__param_module": probe,
+			})
+			intervals.apply(labels, scrapeIntervalLabel, d.MonitoringTier)
+
 			entry := PrometheusFileSDEntry{
 				Targets: []string{target},
-				Labels: map[string]string{
-					"device":         d.Name,
-					"site":           d.Site,
-					"region":         d.Region,
-					"__param_module": probe,
-				},
+				Labels:  labels,
 			}
 			probeTargets[probe] = append(probeTargets[probe], entry)
-			count++
 		}
 	}
 
 	result := make(map[string][]byte)
+	counts := make(map[string]int)
 	for probe, entries := range probeTargets {
 		data, err := json.MarshalIndent(entries, "", "  ")
 		if err != nil {
-			return nil, 0, fmt.Errorf("marshaling blackbox targets for probe %s: %w", probe, err)
+			return nil, nil, fmt.Errorf("marshaling blackbox targets for probe %s: %w", probe, err)
+		}
+		if err := validateFileSD(data, validateProbeTarget(probe)); err != nil {
+			return nil, nil, fmt.Errorf("validating blackbox targets for probe %s: %w", probe, err)
 		}
 		filename := fmt.Sprintf("blackbox-%s-targets.json", probe)
 		result[filename] = data
+		counts[probe] = len(entries)
 	}
 
-	return result, count, nil
+	return result, counts, nil
 }
 
+// targetForProbe returns the address blackbox_exporter probes for d. The
+// tcp_connect port and http_2xx URL can be overridden per device in NetBox.
 func targetForProbe(d netbox.Device, probe string) string {
 	switch probe {
 	case "icmp":
 		return d.PrimaryIP
 	case "tcp_connect":
-		return fmt.Sprintf("%s:22", d.PrimaryIP)
+		port := d.CustomFields.BlackboxTCPPort
+		if port <= 0 || port > 65535 {
+			port = 22
+		}
+		return net.JoinHostPort(d.PrimaryIP, strconv.Itoa(port))
 	case "http_2xx":
-		return fmt.Sprintf("https://%s", d.PrimaryIP)
+		if d.CustomFields.BlackboxHTTPURL != "" {
+			return d.CustomFields.BlackboxHTTPURL
+		}
+		return fmt.Sprintf("https://%s", bracketIPv6(d.PrimaryIP))
 	default:
 		return d.PrimaryIP
 	}
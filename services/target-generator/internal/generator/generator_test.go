@@ -2,9 +2,14 @@ package generator
 
 import (
 	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 
+	"sigs.k8s.io/yaml"
+
 	"github.com/rhwendt/helios/services/target-generator/internal/netbox"
 )
 
@@ -64,11 +69,11 @@ func sampleDevices() []netbox.Device {
 
 func TestGenerateGNMICTargets(t *testing.T) {
 	tests := []struct {
-		name          string
-		devices       []netbox.Device
-		wantCount     int
-		wantContains  []string
-		wantExcludes  []string
+		name         string
+		devices      []netbox.Device
+		wantCount    int
+		wantContains []string
+		wantExcludes []string
 	}{
 		{
 			name:         "only gNMI-enabled devices with IPs",
@@ -108,7 +113,7 @@ func TestGenerateGNMICTargets(t *testing.T) {
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			data, count, err := GenerateGNMICTargets(tc.devices)
+			data, count, err := GenerateGNMICTargets(tc.devices, nil, nil)
 			if err != nil {
 				t.Fatalf("GenerateGNMICTargets error: %v", err)
 			}
@@ -150,7 +155,7 @@ func TestGenerateSNMPTargets(t *testing.T) {
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			data, count, err := GenerateSNMPTargets(tc.devices)
+			data, count, err := GenerateSNMPTargets(tc.devices, nil)
 			if err != nil {
 				t.Fatalf("GenerateSNMPTargets error: %v", err)
 			}
@@ -174,7 +179,7 @@ func TestGenerateSNMPTargets(t *testing.T) {
 
 func TestGenerateSNMPTargets_LabelTaxonomy(t *testing.T) {
 	devices := sampleDevices()
-	data, _, err := GenerateSNMPTargets(devices)
+	data, _, err := GenerateSNMPTargets(devices, nil)
 	if err != nil {
 		t.Fatalf("GenerateSNMPTargets error: %v", err)
 	}
@@ -196,16 +201,18 @@ func TestGenerateSNMPTargets_LabelTaxonomy(t *testing.T) {
 
 func TestGenerateBlackboxTargets(t *testing.T) {
 	tests := []struct {
-		name      string
-		devices   []netbox.Device
-		wantCount int
+		name       string
+		devices    []netbox.Device
+		wantCount  int
 		wantProbes []string
+		wantCounts map[string]int
 	}{
 		{
 			name:       "devices with multiple probes",
 			devices:    sampleDevices(),
 			wantCount:  3, // router-1: icmp+tcp_connect, switch-1: icmp
 			wantProbes: []string{"icmp", "tcp_connect"},
+			wantCounts: map[string]int{"icmp": 2, "tcp_connect": 1},
 		},
 		{
 			name: "device with default icmp probe",
@@ -217,6 +224,7 @@ func TestGenerateBlackboxTargets(t *testing.T) {
 			},
 			wantCount:  1,
 			wantProbes: []string{"icmp"},
+			wantCounts: map[string]int{"icmp": 1},
 		},
 		{
 			name:      "no devices",
@@ -227,10 +235,29 @@ func TestGenerateBlackboxTargets(t *testing.T) {
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			result, count, err := GenerateBlackboxTargets(tc.devices)
+			result, counts, err := GenerateBlackboxTargets(tc.devices, nil)
 			if err != nil {
 				t.Fatalf("GenerateBlackboxTargets error: %v", err)
 			}
+			count := 0
+			for probe, n := range counts {
+				count += n
+				var entries []PrometheusFileSDEntry
+				if err := json.Unmarshal(result["blackbox-"+probe+"-targets.json"], &entries); err != nil {
+					t.Fatalf("parsing %s targets: %v", probe, err)
+				}
+				if n != len(entries) {
+					t.Errorf("counts[%q] = %d, but %d entries were generated", probe, n, len(entries))
+				}
+			}
+			if len(counts) != len(tc.wantCounts) {
+				t.Errorf("counts = %v, want %v", counts, tc.wantCounts)
+			}
+			for probe, want := range tc.wantCounts {
+				if counts[probe] != want {
+					t.Errorf("counts[%q] = %d, want %d", probe, counts[probe], want)
+				}
+			}
 			if count != tc.wantCount {
 				t.Errorf("count = %d, want %d", count, tc.wantCount)
 			}
@@ -312,3 +339,527 @@ func TestDefaultSNMPModule(t *testing.T) {
 		})
 	}
 }
+
+func TestSanitizeLabelValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"plain", "router-1", "router-1"},
+		{"dots kept", "r1.example.net", "r1.example.net"},
+		{"inner spaces", "New York DC1", "New_York_DC1"},
+		{"surrounding spaces", "  dc1\t", "dc1"},
+		{"slashes", "core/edge", "core_edge"},
+		{"unicode kept", "Zürich-Süd", "Zürich-Süd"},
+		{"control characters dropped", "dc\x001\n", "dc1"},
+		{"invalid UTF-8 dropped", "dc\xff1", "dc1"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := sanitizeLabelValue(tc.value); got != tc.want {
+				t.Errorf("sanitizeLabelValue(%q) = %q, want %q", tc.value, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSanitizeLabelName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"device", "device"},
+		{"__param_module", "__param_module"},
+		{"site name", "site_name"},
+		{"rack/unit", "rack_unit"},
+		{"1st_hop", "_1st_hop"},
+		{"städte", "st_dte"},
+		{"", "_"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := sanitizeLabelName(tc.name); got != tc.want {
+				t.Errorf("sanitizeLabelName(%q) = %q, want %q", tc.name, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestGenerators_SanitizeLabels(t *testing.T) {
+	devices := []netbox.Device{{
+		Name:         "edge 1/0",
+		PrimaryIP:    "10.0.0.9",
+		Site:         "São Paulo DC",
+		Region:       "sa-east",
+		Manufacturer: "arista",
+		CustomFields: netbox.DeviceCustomFields{
+			GNMIEnabled:    true,
+			SNMPEnabled:    true,
+			BlackboxProbes: []string{"icmp"},
+		},
+	}}
+	wantDevice, wantSite := "edge_1_0", "São_Paulo_DC"
+
+	gnmicData, _, err := GenerateGNMICTargets(devices, nil, nil)
+	if err != nil {
+		t.Fatalf("GenerateGNMICTargets() error = %v", err)
+	}
+	if !strings.Contains(string(gnmicData), "device: "+wantDevice) || !strings.Contains(string(gnmicData), "site: "+wantSite) {
+		t.Errorf("gnmic labels not sanitized:\n%s", gnmicData)
+	}
+
+	snmpData, _, err := GenerateSNMPTargets(devices, nil)
+	if err != nil {
+		t.Fatalf("GenerateSNMPTargets() error = %v", err)
+	}
+	var snmp []PrometheusFileSDEntry
+	if err := json.Unmarshal(snmpData, &snmp); err != nil {
+		t.Fatalf("unmarshal snmp targets: %v", err)
+	}
+	if snmp[0].Labels["device"] != wantDevice || snmp[0].Labels["site"] != wantSite {
+		t.Errorf("snmp labels = %v, want device=%q site=%q", snmp[0].Labels, wantDevice, wantSite)
+	}
+
+	bb, _, err := GenerateBlackboxTargets(devices, nil)
+	if err != nil {
+		t.Fatalf("GenerateBlackboxTargets() error = %v", err)
+	}
+	var icmp []PrometheusFileSDEntry
+	if err := json.Unmarshal(bb["blackbox-icmp-targets.json"], &icmp); err != nil {
+		t.Fatalf("unmarshal blackbox targets: %v", err)
+	}
+	if icmp[0].Labels["device"] != wantDevice || icmp[0].Labels["site"] != wantSite {
+		t.Errorf("blackbox labels = %v, want device=%q site=%q", icmp[0].Labels, wantDevice, wantSite)
+	}
+}
+
+func TestTargetForProbe_Overrides(t *testing.T) {
+	defaults := netbox.Device{PrimaryIP: "10.0.0.1"}
+	custom := netbox.Device{
+		PrimaryIP: "10.0.0.2",
+		CustomFields: netbox.DeviceCustomFields{
+			BlackboxTCPPort: 2222,
+			BlackboxHTTPURL: "https://10.0.0.2:8443/health",
+		},
+	}
+
+	tests := []struct {
+		name   string
+		device netbox.Device
+		probe  string
+		want   string
+	}{
+		{"default tcp port", defaults, "tcp_connect", "10.0.0.1:22"},
+		{"custom tcp port", custom, "tcp_connect", "10.0.0.2:2222"},
+		{"out of range tcp port falls back", netbox.Device{PrimaryIP: "10.0.0.3", CustomFields: netbox.DeviceCustomFields{BlackboxTCPPort: 70000}}, "tcp_connect", "10.0.0.3:22"},
+		{"default http url", defaults, "http_2xx", "https://10.0.0.1"},
+		{"custom http url", custom, "http_2xx", "https://10.0.0.2:8443/health"},
+		{"icmp unaffected", custom, "icmp", "10.0.0.2"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := targetForProbe(tc.device, tc.probe); got != tc.want {
+				t.Errorf("targetForProbe(%q) = %q, want %q", tc.probe, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSubscriptionProfiles(t *testing.T) {
+	profiles := DefaultSubscriptionProfiles()
+
+	tests := []struct {
+		name    string
+		profile string
+		want    []string
+	}{
+		{"known profile expands", "minimal", []string{"default-counters"}},
+		{"premium tier", "premium", []string{"default-counters", "default-system", "default-bgp"}},
+		{"empty profile uses default", "", []string{"default-counters", "default-system", "default-bgp"}},
+		{"unknown profile uses default", "no-such-profile", []string{"default-counters", "default-system", "default-bgp"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := profiles.subscriptions(tc.profile); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("subscriptions(%q) = %v, want %v", tc.profile, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSubscriptionProfiles_WithDefaultSubscriptions(t *testing.T) {
+	builtin := DefaultSubscriptionProfiles()
+	profiles := builtin.WithDefaultSubscriptions([]string{"site-counters", "site-system"})
+
+	want := []string{"site-counters", "site-system"}
+	if got := profiles.subscriptions(""); !reflect.DeepEqual(got, want) {
+		t.Errorf("subscriptions(\"\") = %v, want %v", got, want)
+	}
+	if got := profiles.subscriptions("no-such-profile"); !reflect.DeepEqual(got, want) {
+		t.Errorf("subscriptions(unknown) = %v, want %v", got, want)
+	}
+	if got := profiles.subscriptions("minimal"); !reflect.DeepEqual(got, []string{"default-counters"}) {
+		t.Errorf("minimal = %v, want it unchanged", got)
+	}
+	if got := builtin.subscriptions(""); !reflect.DeepEqual(got, []string{"default-counters", "default-system", "default-bgp"}) {
+		t.Errorf("built-in default = %v, want it left unmodified", got)
+	}
+	if got := builtin.WithDefaultSubscriptions(nil).subscriptions(""); !reflect.DeepEqual(got, []string{"default-counters", "default-system", "default-bgp"}) {
+		t.Errorf("empty override = %v, want built-in default", got)
+	}
+}
+
+func TestGenerateGNMICTargets_CustomProfiles(t *testing.T) {
+	profiles := SubscriptionProfiles{
+		"default": {"default-counters"},
+		"premium": {"default-counters", "premium-qos"},
+	}
+	devices := []netbox.Device{
+		{Name: "r1", PrimaryIP: "10.0.0.1", TelemetryProfile: "premium", CustomFields: netbox.DeviceCustomFields{GNMIEnabled: true}},
+		{Name: "r2", PrimaryIP: "10.0.0.2", TelemetryProfile: "bogus", CustomFields: netbox.DeviceCustomFields{GNMIEnabled: true}},
+	}
+
+	data, _, err := GenerateGNMICTargets(devices, profiles, nil)
+	if err != nil {
+		t.Fatalf("GenerateGNMICTargets error: %v", err)
+	}
+	var got GNMICTargets
+	if err := yaml.Unmarshal(data, &got); err != nil {
+		t.Fatalf("unmarshal gnmic targets: %v", err)
+	}
+	if subs := got.Targets["r1:6030"].Subscriptions; !reflect.DeepEqual(subs, []string{"default-counters", "premium-qos"}) {
+		t.Errorf("r1 subscriptions = %v", subs)
+	}
+	if subs := got.Targets["r2:6030"].Subscriptions; !reflect.DeepEqual(subs, []string{"default-counters"}) {
+		t.Errorf("r2 subscriptions = %v, want default profile", subs)
+	}
+}
+
+func TestLoadSubscriptionProfiles(t *testing.T) {
+	dir := t.TempDir()
+
+	valid := filepath.Join(dir, "profiles.yaml")
+	if err := os.WriteFile(valid, []byte("default: [default-counters]\npremium: [default-counters, default-bgp]\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	profiles, err := LoadSubscriptionProfiles(valid)
+	if err != nil {
+		t.Fatalf("LoadSubscriptionProfiles() error = %v", err)
+	}
+	if got := profiles.subscriptions("premium"); !reflect.DeepEqual(got, []string{"default-counters", "default-bgp"}) {
+		t.Errorf("premium = %v", got)
+	}
+
+	noDefault := filepath.Join(dir, "no-default.yaml")
+	if err := os.WriteFile(noDefault, []byte("premium: [default-bgp]\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadSubscriptionProfiles(noDefault); err == nil {
+		t.Error("expected error for profiles without a default")
+	}
+}
+
+func TestGenerators_RejectInvalidTargets(t *testing.T) {
+	// A CIDR-suffixed primary IP is a common NetBox export mistake.
+	bad := netbox.Device{
+		Name:      "router-cidr",
+		PrimaryIP: "10.0.0.1/24",
+		CustomFields: netbox.DeviceCustomFields{
+			GNMIEnabled:    true,
+			SNMPEnabled:    true,
+			BlackboxProbes: []string{"icmp", "tcp_connect", "http_2xx"},
+		},
+	}
+	devices := append(sampleDevices(), bad)
+
+	if _, _, err := GenerateGNMICTargets(devices, nil, nil); err == nil {
+		t.Error("GenerateGNMICTargets: expected validation error")
+	}
+	if _, _, err := GenerateSNMPTargets(devices, nil); err == nil {
+		t.Error("GenerateSNMPTargets: expected validation error")
+	}
+	if _, _, err := GenerateBlackboxTargets(devices, nil); err == nil {
+		t.Error("GenerateBlackboxTargets: expected validation error")
+	}
+}
+
+func TestValidateProbeTarget(t *testing.T) {
+	tests := []struct {
+		probe   string
+		target  string
+		wantErr bool
+	}{
+		{"icmp", "10.0.0.1", false},
+		{"icmp", "router-1.example.net", false},
+		{"icmp", "10.0.0.1 ", true},
+		{"tcp_connect", "10.0.0.1:22", false},
+		{"tcp_connect", "[2001:db8::1]:22", false},
+		{"tcp_connect", "10.0.0.1:0", true},
+		{"tcp_connect", "10.0.0.1", true},
+		{"http_2xx", "https://10.0.0.1", false},
+		{"http_2xx", "https://10.0.0.1:8443/health", false},
+		{"http_2xx", "ftp://10.0.0.1", true},
+		{"http_2xx", "10.0.0.1", true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.probe+" "+tc.target, func(t *testing.T) {
+			err := validateProbeTarget(tc.probe)(tc.target)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("validateProbeTarget(%q)(%q) error = %v, wantErr %v", tc.probe, tc.target, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateGNMICTargets_RejectsMissingSubscriptions(t *testing.T) {
+	data := []byte("targets:\n  r1:6030:\n    address: 10.0.0.1:6030\n    labels: {}\n    subscriptions: []\n")
+	if err := validateGNMICTargets(data); err == nil {
+		t.Error("expected error for target without subscriptions")
+	}
+}
+
+func TestGenerateGNMICTargets_DuplicateNames(t *testing.T) {
+	devices := []netbox.Device{
+		{ID: 1, Name: "edge-1", PrimaryIP: "10.0.0.1", CustomFields: netbox.DeviceCustomFields{GNMIEnabled: true}},
+		{ID: 2, Name: "edge-1", PrimaryIP: "10.0.0.2", CustomFields: netbox.DeviceCustomFields{GNMIEnabled: true}},
+		{ID: 3, Name: "edge-1", PrimaryIP: "10.0.0.2", CustomFields: netbox.DeviceCustomFields{GNMIEnabled: true}},
+	}
+
+	data, count, err := GenerateGNMICTargets(devices, nil, nil)
+	if err != nil {
+		t.Fatalf("GenerateGNMICTargets error: %v", err)
+	}
+	if count != 3 {
+		t.Errorf("count = %d, want 3", count)
+	}
+
+	var got GNMICTargets
+	if err := yaml.Unmarshal(data, &got); err != nil {
+		t.Fatalf("unmarshal gnmic targets: %v", err)
+	}
+	want := map[string]string{
+		"edge-1:6030":            "10.0.0.1:6030",
+		"edge-1-10.0.0.2:6030":   "10.0.0.2:6030",
+		"edge-1-10.0.0.2-3:6030": "10.0.0.2:6030",
+	}
+	if len(got.Targets) != len(want) {
+		t.Fatalf("got %d targets, want %d: %v", len(got.Targets), len(want), got.Targets)
+	}
+	for key, addr := range want {
+		if got.Targets[key].Address != addr {
+			t.Errorf("target %q address = %q, want %q", key, got.Targets[key].Address, addr)
+		}
+	}
+}
+
+func TestScrapeIntervals_ByTier(t *testing.T) {
+	intervals := ScrapeIntervals{"premium": "15s", "standard": "1m"}
+	devices := []netbox.Device{
+		{ID: 1, Name: "fast", PrimaryIP: "10.0.0.1", MonitoringTier: "premium",
+			CustomFields: netbox.DeviceCustomFields{GNMIEnabled: true, SNMPEnabled: true}},
+		{ID: 2, Name: "unknown", PrimaryIP: "10.0.0.2", MonitoringTier: "bronze",
+			CustomFields: netbox.DeviceCustomFields{GNMIEnabled: true, SNMPEnabled: true}},
+	}
+
+	snmpData, _, err := GenerateSNMPTargets(devices, intervals)
+	if err != nil {
+		t.Fatalf("GenerateSNMPTargets() error = %v", err)
+	}
+	var snmp []PrometheusFileSDEntry
+	if err := json.Unmarshal(snmpData, &snmp); err != nil {
+		t.Fatalf("unmarshal snmp targets: %v", err)
+	}
+	if got := snmp[0].Labels["__scrape_interval__"]; got != "15s" {
+		t.Errorf("premium snmp interval = %q, want 15s", got)
+	}
+	if _, ok := snmp[1].Labels["__scrape_interval__"]; ok {
+		t.Error("unknown tier should not get a scrape interval")
+	}
+
+	bb, _, err := GenerateBlackboxTargets(devices, intervals)
+	if err != nil {
+		t.Fatalf("GenerateBlackboxTargets() error = %v", err)
+	}
+	var icmp []PrometheusFileSDEntry
+	if err := json.Unmarshal(bb["blackbox-icmp-targets.json"], &icmp); err != nil {
+		t.Fatalf("unmarshal blackbox targets: %v", err)
+	}
+	if got := icmp[0].Labels["__scrape_interval__"]; got != "15s" {
+		t.Errorf("premium blackbox interval = %q, want 15s", got)
+	}
+	if _, ok := icmp[1].Labels["__scrape_interval__"]; ok {
+		t.Error("unknown tier should not get a blackbox scrape interval")
+	}
+
+	gnmicData, _, err := GenerateGNMICTargets(devices, nil, intervals)
+	if err != nil {
+		t.Fatalf("GenerateGNMICTargets() error = %v", err)
+	}
+	var gnmic GNMICTargets
+	if err := yaml.Unmarshal(gnmicData, &gnmic); err != nil {
+		t.Fatalf("unmarshal gnmic targets: %v", err)
+	}
+	if got := gnmic.Targets["fast:6030"].Labels["scrape_interval"]; got != "15s" {
+		t.Errorf("premium gnmic interval = %q, want 15s", got)
+	}
+	if _, ok := gnmic.Targets["unknown:6030"].Labels["scrape_interval"]; ok {
+		t.Error("unknown tier should not get a gnmic interval label")
+	}
+}
+
+func TestParseScrapeIntervals(t *testing.T) {
+	got, err := ParseScrapeIntervals("premium=15s, standard = 1m,")
+	if err != nil {
+		t.Fatalf("ParseScrapeIntervals() error = %v", err)
+	}
+	if want := (ScrapeIntervals{"premium": "15s", "standard": "1m"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseScrapeIntervals() = %v, want %v", got, want)
+	}
+
+	if got, err := ParseScrapeIntervals(""); err != nil || len(got) != 0 {
+		t.Errorf("ParseScrapeIntervals(\"\") = %v, %v; want empty", got, err)
+	}
+	for _, bad := range []string{"premium", "=15s", "premium=fast", "premium=0s"} {
+		if _, err := ParseScrapeIntervals(bad); err == nil {
+			t.Errorf("ParseScrapeIntervals(%q) expected error", bad)
+		}
+	}
+}
+
+func TestGenerateInventoryTargets(t *testing.T) {
+	devices := []netbox.Device{
+		{ID: 1, Name: "rtr-01", PrimaryIP: "10.0.0.1", Site: "dc1", Region: "us-east",
+			Manufacturer: "arista", Platform: "eos", Role: "spine", MonitoringTier: "premium",
+			CustomFields: netbox.DeviceCustomFields{GNMIEnabled: true}},
+		{ID: 2, Name: "fw-01", PrimaryIP: "10.0.0.2", Site: "dc1", Region: "us-east",
+			Manufacturer: "paloalto", Platform: "panos", Role: "firewall", MonitoringTier: "standard"},
+		{ID: 3, Name: "no-ip", Site: "dc2"},
+	}
+
+	data, count, err := GenerateInventoryTargets(devices)
+	if err != nil {
+		t.Fatalf("GenerateInventoryTargets() error = %v", err)
+	}
+	if count != 2 {
+		t.Errorf("count = %d, want 2", count)
+	}
+
+	var entries []PrometheusFileSDEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		t.Fatalf("unmarshal inventory targets: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+	for _, e := range entries {
+		for _, label := range LabelTaxonomy {
+			if e.Labels[label] == "" {
+				t.Errorf("target %v missing label %q", e.Targets, label)
+			}
+		}
+		if _, ok := e.Labels["__param_module"]; ok {
+			t.Errorf("target %v should not carry exporter labels", e.Targets)
+		}
+	}
+	if entries[1].Targets[0] != "10.0.0.2" || entries[1].Labels["role"] != "firewall" {
+		t.Errorf("second entry = %+v, want fw-01 at 10.0.0.2", entries[1])
+	}
+
+	empty, count, err := GenerateInventoryTargets(nil)
+	if err != nil || count != 0 || string(empty) != "[]" {
+		t.Errorf("GenerateInventoryTargets(nil) = %s, %d, %v; want [], 0, nil", empty, count, err)
+	}
+}
+
+func TestGenerators_IPv6PrimaryIP(t *testing.T) {
+	devices := []netbox.Device{
+		{
+			Name: "router-v6", PrimaryIP: "2001:db8::1", Manufacturer: "arista",
+			CustomFields: netbox.DeviceCustomFields{
+				GNMIEnabled:    true,
+				SNMPEnabled:    true,
+				BlackboxProbes: []string{"icmp", "tcp_connect", "http_2xx"},
+			},
+		},
+	}
+	fileSDTarget := func(t *testing.T, data []byte) string {
+		t.Helper()
+		var entries []PrometheusFileSDEntry
+		if err := json.Unmarshal(data, &entries); err != nil {
+			t.Fatalf("unmarshal file_sd: %v", err)
+		}
+		if len(entries) != 1 || len(entries[0].Targets) != 1 {
+			t.Fatalf("entries = %+v, want one target", entries)
+		}
+		return entries[0].Targets[0]
+	}
+
+	t.Run("gnmic", func(t *testing.T) {
+		data, _, err := GenerateGNMICTargets(devices, DefaultSubscriptionProfiles(), nil)
+		if err != nil {
+			t.Fatalf("GenerateGNMICTargets() error = %v", err)
+		}
+		var got GNMICTargets
+		if err := yaml.Unmarshal(data, &got); err != nil {
+			t.Fatalf("unmarshal gnmic targets: %v", err)
+		}
+		if addr := got.Targets["router-v6:6030"].Address; addr != "[2001:db8::1]:6030" {
+			t.Errorf("address = %q, want [2001:db8::1]:6030", addr)
+		}
+	})
+
+	t.Run("snmp", func(t *testing.T) {
+		data, _, err := GenerateSNMPTargets(devices, nil)
+		if err != nil {
+			t.Fatalf("GenerateSNMPTargets() error = %v", err)
+		}
+		if got := fileSDTarget(t, data); got != "[2001:db8::1]" {
+			t.Errorf("target = %q, want [2001:db8::1]", got)
+		}
+	})
+
+	t.Run("blackbox", func(t *testing.T) {
+		files, _, err := GenerateBlackboxTargets(devices, nil)
+		if err != nil {
+			t.Fatalf("GenerateBlackboxTargets() error = %v", err)
+		}
+		for probe, want := range map[string]string{
+			"icmp":        "2001:db8::1",
+			"tcp_connect": "[2001:db8::1]:22",
+			"http_2xx":    "https://[2001:db8::1]",
+		} {
+			if got := fileSDTarget(t, files["blackbox-"+probe+"-targets.json"]); got != want {
+				t.Errorf("%s target = %q, want %q", probe, got, want)
+			}
+		}
+	})
+
+	t.Run("inventory", func(t *testing.T) {
+		data, _, err := GenerateInventoryTargets(devices)
+		if err != nil {
+			t.Fatalf("GenerateInventoryTargets() error = %v", err)
+		}
+		if got := fileSDTarget(t, data); got != "[2001:db8::1]" {
+			t.Errorf("target = %q, want [2001:db8::1]", got)
+		}
+	})
+}
+
+func TestValidateHost_BracketedIPv6(t *testing.T) {
+	for host, wantErr := range map[string]bool{
+		"[2001:db8::1]": false,
+		"[10.0.0.1]":    true,
+		"[router-1]":    true,
+		"[2001:db8::1":  true,
+	} {
+		if err := validateHost(host); (err != nil) != wantErr {
+			t.Errorf("validateHost(%q) error = %v, wantErr %v", host, err, wantErr)
+		}
+	}
+}
@@ -2,6 +2,9 @@ package generator
 
 import (
 	"fmt"
+	"log/slog"
+	"net"
+	"strconv"
 
 	"github.com/rhwendt/helios/services/target-generator/internal/netbox"
 	"sigs.k8s.io/yaml"
@@ -20,11 +23,22 @@ type GNMICTargets struct {
 }
 
 // GenerateGNMICTargets converts NetBox devices to gnmic target YAML format.
-func GenerateGNMICTargets(devices []netbox.Device) ([]byte, int, error) {
+// Each device's telemetry profile is expanded into subscriptions using
+// profiles, or DefaultSubscriptionProfiles if profiles is nil, and its tier
+// into a scrape_interval label using intervals. Devices whose
+// name:port key is already taken get the primary IP added to their key so
+// neither is dropped. The output is re-parsed and validated; an error means
+// nothing should be written.
+func GenerateGNMICTargets(devices []netbox.Device, profiles SubscriptionProfiles, intervals ScrapeIntervals) ([]byte, int, error) {
+	if profiles == nil {
+		profiles = DefaultSubscriptionProfiles()
+	}
+
 	targets := GNMICTargets{
 		Targets: make(map[string]GNMICTarget),
 	}
 
+	owners := make(map[string]int) // target key -> NetBox ID
 	count := 0
 	for _, d := range devices {
 		if !d.CustomFields.GNMIEnabled || d.PrimaryIP == "" {
@@ -37,21 +51,30 @@ func GenerateGNMICTargets(devices []netbox.Device) ([]byte, int, error) {
 		}
 
 		key := fmt.Sprintf("%s:%d", d.Name, port)
-		address := fmt.Sprintf("%s:%d", d.PrimaryIP, port)
+		if ownerID, taken := owners[key]; taken {
+			unique := fmt.Sprintf("%s-%s:%d", d.Name, d.PrimaryIP, port)
+			if _, taken := owners[unique]; taken {
+				unique = fmt.Sprintf("%s-%s-%d:%d", d.Name, d.PrimaryIP, d.ID, port)
+			}
+			slog.Warn("duplicate gnmic target name, disambiguating",
+				"key", key,
+				"device_id", ownerID,
+				"duplicate_device_id", d.ID,
+				"new_key", unique,
+			)
+			key = unique
+		}
+		owners[key] = d.ID
+		address := net.JoinHostPort(d.PrimaryIP, strconv.Itoa(port))
+
+		subs := profiles.subscriptions(d.TelemetryProfile)
 
-		subs := defaultSubscriptions(d)
+		labels := BuildLabels(d)
+		intervals.apply(labels, gnmicIntervalLabel, d.MonitoringTier)
 
 		targets.Targets[key] = GNMICTarget{
-			Address: address,
-			Labels: map[string]string{
-				"device":   d.Name,
-				"site":     d.Site,
-				"region":   d.Region,
-				"vendor":   d.Manufacturer,
-				"platform": d.Platform,
-				"role":     d.Role,
-				"tier":     d.MonitoringTier,
-			},
+			Address:       address,
+			Labels:        labels,
 			Subscriptions: subs,
 		}
 		count++
@@ -61,16 +84,9 @@ func GenerateGNMICTargets(devices []netbox.Device) ([]byte, int, error) {
 	if err != nil {
 		return nil, 0, fmt.Errorf("marshaling gnmic targets: %w", err)
 	}
+	if err := validateGNMICTargets(data); err != nil {
+		return nil, 0, fmt.Errorf("validating gnmic targets: %w", err)
+	}
 
 	return data, count, nil
 }
-
-func defaultSubscriptions(d netbox.Device) []string {
-	subs := []string{"default-counters", "default-system"}
-	if d.TelemetryProfile != "" {
-		subs = append(subs, d.TelemetryProfile)
-	} else {
-		subs = append(subs, "default-bgp")
-	}
-	return subs
-}
@@ -0,0 +1,49 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// scrapeIntervalLabel is the Prometheus target label that overrides the
+// job's scrape interval.
+const scrapeIntervalLabel = "__scrape_interval__"
+
+// gnmicIntervalLabel carries the tier interval on gnmic targets, whose labels
+// end up on the exported metrics and so cannot use a reserved name.
+const gnmicIntervalLabel = "scrape_interval"
+
+// ScrapeIntervals maps a device monitoring tier to its scrape interval, in
+// Prometheus duration syntax. Tiers not in the map get no interval label and
+// fall back to the scrape job's default.
+type ScrapeIntervals map[string]string
+
+// ParseScrapeIntervals parses a comma-separated list of tier=interval pairs,
+// e.g. "premium=15s,standard=1m".
+func ParseScrapeIntervals(s string) (ScrapeIntervals, error) {
+	intervals := make(ScrapeIntervals)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		tier, interval, ok := strings.Cut(pair, "=")
+		tier, interval = strings.TrimSpace(tier), strings.TrimSpace(interval)
+		if !ok || tier == "" {
+			return nil, fmt.Errorf("invalid tier interval %q, want tier=duration", pair)
+		}
+		if d, err := time.ParseDuration(interval); err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid interval %q for tier %s", interval, tier)
+		}
+		intervals[tier] = interval
+	}
+	return intervals, nil
+}
+
+// apply sets label to the interval for tier, if the tier has one.
+func (s ScrapeIntervals) apply(labels map[string]string, label, tier string) {
+	if interval, ok := s[tier]; ok {
+		labels[label] = interval
+	}
+}
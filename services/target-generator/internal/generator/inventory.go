@@ -0,0 +1,39 @@
+package generator
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/rhwendt/helios/services/target-generator/internal/netbox"
+)
+
+// GenerateInventoryTargets converts every NetBox device with a primary IP to
+// a Prometheus file_sd entry carrying the full label taxonomy, regardless of
+// which exporters the device is enabled for. It is meant to drive custom
+// relabel rules rather than to be scraped directly.
+func GenerateInventoryTargets(devices []netbox.Device) ([]byte, int, error) {
+	entries := []PrometheusFileSDEntry{}
+	count := 0
+
+	for _, d := range devices {
+		if d.PrimaryIP == "" {
+			continue
+		}
+
+		entries = append(entries, PrometheusFileSDEntry{
+			Targets: []string{bracketIPv6(d.PrimaryIP)},
+			Labels:  BuildLabels(d),
+		})
+		count++
+	}
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return nil, 0, fmt.Errorf("marshaling inventory targets: %w", err)
+	}
+	if err := validateFileSD(data, validateHost); err != nil {
+		return nil, 0, fmt.Errorf("validating inventory targets: %w", err)
+	}
+
+	return data, count, nil
+}
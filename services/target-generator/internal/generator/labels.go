@@ -0,0 +1,63 @@
+package generator
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// sanitizeLabels returns a copy of labels with every key and value made safe
+// for Prometheus and snmp_exporter.
+func sanitizeLabels(labels map[string]string) map[string]string {
+	out := make(map[string]string, len(labels))
+	for k, v := range labels {
+		out[sanitizeLabelName(k)] = sanitizeLabelValue(v)
+	}
+	return out
+}
+
+// sanitizeLabelName maps name onto [a-zA-Z_][a-zA-Z0-9_]*, replacing every
+// other character with an underscore.
+func sanitizeLabelName(name string) string {
+	if name == "" {
+		return "_"
+	}
+	var b strings.Builder
+	for i, r := range name {
+		switch {
+		case r == '_', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+			b.WriteRune(r)
+		case r >= '0' && r <= '9':
+			if i == 0 {
+				b.WriteByte('_')
+			}
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+// sanitizeLabelValue cleans a NetBox name for use as a label value: invalid
+// UTF-8 and control characters are dropped, surrounding whitespace is
+// trimmed, and inner whitespace and slashes become underscores. Other
+// Unicode is kept as Prometheus accepts any valid UTF-8.
+func sanitizeLabelValue(value string) string {
+	if !utf8.ValidString(value) {
+		value = strings.ToValidUTF8(value, "")
+	}
+	value = strings.TrimSpace(value)
+
+	var b strings.Builder
+	for _, r := range value {
+		switch {
+		case unicode.IsSpace(r), r == '/':
+			b.WriteByte('_')
+		case unicode.IsControl(r):
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
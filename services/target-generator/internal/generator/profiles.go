@@ -0,0 +1,78 @@
+package generator
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+
+	"sigs.k8s.io/yaml"
+)
+
+// defaultProfile is the telemetry profile used for devices without one, or
+// with one that is not in the profile map.
+const defaultProfile = "default"
+
+//go:embed profiles.yaml
+var defaultProfilesYAML []byte
+
+// SubscriptionProfiles maps a NetBox telemetry profile to the gnmic
+// subscriptions it expands into.
+type SubscriptionProfiles map[string][]string
+
+// DefaultSubscriptionProfiles returns the built-in profile map.
+func DefaultSubscriptionProfiles() SubscriptionProfiles {
+	profiles, err := parseSubscriptionProfiles(defaultProfilesYAML)
+	if err != nil {
+		panic(fmt.Sprintf("parsing embedded profiles.yaml: %v", err))
+	}
+	return profiles
+}
+
+// LoadSubscriptionProfiles reads a profile map from a YAML file, typically
+// mounted from a ConfigMap. The map must define the "default" profile.
+func LoadSubscriptionProfiles(path string) (SubscriptionProfiles, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading subscription profiles: %w", err)
+	}
+	profiles, err := parseSubscriptionProfiles(data)
+	if err != nil {
+		return nil, fmt.Errorf("parsing subscription profiles %s: %w", path, err)
+	}
+	return profiles, nil
+}
+
+func parseSubscriptionProfiles(data []byte) (SubscriptionProfiles, error) {
+	var profiles SubscriptionProfiles
+	if err := yaml.UnmarshalStrict(data, &profiles); err != nil {
+		return nil, err
+	}
+	if len(profiles[defaultProfile]) == 0 {
+		return nil, fmt.Errorf("no subscriptions for the %q profile", defaultProfile)
+	}
+	return profiles, nil
+}
+
+// WithDefaultSubscriptions returns a copy of p whose "default" profile is subs,
+// so the fallback list can be aligned with the subscriptions a gnmic
+// deployment actually defines. An empty subs returns p unchanged.
+func (p SubscriptionProfiles) WithDefaultSubscriptions(subs []string) SubscriptionProfiles {
+	if len(subs) == 0 {
+		return p
+	}
+	out := make(SubscriptionProfiles, len(p)+1)
+	for profile, s := range p {
+		out[profile] = s
+	}
+	out[defaultProfile] = subs
+	return out
+}
+
+// subscriptions returns the gnmic subscriptions for a telemetry profile,
+// falling back to the default profile.
+func (p SubscriptionProfiles) subscriptions(profile string) []string {
+	if subs, ok := p[profile]; ok && len(subs) > 0 {
+		return subs
+	}
+	return p[defaultProfile]
+}
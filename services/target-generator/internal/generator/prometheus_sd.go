@@ -1,6 +1,9 @@
 package generator
 
 import (
+	"net"
+	"strings"
+
 	"github.com/rhwendt/helios/services/target-generator/internal/netbox"
 )
 
@@ -15,9 +18,10 @@ var LabelTaxonomy = []string{
 	"tier",
 }
 
-// BuildLabels constructs the standard Helios label set from a NetBox device.
+// BuildLabels constructs the standard Helios label set from a NetBox device,
+// with values sanitized for Prometheus.
 func BuildLabels(d netbox.Device) map[string]string {
-	return map[string]string{
+	return sanitizeLabels(map[string]string{
 		"device":   d.Name,
 		"site":     d.Site,
 		"region":   d.Region,
@@ -25,5 +29,14 @@ func BuildLabels(d netbox.Device) map[string]string {
 		"platform": d.Platform,
 		"role":     d.Role,
 		"tier":     d.MonitoringTier,
+	})
+}
+
+// bracketIPv6 wraps an IPv6 address in brackets so it can be used as the host
+// of a URL or an exporter target. Any other host is returned unchanged.
+func bracketIPv6(host string) string {
+	if strings.Contains(host, ":") && net.ParseIP(host) != nil {
+		return "[" + host + "]"
 	}
+	return host
 }
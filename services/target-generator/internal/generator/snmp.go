@@ -14,7 +14,8 @@ type PrometheusFileSDEntry struct {
 }
 
 // GenerateSNMPTargets converts NetBox devices to Prometheus file_sd JSON for snmp_exporter.
-func GenerateSNMPTargets(devices []netbox.Device) ([]byte, int, error) {
+// Devices whose tier is in intervals get a per-target scrape interval.
+func GenerateSNMPTargets(devices []netbox.Device, intervals ScrapeIntervals) ([]byte, int, error) {
 	var entries []PrometheusFileSDEntry
 	count := 0
 
@@ -28,19 +29,12 @@ func GenerateSNMPTargets(devices []netbox.Device) ([]byte, int, error) {
 			module = defaultSNMPModule(d.Manufacturer, d.Platform)
 		}
 
-		labels := map[string]string{
-			"device":         d.Name,
-			"site":           d.Site,
-			"region":         d.Region,
-			"vendor":         d.Manufacturer,
-			"platform":       d.Platform,
-			"role":           d.Role,
-			"tier":           d.MonitoringTier,
-			"__param_module": module,
-		}
+		labels := BuildLabels(d)
+		labels["__param_module"] = sanitizeLabelValue(module)
+		intervals.apply(labels, scrapeIntervalLabel, d.MonitoringTier)
 
 		entries = append(entries, PrometheusFileSDEntry{
-			Targets: []string{d.PrimaryIP},
+			Targets: []string{bracketIPv6(d.PrimaryIP)},
 			Labels:  labels,
 		})
 		count++
@@ -50,6 +44,9 @@ func GenerateSNMPTargets(devices []netbox.Device) ([]byte, int, error) {
 	if err != nil {
 		return nil, 0, fmt.Errorf("marshaling SNMP targets: %w", err)
 	}
+	if err := validateFileSD(data, validateHost); err != nil {
+		return nil, 0, fmt.Errorf("validating SNMP targets: %w", err)
+	}
 
 	return data, count, nil
 }
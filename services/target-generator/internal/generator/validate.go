@@ -0,0 +1,134 @@
+package generator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"sigs.k8s.io/yaml"
+)
+
+// labelNameRE matches valid Prometheus label names.
+var labelNameRE = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// validateGNMICTargets re-parses generated gnmic targets the way gnmic will
+// and checks every target is usable.
+func validateGNMICTargets(data []byte) error {
+	var targets GNMICTargets
+	if err := yaml.UnmarshalStrict(data, &targets); err != nil {
+		return fmt.Errorf("re-parsing gnmic targets: %w", err)
+	}
+	for name, t := range targets.Targets {
+		if err := validateHostPort(t.Address); err != nil {
+			return fmt.Errorf("gnmic target %s: %w", name, err)
+		}
+		if len(t.Subscriptions) == 0 {
+			return fmt.Errorf("gnmic target %s: no subscriptions", name)
+		}
+		if err := validateLabels(t.Labels); err != nil {
+			return fmt.Errorf("gnmic target %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// validateFileSD re-parses generated Prometheus file_sd JSON and checks each
+// target with validTarget and each label set.
+func validateFileSD(data []byte, validTarget func(string) error) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var entries []PrometheusFileSDEntry
+	if err := dec.Decode(&entries); err != nil {
+		return fmt.Errorf("re-parsing file_sd targets: %w", err)
+	}
+	for _, e := range entries {
+		if len(e.Targets) == 0 {
+			return fmt.Errorf("file_sd entry without targets")
+		}
+		for _, target := range e.Targets {
+			if err := validTarget(target); err != nil {
+				return fmt.Errorf("file_sd target %q: %w", target, err)
+			}
+		}
+		if err := validateLabels(e.Labels); err != nil {
+			return fmt.Errorf("file_sd target %v: %w", e.Targets, err)
+		}
+	}
+	return nil
+}
+
+// validateLabels checks label names and values are acceptable to Prometheus.
+func validateLabels(labels map[string]string) error {
+	for k, v := range labels {
+		if !labelNameRE.MatchString(k) {
+			return fmt.Errorf("invalid label name %q", k)
+		}
+		if !utf8.ValidString(v) {
+			return fmt.Errorf("label %s has invalid UTF-8 value", k)
+		}
+	}
+	return nil
+}
+
+// validateHost accepts an IP address, a bracketed IPv6 address or a DNS name.
+func validateHost(host string) error {
+	if host == "" {
+		return fmt.Errorf("empty host")
+	}
+	if net.ParseIP(host) != nil {
+		return nil
+	}
+	if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		if ip := net.ParseIP(host[1 : len(host)-1]); ip != nil && ip.To4() == nil {
+			return nil
+		}
+	}
+	if strings.ContainsAny(host, "/:@?#[]") || strings.IndexFunc(host, isSpaceOrControl) >= 0 {
+		return fmt.Errorf("invalid host %q", host)
+	}
+	return nil
+}
+
+// validateHostPort accepts host:port with a valid host and a port in 1-65535.
+func validateHostPort(addr string) error {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid address %q: %w", addr, err)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("invalid port in %q", addr)
+	}
+	return validateHost(host)
+}
+
+// validateProbeTarget checks a blackbox target matches the form its probe
+// module expects.
+func validateProbeTarget(probe string) func(string) error {
+	return func(target string) error {
+		switch probe {
+		case "tcp_connect":
+			return validateHostPort(target)
+		case "http_2xx":
+			u, err := url.Parse(target)
+			if err != nil {
+				return err
+			}
+			if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("not an http(s) URL")
+			}
+			return nil
+		default:
+			return validateHost(target)
+		}
+	}
+}
+
+func isSpaceOrControl(r rune) bool {
+	return r <= ' ' || r == 0x7f
+}
@@ -4,11 +4,15 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"maps"
+	"sort"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
@@ -37,9 +41,74 @@ func NewConfigMapUpdater(client kubernetes.Interface, namespace string, logger *
 	}
 }
 
+// generatedByLabel marks ConfigMaps owned by the target generator. Only
+// ConfigMaps carrying it are ever deleted.
+const generatedByLabel = "helios.io/generated-by"
+
+// ConfigMapSpec is the desired state of one generated ConfigMap.
+type ConfigMapSpec struct {
+	Name   string
+	Data   map[string]string
+	Labels map[string]string
+}
+
+// Reconcile writes each ConfigMap so that its data matches the spec exactly,
+// pruning keys that are no longer generated. A spec with no data deletes its
+// ConfigMap. If deviceCount is zero the whole reconcile is skipped: NetBox
+// returning no devices is far more likely an outage or a bad filter than an
+// intentional removal of every target.
+func (u *ConfigMapUpdater) Reconcile(ctx context.Context, deviceCount int, specs []ConfigMapSpec) error {
+	if deviceCount == 0 {
+		u.logger.Warn("skipping ConfigMap reconcile: no devices returned, refusing to remove all targets")
+		return nil
+	}
+
+	for _, spec := range specs {
+		if len(spec.Data) == 0 {
+			if err := u.DeleteConfigMap(ctx, spec.Name); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := u.UpdateConfigMap(ctx, spec.Name, spec.Data, spec.Labels); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteConfigMap removes a ConfigMap generated by the target generator. It
+// is a no-op if the ConfigMap does not exist, and refuses to delete one that
+// lacks the generated-by label.
+func (u *ConfigMapUpdater) DeleteConfigMap(ctx context.Context, name string) error {
+	existing, err := u.client.CoreV1().ConfigMaps(u.namespace).Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		configMapUpdates.WithLabelValues(name, u.namespace, "error").Inc()
+		return fmt.Errorf("getting ConfigMap %s: %w", name, err)
+	}
+	if existing.Labels[generatedByLabel] == "" {
+		u.logger.Warn("not deleting ConfigMap without generated-by label", "name", name)
+		return nil
+	}
+
+	err = u.client.CoreV1().ConfigMaps(u.namespace).Delete(ctx, name, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		configMapUpdates.WithLabelValues(name, u.namespace, "error").Inc()
+		return fmt.Errorf("deleting ConfigMap %s: %w", name, err)
+	}
+
+	u.logger.Info("deleted empty ConfigMap", "name", name, "namespace", u.namespace)
+	configMapUpdates.WithLabelValues(name, u.namespace, "deleted").Inc()
+	return nil
+}
+
 // UpdateConfigMap atomically updates a ConfigMap's data, preserving the existing
 // ConfigMap on error. If the new data is empty, the update is skipped to prevent
-// accidentally removing all targets.
+// accidentally removing all targets; if it matches the existing ConfigMap,
+// nothing is written.
 func (u *ConfigMapUpdater) UpdateConfigMap(ctx context.Context, name string, data map[string]string, labels map[string]string) error {
 	if len(data) == 0 {
 		u.logger.Warn("skipping ConfigMap update with empty data to prevent target loss", "name", name)
@@ -74,7 +143,19 @@ func (u *ConfigMapUpdater) UpdateConfigMap(ctx context.Context, name string, dat
 		return nil
 	}
 
-	// Update existing ConfigMap
+	// Skip no-op writes: every Update bumps resourceVersion and makes the
+	// collectors reload. The last-sync annotation is deliberately left stale;
+	// the sync metrics record when the generator last ran.
+	if maps.Equal(existing.Data, data) && maps.Equal(existing.Labels, labels) {
+		u.logger.Debug("ConfigMap unchanged, skipping update", "name", name)
+		configMapUpdates.WithLabelValues(name, u.namespace, "unchanged").Inc()
+		return nil
+	}
+
+	// Replace the data wholesale so keys that are no longer generated are pruned
+	if stale := staleKeys(existing.Data, data); len(stale) > 0 {
+		u.logger.Info("pruning stale ConfigMap keys", "name", name, "keys", stale)
+	}
 	existing.Data = data
 	existing.Labels = labels
 	existing.Annotations = annotations
@@ -90,7 +171,47 @@ func (u *ConfigMapUpdater) UpdateConfigMap(ctx context.Context, name string, dat
 	return nil
 }
 
+// countTargets sums the targets in every file of a generated ConfigMap.
 func countTargets(data map[string]string) int {
-	// Approximate count based on number of data keys
-	return len(data)
+	total := 0
+	for _, contents := range data {
+		total += countFileTargets(contents)
+	}
+	return total
+}
+
+// countFileTargets counts the targets in one generated file: the addresses
+// of every group in a Prometheus file_sd list, or the entries of a gnmic
+// targets map. A file in neither format counts as none.
+func countFileTargets(contents string) int {
+	var groups []struct {
+		Targets []string `json:"targets"`
+	}
+	if err := yaml.Unmarshal([]byte(contents), &groups); err == nil {
+		n := 0
+		for _, g := range groups {
+			n += len(g.Targets)
+		}
+		return n
+	}
+
+	var gnmic struct {
+		Targets map[string]any `json:"targets"`
+	}
+	if err := yaml.Unmarshal([]byte(contents), &gnmic); err == nil {
+		return len(gnmic.Targets)
+	}
+	return 0
+}
+
+// staleKeys returns the keys of old that are absent from new, sorted.
+func staleKeys(old, new map[string]string) []string {
+	var stale []string
+	for k := range old {
+		if _, ok := new[k]; !ok {
+			stale = append(stale, k)
+		}
+	}
+	sort.Strings(stale)
+	return stale
 }
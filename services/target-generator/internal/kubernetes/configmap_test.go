@@ -0,0 +1,219 @@
+package kubernetes
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const testNamespace = "helios-collection"
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+}
+
+func generatedLabels() map[string]string {
+	return map[string]string{
+		"app.kubernetes.io/name": "blackbox-exporter",
+		generatedByLabel:         "target-generator",
+	}
+}
+
+func getConfigMap(t *testing.T, client *fake.Clientset, name string) *corev1.ConfigMap {
+	t.Helper()
+	cm, err := client.CoreV1().ConfigMaps(testNamespace).Get(context.Background(), name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("getting ConfigMap %s: %v", name, err)
+	}
+	return cm
+}
+
+func TestReconcile_PrunesStaleKeys(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	u := NewConfigMapUpdater(client, testNamespace, testLogger())
+	ctx := context.Background()
+
+	first := []ConfigMapSpec{{
+		Name:   "helios-blackbox-targets",
+		Data:   map[string]string{"icmp.json": "[]", "http_2xx.json": "[]"},
+		Labels: generatedLabels(),
+	}}
+	if err := u.Reconcile(ctx, 2, first); err != nil {
+		t.Fatalf("first Reconcile() error = %v", err)
+	}
+
+	second := []ConfigMapSpec{{
+		Name:   "helios-blackbox-targets",
+		Data:   map[string]string{"icmp.json": "[]"},
+		Labels: generatedLabels(),
+	}}
+	if err := u.Reconcile(ctx, 1, second); err != nil {
+		t.Fatalf("second Reconcile() error = %v", err)
+	}
+
+	cm := getConfigMap(t, client, "helios-blackbox-targets")
+	if want := map[string]string{"icmp.json": "[]"}; !reflect.DeepEqual(cm.Data, want) {
+		t.Errorf("Data = %v, want %v", cm.Data, want)
+	}
+}
+
+func TestReconcile_ZeroDevicesSkipped(t *testing.T) {
+	existing := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "helios-blackbox-targets",
+			Namespace: testNamespace,
+			Labels:    generatedLabels(),
+		},
+		Data: map[string]string{"icmp.json": "[]"},
+	}
+	client := fake.NewSimpleClientset(existing)
+	u := NewConfigMapUpdater(client, testNamespace, testLogger())
+
+	specs := []ConfigMapSpec{{Name: "helios-blackbox-targets", Labels: generatedLabels()}}
+	if err := u.Reconcile(context.Background(), 0, specs); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+
+	cm := getConfigMap(t, client, "helios-blackbox-targets")
+	if want := map[string]string{"icmp.json": "[]"}; !reflect.DeepEqual(cm.Data, want) {
+		t.Errorf("Data = %v, want %v", cm.Data, want)
+	}
+}
+
+func TestReconcile_DeletesEmptyConfigMap(t *testing.T) {
+	existing := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "helios-blackbox-targets",
+			Namespace: testNamespace,
+			Labels:    generatedLabels(),
+		},
+		Data: map[string]string{"icmp.json": "[]"},
+	}
+	client := fake.NewSimpleClientset(existing)
+	u := NewConfigMapUpdater(client, testNamespace, testLogger())
+
+	specs := []ConfigMapSpec{{Name: "helios-blackbox-targets", Labels: generatedLabels()}}
+	if err := u.Reconcile(context.Background(), 3, specs); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+
+	_, err := client.CoreV1().ConfigMaps(testNamespace).Get(context.Background(), "helios-blackbox-targets", metav1.GetOptions{})
+	if err == nil {
+		t.Error("expected empty ConfigMap to be deleted")
+	}
+}
+
+func TestDeleteConfigMap_KeepsUnmanaged(t *testing.T) {
+	existing := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "hand-written", Namespace: testNamespace},
+		Data:       map[string]string{"targets.yaml": "{}"},
+	}
+	client := fake.NewSimpleClientset(existing)
+	u := NewConfigMapUpdater(client, testNamespace, testLogger())
+
+	if err := u.DeleteConfigMap(context.Background(), "hand-written"); err != nil {
+		t.Fatalf("DeleteConfigMap() error = %v", err)
+	}
+	getConfigMap(t, client, "hand-written")
+
+	if err := u.DeleteConfigMap(context.Background(), "missing"); err != nil {
+		t.Errorf("DeleteConfigMap() on missing ConfigMap error = %v", err)
+	}
+}
+
+func TestUpdateConfigMap_SkipsUnchangedData(t *testing.T) {
+	data := map[string]string{"targets.yaml": "targets: {}\n"}
+	existing := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "helios-gnmic-targets",
+			Namespace: testNamespace,
+			Labels:    generatedLabels(),
+		},
+		Data: map[string]string{"targets.yaml": "targets: {}\n"},
+	}
+	client := fake.NewSimpleClientset(existing)
+	u := NewConfigMapUpdater(client, testNamespace, testLogger())
+	before := testutil.ToFloat64(configMapUpdates.WithLabelValues("helios-gnmic-targets", testNamespace, "unchanged"))
+
+	if err := u.UpdateConfigMap(context.Background(), "helios-gnmic-targets", data, generatedLabels()); err != nil {
+		t.Fatalf("UpdateConfigMap() error = %v", err)
+	}
+
+	for _, action := range client.Actions() {
+		if action.GetVerb() == "update" {
+			t.Errorf("unexpected %s action for unchanged data", action.GetVerb())
+		}
+	}
+	after := testutil.ToFloat64(configMapUpdates.WithLabelValues("helios-gnmic-targets", testNamespace, "unchanged"))
+	if after-before != 1 {
+		t.Errorf("unchanged counter increased by %v, want 1", after-before)
+	}
+
+	// A real change is still written.
+	changed := map[string]string{"targets.yaml": "targets: {r1: {}}\n"}
+	if err := u.UpdateConfigMap(context.Background(), "helios-gnmic-targets", changed, generatedLabels()); err != nil {
+		t.Fatalf("UpdateConfigMap() error = %v", err)
+	}
+	if cm := getConfigMap(t, client, "helios-gnmic-targets"); !reflect.DeepEqual(cm.Data, changed) {
+		t.Errorf("Data = %v, want %v", cm.Data, changed)
+	}
+}
+
+func TestUpdateConfigMap_DeviceCountAnnotation(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	u := NewConfigMapUpdater(client, testNamespace, testLogger())
+	ctx := context.Background()
+
+	gnmic := `targets:
+  router-1:6030:
+    address: 10.0.0.1:6030
+  router-2:6030:
+    address: 10.0.0.2:6030
+  router-3:6030:
+    address: 10.0.0.3:6030
+`
+	if err := u.UpdateConfigMap(ctx, "helios-gnmic-targets", map[string]string{"targets.yaml": gnmic}, generatedLabels()); err != nil {
+		t.Fatalf("UpdateConfigMap() error = %v", err)
+	}
+	if got := getConfigMap(t, client, "helios-gnmic-targets").Annotations["helios.io/device-count"]; got != "3" {
+		t.Errorf("gnmic device-count = %s, want 3", got)
+	}
+
+	blackbox := map[string]string{
+		"icmp.json":     `[{"targets":["10.0.0.1"],"labels":{"device":"router-1"}},{"targets":["10.0.0.2"],"labels":{"device":"router-2"}}]`,
+		"http_2xx.json": `[{"targets":["https://10.0.0.1","https://10.0.0.2"],"labels":{}}]`,
+	}
+	if err := u.UpdateConfigMap(ctx, "helios-blackbox-targets", blackbox, generatedLabels()); err != nil {
+		t.Fatalf("UpdateConfigMap() error = %v", err)
+	}
+	if got := getConfigMap(t, client, "helios-blackbox-targets").Annotations["helios.io/device-count"]; got != "4" {
+		t.Errorf("blackbox device-count = %s, want 4", got)
+	}
+}
+
+func TestCountFileTargets(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+		want     int
+	}{
+		{"empty file_sd list", "[]", 0},
+		{"file_sd groups", `[{"targets":["a","b"]},{"targets":["c"]}]`, 3},
+		{"gnmic targets", "targets:\n  r1:6030: {address: 10.0.0.1:6030}\n", 1},
+		{"unknown format", "not: [targets", 0},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := countFileTargets(tc.contents); got != tc.want {
+				t.Errorf("countFileTargets() = %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
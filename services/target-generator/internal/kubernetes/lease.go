@@ -0,0 +1,122 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// LeaseGuard uses a coordination.k8s.io Lease so that only one target
+// generator at a time writes the generated ConfigMaps, e.g. while a rollout
+// briefly runs two. Rather than holding leadership in the background, each
+// sync calls Acquire to take or renew the lease, which works the same for a
+// one-shot run as for a continuous sync.
+type LeaseGuard struct {
+	client    kubernetes.Interface
+	logger    *slog.Logger
+	namespace string
+	name      string
+	identity  string
+	duration  time.Duration
+	now       func() time.Time
+}
+
+// NewLeaseGuard creates a guard for the named Lease in namespace. identity
+// must be unique to this instance, and duration should outlast the time
+// between two syncs so the holder keeps the lease.
+func NewLeaseGuard(client kubernetes.Interface, namespace, name, identity string, duration time.Duration, logger *slog.Logger) *LeaseGuard {
+	return &LeaseGuard{
+		client:    client,
+		logger:    logger,
+		namespace: namespace,
+		name:      name,
+		identity:  identity,
+		duration:  duration,
+		now:       time.Now,
+	}
+}
+
+// Acquire takes the lease if it is unheld, expired or already held by this
+// instance, and renews it for another duration. It returns false without an
+// error while another instance holds an unexpired lease, or if another
+// instance took the lease between reading and writing it.
+func (g *LeaseGuard) Acquire(ctx context.Context) (bool, error) {
+	leases := g.client.CoordinationV1().Leases(g.namespace)
+	now := metav1.NewMicroTime(g.now())
+	seconds := int32(g.duration / time.Second)
+
+	lease, err := leases.Get(ctx, g.name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = leases.Create(ctx, &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{Name: g.name, Namespace: g.namespace},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &g.identity,
+				LeaseDurationSeconds: &seconds,
+				AcquireTime:          &now,
+				RenewTime:            &now,
+			},
+		}, metav1.CreateOptions{})
+		if apierrors.IsAlreadyExists(err) {
+			return false, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("creating lease %s: %w", g.name, err)
+		}
+		g.logger.Info("acquired lease", "lease", g.name, "identity", g.identity)
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("getting lease %s: %w", g.name, err)
+	}
+
+	var holder string
+	if lease.Spec.HolderIdentity != nil {
+		holder = *lease.Spec.HolderIdentity
+	}
+	if holder != g.identity {
+		if holder != "" && !g.expired(lease) {
+			return false, nil
+		}
+		var transitions int32
+		if lease.Spec.LeaseTransitions != nil {
+			transitions = *lease.Spec.LeaseTransitions
+		}
+		if holder != "" {
+			transitions++
+		}
+		lease.Spec.AcquireTime = &now
+		lease.Spec.LeaseTransitions = &transitions
+	}
+	lease.Spec.HolderIdentity = &g.identity
+	lease.Spec.LeaseDurationSeconds = &seconds
+	lease.Spec.RenewTime = &now
+
+	// The update carries the resourceVersion that was read, so a concurrent
+	// acquisition by another instance makes it fail with a conflict.
+	_, err = leases.Update(ctx, lease, metav1.UpdateOptions{})
+	if apierrors.IsConflict(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("updating lease %s: %w", g.name, err)
+	}
+	if holder != g.identity {
+		g.logger.Info("acquired lease", "lease", g.name, "identity", g.identity, "previous_holder", holder)
+	}
+	return true, nil
+}
+
+// expired reports whether lease was last renewed more than its duration ago.
+func (g *LeaseGuard) expired(lease *coordinationv1.Lease) bool {
+	if lease.Spec.RenewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+		return true
+	}
+	expiry := lease.Spec.RenewTime.Add(time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second)
+	return g.now().After(expiry)
+}
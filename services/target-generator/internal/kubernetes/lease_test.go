@@ -0,0 +1,88 @@
+package kubernetes
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const testLease = "helios-target-generator"
+
+func TestLeaseGuard_AcquireAndSkip(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	ctx := context.Background()
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	first := NewLeaseGuard(client, testNamespace, testLease, "pod-a", time.Minute, testLogger())
+	first.now = clock
+	second := NewLeaseGuard(client, testNamespace, testLease, "pod-b", time.Minute, testLogger())
+	second.now = clock
+
+	if ok, err := first.Acquire(ctx); err != nil || !ok {
+		t.Fatalf("first Acquire() = %v, %v, want true", ok, err)
+	}
+	if ok, err := second.Acquire(ctx); err != nil || ok {
+		t.Fatalf("second Acquire() while held = %v, %v, want false", ok, err)
+	}
+
+	// The holder renews its own lease, pushing the expiry back.
+	now = now.Add(45 * time.Second)
+	if ok, err := first.Acquire(ctx); err != nil || !ok {
+		t.Fatalf("renewing Acquire() = %v, %v, want true", ok, err)
+	}
+	now = now.Add(45 * time.Second)
+	if ok, err := second.Acquire(ctx); err != nil || ok {
+		t.Fatalf("second Acquire() after renewal = %v, %v, want false", ok, err)
+	}
+
+	lease, err := client.CoordinationV1().Leases(testNamespace).Get(ctx, testLease, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("getting lease: %v", err)
+	}
+	if got := *lease.Spec.HolderIdentity; got != "pod-a" {
+		t.Errorf("holder = %q, want pod-a", got)
+	}
+	if got := *lease.Spec.LeaseDurationSeconds; got != 60 {
+		t.Errorf("lease duration = %ds, want 60s", got)
+	}
+}
+
+func TestLeaseGuard_TakesOverExpiredLease(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	ctx := context.Background()
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	first := NewLeaseGuard(client, testNamespace, testLease, "pod-a", time.Minute, testLogger())
+	first.now = clock
+	second := NewLeaseGuard(client, testNamespace, testLease, "pod-b", time.Minute, testLogger())
+	second.now = clock
+
+	if ok, err := first.Acquire(ctx); err != nil || !ok {
+		t.Fatalf("first Acquire() = %v, %v, want true", ok, err)
+	}
+
+	// pod-a stopped renewing; once the lease expires pod-b takes it over.
+	now = now.Add(2 * time.Minute)
+	if ok, err := second.Acquire(ctx); err != nil || !ok {
+		t.Fatalf("second Acquire() after expiry = %v, %v, want true", ok, err)
+	}
+	if ok, err := first.Acquire(ctx); err != nil || ok {
+		t.Fatalf("first Acquire() after takeover = %v, %v, want false", ok, err)
+	}
+
+	lease, err := client.CoordinationV1().Leases(testNamespace).Get(ctx, testLease, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("getting lease: %v", err)
+	}
+	if got := *lease.Spec.HolderIdentity; got != "pod-b" {
+		t.Errorf("holder = %q, want pod-b", got)
+	}
+	if got := *lease.Spec.LeaseTransitions; got != 1 {
+		t.Errorf("lease transitions = %d, want 1", got)
+	}
+}
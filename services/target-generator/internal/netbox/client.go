@@ -8,7 +8,8 @@ import (
 	"log/slog"
 	"net/http"
 	"net/url"
-	"time"
+	"strings"
+	"sync"
 )
 
 // Device represents a NetBox device with Helios-specific custom fields.
@@ -35,26 +36,102 @@ type DeviceCustomFields struct {
 	SNMPEnabled    bool     `json:"snmp_enabled"`
 	SNMPModule     string   `json:"snmp_module"`
 	BlackboxProbes []string `json:"blackbox_probes"`
+	// BlackboxTCPPort overrides the port probed by tcp_connect (default 22).
+	BlackboxTCPPort int `json:"blackbox_tcp_port"`
+	// BlackboxHTTPURL overrides the URL probed by http_2xx
+	// (default https://<primary IP>).
+	BlackboxHTTPURL string `json:"blackbox_http_url"`
 }
 
 // Client queries NetBox for device inventory with Helios monitoring enabled.
 type Client struct {
-	baseURL    string
-	apiToken   string
-	httpClient *http.Client
-	logger     *slog.Logger
+	baseURL      string
+	apiToken     string
+	httpClient   *http.Client
+	deviceFilter string
+	deviceStatus string
+	sites        []string
+	regions      []string
+	tags         []string
+	retry        retryPolicy
+	logger       *slog.Logger
+}
+
+// DefaultDeviceFilter is the query fragment selecting devices Helios monitors.
+const DefaultDeviceFilter = "cf_helios_monitor=true"
+
+// DefaultDeviceStatus is the NetBox status of devices that get targets.
+const DefaultDeviceStatus = "active"
+
+// ClientOption configures optional Client behaviour.
+type ClientOption func(*Client)
+
+// WithDeviceFilter replaces the query fragment used to select monitored
+// devices, e.g. "tag=helios". An empty filter selects every device.
+func WithDeviceFilter(filter string) ClientOption {
+	return func(c *Client) {
+		c.deviceFilter = filter
+	}
+}
+
+// WithDeviceStatus sets the required device status. An empty status
+// disables status filtering.
+func WithDeviceStatus(status string) ClientOption {
+	return func(c *Client) {
+		c.deviceStatus = status
+	}
+}
+
+// WithSites limits devices to those in any of the given NetBox site slugs.
+func WithSites(sites ...string) ClientOption {
+	return func(c *Client) {
+		c.sites = sites
+	}
+}
+
+// WithRegions limits devices to those in any of the given NetBox region
+// slugs, including their child regions.
+func WithRegions(regions ...string) ClientOption {
+	return func(c *Client) {
+		c.regions = regions
+	}
+}
+
+// WithTags limits devices to those carrying all of the given NetBox tag
+// slugs.
+func WithTags(tags ...string) ClientOption {
+	return func(c *Client) {
+		c.tags = tags
+	}
+}
+
+// WithHTTPClient sets the client used for NetBox API requests, typically one
+// built by NewHTTPClient. A nil client is ignored.
+func WithHTTPClient(client *http.Client) ClientOption {
+	return func(c *Client) {
+		if client != nil {
+			c.httpClient = client
+		}
+	}
 }
 
 // NewClient creates a NetBox API client.
-func NewClient(baseURL, apiToken string, logger *slog.Logger) *Client {
-	return &Client{
+func NewClient(baseURL, apiToken string, logger *slog.Logger, opts ...ClientOption) *Client {
+	c := &Client{
 		baseURL:  baseURL,
 		apiToken: apiToken,
 		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
+			Timeout: DefaultTimeout,
 		},
-		logger: logger,
+		deviceFilter: DefaultDeviceFilter,
+		deviceStatus: DefaultDeviceStatus,
+		retry:        defaultRetryPolicy,
+		logger:       logger,
+	}
+	for _, opt := range opts {
+		opt(c)
 	}
+	return c
 }
 
 // paginatedResponse represents NetBox paginated API response.
@@ -65,26 +142,82 @@ type paginatedResponse struct {
 	Results  []json.RawMessage `json:"results"`
 }
 
-// ListMonitoredDevices returns all devices with helios_monitor=true custom field.
+// ListMonitoredDevices returns all devices and virtual machines matching the
+// configured filter and status, by default those with the helios_monitor
+// custom field. Virtual machines are returned as Devices; fields NetBox does
+// not have for them, such as the manufacturer, are left empty.
 func (c *Client) ListMonitoredDevices(ctx context.Context) ([]Device, error) {
-	var allDevices []Device
-	nextURL := fmt.Sprintf("%s/api/dcim/devices/?cf_helios_monitor=true&status=active&limit=100", c.baseURL)
+	var (
+		wg            sync.WaitGroup
+		devices, vms  []Device
+		devErr, vmErr error
+	)
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		devices, devErr = c.listAll(ctx, "/api/dcim/devices/")
+	}()
+	go func() {
+		defer wg.Done()
+		vms, vmErr = c.listAll(ctx, "/api/virtualization/virtual-machines/")
+	}()
+	wg.Wait()
+
+	// A partial inventory would prune the missing targets, so either failure
+	// fails the whole listing.
+	if devErr != nil {
+		return nil, fmt.Errorf("listing devices: %w", devErr)
+	}
+	if vmErr != nil {
+		return nil, fmt.Errorf("listing virtual machines: %w", vmErr)
+	}
+
+	c.logger.Info("fetched monitored devices from NetBox", "devices", len(devices), "virtual_machines", len(vms))
+	return append(devices, vms...), nil
+}
+
+// listAll follows pagination of a NetBox list endpoint filtered by the
+// device query.
+func (c *Client) listAll(ctx context.Context, path string) ([]Device, error) {
+	var all []Device
+	nextURL := fmt.Sprintf("%s%s?%s", c.baseURL, path, c.deviceQuery())
 
 	for nextURL != "" {
-		devices, next, err := c.fetchPage(ctx, nextURL)
+		page, next, err := c.fetchPage(ctx, nextURL)
 		if err != nil {
-			return nil, fmt.Errorf("fetching devices page: %w", err)
+			return nil, fmt.Errorf("fetching page: %w", err)
 		}
-		allDevices = append(allDevices, devices...)
+		all = append(all, page...)
 		if next != nil {
 			nextURL = *next
 		} else {
 			nextURL = ""
 		}
 	}
+	return all, nil
+}
 
-	c.logger.Info("fetched monitored devices from NetBox", "count", len(allDevices))
-	return allDevices, nil
+// deviceQuery returns the device list query string for the configured
+// filter, status, sites, regions and tags.
+func (c *Client) deviceQuery() string {
+	var parts []string
+	if c.deviceFilter != "" {
+		parts = append(parts, strings.TrimPrefix(c.deviceFilter, "&"))
+	}
+	if c.deviceStatus != "" {
+		parts = append(parts, "status="+url.QueryEscape(c.deviceStatus))
+	}
+	for _, f := range []struct {
+		param  string
+		values []string
+	}{{"site", c.sites}, {"region", c.regions}, {"tag", c.tags}} {
+		for _, v := range f.values {
+			if v != "" {
+				parts = append(parts, f.param+"="+url.QueryEscape(v))
+			}
+		}
+	}
+	return strings.Join(append(parts, "limit=100"), "&")
 }
 
 func (c *Client) fetchPage(ctx context.Context, rawURL string) ([]Device, *string, error) {
@@ -93,16 +226,17 @@ func (c *Client) fetchPage(ctx context.Context, rawURL string) ([]Device, *strin
 		return nil, nil, fmt.Errorf("parsing URL: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, parsedURL.String(), nil)
-	if err != nil {
-		return nil, nil, fmt.Errorf("creating request: %w", err)
-	}
-	req.Header.Set("Authorization", fmt.Sprintf("Token %s", c.apiToken))
-	req.Header.Set("Accept", "application/json")
-
-	resp, err := c.httpClient.Do(req)
+	resp, err := doWithRetry(ctx, c.httpClient, c.retry, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, parsedURL.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", fmt.Sprintf("Token %s", c.apiToken))
+		req.Header.Set("Accept", "application/json")
+		return req, nil
+	})
 	if err != nil {
-		return nil, nil, fmt.Errorf("executing request: %w", err)
+		return nil, nil, err
 	}
 	defer resp.Body.Close()
 
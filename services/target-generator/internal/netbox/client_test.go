@@ -2,18 +2,35 @@ package netbox
 
 import (
 	"context"
+	"crypto/x509"
 	"encoding/json"
+	"encoding/pem"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func testLogger() *slog.Logger {
 	return slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
 }
 
+// devicesOnly serves h for the device list and an empty page for virtual
+// machines, so device-focused tests are unaffected by the VM fetch.
+func devicesOnly(h http.Handler) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/api/dcim/devices/", h)
+	mux.HandleFunc("/api/virtualization/virtual-machines/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"count": 0, "next": nil, "results": []interface{}{}})
+	})
+	return mux
+}
+
 func TestClient_ListMonitoredDevices(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -94,10 +111,11 @@ func TestClient_ListMonitoredDevices(t *testing.T) {
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			server := httptest.NewServer(tc.handler)
+			server := httptest.NewServer(devicesOnly(tc.handler))
 			defer server.Close()
 
 			client := NewClient(server.URL, "test-token", testLogger())
+			client.retry = testRetryPolicy
 			devices, err := client.ListMonitoredDevices(context.Background())
 
 			if tc.wantErr {
@@ -130,7 +148,7 @@ func TestClient_ListMonitoredDevices(t *testing.T) {
 func TestClient_Pagination(t *testing.T) {
 	callCount := 0
 
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	server := httptest.NewServer(devicesOnly(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		callCount++
 		var resp map[string]interface{}
 
@@ -155,7 +173,7 @@ func TestClient_Pagination(t *testing.T) {
 
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(resp)
-	}))
+	})))
 	defer server.Close()
 
 	client := NewClient(server.URL, "test-token", testLogger())
@@ -174,12 +192,12 @@ func TestClient_Pagination(t *testing.T) {
 func TestClient_AuthorizationHeader(t *testing.T) {
 	var receivedAuth string
 
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	server := httptest.NewServer(devicesOnly(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		receivedAuth = r.Header.Get("Authorization")
 		resp := map[string]interface{}{"count": 0, "next": nil, "results": []interface{}{}}
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(resp)
-	}))
+	})))
 	defer server.Close()
 
 	client := NewClient(server.URL, "my-secret-token", testLogger())
@@ -206,3 +224,313 @@ func TestClient_ContextCancellation(t *testing.T) {
 		t.Fatal("expected error for cancelled context")
 	}
 }
+
+// testRetryPolicy keeps retry behaviour but shrinks the delays.
+var testRetryPolicy = retryPolicy{maxAttempts: 3, baseDelay: time.Millisecond, maxDelay: 5 * time.Millisecond}
+
+func TestClient_RetriesTransientErrors(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(devicesOnly(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= 2 {
+			http.Error(w, "service unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"count":   1,
+			"next":    nil,
+			"results": []map[string]interface{}{{"id": 1, "name": "router-1"}},
+		})
+	})))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-token", testLogger())
+	client.retry = testRetryPolicy
+	devices, err := client.ListMonitoredDevices(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(devices) != 1 {
+		t.Errorf("got %d devices, want 1", len(devices))
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("server saw %d requests, want 3", got)
+	}
+}
+
+func TestClient_RetriesExhausted(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(devicesOnly(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Retry-After", "0")
+		http.Error(w, "slow down", http.StatusTooManyRequests)
+	})))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-token", testLogger())
+	client.retry = testRetryPolicy
+	if _, err := client.ListMonitoredDevices(context.Background()); err == nil {
+		t.Fatal("expected error after retries are exhausted")
+	}
+	if got := calls.Load(); got != int32(testRetryPolicy.maxAttempts) {
+		t.Errorf("server saw %d requests, want %d", got, testRetryPolicy.maxAttempts)
+	}
+}
+
+func TestClient_ClientErrorNotRetried(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(devicesOnly(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		http.Error(w, "not found", http.StatusNotFound)
+	})))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-token", testLogger())
+	client.retry = testRetryPolicy
+	if _, err := client.ListMonitoredDevices(context.Background()); err == nil {
+		t.Fatal("expected error for 404 response")
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("server saw %d requests, want 1", got)
+	}
+}
+
+func TestClient_CustomDeviceFilter(t *testing.T) {
+	var receivedQuery string
+	server := httptest.NewServer(devicesOnly(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedQuery = r.URL.RawQuery
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"count": 0, "next": nil, "results": []interface{}{}})
+	})))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-token", testLogger(), WithDeviceFilter("tag=helios"), WithDeviceStatus(""))
+	if _, err := client.ListMonitoredDevices(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if receivedQuery != "tag=helios&limit=100" {
+		t.Errorf("query = %q, want %q", receivedQuery, "tag=helios&limit=100")
+	}
+}
+
+func TestClient_SiteRegionTagFilters(t *testing.T) {
+	var deviceQuery, vmQuery string
+	mux := http.NewServeMux()
+	empty := func(w http.ResponseWriter) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"count": 0, "next": nil, "results": []interface{}{}})
+	}
+	mux.HandleFunc("/api/dcim/devices/", func(w http.ResponseWriter, r *http.Request) {
+		deviceQuery = r.URL.RawQuery
+		empty(w)
+	})
+	mux.HandleFunc("/api/virtualization/virtual-machines/", func(w http.ResponseWriter, r *http.Request) {
+		vmQuery = r.URL.RawQuery
+		empty(w)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-token", testLogger(),
+		WithSites("dc1", "dc2"),
+		WithRegions("us-east"),
+		WithTags("core", ""))
+	if _, err := client.ListMonitoredDevices(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := "cf_helios_monitor=true&status=active&site=dc1&site=dc2&region=us-east&tag=core&limit=100"
+	if deviceQuery != want {
+		t.Errorf("device query = %q, want %q", deviceQuery, want)
+	}
+	if vmQuery != want {
+		t.Errorf("VM query = %q, want %q", vmQuery, want)
+	}
+}
+
+func TestClient_DefaultDeviceFilter(t *testing.T) {
+	client := NewClient("http://netbox", "test-token", testLogger())
+	if got, want := client.deviceQuery(), "cf_helios_monitor=true&status=active&limit=100"; got != want {
+		t.Errorf("deviceQuery() = %q, want %q", got, want)
+	}
+}
+
+func TestClient_IncludesVirtualMachines(t *testing.T) {
+	var vmQuery string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/dcim/devices/", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"count": 1,
+			"next":  nil,
+			"results": []map[string]interface{}{
+				{"id": 1, "name": "router-1", "primary_ip_address": "10.0.0.1", "manufacturer": "arista"},
+			},
+		})
+	})
+	mux.HandleFunc("/api/virtualization/virtual-machines/", func(w http.ResponseWriter, r *http.Request) {
+		vmQuery = r.URL.RawQuery
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"count": 1,
+			"next":  nil,
+			"results": []map[string]interface{}{
+				{"id": 1, "name": "vrouter-1", "primary_ip_address": "10.1.0.1", "site": "dc1",
+					"custom_fields": map[string]interface{}{"gnmi_enabled": true, "blackbox_probes": []string{"icmp"}}},
+			},
+		})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-token", testLogger())
+	devices, err := client.ListMonitoredDevices(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(devices) != 2 {
+		t.Fatalf("expected device and VM, got %d entries", len(devices))
+	}
+	vm := devices[1]
+	if vm.Name != "vrouter-1" || vm.PrimaryIP != "10.1.0.1" || !vm.CustomFields.GNMIEnabled {
+		t.Errorf("unexpected VM %+v", vm)
+	}
+	if vm.Manufacturer != "" {
+		t.Errorf("VM manufacturer = %q, want empty", vm.Manufacturer)
+	}
+	if vmQuery != "cf_helios_monitor=true&status=active&limit=100" {
+		t.Errorf("VM query = %q, want the device filter", vmQuery)
+	}
+}
+
+func TestClient_VirtualMachineErrorFailsListing(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/dcim/devices/", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"count": 0, "next": nil, "results": []interface{}{}})
+	})
+	mux.HandleFunc("/api/virtualization/virtual-machines/", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-token", testLogger())
+	if _, err := client.ListMonitoredDevices(context.Background()); err == nil {
+		t.Fatal("expected error when virtual machines cannot be listed")
+	}
+}
+
+func TestNewHTTPClient(t *testing.T) {
+	client, err := NewHTTPClient(HTTPConfig{Timeout: 5 * time.Second, MaxIdleConnsPerHost: 3})
+	if err != nil {
+		t.Fatalf("NewHTTPClient() error = %v", err)
+	}
+	if client.Timeout != 5*time.Second {
+		t.Errorf("Timeout = %v, want 5s", client.Timeout)
+	}
+	if got := client.Transport.(*http.Transport).MaxIdleConnsPerHost; got != 3 {
+		t.Errorf("MaxIdleConnsPerHost = %d, want 3", got)
+	}
+
+	client, err = NewHTTPClient(HTTPConfig{})
+	if err != nil {
+		t.Fatalf("NewHTTPClient() error = %v", err)
+	}
+	if client.Timeout != DefaultTimeout {
+		t.Errorf("default Timeout = %v, want %v", client.Timeout, DefaultTimeout)
+	}
+}
+
+func TestClient_HTTPClientTimeoutApplied(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	httpClient, err := NewHTTPClient(HTTPConfig{Timeout: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("NewHTTPClient() error = %v", err)
+	}
+	client := NewClient(server.URL, "test-token", testLogger(), WithHTTPClient(httpClient))
+	client.retry = testRetryPolicy
+
+	if client.httpClient != httpClient {
+		t.Fatal("client does not use the configured HTTP client")
+	}
+	start := time.Now()
+	if _, err := client.ListMonitoredDevices(context.Background()); err == nil {
+		t.Fatal("expected a timeout error from a stalled NetBox")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("ListMonitoredDevices took %v, want it bounded by the client timeout", elapsed)
+	}
+}
+
+func TestNewHTTPClient_CAFile(t *testing.T) {
+	server := httptest.NewTLSServer(devicesOnly(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"count": 0, "next": null, "results": []}`))
+	})))
+	defer server.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caFile, certPEM, 0o600); err != nil {
+		t.Fatalf("writing CA file: %v", err)
+	}
+
+	httpClient, err := NewHTTPClient(HTTPConfig{CAFile: caFile})
+	if err != nil {
+		t.Fatalf("NewHTTPClient() error = %v", err)
+	}
+	client := NewClient(server.URL, "test-token", testLogger(), WithHTTPClient(httpClient))
+	if _, err := client.ListMonitoredDevices(context.Background()); err != nil {
+		t.Fatalf("ListMonitoredDevices() with the NetBox CA trusted: %v", err)
+	}
+
+	if err := os.WriteFile(caFile, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatalf("writing CA file: %v", err)
+	}
+	if _, err := NewHTTPClient(HTTPConfig{CAFile: caFile}); err == nil {
+		t.Error("expected error for a CA file without certificates")
+	}
+}
+
+func TestClient_CustomRootCAs(t *testing.T) {
+	server := httptest.NewTLSServer(devicesOnly(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"count": 0, "next": null, "results": []}`))
+	})))
+	defer server.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+
+	tests := []struct {
+		name    string
+		cfg     HTTPConfig
+		wantErr bool
+	}{
+		{name: "system roots reject the NetBox CA", cfg: HTTPConfig{}, wantErr: true},
+		{name: "custom root pool", cfg: HTTPConfig{RootCAs: pool}},
+		{name: "insecure skip verify", cfg: HTTPConfig{InsecureSkipVerify: true}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			httpClient, err := NewHTTPClient(tc.cfg)
+			if err != nil {
+				t.Fatalf("NewHTTPClient() error = %v", err)
+			}
+			client := NewClient(server.URL, "test-token", testLogger(), WithHTTPClient(httpClient))
+			client.retry = testRetryPolicy
+
+			_, err = client.ListMonitoredDevices(context.Background())
+			if tc.wantErr != (err != nil) {
+				t.Errorf("ListMonitoredDevices() error = %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+}
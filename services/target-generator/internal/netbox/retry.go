@@ -0,0 +1,95 @@
+package netbox
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// retryPolicy controls retries of NetBox page fetches.
+type retryPolicy struct {
+	maxAttempts int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+}
+
+// defaultRetryPolicy rides out short NetBox outages without letting a single
+// page hold up the sync for long.
+var defaultRetryPolicy = retryPolicy{
+	maxAttempts: 4,
+	baseDelay:   500 * time.Millisecond,
+	maxDelay:    10 * time.Second,
+}
+
+// doWithRetry sends the request built by newReq until it gets a response
+// that is neither a 429 nor a 5xx, or attempts run out. Network errors are
+// retried too. Delays double from baseDelay with jitter, and a Retry-After
+// header takes precedence; both are capped at maxDelay. Other 4xx responses
+// are returned immediately for the caller to handle.
+func doWithRetry(ctx context.Context, client *http.Client, policy retryPolicy, newReq func() (*http.Request, error)) (*http.Response, error) {
+	attempts := max(policy.maxAttempts, 1)
+	delay := policy.baseDelay
+
+	for attempt := 1; ; attempt++ {
+		req, err := newReq()
+		if err != nil {
+			return nil, fmt.Errorf("creating request: %w", err)
+		}
+
+		resp, err := client.Do(req)
+		if err == nil && !retryableStatus(resp.StatusCode) {
+			return resp, nil
+		}
+		if attempt >= attempts || ctx.Err() != nil {
+			if err != nil {
+				return nil, fmt.Errorf("executing request: %w", err)
+			}
+			return resp, nil
+		}
+
+		wait := jitter(min(delay, policy.maxDelay))
+		if err == nil {
+			if after, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+				wait = min(after, policy.maxDelay)
+			}
+			resp.Body.Close()
+		}
+		delay *= 2
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+func retryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+}
+
+// retryAfter parses a Retry-After value in either delay-seconds or HTTP-date
+// form.
+func retryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0), true
+	}
+	return 0, false
+}
+
+// jitter spreads retries from many clients by picking a delay in [d/2, d].
+func jitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + rand.N(d/2+1)
+}